@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/serverkit/agent/internal/config"
+	"github.com/serverkit/agent/internal/docker"
+	"github.com/serverkit/agent/internal/logger"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/spf13/cobra"
+)
+
+// checkStatus is the outcome of a single doctor check
+type checkStatus int
+
+const (
+	checkPass checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+func (s checkStatus) marker() string {
+	switch s {
+	case checkPass:
+		return "[PASS]"
+	case checkWarn:
+		return "[WARN]"
+	default:
+		return "[FAIL]"
+	}
+}
+
+// doctorReport collects and prints check results
+type doctorReport struct {
+	failures int
+	warnings int
+}
+
+func (r *doctorReport) add(status checkStatus, name, detail, hint string) {
+	switch status {
+	case checkFail:
+		r.failures++
+	case checkWarn:
+		r.warnings++
+	}
+
+	fmt.Printf("%s %s", status.marker(), name)
+	if detail != "" {
+		fmt.Printf(": %s", detail)
+	}
+	fmt.Println()
+	if hint != "" && status != checkPass {
+		fmt.Printf("       -> %s\n", hint)
+	}
+}
+
+const (
+	// maxClockSkew is the largest clock difference tolerated before warning
+	maxClockSkew = 30 * time.Second
+	// minLogFreeSpace is the free space below which the log disk check warns
+	minLogFreeSpace = 100 * 1024 * 1024
+)
+
+func doctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose configuration, connectivity and dependency problems",
+		Long: `Run a checklist of common problems: configuration, credentials,
+Docker access, control plane reachability, clock skew, log disk space
+and service installation.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor()
+		},
+	}
+}
+
+func runDoctor() error {
+	report := &doctorReport{}
+
+	configPath := cfgFile
+	if configPath == "" {
+		configPath = config.DefaultConfigPath()
+	}
+
+	fmt.Printf("ServerKit Agent doctor (version %s)\n\n", Version)
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		report.add(checkFail, "Configuration", err.Error(),
+			"Run 'serverkit-agent register' to create the configuration")
+		// Keep going with defaults so Docker, disk and service checks still run
+		cfg = config.Default()
+	} else {
+		applyFlags(cfg)
+		report.add(checkPass, "Configuration", configPath, "")
+		checkCredentials(report, cfg)
+	}
+
+	checkDocker(report, cfg)
+	serverTime := checkControlPlane(report, cfg)
+	checkClockSkew(report, serverTime)
+	checkLogDisk(report, cfg)
+	checkService(report)
+
+	fmt.Printf("\n%d failure(s), %d warning(s)\n", report.failures, report.warnings)
+	if report.failures > 0 {
+		return fmt.Errorf("doctor found %d problem(s)", report.failures)
+	}
+	return nil
+}
+
+func checkCredentials(report *doctorReport, cfg *config.Config) {
+	if cfg.Agent.ID == "" {
+		report.add(checkFail, "Registration", "agent ID is not set",
+			"Run 'serverkit-agent register --token <token> --server <url>'")
+		return
+	}
+	report.add(checkPass, "Registration", cfg.Agent.ID, "")
+
+	if err := cfg.LoadCredentials(); err != nil {
+		hint := "Re-register the agent to issue new credentials"
+		if cfg.Security.CredentialStore != config.CredentialStoreKeyring {
+			hint = "The machine key changes with the hostname or /etc/machine-id; re-register or use security.credential_store: keyring"
+		}
+		report.add(checkFail, "Credentials", err.Error(), hint)
+		return
+	}
+	report.add(checkPass, "Credentials", "decrypted successfully", "")
+}
+
+func checkDocker(report *doctorReport, cfg *config.Config) {
+	if !cfg.Features.Docker {
+		report.add(checkPass, "Docker", "disabled in configuration", "")
+		return
+	}
+
+	log := logger.New(config.LoggingConfig{Level: "error"})
+	client, err := docker.NewClient(cfg.Docker, log)
+	if err != nil {
+		report.add(checkFail, "Docker", err.Error(), "Check docker.socket in the configuration")
+		return
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx); err != nil {
+		report.add(checkFail, "Docker", err.Error(),
+			fmt.Sprintf("Make sure Docker is running and %s is accessible to the agent user", cfg.Docker.Socket))
+		return
+	}
+
+	version, _ := client.Version(ctx)
+	report.add(checkPass, "Docker", "version "+version, "")
+}
+
+// checkControlPlane resolves and connects to the control plane, returning
+// the server's clock reading when it could be obtained
+func checkControlPlane(report *doctorReport, cfg *config.Config) time.Time {
+	if cfg.Server.URL == "" {
+		report.add(checkFail, "Control plane", "server URL is not set",
+			"Run 'serverkit-agent register' to configure the server")
+		return time.Time{}
+	}
+
+	u, err := url.Parse(cfg.Server.URL)
+	if err != nil || u.Hostname() == "" {
+		report.add(checkFail, "Control plane", fmt.Sprintf("invalid server URL %q", cfg.Server.URL),
+			"server.url should look like wss://serverkit.example.com/agent/ws")
+		return time.Time{}
+	}
+
+	host := u.Hostname()
+	addrs, err := net.LookupHost(host)
+	if err != nil {
+		report.add(checkFail, "DNS", err.Error(), "Check the server hostname and this host's DNS resolver")
+		return time.Time{}
+	}
+	report.add(checkPass, "DNS", fmt.Sprintf("%s -> %v", host, addrs), "")
+
+	tlsCfg, err := cfg.Server.TLSConfig()
+	if err != nil {
+		report.add(checkFail, "TLS configuration", err.Error(), "Check server.ca_cert_file and server.pinned_sha256")
+		return time.Time{}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = cfg.Server.ProxyFunc()
+	transport.TLSClientConfig = tlsCfg
+	client := &http.Client{Timeout: 10 * time.Second, Transport: transport}
+
+	// Any HTTP response proves the endpoint is reachable through TLS and proxies
+	resp, err := client.Get(cfg.Server.HTTPBaseURL())
+	if err != nil {
+		hint := "Check firewall rules for outbound connections and any required proxy (server.proxy)"
+		var verifyErr *tls.CertificateVerificationError
+		var authorityErr x509.UnknownAuthorityError
+		if errors.As(err, &verifyErr) || errors.As(err, &authorityErr) {
+			hint = "The server certificate is not trusted; set server.ca_cert_file or server.pinned_sha256"
+		}
+		report.add(checkFail, "Control plane", err.Error(), hint)
+		return time.Time{}
+	}
+	resp.Body.Close()
+	report.add(checkPass, "Control plane", fmt.Sprintf("%s reachable (HTTP %d)", cfg.Server.HTTPBaseURL(), resp.StatusCode), "")
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}
+	}
+	return serverTime
+}
+
+func checkClockSkew(report *doctorReport, serverTime time.Time) {
+	if serverTime.IsZero() {
+		report.add(checkWarn, "Clock skew", "could not read server time", "Skipped because the control plane was not reachable")
+		return
+	}
+
+	skew := time.Since(serverTime)
+	if skew < 0 {
+		skew = -skew
+	}
+	// The Date header has one-second resolution
+	if skew > maxClockSkew {
+		report.add(checkFail, "Clock skew", fmt.Sprintf("local clock differs from server by %s", skew.Round(time.Second)),
+			"Enable time synchronization (e.g. systemd-timesyncd, chrony or w32time); authentication uses timestamps. server.clock_skew_correction works around it meanwhile")
+		return
+	}
+	report.add(checkPass, "Clock skew", skew.Round(time.Second).String(), "")
+}
+
+func checkLogDisk(report *doctorReport, cfg *config.Config) {
+	if cfg.Logging.File == "" {
+		report.add(checkPass, "Log disk space", "file logging disabled", "")
+		return
+	}
+
+	// Walk up to the nearest existing directory
+	dir := filepath.Dir(cfg.Logging.File)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	usage, err := disk.Usage(dir)
+	if err != nil {
+		report.add(checkWarn, "Log disk space", err.Error(), "")
+		return
+	}
+
+	detail := fmt.Sprintf("%d MB free on %s", usage.Free/1024/1024, dir)
+	if usage.Free < minLogFreeSpace {
+		report.add(checkWarn, "Log disk space", detail, "Free up space or lower logging.max_backups / logging.max_size_mb")
+		return
+	}
+	report.add(checkPass, "Log disk space", detail, "")
+}
+
+func checkService(report *doctorReport) {
+	switch runtime.GOOS {
+	case "windows":
+		if err := exec.Command("sc", "query", "ServerKitAgent").Run(); err != nil {
+			report.add(checkWarn, "Service", "ServerKitAgent service is not installed",
+				"Install the service with serverkit-agent service install")
+			return
+		}
+		report.add(checkPass, "Service", "ServerKitAgent installed", "")
+	case "linux":
+		for _, path := range []string{
+			"/etc/systemd/system/serverkit-agent.service",
+			"/lib/systemd/system/serverkit-agent.service",
+			"/usr/lib/systemd/system/serverkit-agent.service",
+		} {
+			if _, err := os.Stat(path); err == nil {
+				report.add(checkPass, "Service", path, "")
+				return
+			}
+		}
+		report.add(checkWarn, "Service", "serverkit-agent systemd unit not found",
+			"Install with serverkit-agent service install to run the agent at boot")
+	default:
+		report.add(checkWarn, "Service", "service check not supported on "+runtime.GOOS, "")
+	}
+}
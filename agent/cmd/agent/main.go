@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/serverkit/agent/internal/agent"
 	"github.com/serverkit/agent/internal/config"
 	"github.com/serverkit/agent/internal/logger"
+	"github.com/serverkit/agent/internal/pidfile"
+	"github.com/serverkit/agent/internal/runstate"
 	"github.com/serverkit/agent/internal/tray"
 	"github.com/serverkit/agent/internal/updater"
 	"github.com/spf13/cobra"
@@ -24,6 +31,13 @@ var (
 var (
 	cfgFile   string
 	debugMode bool
+
+	// Overrides for the most common settings; these win over SERVERKIT_*
+	// environment variables, which win over the config file
+	serverURLFlag    string
+	logLevelFlag     string
+	logFileFlag      string
+	dockerSocketFlag string
 )
 
 func main() {
@@ -37,15 +51,25 @@ enabling remote Docker management, monitoring, and more.`,
 	// Global flags
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file path")
 	rootCmd.PersistentFlags().BoolVarP(&debugMode, "debug", "d", false, "enable debug logging")
+	rootCmd.PersistentFlags().StringVar(&serverURLFlag, "server-url", "", "ServerKit WebSocket URL (overrides "+config.EnvServerURL+")")
+	rootCmd.PersistentFlags().StringVar(&logLevelFlag, "log-level", "", "log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFileFlag, "log-file", "", "log file path")
+	rootCmd.PersistentFlags().StringVar(&dockerSocketFlag, "docker-socket", "", "Docker daemon socket")
 
 	// Add commands
 	rootCmd.AddCommand(startCmd())
 	rootCmd.AddCommand(registerCmd())
+	rootCmd.AddCommand(unregisterCmd())
 	rootCmd.AddCommand(statusCmd())
+	rootCmd.AddCommand(doctorCmd())
+	rootCmd.AddCommand(metricsCmd())
+	rootCmd.AddCommand(pingCmd())
+	rootCmd.AddCommand(sessionsCmd())
 	rootCmd.AddCommand(versionCmd())
 	rootCmd.AddCommand(configCmd())
 	rootCmd.AddCommand(updateCmd())
 	rootCmd.AddCommand(trayCmd())
+	rootCmd.AddCommand(serviceCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -53,6 +77,33 @@ enabling remote Docker management, monitoring, and more.`,
 	}
 }
 
+// loadConfig loads the config file with environment and flag overrides
+// applied. Precedence is flags > environment > file > defaults.
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	applyFlags(cfg)
+	return cfg, nil
+}
+
+// applyFlags applies the global override flags that were set
+func applyFlags(cfg *config.Config) {
+	if serverURLFlag != "" {
+		cfg.Server.URL = serverURLFlag
+	}
+	if logLevelFlag != "" {
+		cfg.Logging.Level = logLevelFlag
+	}
+	if logFileFlag != "" {
+		cfg.Logging.File = logFileFlag
+	}
+	if dockerSocketFlag != "" {
+		cfg.Docker.Socket = dockerSocketFlag
+	}
+}
+
 func startCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "start",
@@ -67,20 +118,74 @@ func registerCmd() *cobra.Command {
 	var token string
 	var serverURL string
 	var name string
+	var proxy string
+	var caCertFile string
+	var pinnedSHA256 string
+	var authMode string
 
 	cmd := &cobra.Command{
 		Use:   "register",
 		Short: "Register this agent with a ServerKit instance",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runRegister(token, serverURL, name)
+			// Fall back to the environment for unattended registration
+			if token == "" {
+				token = os.Getenv(config.EnvAgentToken)
+			}
+			if serverURL == "" {
+				serverURL = config.ServerConfig{URL: os.Getenv(config.EnvServerURL)}.HTTPBaseURL()
+			}
+			if name == "" {
+				name = os.Getenv(config.EnvAgentName)
+			}
+			if token == "" {
+				return fmt.Errorf("a registration token is required: pass --token or set %s", config.EnvAgentToken)
+			}
+			if serverURL == "" {
+				return fmt.Errorf("a server URL is required: pass --server or set %s", config.EnvServerURL)
+			}
+			switch authMode {
+			case "", config.AuthModeHMAC, config.AuthModeEd25519:
+			default:
+				return fmt.Errorf("unknown auth mode %q: use hmac or ed25519", authMode)
+			}
+			return runRegister(token, serverURL, name, authMode, config.ServerConfig{
+				Proxy:        proxy,
+				CACertFile:   caCertFile,
+				PinnedSHA256: pinnedSHA256,
+			})
 		},
 	}
 
-	cmd.Flags().StringVarP(&token, "token", "t", "", "registration token (required)")
-	cmd.Flags().StringVarP(&serverURL, "server", "s", "", "ServerKit server URL (required)")
-	cmd.Flags().StringVarP(&name, "name", "n", "", "display name for this server")
-	cmd.MarkFlagRequired("token")
-	cmd.MarkFlagRequired("server")
+	cmd.Flags().StringVarP(&token, "token", "t", "", "registration token (required, or set "+config.EnvAgentToken+")")
+	cmd.Flags().StringVarP(&serverURL, "server", "s", "", "ServerKit server URL (required, or set "+config.EnvServerURL+")")
+	cmd.Flags().StringVarP(&name, "name", "n", "", "display name for this server (or set "+config.EnvAgentName+")")
+	cmd.Flags().StringVar(&proxy, "proxy", "", "proxy URL for outbound connections (defaults to HTTP(S)_PROXY/ALL_PROXY)")
+	cmd.Flags().StringVar(&caCertFile, "ca-cert", "", "PEM CA bundle to trust for the ServerKit server")
+	cmd.Flags().StringVar(&pinnedSHA256, "pin-sha256", "", "expected SHA-256 fingerprint of the server certificate")
+	cmd.Flags().StringVar(&authMode, "auth-mode", "", "message signing: hmac (default) or ed25519 with a key pair generated here")
+
+	return cmd
+}
+
+func unregisterCmd() *cobra.Command {
+	var keepConfig bool
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "unregister",
+		Short: "Unregister this agent from its ServerKit instance",
+		Long: `Remove this agent from the ServerKit server and delete its local
+configuration and credentials.
+
+Use --keep-config to keep the configuration file (server URL, features, etc.)
+so the agent can be registered again later.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUnregister(keepConfig, force)
+		},
+	}
+
+	cmd.Flags().BoolVar(&keepConfig, "keep-config", false, "keep the configuration file, only remove registration and credentials")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "unregister without prompting")
 
 	return cmd
 }
@@ -95,6 +200,38 @@ func statusCmd() *cobra.Command {
 	}
 }
 
+func sessionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "List open remote terminal sessions",
+		Long: `List the terminal sessions currently open on the running agent.
+
+Use 'sessions close <id>' to forcibly close a stale session.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listSessions()
+		},
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "close <id>",
+		Short: "Forcibly close a terminal session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := ipcClient()
+			if err != nil {
+				return err
+			}
+			if err := client.CloseSession(args[0]); err != nil {
+				return fmt.Errorf("failed to close session: %w", err)
+			}
+			fmt.Printf("Session %s closed\n", args[0])
+			return nil
+		},
+	})
+
+	return cmd
+}
+
 func versionCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
@@ -118,7 +255,7 @@ func configCmd() *cobra.Command {
 		Use:   "show",
 		Short: "Show current configuration",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := config.Load(cfgFile)
+			cfg, err := loadConfig()
 			if err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
@@ -127,6 +264,22 @@ func configCmd() *cobra.Command {
 		},
 	})
 
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Check the configuration for invalid values",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if err := cfg.Validate(); err != nil {
+				return fmt.Errorf("invalid configuration:\n%w", err)
+			}
+			fmt.Println("Configuration is valid")
+			return nil
+		},
+	})
+
 	cmd.AddCommand(&cobra.Command{
 		Use:   "path",
 		Short: "Show configuration file path",
@@ -135,12 +288,57 @@ func configCmd() *cobra.Command {
 		},
 	})
 
+	var force bool
+	initCmd := &cobra.Command{
+		Use:   "init [path]",
+		Short: "Write a commented configuration file with every default",
+		Long: `Write a configuration file listing every setting with its default value
+and a short description. The path defaults to --config, or the standard
+config location. An existing file is left alone unless --force is given.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := cfgFile
+			if path == "" {
+				path = config.DefaultConfigPath()
+			}
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return runConfigInit(path, force)
+		},
+	}
+	initCmd.Flags().BoolVarP(&force, "force", "f", false, "overwrite an existing file")
+	cmd.AddCommand(initCmd)
+
 	return cmd
 }
 
+func runConfigInit(path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+	}
+
+	data, err := config.Template()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	fmt.Printf("Wrote default configuration to %s\n", path)
+	fmt.Println("Run 'serverkit-agent register' to connect it to ServerKit.")
+	return nil
+}
+
 func updateCmd() *cobra.Command {
 	var forceUpdate bool
 	var checkOnly bool
+	var rollback bool
+	var channel string
 
 	cmd := &cobra.Command{
 		Use:   "update",
@@ -149,32 +347,52 @@ func updateCmd() *cobra.Command {
 
 By default, this command checks for updates and prompts before installing.
 Use --force to install without prompting.
-Use --check to only check for updates without installing.`,
+Use --check to only check for updates without installing.
+Use --rollback to restore the binary replaced by the last update.
+Use --channel to check a different release channel (stable, beta, nightly)
+for this run only.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runUpdate(forceUpdate, checkOnly)
+			if rollback {
+				return runRollback(forceUpdate)
+			}
+			return runUpdate(forceUpdate, checkOnly, channel)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&forceUpdate, "force", "f", false, "install update without prompting")
 	cmd.Flags().BoolVarP(&checkOnly, "check", "c", false, "only check for updates, don't install")
+	// -c means --check here, so the global --config is redeclared without
+	// its shorthand; cobra would otherwise panic on the clash
+	cmd.Flags().StringVar(&cfgFile, "config", "", "config file path")
+	cmd.Flags().BoolVar(&rollback, "rollback", false, "restore the previous version from the last update's backup")
+	cmd.Flags().StringVar(&channel, "channel", "", "release channel to check: stable, beta or nightly (default from config)")
+	cmd.MarkFlagsMutuallyExclusive("rollback", "check")
+	cmd.MarkFlagsMutuallyExclusive("rollback", "channel")
 
 	return cmd
 }
 
-func runUpdate(force, checkOnly bool) error {
+func runUpdate(force, checkOnly bool, channel string) error {
 	// Load configuration
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	if channel != "" {
+		if !config.ValidUpdateChannel(channel) {
+			return fmt.Errorf("invalid channel %q: use stable, beta or nightly", channel)
+		}
+		cfg.Update.Channel = channel
+	}
+
 	log := logger.New(config.LoggingConfig{Level: "info"})
 	u := updater.New(cfg, log, Version)
 
 	ctx := context.Background()
 
 	fmt.Printf("Current version: %s\n", Version)
-	fmt.Println("Checking for updates...")
+	fmt.Printf("Checking for updates (%s channel)...\n", u.Channel())
 
 	info, err := u.CheckForUpdate(ctx)
 	if err != nil {
@@ -208,7 +426,9 @@ func runUpdate(force, checkOnly bool) error {
 	}
 
 	fmt.Println("\nDownloading update...")
+	u.SetProgress(printProgress)
 	binaryPath, err := u.DownloadUpdate(ctx, info)
+	fmt.Println()
 	if err != nil {
 		return fmt.Errorf("failed to download update: %w", err)
 	}
@@ -225,9 +445,74 @@ func runUpdate(force, checkOnly bool) error {
 	return nil
 }
 
+// printProgress renders a download progress bar on the current line
+func printProgress(downloaded, total int64) {
+	const width = 30
+	const mb = 1024 * 1024
+
+	if total <= 0 {
+		fmt.Printf("\r  %.1f MB", float64(downloaded)/mb)
+		return
+	}
+
+	filled := int(downloaded * width / total)
+	if filled > width {
+		filled = width
+	}
+	fmt.Printf("\r  [%s%s] %3d%%  %.1f/%.1f MB",
+		strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+		downloaded*100/total,
+		float64(downloaded)/mb, float64(total)/mb,
+	)
+}
+
+func runRollback(force bool) error {
+	// Load configuration
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	log := logger.New(config.LoggingConfig{Level: "info"})
+	u := updater.New(cfg, log, Version)
+
+	fmt.Printf("Current version: %s\n", Version)
+
+	if !force {
+		fmt.Print("\nRoll back to the previous version? [y/N]: ")
+		var response string
+		fmt.Scanln(&response)
+		if response != "y" && response != "Y" {
+			fmt.Println("Rollback cancelled.")
+			return nil
+		}
+	}
+
+	fmt.Println("Rolling back...")
+	if err := u.Rollback(); err != nil {
+		return fmt.Errorf("failed to roll back: %w", err)
+	}
+
+	fmt.Println("\nRollback complete!")
+	fmt.Println("The agent will restart with the previous version.")
+
+	return nil
+}
+
 func runAgent() error {
+	// Under the Windows service manager, Stop and Shutdown requests end the
+	// agent by cancelling its context
+	if handled, err := runAsService(serveAgent); handled {
+		return err
+	}
+	return serveAgent(context.Background())
+}
+
+// serveAgent runs the agent until parent is cancelled or a shutdown signal
+// arrives
+func serveAgent(parent context.Context) error {
 	// Load configuration
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -235,6 +520,12 @@ func runAgent() error {
 	// Override debug mode if flag is set
 	if debugMode {
 		cfg.Logging.Level = "debug"
+		cfg.Logging.ConsoleLevel = "debug"
+	}
+
+	// Fail fast rather than misbehave later on a bad value
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
 	}
 
 	// Initialize logger
@@ -249,8 +540,34 @@ func runAgent() error {
 		return fmt.Errorf("agent not registered. Run 'serverkit-agent register' first")
 	}
 
+	// One agent per config; a PID file we can't create only loses the check
+	if cfg.Agent.PIDFile != "" {
+		pid, err := pidfile.Acquire(cfg.Agent.PIDFile)
+		var running *pidfile.AlreadyRunningError
+		switch {
+		case errors.As(err, &running):
+			return err
+		case err != nil:
+			log.Warn("Could not create PID file, not guarding against a second instance", "error", err)
+		default:
+			defer pid.Release()
+		}
+	}
+
+	// Record this start so restarts can be counted and explained
+	var runState *runstate.Tracker
+	if cfg.Agent.StateFile != "" {
+		runState, err = runstate.Start(cfg.Agent.StateFile)
+		if err != nil {
+			log.Warn("Could not record the agent start", "error", err)
+		}
+		if reason := runState.RestartReason(); reason != runstate.ReasonFirstStart {
+			log.Info("Agent restarted", "reason", reason, "restarts", runState.RestartCount())
+		}
+	}
+
 	// Create and start agent
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
 	ag, err := agent.New(cfg, log)
@@ -260,6 +577,10 @@ func runAgent() error {
 
 	// Start update checker in background
 	updateChecker := updater.NewChecker(cfg, log, Version)
+	ag.SetUpdateChecker(updateChecker)
+	if runState != nil {
+		ag.SetRunState(runState)
+	}
 	go updateChecker.Start(ctx)
 
 	// Handle graceful shutdown
@@ -269,45 +590,107 @@ func runAgent() error {
 	go func() {
 		sig := <-sigCh
 		log.Info("Received shutdown signal", "signal", sig.String())
+		runState.Exit(runstate.ReasonSignal)
 		cancel()
 	}()
 
+	// Reload the config file on SIGHUP
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupCh:
+				log.Info("Received SIGHUP, reloading configuration")
+				reloadConfig(ag, log)
+			}
+		}
+	}()
+
 	// Start agent
 	if err := ag.Run(ctx); err != nil && err != context.Canceled {
+		runState.Exit(runstate.ReasonError)
 		return fmt.Errorf("agent error: %w", err)
 	}
+	// Stopped by the service manager, or a reason was recorded already
+	runState.Exit(runstate.ReasonSignal)
 
 	log.Info("Agent stopped gracefully")
 	return nil
 }
 
-func runRegister(token, serverURL, name string) error {
+// reloadConfig reloads the config file and applies it to the running
+// agent. An unreadable or invalid file leaves the current config in place.
+func reloadConfig(ag *agent.Agent, log *logger.Logger) {
+	next, err := loadConfig()
+	if err != nil {
+		log.Error("Failed to reload configuration", "error", err)
+		return
+	}
+	if debugMode {
+		next.Logging.Level = "debug"
+		next.Logging.ConsoleLevel = "debug"
+	}
+	if err := next.Validate(); err != nil {
+		log.Error("Invalid configuration, keeping the current one", "error", err)
+		return
+	}
+	ag.Reload(next)
+}
+
+// runRegister registers the agent. Connection settings given in conn
+// (proxy, CA bundle, pinned fingerprint) override the loaded config and are
+// saved with it.
+func runRegister(token, serverURL, name, authMode string, conn config.ServerConfig) error {
 	log := logger.New(config.LoggingConfig{Level: "info"})
 
 	log.Info("Registering agent with ServerKit",
 		"server", serverURL,
 	)
 
-	// Load or create config
-	cfg, err := config.Load(cfgFile)
+	// Load or create config. Environment overrides are left out so they
+	// are not written into the saved file.
+	cfg, err := config.LoadFile(cfgFile)
 	if err != nil {
 		// Create new config if doesn't exist
 		cfg = config.Default()
 	}
 
+	if conn.Proxy != "" {
+		cfg.Server.Proxy = conn.Proxy
+	}
+	if conn.CACertFile != "" {
+		cfg.Server.CACertFile = conn.CACertFile
+	}
+	if conn.PinnedSHA256 != "" {
+		cfg.Server.PinnedSHA256 = conn.PinnedSHA256
+	}
+
+	if authMode != "" {
+		cfg.Auth.Mode = authMode
+	}
+	if cfg.Auth.Mode == "" {
+		cfg.Auth.Mode = config.AuthModeHMAC
+	}
+
 	// Register with server
-	reg := agent.NewRegistration(log)
-	result, err := reg.Register(serverURL, token, name)
+	reg := agent.NewRegistration(log, cfg.Server)
+	result, err := reg.Register(serverURL, token, name, cfg.Auth.Mode)
 	if err != nil {
 		return fmt.Errorf("registration failed: %w", err)
 	}
 
 	// Update config
-	cfg.Server.URL = result.WebSocketURL
+	cfg.Server.URL, cfg.Server.FallbackURLs = result.ConnectURLs()
 	cfg.Agent.ID = result.AgentID
 	cfg.Agent.Name = result.Name
 	cfg.Auth.APIKey = result.APIKey
 	cfg.Auth.APISecret = result.APISecret
+	cfg.Auth.PrivateKey = result.PrivateKey
+	cfg.Auth.IssuedAt = time.Now()
 
 	// Save config
 	if err := cfg.Save(config.DefaultConfigPath()); err != nil {
@@ -332,8 +715,71 @@ func runRegister(token, serverURL, name string) error {
 	return nil
 }
 
+func runUnregister(keepConfig, force bool) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if cfg.Agent.ID == "" {
+		return fmt.Errorf("agent is not registered")
+	}
+
+	// Prompt for confirmation unless forced
+	if !force {
+		fmt.Printf("Unregister agent %s (%s) from %s? [y/N]: ", cfg.Agent.Name, cfg.Agent.ID, cfg.Server.HTTPBaseURL())
+		var response string
+		fmt.Scanln(&response)
+		if response != "y" && response != "Y" {
+			fmt.Println("Unregister cancelled.")
+			return nil
+		}
+	}
+
+	log := logger.New(config.LoggingConfig{Level: "info"})
+	reg := agent.NewRegistration(log, cfg.Server)
+	authenticator, err := agent.NewAuthenticator(cfg)
+	if err != nil {
+		return err
+	}
+	if err := reg.Unregister(cfg.Server.HTTPBaseURL(), cfg.Agent.ID, authenticator); err != nil {
+		return fmt.Errorf("unregister failed: %w", err)
+	}
+
+	if err := cfg.DeleteCredentials(); err != nil {
+		return err
+	}
+
+	configPath := cfgFile
+	if configPath == "" {
+		configPath = config.DefaultConfigPath()
+	}
+
+	if keepConfig {
+		// Save what is in the file, not the environment overrides
+		if fileCfg, err := config.LoadFile(configPath); err == nil {
+			cfg = fileCfg
+		}
+		cfg.Agent.ID = ""
+		cfg.Agent.Name = ""
+		if err := cfg.Save(configPath); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+	} else if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove config: %w", err)
+	}
+
+	fmt.Println("\nAgent unregistered successfully!")
+	if keepConfig {
+		fmt.Printf("  Configuration kept at %s\n", configPath)
+		fmt.Println("\nRegister again with: serverkit-agent register")
+	}
+
+	return nil
+}
+
 func showStatus() error {
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		fmt.Println("Status: Not configured")
 		fmt.Printf("  Config file not found at %s\n", config.DefaultConfigPath())
@@ -352,12 +798,97 @@ func showStatus() error {
 	fmt.Printf("  Agent Name: %s\n", cfg.Agent.Name)
 	fmt.Printf("  Server:     %s\n", cfg.Server.URL)
 
-	// TODO: Check if actually connected
-	// This would require checking a PID file or socket
+	if cfg.Agent.PIDFile == "" {
+		return nil
+	}
+	pid, err := pidfile.Running(cfg.Agent.PIDFile)
+	switch {
+	case err != nil:
+		fmt.Printf("  Running:    unknown (%v)\n", err)
+	case pid == 0:
+		fmt.Println("  Running:    no")
+	default:
+		fmt.Printf("  Running:    yes (PID %d)\n", pid)
+		if cfg.IPC.Enabled {
+			health := "not responding"
+			if tray.NewClient(cfg.IPC).IsAgentRunning() {
+				health = "ok"
+			}
+			fmt.Printf("  IPC health: %s\n", health)
+			showRunningDetails(tray.NewClient(cfg.IPC))
+		}
+	}
 
 	return nil
 }
 
+// showRunningDetails prints the running agent's uptime, restart history
+// and the clock skew it measured when it authenticated
+func showRunningDetails(client *tray.Client) {
+	if status, err := client.GetStatus(); err == nil {
+		uptime := time.Duration(status.Uptime) * time.Second
+		fmt.Printf("  Uptime:     %s\n", uptime)
+		if status.RestartCount > 0 {
+			fmt.Printf("  Restarts:   %d (last: %s)\n", status.RestartCount, status.LastRestartReason)
+		}
+	}
+
+	info, err := client.GetConnection()
+	if err != nil || !info.ClockSkewKnown {
+		return
+	}
+	skew := time.Duration(info.ClockSkewMs) * time.Millisecond
+	detail := "in sync"
+	switch {
+	case skew >= time.Second:
+		detail = fmt.Sprintf("%s behind the server", skew.Round(time.Second))
+	case skew <= -time.Second:
+		detail = fmt.Sprintf("%s ahead of the server", (-skew).Round(time.Second))
+	}
+	fmt.Printf("  Clock:      %s\n", detail)
+}
+
+func listSessions() error {
+	client, err := ipcClient()
+	if err != nil {
+		return err
+	}
+
+	sessions, err := client.GetSessions()
+	if err != nil {
+		return fmt.Errorf("failed to query agent (is it running with IPC enabled?): %w", err)
+	}
+
+	if len(sessions) == 0 {
+		fmt.Println("No open terminal sessions")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSHELL\tSIZE\tCREATED\tAGE")
+	for _, s := range sessions {
+		created := time.UnixMilli(s.CreatedAt)
+		fmt.Fprintf(w, "%s\t%s\t%dx%d\t%s\t%s\n",
+			s.ID, s.Shell, s.Cols, s.Rows,
+			created.Format(time.RFC3339),
+			time.Since(created).Round(time.Second),
+		)
+	}
+	return w.Flush()
+}
+
+// ipcClient returns a client for the running agent's IPC server
+func ipcClient() (*tray.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		cfg = config.Default()
+	}
+	if !cfg.IPC.Enabled {
+		return nil, fmt.Errorf("IPC server is disabled in the configuration")
+	}
+	return tray.NewClient(cfg.IPC), nil
+}
+
 func trayCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "tray",
@@ -376,7 +907,7 @@ This is typically auto-started on Windows login when installed via MSI.`,
 
 func runTray() error {
 	// Load agent config to get server URL and IPC settings
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		// Continue without config - tray can still show status
 		cfg = config.Default()
@@ -385,8 +916,9 @@ func runTray() error {
 	// Create and run tray application
 	app := tray.NewApp(tray.AppConfig{
 		Version:      Version,
-		IPCAddress:   cfg.IPC.Address,
-		IPCPort:      cfg.IPC.Port,
+		IPC:          cfg.IPC,
+		Notify:       cfg.Tray.Notifications,
+		Log:          logger.New(config.LoggingConfig{Level: cfg.Logging.Level, ConsoleLevel: cfg.Logging.ConsoleLevel, Format: cfg.Logging.Format}),
 		ServerURL:    cfg.Server.URL,
 		DashboardURL: getDashboardURL(cfg.Server.URL),
 		LogFile:      cfg.Logging.File,
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/serverkit/agent/internal/config"
+	"github.com/serverkit/agent/internal/docker"
+	"github.com/serverkit/agent/internal/logger"
+	"github.com/serverkit/agent/internal/metrics"
+	"github.com/spf13/cobra"
+)
+
+// metricsSample is one reading printed by the metrics command
+type metricsSample struct {
+	*metrics.SystemMetrics
+	Containers *containerCounts `json:"containers,omitempty"`
+}
+
+// containerCounts is set when Docker is enabled and reachable
+type containerCounts struct {
+	Total   int `json:"total"`
+	Running int `json:"running"`
+}
+
+func metricsCmd() *cobra.Command {
+	var (
+		asJSON   bool
+		watch    bool
+		interval time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "metrics",
+		Short: "Collect and print system metrics",
+		Long: `Collect system metrics on this host and print them, the same way the
+agent does before reporting them. Neither the control plane nor a running
+agent is needed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			return printMetrics(asJSON, watch, interval)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print JSON instead of a table")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "keep printing until interrupted")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "refresh interval with --watch")
+
+	return cmd
+}
+
+func printMetrics(asJSON, watch bool, interval time.Duration) error {
+	// Unregistered hosts can still be inspected
+	cfg, err := loadConfig()
+	if err != nil {
+		cfg = config.Default()
+		applyFlags(cfg)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log := logger.New(config.LoggingConfig{Level: "error"})
+	collector := metrics.NewCollector(cfg.Metrics, log)
+
+	var dockerClient *docker.Client
+	if cfg.Features.Docker {
+		if client, err := docker.NewClient(cfg.Docker, log); err == nil {
+			dockerClient = client
+			defer dockerClient.Close()
+		}
+	}
+
+	// Rates are measured between collections, so take a throwaway first
+	// reading rather than print zeroes
+	if _, err := collector.Collect(ctx); err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-time.After(time.Second):
+	}
+
+	for {
+		sample, err := collectSample(ctx, collector, dockerClient)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to collect metrics: %w", err)
+		}
+
+		if asJSON {
+			// One object per line, so --watch output can be piped to jq
+			data, err := json.Marshal(sample)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		} else {
+			if watch {
+				fmt.Print("\033[H\033[2J")
+			}
+			if err := printMetricsTable(sample); err != nil {
+				return err
+			}
+		}
+
+		if !watch {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// collectSample takes one reading, with container counts when Docker
+// answers
+func collectSample(ctx context.Context, collector *metrics.Collector, dockerClient *docker.Client) (*metricsSample, error) {
+	m, err := collector.Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sample := &metricsSample{SystemMetrics: m}
+
+	if dockerClient != nil {
+		dockerCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if total, running, err := dockerClient.GetContainerCount(dockerCtx); err == nil {
+			sample.Containers = &containerCounts{Total: total, Running: running}
+		}
+	}
+	return sample, nil
+}
+
+func printMetricsTable(s *metricsSample) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Time\t%s\n", time.UnixMilli(s.Timestamp).Format(time.RFC3339))
+	fmt.Fprintf(w, "CPU\t%.1f%%\n", s.CPUPercent)
+	if len(s.CPUPerCore) > 0 {
+		cores := make([]string, len(s.CPUPerCore))
+		for i, p := range s.CPUPerCore {
+			cores[i] = fmt.Sprintf("%.0f", p)
+		}
+		fmt.Fprintf(w, "CPU per core\t%s\n", strings.Join(cores, " "))
+	}
+	if s.LoadAvg1 > 0 || s.LoadAvg5 > 0 || s.LoadAvg15 > 0 {
+		fmt.Fprintf(w, "Load average\t%.2f %.2f %.2f\n", s.LoadAvg1, s.LoadAvg5, s.LoadAvg15)
+	}
+	fmt.Fprintf(w, "Memory\t%s / %s (%.1f%%)\n", formatBytes(s.MemoryUsed), formatBytes(s.MemoryTotal), s.MemoryPercent)
+	if s.SwapTotal > 0 {
+		fmt.Fprintf(w, "Swap\t%s / %s (%.1f%%)\n", formatBytes(s.SwapUsed), formatBytes(s.SwapTotal), s.SwapPercent)
+	}
+	fmt.Fprintf(w, "Disk\t%s / %s (%.1f%%)\n", formatBytes(s.DiskUsed), formatBytes(s.DiskTotal), s.DiskPercent)
+	if s.DiskInodesTotal > 0 {
+		fmt.Fprintf(w, "Inodes\t%d / %d (%.1f%%)\n", s.DiskInodesUsed, s.DiskInodesTotal, s.DiskInodesPercent)
+	}
+	for _, d := range s.Disks {
+		fmt.Fprintf(w, "Disk %s\t%s / %s (%.1f%%)\n", d.Path, formatBytes(d.Used), formatBytes(d.Total), d.UsagePercent)
+	}
+	fmt.Fprintf(w, "Disk I/O\tread %s/s (%.0f IOPS), write %s/s (%.0f IOPS)\n",
+		formatBytes(uint64(s.DiskReadRate)), s.DiskReadIOPS, formatBytes(uint64(s.DiskWriteRate)), s.DiskWriteIOPS)
+	fmt.Fprintf(w, "Network\trx %s/s, tx %s/s\n", formatBytes(uint64(s.NetworkRxRate)), formatBytes(uint64(s.NetworkTxRate)))
+	fmt.Fprintf(w, "Uptime\t%s\n", time.Duration(s.Uptime)*time.Second)
+	if s.Containers != nil {
+		fmt.Fprintf(w, "Containers\t%d running / %d total\n", s.Containers.Running, s.Containers.Total)
+	}
+	for _, p := range s.Processes {
+		fmt.Fprintf(w, "Process %s\t%d running, %.1f%% CPU, %s\n", p.Pattern, p.Count, p.CPUPercent, formatBytes(p.MemRSS))
+	}
+	return w.Flush()
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "1.5 GiB"
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
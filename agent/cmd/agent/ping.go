@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/serverkit/agent/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// certExpiryWarning is how close to expiry the server certificate may be
+// before ping warns about it
+const certExpiryWarning = 14 * 24 * time.Hour
+
+func pingCmd() *cobra.Command {
+	var serverURL, proxy, caCertFile, pinnedSHA256 string
+
+	cmd := &cobra.Command{
+		Use:   "ping",
+		Short: "Test the connection to the ServerKit server",
+		Long: `Resolve the server, open a TLS connection and attempt the WebSocket
+upgrade without credentials, reporting latency and certificate details.
+Proxy and TLS settings come from the configuration unless overridden, so
+this also works before registering.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				cfg = config.Default()
+				applyFlags(cfg)
+			}
+			server := cfg.Server
+			if serverURL != "" {
+				server.URL = serverURL
+			}
+			if proxy != "" {
+				server.Proxy = proxy
+			}
+			if caCertFile != "" {
+				server.CACertFile = caCertFile
+			}
+			if pinnedSHA256 != "" {
+				server.PinnedSHA256 = pinnedSHA256
+			}
+			if server.URL == "" {
+				return fmt.Errorf("a server URL is required: pass --server or set %s", config.EnvServerURL)
+			}
+			return runPing(server)
+		},
+	}
+
+	cmd.Flags().StringVarP(&serverURL, "server", "s", "", "ServerKit server URL, e.g. https://serverkit.example.com (defaults to server.url)")
+	cmd.Flags().StringVar(&proxy, "proxy", "", "proxy URL for outbound connections (defaults to server.proxy, then HTTP(S)_PROXY/ALL_PROXY)")
+	cmd.Flags().StringVar(&caCertFile, "ca-cert", "", "PEM CA bundle to trust for the ServerKit server")
+	cmd.Flags().StringVar(&pinnedSHA256, "pin-sha256", "", "expected SHA-256 fingerprint of the server certificate")
+
+	return cmd
+}
+
+// webSocketURL accepts either the agent WebSocket URL or the server's base
+// URL, as given to register, and returns the WebSocket URL
+func webSocketURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	case "wss", "ws":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("missing host")
+	}
+	if strings.TrimSuffix(u.Path, "/") == "" {
+		u.Path = "/agent/ws"
+	}
+	return u, nil
+}
+
+func runPing(server config.ServerConfig) error {
+	report := &doctorReport{}
+
+	target, err := webSocketURL(server.URL)
+	if err != nil {
+		report.add(checkFail, "Server URL", fmt.Sprintf("%q: %v", server.URL, err),
+			"Use the server address, e.g. https://serverkit.example.com")
+		return fmt.Errorf("invalid server URL")
+	}
+	server.URL = target.String()
+	fmt.Printf("Pinging %s\n\n", server.URL)
+
+	// The proxy is chosen for the equivalent HTTP URL, as the dialer does
+	httpURL := *target
+	httpURL.Scheme = strings.Replace(httpURL.Scheme, "ws", "http", 1)
+	proxyURL, err := server.ProxyFunc()(&http.Request{URL: &httpURL})
+	if err != nil {
+		report.add(checkFail, "Proxy", err.Error(), "Check server.proxy or the --proxy flag")
+		return fmt.Errorf("invalid proxy")
+	}
+	if proxyURL != nil {
+		report.add(checkPass, "Proxy", proxyURL.Redacted(), "")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	host := target.Hostname()
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	switch {
+	case err != nil && proxyURL == nil:
+		report.add(checkFail, "DNS", err.Error(), "Check the server hostname and this host's DNS resolver")
+		return fmt.Errorf("ping failed")
+	case err != nil:
+		// The proxy resolves the name itself
+		report.add(checkWarn, "DNS", err.Error(), "Resolution is left to the proxy")
+	default:
+		report.add(checkPass, "DNS", fmt.Sprintf("%s -> %s (%s)", host, strings.Join(addrs, ", "), roundLatency(time.Since(start))), "")
+	}
+
+	tlsCfg, err := server.TLSConfig()
+	if err != nil {
+		report.add(checkFail, "TLS configuration", err.Error(), "Check server.ca_cert_file and server.pinned_sha256")
+		return fmt.Errorf("ping failed")
+	}
+
+	var connStart, connDone, tlsStart time.Time
+	var tlsState *tls.ConnectionState
+	var tlsTime time.Duration
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) { connStart = time.Now() },
+		GotConn: func(httptrace.GotConnInfo) { connDone = time.Now() },
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			tlsTime = time.Since(tlsStart)
+			if err == nil {
+				tlsState = &state
+			}
+		},
+	}
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		Proxy:            func(*http.Request) (*url.URL, error) { return proxyURL, nil },
+		TLSClientConfig:  tlsCfg,
+	}
+	upgradeStart := time.Now()
+	conn, resp, err := dialer.DialContext(httptrace.WithClientTrace(ctx, trace), server.URL, nil)
+	upgradeTime := time.Since(upgradeStart)
+
+	if !connDone.IsZero() {
+		report.add(checkPass, "Connect", fmt.Sprintf("%s (%s)", target.Host, roundLatency(connDone.Sub(connStart))), "")
+	}
+	if tlsState != nil {
+		report.add(checkPass, "TLS", fmt.Sprintf("%s, %s (%s)",
+			tls.VersionName(tlsState.Version), tls.CipherSuiteName(tlsState.CipherSuite), roundLatency(tlsTime)), "")
+		if len(tlsState.PeerCertificates) > 0 {
+			reportCertificate(report, tlsState.PeerCertificates[0], true)
+		}
+	}
+
+	switch {
+	case err == nil:
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		conn.Close()
+		report.add(checkPass, "WebSocket", fmt.Sprintf("upgrade accepted (%s)", roundLatency(upgradeTime)), "")
+	case resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden):
+		// Expected without credentials: the agent endpoint answered
+		report.add(checkPass, "WebSocket", fmt.Sprintf("endpoint answered HTTP %d without credentials (%s)", resp.StatusCode, roundLatency(upgradeTime)), "")
+	case resp != nil:
+		report.add(checkFail, "WebSocket", fmt.Sprintf("upgrade rejected with HTTP %d", resp.StatusCode),
+			"Check that the URL points at the ServerKit agent endpoint and that any reverse proxy forwards WebSocket upgrades")
+	default:
+		hint := "Check firewall rules for outbound connections and any required proxy (server.proxy)"
+		var verifyErr *tls.CertificateVerificationError
+		if errors.As(err, &verifyErr) {
+			hint = "The server certificate is not trusted; set server.ca_cert_file or server.pinned_sha256"
+			// Show what was presented, so it can be checked and pinned
+			if len(verifyErr.UnverifiedCertificates) > 0 {
+				reportCertificate(report, verifyErr.UnverifiedCertificates[0], false)
+			}
+		}
+		report.add(checkFail, "WebSocket", err.Error(), hint)
+	}
+
+	fmt.Printf("\n%d failure(s), %d warning(s)\n", report.failures, report.warnings)
+	if report.failures > 0 {
+		return fmt.Errorf("ping failed")
+	}
+	return nil
+}
+
+// reportCertificate prints the server certificate's subject, issuer, expiry
+// and fingerprint, the last in the format server.pinned_sha256 expects
+func reportCertificate(report *doctorReport, cert *x509.Certificate, trusted bool) {
+	subject := cert.Subject.CommonName
+	if len(cert.DNSNames) > 0 {
+		subject = strings.Join(cert.DNSNames, ", ")
+	}
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := make([]string, len(sum))
+	for i, b := range sum {
+		fingerprint[i] = fmt.Sprintf("%02X", b)
+	}
+
+	issuer := cert.Issuer.CommonName
+	if issuer == "" {
+		issuer = cert.Issuer.String()
+	}
+
+	left := time.Until(cert.NotAfter)
+	detail := fmt.Sprintf("%s, issued by %s, expires %s (%d days)\n       sha256 %s",
+		subject, issuer, cert.NotAfter.Format("2006-01-02"), int(left.Hours()/24),
+		strings.Join(fingerprint, ":"))
+	switch {
+	case !trusted:
+		report.add(checkWarn, "Certificate", detail, "Not trusted by this host; if this is your server, pin it with --pin-sha256 or server.pinned_sha256")
+	case left <= 0:
+		report.add(checkFail, "Certificate", detail, "The server certificate has expired")
+	case left < certExpiryWarning:
+		report.add(checkWarn, "Certificate", detail, "The server certificate expires soon")
+	default:
+		report.add(checkPass, "Certificate", detail, "")
+	}
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	return d.Round(100 * time.Microsecond)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/serverkit/agent/internal/config"
+	"github.com/spf13/cobra"
+)
+
+const (
+	serviceName        = "ServerKitAgent"
+	serviceDisplayName = "ServerKit Agent"
+	serviceDescription = "ServerKit Agent - Remote server management agent"
+
+	// defaultServiceUser runs the systemd unit; it is created if missing
+	defaultServiceUser = "serverkit-agent"
+)
+
+// serviceOptions are the settings the service is installed with
+type serviceOptions struct {
+	Executable string // Absolute path of this binary
+	ConfigPath string // Absolute config path passed to `start`
+	LogDir     string
+	User       string // Linux only; empty or root runs as root
+}
+
+func serviceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "Install or remove the agent system service",
+	}
+
+	var user string
+	install := &cobra.Command{
+		Use:   "install",
+		Short: "Install, enable and start the agent service",
+		Long: `Register the agent with the system service manager and start it.
+
+On Linux this writes a systemd unit that runs as a dedicated user, which
+is created and added to the docker group if needed. On Windows it
+registers the ServerKitAgent service. Running it again updates the
+service to this binary and config path.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := resolveServiceOptions(user)
+			if err != nil {
+				return err
+			}
+			if err := installService(opts); err != nil {
+				return err
+			}
+			fmt.Println("Service installed and started")
+			fmt.Printf("  Binary: %s\n", opts.Executable)
+			fmt.Printf("  Config: %s\n", opts.ConfigPath)
+			return nil
+		},
+	}
+	install.Flags().StringVar(&user, "user", defaultServiceUser, "Linux user the service runs as (root to skip creating one)")
+	cmd.AddCommand(install)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "uninstall",
+		Short: "Stop and remove the agent service",
+		Long: `Stop the agent service and remove it from the system service manager.
+The configuration, credentials and service user are kept.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := uninstallService(); err != nil {
+				return err
+			}
+			fmt.Println("Service removed")
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+// resolveServiceOptions finds the binary and config the service should use
+func resolveServiceOptions(user string) (*serviceOptions, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate agent binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	configPath := cfgFile
+	if configPath == "" {
+		configPath = config.DefaultConfigPath()
+	}
+	configPath, err = filepath.Abs(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config path: %w", err)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config %s (run 'serverkit-agent register' first): %w", configPath, err)
+	}
+	if cfg.Agent.ID == "" {
+		return nil, fmt.Errorf("agent is not registered, run 'serverkit-agent register' first")
+	}
+
+	opts := &serviceOptions{
+		Executable: exe,
+		ConfigPath: configPath,
+		User:       user,
+	}
+	if cfg.Logging.File != "" {
+		opts.LogDir = filepath.Dir(cfg.Logging.File)
+	}
+	return opts, nil
+}
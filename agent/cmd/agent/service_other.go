@@ -0,0 +1,186 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/serverkit/agent/internal/config"
+)
+
+const (
+	systemdUnitName = "serverkit-agent.service"
+	systemdUnitPath = "/etc/systemd/system/" + systemdUnitName
+)
+
+const systemdUnitTemplate = `[Unit]
+Description=%[1]s
+Documentation=https://github.com/serverkit/agent
+After=network-online.target docker.service
+Wants=network-online.target
+
+[Service]
+Type=simple
+User=%[2]s
+Group=%[2]s
+ExecStart=%[3]s
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=always
+RestartSec=5
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=serverkit-agent
+
+# Security hardening
+NoNewPrivileges=yes
+ProtectSystem=strict
+ProtectHome=yes
+ReadWritePaths=%[4]s
+RuntimeDirectory=serverkit-agent
+PrivateTmp=yes
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// installService writes and starts the systemd unit
+func installService(opts *serviceOptions) error {
+	if err := requireSystemd(); err != nil {
+		return err
+	}
+
+	account := opts.User
+	if account == "" {
+		account = "root"
+	}
+	if account != "root" {
+		if err := ensureServiceUser(account); err != nil {
+			fmt.Printf("Warning: %v, the service will run as root\n", err)
+			account = "root"
+		}
+	}
+
+	writable := []string{filepath.Dir(opts.ConfigPath)}
+	if opts.LogDir != "" {
+		if err := os.MkdirAll(opts.LogDir, 0755); err != nil {
+			return fmt.Errorf("failed to create log directory: %w", err)
+		}
+		writable = append(writable, opts.LogDir)
+	}
+	if account != "root" {
+		// Only take over the agent's own directories, a custom path may be
+		// shared with other software
+		owned := map[string]bool{
+			filepath.Dir(config.DefaultConfigPath()):    true,
+			filepath.Dir(config.Default().Logging.File): true,
+		}
+		for _, dir := range writable {
+			if !owned[dir] {
+				fmt.Printf("Warning: make sure %s is writable by %s\n", dir, account)
+				continue
+			}
+			if err := chownTree(dir, account); err != nil {
+				return err
+			}
+		}
+	}
+
+	execStart := strconv.Quote(opts.Executable) + " start --config " + strconv.Quote(opts.ConfigPath)
+	unit := fmt.Sprintf(systemdUnitTemplate, serviceDisplayName, account, execStart, strings.Join(writable, " "))
+	if err := os.WriteFile(systemdUnitPath, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", systemdUnitPath, err)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if err := systemctl("enable", systemdUnitName); err != nil {
+		return err
+	}
+	// Restart so a reinstall picks up the new unit
+	return systemctl("restart", systemdUnitName)
+}
+
+// uninstallService stops, disables and removes the systemd unit
+func uninstallService() error {
+	if err := requireSystemd(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(systemdUnitPath); os.IsNotExist(err) {
+		return fmt.Errorf("service is not installed (%s not found)", systemdUnitPath)
+	}
+
+	if err := systemctl("disable", "--now", systemdUnitName); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if err := os.Remove(systemdUnitPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", systemdUnitPath, err)
+	}
+	return systemctl("daemon-reload")
+}
+
+// requireSystemd checks the service can be managed on this host
+func requireSystemd() error {
+	if _, err := os.Stat("/run/systemd/system"); err != nil {
+		return fmt.Errorf("systemd was not detected; run 'serverkit-agent start' from your init system instead")
+	}
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("managing the service requires root, try again with sudo")
+	}
+	return nil
+}
+
+// ensureServiceUser creates a system account for the service and adds it
+// to the docker group so it can reach the Docker socket
+func ensureServiceUser(name string) error {
+	if _, err := user.Lookup(name); err != nil {
+		out, err := exec.Command("useradd", "--system", "--no-create-home", "--shell", "/bin/false", name).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to create user %s: %s", name, strings.TrimSpace(string(out)))
+		}
+		fmt.Printf("Created service user %s\n", name)
+	}
+
+	if _, err := user.LookupGroup("docker"); err == nil {
+		if out, err := exec.Command("usermod", "-aG", "docker", name).CombinedOutput(); err != nil {
+			fmt.Printf("Warning: failed to add %s to the docker group: %s\n", name, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// chownTree gives the service user ownership of dir and everything in it
+func chownTree(dir, account string) error {
+	u, err := user.Lookup(account)
+	if err != nil {
+		return err
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+
+	err = filepath.WalkDir(dir, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set ownership of %s: %w", dir, err)
+	}
+	return nil
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s failed: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
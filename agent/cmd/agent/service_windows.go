@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceStopTimeout bounds how long uninstall waits for the agent to exit
+const serviceStopTimeout = 30 * time.Second
+
+// installService registers the agent with the service control manager and
+// starts it. It runs as LocalSystem, which the Docker named pipe requires.
+func installService(opts *serviceOptions) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+
+	cfg := mgr.Config{
+		DisplayName:      serviceDisplayName,
+		Description:      serviceDescription,
+		StartType:        mgr.StartAutomatic,
+		DelayedAutoStart: true,
+	}
+
+	s, err := m.OpenService(serviceName)
+	if err == nil {
+		// Reinstall: point the existing service at this binary and config
+		defer s.Close()
+		if err := stopService(s); err != nil {
+			return err
+		}
+		current, err := s.Config()
+		if err != nil {
+			return fmt.Errorf("failed to read service config: %w", err)
+		}
+		current.DisplayName = cfg.DisplayName
+		current.Description = cfg.Description
+		current.StartType = cfg.StartType
+		current.DelayedAutoStart = cfg.DelayedAutoStart
+		current.BinaryPathName = fmt.Sprintf(`"%s" start --config "%s"`, opts.Executable, opts.ConfigPath)
+		if err := s.UpdateConfig(current); err != nil {
+			return fmt.Errorf("failed to update service: %w", err)
+		}
+	} else {
+		s, err = m.CreateService(serviceName, opts.Executable, cfg, "start", "--config", opts.ConfigPath)
+		if err != nil {
+			return fmt.Errorf("failed to create service: %w", err)
+		}
+		defer s.Close()
+	}
+
+	// Lets a failed run be reported in the Application event log. An
+	// existing source from an earlier install is fine.
+	if err := eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		if !strings.Contains(err.Error(), "already exists") {
+			fmt.Printf("Warning: failed to register event log source: %v\n", err)
+		}
+	}
+
+	// Restart on failure, the same recovery install.ps1 configures
+	actions := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+	}
+	if err := s.SetRecoveryActions(actions, uint32((24 * time.Hour).Seconds())); err != nil {
+		fmt.Printf("Warning: failed to set service recovery actions: %v\n", err)
+	}
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("service installed but failed to start: %w", err)
+	}
+	return nil
+}
+
+// uninstallService stops the agent service and deletes it
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+
+	if err := stopService(s); err != nil {
+		return err
+	}
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+	eventlog.Remove(serviceName)
+	return nil
+}
+
+// stopService asks a running service to stop and waits until it has
+func stopService(s *mgr.Service) error {
+	status, err := s.Query()
+	if err != nil {
+		return fmt.Errorf("failed to query service: %w", err)
+	}
+	if status.State == svc.Stopped {
+		return nil
+	}
+
+	if status.State != svc.StopPending {
+		if status, err = s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("failed to stop service: %w", err)
+		}
+	}
+
+	deadline := time.Now().Add(serviceStopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for the service to stop")
+		}
+		time.Sleep(500 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return fmt.Errorf("failed to query service: %w", err)
+		}
+	}
+	return nil
+}
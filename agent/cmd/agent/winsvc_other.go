@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "context"
+
+// runAsService reports false: only Windows has a service control loop to
+// run under
+func runAsService(run func(ctx context.Context) error) (bool, error) {
+	return false, nil
+}
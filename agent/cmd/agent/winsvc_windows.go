@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// serviceStopWaitHint tells the service manager how long a stop may take,
+// in milliseconds. Shutdown sends the goodbye frame and drains the IPC
+// server.
+const serviceStopWaitHint = 20000
+
+// serviceFailedEventID is the event log ID for a failed service run
+const serviceFailedEventID = 1
+
+// runAsService runs the agent under the service control loop when the
+// process was started by the service manager. It reports false for an
+// interactive `start`, which the caller handles with signals instead.
+func runAsService(run func(ctx context.Context) error) (bool, error) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false, nil
+	}
+
+	handler := &agentService{run: run}
+	if err := svc.Run(serviceName, handler); err != nil {
+		return true, fmt.Errorf("service failed: %w", err)
+	}
+	if handler.err != nil {
+		// Nothing reads stderr under the service manager
+		if elog, err := eventlog.Open(serviceName); err == nil {
+			elog.Error(serviceFailedEventID, handler.err.Error())
+			elog.Close()
+		}
+	}
+	return true, handler.err
+}
+
+// agentService adapts the agent to the svc.Handler interface
+type agentService struct {
+	run func(ctx context.Context) error
+	err error
+}
+
+// Execute runs the agent and cancels it on Stop or Shutdown
+func (s *agentService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- s.run(ctx) }()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case err := <-done:
+			s.err = err
+			if err != nil {
+				// A service-specific exit code so recovery actions restart us
+				return true, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending, WaitHint: serviceStopWaitHint}
+				cancel()
+			}
+		}
+	}
+}
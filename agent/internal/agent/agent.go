@@ -2,12 +2,19 @@ package agent
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/serverkit/agent/internal/auth"
@@ -16,14 +23,18 @@ import (
 	"github.com/serverkit/agent/internal/ipc"
 	"github.com/serverkit/agent/internal/logger"
 	"github.com/serverkit/agent/internal/metrics"
+	"github.com/serverkit/agent/internal/prometheus"
+	"github.com/serverkit/agent/internal/runstate"
 	"github.com/serverkit/agent/internal/terminal"
+	"github.com/serverkit/agent/internal/updater"
 	"github.com/serverkit/agent/internal/ws"
 	"github.com/serverkit/agent/pkg/protocol"
 )
 
 // Agent is the main agent that coordinates all components
 type Agent struct {
-	cfg      *config.Config
+	cfg      atomic.Pointer[config.Config] // Replaced whole on reload; read with config()
+	cfgMu    sync.Mutex                    // Serializes config replacements
 	log      *logger.Logger
 	auth     *auth.Authenticator
 	ws       *ws.Client
@@ -31,19 +42,35 @@ type Agent struct {
 	metrics  *metrics.Collector
 	terminal *terminal.Manager
 	ipc      *ipc.Server
+	updates  *updater.UpdateChecker
+	exporter *prometheus.Server
+	runState *runstate.Tracker // Nil when the state file is not in use
+	backfill *metricsBuffer    // Samples collected while disconnected; nil when disabled
 
 	// Active subscriptions
 	subscriptions map[string]context.CancelFunc
 	subMu         sync.Mutex
 
 	// Command handlers
-	handlers map[string]CommandHandler
+	handlers      map[string]CommandHandler
+	commands      *commandLimiter   // Bounds commands running at once
+	replay        *auth.ReplayCache // Command IDs seen recently, see verifyCommand
+	terminalOrder *sessionQueues    // Keeps terminal input in order per session
+
+	// paused stops heartbeats and metric streams during maintenance;
+	// commands are still handled
+	paused atomic.Bool
 
 	// Lifecycle tracking
-	startTime      time.Time
-	restartCh      chan struct{}
-	lastConnected  time.Time
-	reconnectCount int
+	startTime time.Time
+	restartCh chan struct{}
+	sysInfoCh chan struct{} // Asks systemInfoLoop to send system info now
+
+	// Connection lifecycle, updated by the WebSocket state handler
+	connMu           sync.Mutex
+	connState        ws.ConnState
+	connStateSince   time.Time
+	lastDisconnected time.Time // Zero until an authenticated connection is lost
 }
 
 // CommandHandler is a function that handles a command
@@ -52,7 +79,10 @@ type CommandHandler func(ctx context.Context, params json.RawMessage) (interface
 // New creates a new Agent
 func New(cfg *config.Config, log *logger.Logger) (*Agent, error) {
 	// Create authenticator
-	authenticator := auth.New(cfg.Agent.ID, cfg.Auth.APIKey, cfg.Auth.APISecret)
+	authenticator, err := NewAuthenticator(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create WebSocket client
 	wsClient := ws.NewClient(cfg.Server, authenticator, log)
@@ -76,13 +106,19 @@ func New(cfg *config.Config, log *logger.Logger) (*Agent, error) {
 
 	// Create terminal manager if exec is enabled
 	var termManager *terminal.Manager
-	if cfg.Features.Exec {
-		termManager = terminal.NewManager()
+	if cfg.Features.Exec && !cfg.Features.ReadOnly {
+		var err error
+		termManager, err = terminal.NewManager(cfg.Terminal)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create terminal manager: %w", err)
+		}
 		log.Info("Terminal/PTY support enabled")
+		if cfg.Terminal.AuditLog != "" {
+			log.Info("Terminal audit logging enabled", "file", cfg.Terminal.AuditLog, "output", cfg.Terminal.AuditOutput)
+		}
 	}
 
 	agent := &Agent{
-		cfg:           cfg,
 		log:           log,
 		auth:          authenticator,
 		ws:            wsClient,
@@ -91,21 +127,49 @@ func New(cfg *config.Config, log *logger.Logger) (*Agent, error) {
 		terminal:      termManager,
 		subscriptions: make(map[string]context.CancelFunc),
 		handlers:      make(map[string]CommandHandler),
+		commands:      newCommandLimiter(cfg.Security.MaxConcurrentCommands, cfg.Security.MaxQueuedCommands),
+		replay:        newReplayCache(cfg.Security),
+		terminalOrder: newSessionQueues(),
 		startTime:     time.Now(),
 		restartCh:     make(chan struct{}),
+		sysInfoCh:     make(chan struct{}, 1),
+	}
+
+	agent.cfg.Store(cfg)
+
+	if metricsCollector != nil && cfg.Metrics.BackfillSamples > 0 {
+		agent.backfill = newMetricsBuffer(cfg.Metrics.BackfillSamples, cfg.Metrics.BackfillMaxKB*1024)
+	}
+
+	if cfg.Security.CommandVerification == config.CommandVerificationEnforce && authenticator.Algorithm() != auth.AlgorithmHMACSHA256 {
+		return nil, fmt.Errorf("security.command_verification enforce requires hmac auth: command signatures cannot be checked in %s mode", authenticator.Algorithm())
+	}
+	if cfg.Security.CommandVerification != config.CommandVerificationOff && authenticator.Algorithm() != auth.AlgorithmHMACSHA256 {
+		log.Warn("Command signatures cannot be checked in this auth mode; only timestamps and IDs are verified", "mode", cfg.Auth.Mode)
 	}
 
 	// Register command handlers
 	agent.registerHandlers()
 
-	// Set WebSocket message handler
+	// Set WebSocket message and state handlers
 	wsClient.SetHandler(agent.handleMessage)
+	wsClient.SetStateHandler(agent.handleConnState)
 
 	// Create IPC server if enabled
 	if cfg.IPC.Enabled {
 		agent.ipc = ipc.NewServer(cfg.IPC, log, agent)
 	}
 
+	// Create Prometheus endpoint if enabled; it needs a collector even
+	// when metrics are not sent to the server
+	if cfg.Features.Prometheus {
+		collector := metricsCollector
+		if collector == nil {
+			collector = metrics.NewCollector(cfg.Metrics, log)
+		}
+		agent.exporter = prometheus.NewServer(cfg.Prometheus, collector, dockerClient, cfg.Metrics.IncludeDockerStats, Version, log)
+	}
+
 	return agent, nil
 }
 
@@ -118,21 +182,48 @@ func (a *Agent) registerHandlers() {
 		a.handlers[protocol.ActionDockerContainerStart] = a.handleDockerContainerStart
 		a.handlers[protocol.ActionDockerContainerStop] = a.handleDockerContainerStop
 		a.handlers[protocol.ActionDockerContainerRestart] = a.handleDockerContainerRestart
+		a.handlers[protocol.ActionDockerContainerPause] = a.handleDockerContainerPause
+		a.handlers[protocol.ActionDockerContainerUnpause] = a.handleDockerContainerUnpause
+		a.handlers[protocol.ActionDockerContainerRename] = a.handleDockerContainerRename
+		a.handlers[protocol.ActionDockerContainerUpdate] = a.handleDockerContainerUpdate
+		a.handlers[protocol.ActionDockerContainerPrune] = a.handleDockerContainerPrune
 		a.handlers[protocol.ActionDockerContainerRemove] = a.handleDockerContainerRemove
 		a.handlers[protocol.ActionDockerContainerStats] = a.handleDockerContainerStats
+		a.handlers[protocol.ActionDockerContainerHealth] = a.handleDockerContainerHealth
+		a.handlers[protocol.ActionDockerContainerTop] = a.handleDockerContainerTop
 		a.handlers[protocol.ActionDockerContainerLogs] = a.handleDockerContainerLogs
+		a.handlers[protocol.ActionDockerContainerUpdateImage] = a.handleDockerContainerUpdateImage
+		if a.config().Features.FileAccess {
+			a.handlers[protocol.ActionDockerContainerCopy] = a.handleDockerContainerCopy
+		}
 
 		// Docker image commands
 		a.handlers[protocol.ActionDockerImageList] = a.handleDockerImageList
 		a.handlers[protocol.ActionDockerImagePull] = a.handleDockerImagePull
 		a.handlers[protocol.ActionDockerImageRemove] = a.handleDockerImageRemove
+		a.handlers[protocol.ActionDockerImagePrune] = a.handleDockerImagePrune
+		a.handlers[protocol.ActionDockerImageTag] = a.handleDockerImageTag
+		a.handlers[protocol.ActionDockerImagePush] = a.handleDockerImagePush
+		a.handlers[protocol.ActionDockerImageInspect] = a.handleDockerImageInspect
 
 		// Docker volume commands
 		a.handlers[protocol.ActionDockerVolumeList] = a.handleDockerVolumeList
+		a.handlers[protocol.ActionDockerVolumeCreate] = a.handleDockerVolumeCreate
+		a.handlers[protocol.ActionDockerVolumeInspect] = a.handleDockerVolumeInspect
 		a.handlers[protocol.ActionDockerVolumeRemove] = a.handleDockerVolumeRemove
+		a.handlers[protocol.ActionDockerVolumePrune] = a.handleDockerVolumePrune
 
 		// Docker network commands
 		a.handlers[protocol.ActionDockerNetworkList] = a.handleDockerNetworkList
+		a.handlers[protocol.ActionDockerNetworkCreate] = a.handleDockerNetworkCreate
+		a.handlers[protocol.ActionDockerNetworkRemove] = a.handleDockerNetworkRemove
+		a.handlers[protocol.ActionDockerNetworkConnect] = a.handleDockerNetworkConnect
+		a.handlers[protocol.ActionDockerNetworkDisconnect] = a.handleDockerNetworkDisconnect
+		a.handlers[protocol.ActionDockerNetworkPrune] = a.handleDockerNetworkPrune
+
+		// Docker system commands
+		a.handlers[protocol.ActionDockerSystemPrune] = a.handleDockerSystemPrune
+		a.handlers[protocol.ActionDockerSystemDf] = a.handleDockerSystemDf
 
 		// Docker compose commands
 		a.handlers[protocol.ActionDockerComposeList] = a.handleDockerComposeList
@@ -142,6 +233,15 @@ func (a *Agent) registerHandlers() {
 		a.handlers[protocol.ActionDockerComposeLogs] = a.handleDockerComposeLogs
 		a.handlers[protocol.ActionDockerComposeRestart] = a.handleDockerComposeRestart
 		a.handlers[protocol.ActionDockerComposePull] = a.handleDockerComposePull
+		a.handlers[protocol.ActionDockerComposeBuild] = a.handleDockerComposeBuild
+		a.handlers[protocol.ActionDockerComposeConfig] = a.handleDockerComposeConfig
+		a.handlers[protocol.ActionDockerComposeValidate] = a.handleDockerComposeValidate
+		a.handlers[protocol.ActionDockerComposeScale] = a.handleDockerComposeScale
+		a.handlers[protocol.ActionDockerComposeStart] = a.handleDockerComposeStart
+		a.handlers[protocol.ActionDockerComposeStop] = a.handleDockerComposeStop
+
+		// Docker event commands
+		a.handlers[protocol.ActionDockerEventsHistory] = a.handleDockerEventsHistory
 	}
 
 	// System commands
@@ -149,6 +249,7 @@ func (a *Agent) registerHandlers() {
 		a.handlers[protocol.ActionSystemMetrics] = a.handleSystemMetrics
 		a.handlers[protocol.ActionSystemInfo] = a.handleSystemInfo
 		a.handlers[protocol.ActionSystemProcesses] = a.handleSystemProcesses
+		a.handlers[protocol.ActionSystemProcessWatch] = a.handleSystemProcessWatch
 	}
 
 	// Terminal commands
@@ -158,14 +259,51 @@ func (a *Agent) registerHandlers() {
 		a.handlers[protocol.ActionTerminalResize] = a.handleTerminalResize
 		a.handlers[protocol.ActionTerminalClose] = a.handleTerminalClose
 	}
+
+	if a.config().Features.ReadOnly {
+		for action := range a.handlers {
+			if !readOnlyActions[action] {
+				delete(a.handlers, action)
+			}
+		}
+	}
+}
+
+// readOnlyActions are the only commands a features.read_only agent keeps.
+// Anything not listed here, including actions added later, is dropped, so
+// new mutating actions cannot slip in by default.
+var readOnlyActions = map[string]bool{
+	protocol.ActionDockerContainerList:    true,
+	protocol.ActionDockerContainerInspect: true,
+	protocol.ActionDockerContainerStats:   true,
+	protocol.ActionDockerContainerHealth:  true,
+	protocol.ActionDockerContainerTop:     true,
+	protocol.ActionDockerContainerLogs:    true,
+	protocol.ActionDockerImageList:        true,
+	protocol.ActionDockerImageInspect:     true,
+	protocol.ActionDockerVolumeList:       true,
+	protocol.ActionDockerVolumeInspect:    true,
+	protocol.ActionDockerNetworkList:      true,
+	protocol.ActionDockerSystemDf:         true,
+	protocol.ActionDockerComposeList:      true,
+	protocol.ActionDockerComposePs:        true,
+	protocol.ActionDockerComposeLogs:      true,
+	protocol.ActionDockerComposeConfig:    true,
+	protocol.ActionDockerComposeValidate:  true,
+	protocol.ActionDockerEventsHistory:    true,
+	protocol.ActionSystemMetrics:          true,
+	protocol.ActionSystemInfo:             true,
+	protocol.ActionSystemProcesses:        true,
+	protocol.ActionSystemProcessWatch:     true,
 }
 
 // Run starts the agent
 func (a *Agent) Run(ctx context.Context) error {
+	cfg := a.config()
 	a.log.Info("Starting agent",
-		"agent_id", a.cfg.Agent.ID,
+		"agent_id", cfg.Agent.ID,
 		"version", Version,
-		"features", fmt.Sprintf("docker=%v metrics=%v ipc=%v", a.cfg.Features.Docker, a.cfg.Features.Metrics, a.cfg.IPC.Enabled),
+		"features", fmt.Sprintf("docker=%v metrics=%v ipc=%v prometheus=%v read_only=%v", cfg.Features.Docker, cfg.Features.Metrics, cfg.IPC.Enabled, cfg.Features.Prometheus, cfg.Features.ReadOnly),
 	)
 
 	// Verify Docker connection if enabled
@@ -176,6 +314,14 @@ func (a *Agent) Run(ctx context.Context) error {
 			version, _ := a.docker.Version(ctx)
 			a.log.Info("Docker connected", "version", version)
 		}
+
+		// Record events so recent history can be queried later
+		go a.docker.WatchEvents(ctx)
+	}
+
+	// Close terminal sessions that outlive the configured timeouts
+	if a.terminal != nil {
+		go a.terminal.RunReaper(ctx)
 	}
 
 	// Start IPC server if enabled
@@ -185,9 +331,19 @@ func (a *Agent) Run(ctx context.Context) error {
 		}
 	}
 
-	// Start WebSocket connection in background
+	// Start Prometheus endpoint if enabled
+	if a.exporter != nil {
+		if err := a.exporter.Start(ctx); err != nil {
+			a.log.Warn("Failed to start Prometheus metrics endpoint", "error", err)
+		}
+	}
+
+	// Start WebSocket connection in background. It gets its own context so
+	// cleanup can say goodbye to the server before the connection closes.
+	wsCtx, wsCancel := context.WithCancel(context.Background())
+	defer wsCancel()
 	go func() {
-		if err := a.ws.Run(ctx); err != nil && err != context.Canceled {
+		if err := a.ws.Run(wsCtx); err != nil && err != context.Canceled {
 			a.log.Error("WebSocket error", "error", err)
 		}
 	}()
@@ -195,30 +351,69 @@ func (a *Agent) Run(ctx context.Context) error {
 	// Start heartbeat loop
 	go a.heartbeatLoop(ctx)
 
+	// Keep the server's host facts current
+	go a.systemInfoLoop(ctx)
+
+	// Ask for new credentials once they reach security.rotate_every
+	go a.credentialRotationLoop(ctx)
+
+	// Restart after a long outage, if enabled
+	go a.watchdogLoop(ctx)
+
+	// Push metrics without a subscription, if enabled
+	if a.config().Metrics.AutoPush && a.metrics != nil {
+		go a.metricsPushLoop(ctx)
+	}
+
+	// Keep sampling through outages for the server to backfill
+	if a.backfill != nil {
+		go a.backfillLoop(ctx)
+	}
+
 	// Wait for context cancellation or restart request
+	reason := protocol.DisconnectReasonShutdown
 	select {
 	case <-ctx.Done():
 	case <-a.restartCh:
 		a.log.Info("Restart requested")
+		reason = protocol.DisconnectReasonRestart
 	}
 
 	// Cleanup
-	a.cleanup()
+	a.cleanup(reason)
 
 	return ctx.Err()
 }
 
+// interval returns a configured loop interval, falling back to
+// config.MinInterval with a warning when it is not positive
+func (a *Agent) interval(field string, d time.Duration) time.Duration {
+	effective, clamped := config.EffectiveInterval(d)
+	if clamped {
+		a.log.Warn("Interval must be positive, using the minimum instead",
+			"field", field,
+			"configured", d,
+			"using", effective,
+		)
+	}
+	return effective
+}
+
 // heartbeatLoop sends periodic heartbeats
 func (a *Agent) heartbeatLoop(ctx context.Context) {
-	ticker := time.NewTicker(a.cfg.Server.PingInterval)
+	ticker := time.NewTicker(a.interval("server.ping_interval", a.config().Server.PingInterval))
 	defer ticker.Stop()
 
+	// Container crashes are reported from this point on, and it only
+	// advances once a heartbeat is sent
+	crashesSince := time.Now()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if !a.ws.IsConnected() {
+			if !a.ws.IsConnected() || a.paused.Load() {
 				continue
 			}
 
@@ -243,9 +438,21 @@ func (a *Agent) heartbeatLoop(ctx context.Context) {
 				}
 			}
 
+			// Taken before the report so events during it are not missed
+			reportedAt := time.Now()
+			if a.docker != nil {
+				crashes := a.docker.CrashReport(ctx, crashesSince)
+				heartbeatMetrics.CrashLooping = crashes.CrashLooping
+				heartbeatMetrics.OOMKilled = crashes.OOMKilled
+				if len(crashes.OOMKilled) > 0 {
+					a.log.Warn("Containers ran out of memory", "containers", crashes.OOMKilled)
+				}
+			}
+
 			if err := a.ws.SendHeartbeat(heartbeatMetrics); err != nil {
 				a.log.Warn("Failed to send heartbeat", "error", err)
 			} else {
+				crashesSince = reportedAt
 				a.log.Debug("Heartbeat sent",
 					"cpu", fmt.Sprintf("%.1f%%", heartbeatMetrics.CPUPercent),
 					"mem", fmt.Sprintf("%.1f%%", heartbeatMetrics.MemoryPercent),
@@ -255,13 +462,57 @@ func (a *Agent) heartbeatLoop(ctx context.Context) {
 	}
 }
 
+// handleConnState records a connection state transition. Once the agent is
+// authenticated again it pushes a connection status frame, carrying when
+// the previous connection dropped, so the dashboard can show the outage.
+// Frames for the other states are not sent: the socket is down or not yet
+// authenticated, and they would only pile up in the send queue.
+func (a *Agent) handleConnState(state ws.ConnState) {
+	now := time.Now()
+
+	a.connMu.Lock()
+	previous := a.connState
+	a.connState = state
+	a.connStateSince = now
+	if state == ws.StateDisconnected && previous == ws.StateAuthenticated {
+		a.lastDisconnected = now
+	}
+	lastDisconnected := a.lastDisconnected
+	a.connMu.Unlock()
+
+	a.log.Debug("Connection state changed", "from", previous, "to", state)
+
+	if state != ws.StateAuthenticated {
+		return
+	}
+
+	// Host facts may have changed while the agent was away
+	a.requestSystemInfo()
+	a.flushBackfill()
+
+	status := map[string]interface{}{
+		"state":           state,
+		"timestamp":       now.UnixMilli(),
+		"reconnect_count": a.ws.ReconnectCount(),
+	}
+	if !lastDisconnected.IsZero() {
+		status["disconnected_at"] = lastDisconnected.UnixMilli()
+		status["offline_ms"] = now.Sub(lastDisconnected).Milliseconds()
+	}
+	if err := a.ws.SendStream(protocol.ChannelConnection, status); err != nil {
+		a.log.Warn("Failed to send connection status", "error", err)
+	}
+}
+
 // handleMessage handles incoming WebSocket messages
 func (a *Agent) handleMessage(msgType protocol.MessageType, data []byte) {
 	a.log.Debug("Received message", "type", msgType)
 
 	switch msgType {
 	case protocol.TypeCommand:
-		a.handleCommand(data)
+		a.dispatchCommand(data)
+	case protocol.TypeBatchCommand:
+		go a.handleBatchCommand(data)
 	case protocol.TypeSubscribe:
 		a.handleSubscribe(data)
 	case protocol.TypeUnsubscribe:
@@ -273,39 +524,43 @@ func (a *Agent) handleMessage(msgType protocol.MessageType, data []byte) {
 	}
 }
 
-// handleCommand handles command messages
-func (a *Agent) handleCommand(data []byte) {
+// dispatchCommand runs a command off the read loop so a slow one does not
+// hold up the connection; executeCommand bounds how many run at once.
+// Input, resize and close for a terminal session run one at a time in
+// arrival order instead, so keystrokes are not reordered.
+func (a *Agent) dispatchCommand(data []byte) {
 	var cmd protocol.CommandMessage
 	if err := json.Unmarshal(data, &cmd); err != nil {
 		a.log.Error("Failed to parse command", "error", err)
 		return
 	}
 
+	if session, ok := terminalSessionOf(cmd); ok {
+		a.terminalOrder.run(session, func() { a.handleCommand(cmd) })
+		return
+	}
+	go a.handleCommand(cmd)
+}
+
+// handleCommand handles command messages
+func (a *Agent) handleCommand(cmd protocol.CommandMessage) {
+	if err := a.verifyCommand(cmd.Message, cmd.Action); err != nil {
+		a.ws.SendCommandResult(cmd.ID, false, nil, err.Error(), 0)
+		return
+	}
+
 	a.log.Info("Executing command",
 		"id", cmd.ID,
 		"action", cmd.Action,
 	)
 
-	// Find handler
-	handler, ok := a.handlers[cmd.Action]
-	if !ok {
+	result, duration, err := a.executeCommand(cmd.Action, cmd.Params, cmd.Timeout)
+	if errors.Is(err, errUnknownAction) {
 		a.log.Warn("Unknown command action", "action", cmd.Action)
-		a.ws.SendCommandResult(cmd.ID, false, nil, "unknown action: "+cmd.Action, 0)
+		a.ws.SendCommandResult(cmd.ID, false, nil, err.Error(), 0)
 		return
 	}
 
-	// Execute command
-	start := time.Now()
-	ctx := context.Background()
-	if cmd.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, time.Duration(cmd.Timeout)*time.Millisecond)
-		defer cancel()
-	}
-
-	result, err := handler(ctx, cmd.Params)
-	duration := time.Since(start)
-
 	if err != nil {
 		a.log.Error("Command failed",
 			"id", cmd.ID,
@@ -325,6 +580,54 @@ func (a *Agent) handleCommand(data []byte) {
 	a.ws.SendCommandResult(cmd.ID, true, result, "", duration)
 }
 
+// errUnknownAction is returned by executeCommand for unregistered actions
+var errUnknownAction = errors.New("unknown action")
+
+// errActionDenied is returned by executeCommand for actions excluded by
+// security.allowed_actions, security.denied_actions or features.read_only
+var errActionDenied = errors.New("action not permitted on this agent")
+
+// executeCommand runs a registered command handler with an optional
+// timeout in milliseconds. It waits for a free command slot first, which
+// counts against the timeout but not the reported duration; ordered
+// terminal actions skip the limit.
+func (a *Agent) executeCommand(action string, params json.RawMessage, timeout int) (interface{}, time.Duration, error) {
+	handler, ok := a.handlers[action]
+	if !ok && a.config().Features.ReadOnly && !readOnlyActions[action] {
+		return nil, 0, fmt.Errorf("%w: %s (read-only agent)", errActionDenied, action)
+	}
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s", errUnknownAction, action)
+	}
+	if !a.config().Security.IsActionAllowed(action) {
+		a.log.Warn("Refusing command, action not permitted", "action", action)
+		return nil, 0, fmt.Errorf("%w: %s", errActionDenied, action)
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+		defer cancel()
+	}
+
+	// Terminal input is quick and must not be refused as busy while long
+	// commands hold every slot
+	if !orderedTerminalActions[action] {
+		if err := a.commands.acquire(ctx); err != nil {
+			if errors.Is(err, errBusy) {
+				a.log.Warn("Rejecting command, too many in flight", "action", action, "running", a.commands.running())
+			}
+			return nil, 0, err
+		}
+		defer a.commands.release()
+	}
+
+	start := time.Now()
+	result, err := handler(ctx, params)
+	return result, time.Since(start), err
+}
+
 // handleSubscribe handles subscription requests
 func (a *Agent) handleSubscribe(data []byte) {
 	var sub protocol.SubscribeMessage
@@ -347,7 +650,7 @@ func (a *Agent) handleSubscribe(data []byte) {
 	a.subMu.Unlock()
 
 	// Start streaming based on channel type
-	go a.streamData(ctx, sub.Channel)
+	go a.streamData(ctx, sub.Channel, sub.Params)
 }
 
 // handleUnsubscribe handles unsubscription requests
@@ -369,19 +672,58 @@ func (a *Agent) handleUnsubscribe(data []byte) {
 }
 
 // streamData streams data for a subscription
-func (a *Agent) streamData(ctx context.Context, channel string) {
+func (a *Agent) streamData(ctx context.Context, channel string, params json.RawMessage) {
 	// Determine what to stream based on channel
 	switch channel {
 	case protocol.ChannelMetrics:
 		a.streamMetrics(ctx, channel)
+	case protocol.ChannelDockerEvents:
+		a.streamDockerEvents(ctx, channel, params)
 	default:
+		if project, ok := composeLogsProject(channel); ok {
+			a.streamComposeLogs(ctx, channel, project, params)
+			return
+		}
+		if id, ok := channelParam(protocol.ChannelContainerLogs, channel); ok {
+			a.streamContainerLogs(ctx, channel, id, params)
+			return
+		}
 		a.log.Warn("Unknown stream channel", "channel", channel)
 	}
 }
 
 // streamMetrics streams system metrics
 func (a *Agent) streamMetrics(ctx context.Context, channel string) {
-	ticker := time.NewTicker(a.cfg.Metrics.Interval)
+	a.metricsLoop(ctx, func() bool { return true }, a.metricsSender(channel))
+}
+
+// metricsPushLoop sends system metrics on the metrics channel every
+// metrics.interval without a subscription, for always-on dashboards. It
+// leaves the channel to an active subscription so samples are not sent
+// twice, and sends nothing while disconnected or paused.
+func (a *Agent) metricsPushLoop(ctx context.Context) {
+	a.metricsLoop(ctx, func() bool {
+		a.subMu.Lock()
+		_, subscribed := a.subscriptions[protocol.ChannelMetrics]
+		a.subMu.Unlock()
+		return a.ws.IsConnected() && !subscribed
+	}, a.metricsSender(protocol.ChannelMetrics))
+}
+
+// metricsSender returns a metricsLoop sink that streams samples on channel
+func (a *Agent) metricsSender(channel string) func(*metrics.SystemMetrics) {
+	return func(sysMetrics *metrics.SystemMetrics) {
+		if err := a.ws.SendStream(channel, sysMetrics); err != nil {
+			a.log.Warn("Failed to send metrics stream", "error", err)
+		}
+	}
+}
+
+// metricsLoop collects system metrics every metrics.interval while active
+// reports true and hands each sample to send
+func (a *Agent) metricsLoop(ctx context.Context, active func() bool, send func(*metrics.SystemMetrics)) {
+	interval := a.interval("metrics.interval", a.config().Metrics.Interval)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -389,7 +731,14 @@ func (a *Agent) streamMetrics(ctx context.Context, channel string) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if a.metrics == nil {
+			// Pick up an interval changed by a config reload
+			if next, _ := config.EffectiveInterval(a.config().Metrics.Interval); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+
+			// Subscriptions stay open while paused so streaming resumes
+			if a.metrics == nil || a.paused.Load() || !active() {
 				continue
 			}
 
@@ -398,15 +747,161 @@ func (a *Agent) streamMetrics(ctx context.Context, channel string) {
 				a.log.Warn("Failed to collect metrics", "error", err)
 				continue
 			}
+			// Too expensive for the heartbeat and on-demand collections
+			sysMetrics.ConnectionStates = a.metrics.ConnectionStates(ctx)
 
-			if err := a.ws.SendStream(channel, sysMetrics); err != nil {
-				a.log.Warn("Failed to send metrics stream", "error", err)
-			}
+			send(sysMetrics)
+		}
+	}
+}
+
+// composeLogsProject extracts the project name from a compose logs channel
+func composeLogsProject(channel string) (string, bool) {
+	return channelParam(protocol.ChannelComposeLogs, channel)
+}
+
+// channelParam extracts the %s part of a parameterized channel such as
+// protocol.ChannelContainerLogs, reporting false when channel does not
+// match the pattern
+func channelParam(pattern, channel string) (string, bool) {
+	prefix, suffix, _ := strings.Cut(pattern, "%s")
+	if !strings.HasPrefix(channel, prefix) || !strings.HasSuffix(channel, suffix) {
+		return "", false
+	}
+	value := strings.TrimSuffix(strings.TrimPrefix(channel, prefix), suffix)
+	return value, value != ""
+}
+
+// streamContainerLogs sends a container's logs line by line, following new
+// output until unsubscribed unless follow is false. Each line carries its
+// stream, stdout or stderr. A closed event is sent when the logs end on
+// their own, e.g. because the container stopped.
+func (a *Agent) streamContainerLogs(ctx context.Context, channel, id string, params json.RawMessage) {
+	if a.docker == nil {
+		a.log.Warn("Container logs requested but Docker is not available")
+		return
+	}
+
+	var p struct {
+		Tail       string `json:"tail"`       // Defaults to 100
+		Since      string `json:"since"`      // RFC 3339, Unix seconds or a duration ago such as 10m
+		Timestamps *bool  `json:"timestamps"` // Defaults to true
+		Follow     *bool  `json:"follow"`     // Defaults to true
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			a.log.Warn("Invalid container logs params", "error", err)
+			return
+		}
+	}
+	if p.Tail == "" {
+		p.Tail = "100"
+	}
+
+	opts := docker.ContainerLogOptions{
+		Tail:       p.Tail,
+		Since:      p.Since,
+		Timestamps: p.Timestamps == nil || *p.Timestamps,
+	}
+	follow := p.Follow == nil || *p.Follow
+
+	err := a.docker.FollowContainerLogs(ctx, id, opts, follow, func(stream, line string) {
+		if err := a.ws.SendStreamWait(ctx, channel, map[string]interface{}{
+			"type":   "output",
+			"stream": stream,
+			"line":   line,
+		}); err != nil && err != context.Canceled {
+			a.log.Warn("Failed to send container log line", "error", err)
+		}
+	})
+	if ctx.Err() != nil {
+		return
+	}
+
+	event := map[string]interface{}{
+		"type": "closed",
+	}
+	if err != nil {
+		event["reason"] = err.Error()
+	}
+	if err := a.ws.SendStream(channel, event); err != nil {
+		a.log.Warn("Failed to send container logs close event", "error", err)
+	}
+}
+
+// streamComposeLogs follows a compose project's logs, sending each line
+// until the subscription is cancelled or compose exits
+func (a *Agent) streamComposeLogs(ctx context.Context, channel, project string, params json.RawMessage) {
+	if a.docker == nil {
+		a.log.Warn("Compose logs requested but Docker is not available")
+		return
+	}
+
+	var p struct {
+		docker.ComposeTarget
+		Service string `json:"service"`
+		Tail    int    `json:"tail"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			a.log.Warn("Invalid compose logs params", "error", err)
+			return
+		}
+	}
+
+	// Without compose files, address the project by the channel's name
+	if p.ProjectPath == "" && p.ProjectDir == "" && len(p.ComposeFiles) == 0 && p.ProjectName == "" {
+		p.ProjectName = project
+	}
+
+	err := a.docker.ComposeLogsFollow(ctx, p.ComposeTarget, p.Service, p.Tail, func(line string) {
+		if err := a.ws.SendStreamWait(ctx, channel, map[string]interface{}{
+			"type": "output",
+			"line": line,
+		}); err != nil && err != context.Canceled {
+			a.log.Warn("Failed to send compose log line", "error", err)
+		}
+	})
+	if ctx.Err() != nil {
+		return
+	}
+
+	// compose exited on its own, e.g. the project was taken down
+	event := map[string]interface{}{
+		"type": "closed",
+	}
+	if err != nil {
+		event["reason"] = err.Error()
+	}
+	if err := a.ws.SendStream(channel, event); err != nil {
+		a.log.Warn("Failed to send compose logs close event", "error", err)
+	}
+}
+
+// streamDockerEvents forwards Docker events until the subscription is
+// cancelled, resubscribing from the last event if the stream drops
+func (a *Agent) streamDockerEvents(ctx context.Context, channel string, params json.RawMessage) {
+	if a.docker == nil {
+		a.log.Warn("Docker events requested but Docker is not available")
+		return
+	}
+
+	var filter docker.EventStreamFilter
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &filter); err != nil {
+			a.log.Warn("Invalid docker events filter", "error", err)
+			return
 		}
 	}
+
+	// The stream is torn down with ctx when the subscription ends
+	a.docker.FollowEvents(ctx, filter, time.Time{}, func(ev docker.Event) {
+		if err := a.ws.SendStream(channel, ev); err != nil {
+			a.log.Warn("Failed to send docker event", "error", err)
+		}
+	})
 }
 
-// cleanup performs cleanup on shutdown
 // handleCredentialUpdate handles credential rotation from server
 func (a *Agent) handleCredentialUpdate(data []byte) {
 	var msg protocol.CredentialUpdateMessage
@@ -441,15 +936,56 @@ func (a *Agent) handleCredentialUpdate(data []byte) {
 
 // saveCredentials saves new credentials to the key file
 func (a *Agent) saveCredentials(apiKey, apiSecret string) error {
+	a.cfgMu.Lock()
+	defer a.cfgMu.Unlock()
+
 	// Update config with new credentials
-	a.cfg.Auth.APIKey = apiKey
-	a.cfg.Auth.APISecret = apiSecret
+	cfg := *a.config()
+	cfg.Auth.APIKey = apiKey
+	cfg.Auth.APISecret = apiSecret
+	cfg.Auth.IssuedAt = time.Now()
+	a.cfg.Store(&cfg)
 
 	// Save using existing secure method
-	return a.cfg.SaveCredentials()
+	return cfg.SaveCredentials()
+}
+
+// config returns the current configuration. The returned value is never
+// modified, so callers needing several settings should read it once.
+func (a *Agent) config() *config.Config {
+	return a.cfg.Load()
+}
+
+// Reload applies the reloadable settings of next (log level, metrics
+// interval, update settings and allowed paths) without reconnecting. Other
+// changes are logged as needing a restart.
+func (a *Agent) Reload(next *config.Config) {
+	a.cfgMu.Lock()
+	defer a.cfgMu.Unlock()
+
+	current := a.config()
+	for _, section := range current.RestartRequired(next) {
+		a.log.Warn("Config change needs a restart to take effect", "section", section)
+	}
+
+	updated := current.Reloaded(next)
+	a.cfg.Store(updated)
+	a.log.SetLevel(updated.Logging.Level, updated.Logging.ConsoleLevel)
+	if a.updates != nil {
+		a.updates.Reload(updated.Update)
+	}
+
+	a.log.Info("Configuration reloaded",
+		"log_level", updated.Logging.Level,
+		"console_log_level", updated.Logging.ConsoleLevel,
+		"metrics_interval", updated.Metrics.Interval,
+		"allowed_paths", len(updated.Security.AllowedPaths),
+	)
 }
 
-func (a *Agent) cleanup() {
+// cleanup performs cleanup on shutdown. The server is told why the agent
+// is going away before the connection closes.
+func (a *Agent) cleanup(reason string) {
 	a.log.Info("Cleaning up...")
 
 	// Cancel all subscriptions
@@ -470,8 +1006,13 @@ func (a *Agent) cleanup() {
 		a.ipc.Stop()
 	}
 
-	// Close WebSocket
-	a.ws.Close()
+	// Stop Prometheus endpoint
+	if a.exporter != nil {
+		a.exporter.Stop()
+	}
+
+	// Say goodbye and close WebSocket
+	a.ws.Shutdown(reason)
 
 	// Close Docker client
 	if a.docker != nil {
@@ -482,23 +1023,42 @@ func (a *Agent) cleanup() {
 // Docker command handlers
 
 func (a *Agent) handleDockerContainerList(ctx context.Context, params json.RawMessage) (interface{}, error) {
-	var p struct {
-		All bool `json:"all"`
-	}
+	var p docker.ContainerFilters
 	if len(params) > 0 {
-		json.Unmarshal(params, &p)
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+	containers, total, err := a.docker.ListContainers(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unpaged requests keep the plain list older servers expect
+	if p.Limit == 0 && p.Offset == 0 {
+		return containers, nil
 	}
-	return a.docker.ListContainers(ctx, p.All)
+	return map[string]interface{}{
+		"containers": containers,
+		"total":      total,
+		"limit":      p.Limit,
+		"offset":     p.Offset,
+	}, nil
 }
 
 func (a *Agent) handleDockerContainerInspect(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		ID string `json:"id"`
+		ID     string   `json:"id"`
+		Fields []string `json:"fields"` // JSON paths to return, e.g. "State.Status"; all when empty
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-	return a.docker.InspectContainer(ctx, p.ID)
+	info, err := a.docker.InspectContainer(ctx, p.ID)
+	if err != nil || len(p.Fields) == 0 {
+		return info, err
+	}
+	return projectFields(info, p.Fields)
 }
 
 func (a *Agent) handleDockerContainerStart(ctx context.Context, params json.RawMessage) (interface{}, error) {
@@ -533,123 +1093,570 @@ func (a *Agent) handleDockerContainerRestart(ctx context.Context, params json.Ra
 	return map[string]bool{"success": true}, a.docker.RestartContainer(ctx, p.ID, p.Timeout)
 }
 
-func (a *Agent) handleDockerContainerRemove(ctx context.Context, params json.RawMessage) (interface{}, error) {
+func (a *Agent) handleDockerContainerPause(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		ID            string `json:"id"`
-		Force         bool   `json:"force"`
-		RemoveVolumes bool   `json:"remove_volumes"`
+		ID string `json:"id"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-	return map[string]bool{"success": true}, a.docker.RemoveContainer(ctx, p.ID, p.Force, p.RemoveVolumes)
+	// Docker's own error is returned as-is, e.g. when already paused
+	if err := a.docker.PauseContainer(ctx, p.ID); err != nil {
+		return nil, err
+	}
+	return a.containerStateResult(ctx, p.ID)
 }
 
-func (a *Agent) handleDockerContainerStats(ctx context.Context, params json.RawMessage) (interface{}, error) {
+func (a *Agent) handleDockerContainerUnpause(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
 		ID string `json:"id"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-	return a.docker.ContainerStats(ctx, p.ID)
+	if err := a.docker.UnpauseContainer(ctx, p.ID); err != nil {
+		return nil, err
+	}
+	return a.containerStateResult(ctx, p.ID)
 }
 
-func (a *Agent) handleDockerContainerLogs(ctx context.Context, params json.RawMessage) (interface{}, error) {
+func (a *Agent) handleDockerContainerRename(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		ID         string `json:"id"`
-		Tail       string `json:"tail"`
-		Since      string `json:"since"`
-		Timestamps bool   `json:"timestamps"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-
-	// Set defaults
-	if p.Tail == "" {
-		p.Tail = "100"
-	}
-
-	reader, err := a.docker.ContainerLogs(ctx, p.ID, p.Tail, p.Since, false)
-	if err != nil {
+	if err := a.docker.RenameContainer(ctx, p.ID, p.Name); err != nil {
 		return nil, err
 	}
-	defer reader.Close()
-
-	// Read logs into buffer
-	buf := make([]byte, 1024*1024) // 1MB max
-	n, _ := reader.Read(buf)
-	logs := string(buf[:n])
-
 	return map[string]interface{}{
-		"logs": logs,
+		"success": true,
+		"id":      p.ID,
+		"name":    strings.TrimPrefix(p.Name, "/"),
 	}, nil
 }
 
-func (a *Agent) handleDockerImageList(ctx context.Context, params json.RawMessage) (interface{}, error) {
-	return a.docker.ListImages(ctx)
-}
-
-func (a *Agent) handleDockerImagePull(ctx context.Context, params json.RawMessage) (interface{}, error) {
+func (a *Agent) handleDockerContainerUpdate(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		Image string `json:"image"`
+		ID string `json:"id"`
+		docker.ContainerResources
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-
-	reader, err := a.docker.PullImage(ctx, p.Image)
+	warnings, err := a.docker.UpdateContainer(ctx, p.ID, p.ContainerResources)
 	if err != nil {
 		return nil, err
 	}
-	defer reader.Close()
-
-	// Read the output to completion
-	var output []map[string]interface{}
-	decoder := json.NewDecoder(reader)
-	for decoder.More() {
-		var msg map[string]interface{}
-		if err := decoder.Decode(&msg); err != nil {
-			break
-		}
-		output = append(output, msg)
-	}
-
 	return map[string]interface{}{
-		"success": true,
-		"output":  output,
+		"success":  true,
+		"id":       p.ID,
+		"warnings": warnings,
 	}, nil
 }
 
+// containerStateResult reports a container's state after an action so the
+// dashboard can update without another round trip
+func (a *Agent) containerStateResult(ctx context.Context, id string) (interface{}, error) {
+	state, err := a.docker.ContainerState(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"state":   state,
+	}, nil
+}
+
+func (a *Agent) handleDockerContainerRemove(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID            string `json:"id"`
+		Force         bool   `json:"force"`
+		RemoveVolumes bool   `json:"remove_volumes"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return map[string]bool{"success": true}, a.docker.RemoveContainer(ctx, p.ID, p.Force, p.RemoveVolumes)
+}
+
+func (a *Agent) handleDockerContainerStats(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return a.docker.ContainerStats(ctx, p.ID)
+}
+
+func (a *Agent) handleDockerContainerHealth(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return a.docker.ContainerHealth(ctx, p.ID)
+}
+
+func (a *Agent) handleDockerContainerTop(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID     string `json:"id"`
+		PsArgs string `json:"ps_args"` // e.g. "aux"; defaults to "-ef"
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return a.docker.ContainerTop(ctx, p.ID, p.PsArgs)
+}
+
+// handleDockerContainerCopy moves files in or out of a container as a
+// base64-encoded tar archive of at most docker.MaxCopySize bytes
+func (a *Agent) handleDockerContainerCopy(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID        string `json:"id"`
+		Direction string `json:"direction"` // "from" the container or "to" it
+		Path      string `json:"path"`      // Source for "from", destination directory for "to"
+		Data      string `json:"data"`      // base64 tar archive, for "to"
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	switch p.Direction {
+	case "from":
+		reader, stat, err := a.docker.CopyFromContainer(ctx, p.ID, p.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		archive, err := io.ReadAll(io.LimitReader(reader, docker.MaxCopySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if len(archive) > docker.MaxCopySize {
+			return nil, fmt.Errorf("%s is larger than the %d byte copy limit", p.Path, docker.MaxCopySize)
+		}
+		return map[string]interface{}{
+			"stat": stat,
+			"data": base64.StdEncoding.EncodeToString(archive),
+		}, nil
+
+	case "to":
+		if base64.StdEncoding.DecodedLen(len(p.Data)) > docker.MaxCopySize {
+			return nil, fmt.Errorf("archive is larger than the %d byte copy limit", docker.MaxCopySize)
+		}
+		archive, err := base64.StdEncoding.DecodeString(p.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 data: %w", err)
+		}
+		if err := docker.ValidateArchive(bytes.NewReader(archive)); err != nil {
+			return nil, err
+		}
+		if err := a.docker.CopyToContainer(ctx, p.ID, p.Path, bytes.NewReader(archive)); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"success": true, "bytes": len(archive)}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid direction %q: use from or to", p.Direction)
+	}
+}
+
+func (a *Agent) handleDockerContainerLogs(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID         string `json:"id"`
+		Tail       string `json:"tail"`
+		Since      string `json:"since"`
+		Timestamps bool   `json:"timestamps"`
+		MaxBytes   int64  `json:"max_bytes"` // Defaults to 1MB
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	// Set defaults
+	if p.Tail == "" {
+		p.Tail = "100"
+	}
+
+	return a.docker.ReadContainerLogs(ctx, p.ID, docker.ContainerLogOptions{
+		Tail:       p.Tail,
+		Since:      p.Since,
+		Timestamps: p.Timestamps,
+		MaxBytes:   p.MaxBytes,
+	})
+}
+
+func (a *Agent) handleDockerContainerUpdateImage(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID     string `json:"id"`
+		Force  bool   `json:"force"`
+		DryRun bool   `json:"dry_run"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	if p.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	return a.docker.UpdateContainerImage(ctx, p.ID, p.Force, p.DryRun)
+}
+
+func (a *Agent) handleDockerImageList(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return a.docker.ListImages(ctx)
+}
+
+func (a *Agent) handleDockerImagePull(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Image        string `json:"image"`
+		RegistryAuth string `json:"registry_auth"` // base64 encoded, never logged
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	reader, err := a.docker.PullImage(ctx, p.Image, p.RegistryAuth)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// Read the output to completion
+	var output []map[string]interface{}
+	decoder := json.NewDecoder(reader)
+	for decoder.More() {
+		var msg map[string]interface{}
+		if err := decoder.Decode(&msg); err != nil {
+			break
+		}
+		redactProgress(msg, p.RegistryAuth)
+		output = append(output, msg)
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"output":  output,
+	}, nil
+}
+
+// redactProgress strips registry credentials from the error fields of a
+// pull or push progress message before it leaves the agent
+func redactProgress(msg map[string]interface{}, registryAuth string) {
+	if registryAuth == "" {
+		return
+	}
+	if errMsg, ok := msg["error"].(string); ok {
+		msg["error"] = docker.RedactRegistryAuth(errMsg, registryAuth)
+	}
+	if detail, ok := msg["errorDetail"].(map[string]interface{}); ok {
+		if m, ok := detail["message"].(string); ok {
+			detail["message"] = docker.RedactRegistryAuth(m, registryAuth)
+		}
+	}
+}
+
+func (a *Agent) handleDockerImageTag(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Source string `json:"source"`
+		Target string `json:"target"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return map[string]bool{"success": true}, a.docker.TagImage(ctx, p.Source, p.Target)
+}
+
+// handleDockerImagePush pushes an image and, when a stream ID is given,
+// forwards layer progress on the image push channel as it arrives
+func (a *Agent) handleDockerImagePush(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Image        string `json:"image"`
+		RegistryAuth string `json:"registry_auth"` // base64 encoded, never logged
+		StreamID     string `json:"stream_id"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	if p.Image == "" {
+		return nil, fmt.Errorf("image is required")
+	}
+
+	reader, err := a.docker.PushImage(ctx, p.Image, p.RegistryAuth)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	channel := ""
+	if p.StreamID != "" {
+		channel = fmt.Sprintf(protocol.ChannelImagePush, p.StreamID)
+	}
+
+	// The daemon reports push failures inside the stream, not as an
+	// HTTP error, so every message has to be checked
+	var digest string
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg map[string]interface{}
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to read push output: %w", err)
+		}
+		redactProgress(msg, p.RegistryAuth)
+		if channel != "" {
+			if err := a.ws.SendStreamWait(ctx, channel, msg); err != nil {
+				a.log.Warn("Failed to send push progress", "error", err)
+			}
+		}
+		if errMsg, ok := msg["error"].(string); ok && errMsg != "" {
+			return nil, fmt.Errorf("push failed: %s", errMsg)
+		}
+		if aux, ok := msg["aux"].(map[string]interface{}); ok {
+			if d, ok := aux["Digest"].(string); ok {
+				digest = d
+			}
+		}
+	}
+
+	a.log.Info("Image pushed", "image", p.Image, "digest", digest)
+	return map[string]interface{}{
+		"success": true,
+		"image":   p.Image,
+		"digest":  digest,
+	}, nil
+}
+
+func (a *Agent) handleDockerImageInspect(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return a.docker.InspectImage(ctx, p.ID)
+}
+
 func (a *Agent) handleDockerImageRemove(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		ID    string `json:"id"`
-		Force bool   `json:"force"`
+		ID    string `json:"id"`
+		Force bool   `json:"force"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return map[string]bool{"success": true}, a.docker.RemoveImage(ctx, p.ID, p.Force)
+}
+
+func (a *Agent) handleDockerVolumeList(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return a.docker.ListVolumes(ctx)
+}
+
+func (a *Agent) handleDockerVolumeCreate(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name       string            `json:"name"`
+		Driver     string            `json:"driver"`
+		Labels     map[string]string `json:"labels"`
+		DriverOpts map[string]string `json:"driver_opts"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return a.docker.CreateVolume(ctx, p.Name, p.Driver, p.Labels, p.DriverOpts)
+}
+
+func (a *Agent) handleDockerVolumeInspect(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return a.docker.InspectVolume(ctx, p.Name)
+}
+
+func (a *Agent) handleDockerVolumeRemove(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name  string `json:"name"`
+		Force bool   `json:"force"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return map[string]bool{"success": true}, a.docker.RemoveVolume(ctx, p.Name, p.Force)
+}
+
+func (a *Agent) handleDockerNetworkList(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return a.docker.ListNetworks(ctx)
+}
+
+func (a *Agent) handleDockerContainerPrune(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	f, err := parsePruneFilters(params)
+	if err != nil {
+		return nil, err
+	}
+	return a.docker.PruneContainers(ctx, f)
+}
+
+func (a *Agent) handleDockerImagePrune(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	f, err := parsePruneFilters(params)
+	if err != nil {
+		return nil, err
+	}
+	return a.docker.PruneImages(ctx, f)
+}
+
+func (a *Agent) handleDockerVolumePrune(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		docker.PruneFilters
+		Confirm bool `json:"confirm"` // Volume data is lost, so opt-in only
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+	if !p.Confirm {
+		return nil, fmt.Errorf("volume prune deletes data: set confirm to true")
+	}
+	return a.docker.PruneVolumes(ctx, p.PruneFilters)
+}
+
+func (a *Agent) handleDockerNetworkPrune(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	f, err := parsePruneFilters(params)
+	if err != nil {
+		return nil, err
+	}
+	return a.docker.PruneNetworks(ctx, f)
+}
+
+func (a *Agent) handleDockerSystemPrune(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		docker.PruneFilters
+		IncludeVolumes bool `json:"include_volumes"` // Volume data is lost, so opt-in only
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+	return a.docker.SystemPrune(ctx, p.PruneFilters, p.IncludeVolumes)
+}
+
+func (a *Agent) handleDockerSystemDf(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Refresh bool `json:"refresh"` // Skip the cached result
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+	return a.docker.DiskUsage(ctx, p.Refresh)
+}
+
+// parsePruneFilters reads optional prune filters from command params
+func parsePruneFilters(params json.RawMessage) (docker.PruneFilters, error) {
+	var f docker.PruneFilters
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &f); err != nil {
+			return f, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+	return f, nil
+}
+
+func (a *Agent) handleDockerNetworkCreate(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name    string            `json:"name"`
+		Driver  string            `json:"driver"`
+		Options map[string]string `json:"options"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	id, err := a.docker.CreateNetwork(ctx, p.Name, p.Driver, p.Options)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"success": true,
+		"id":      id,
+	}, nil
+}
+
+func (a *Agent) handleDockerNetworkRemove(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID string `json:"id"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-	return map[string]bool{"success": true}, a.docker.RemoveImage(ctx, p.ID, p.Force)
+	return map[string]bool{"success": true}, a.docker.RemoveNetwork(ctx, p.ID)
 }
 
-func (a *Agent) handleDockerVolumeList(ctx context.Context, params json.RawMessage) (interface{}, error) {
-	return a.docker.ListVolumes(ctx)
+func (a *Agent) handleDockerNetworkConnect(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		NetworkID   string   `json:"network_id"`
+		ContainerID string   `json:"container_id"`
+		Aliases     []string `json:"aliases"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return map[string]bool{"success": true}, a.docker.ConnectContainer(ctx, p.NetworkID, p.ContainerID, p.Aliases)
 }
 
-func (a *Agent) handleDockerVolumeRemove(ctx context.Context, params json.RawMessage) (interface{}, error) {
+func (a *Agent) handleDockerNetworkDisconnect(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		Name  string `json:"name"`
-		Force bool   `json:"force"`
+		NetworkID   string `json:"network_id"`
+		ContainerID string `json:"container_id"`
+		Force       bool   `json:"force"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-	return map[string]bool{"success": true}, a.docker.RemoveVolume(ctx, p.Name, p.Force)
+	return map[string]bool{"success": true}, a.docker.DisconnectContainer(ctx, p.NetworkID, p.ContainerID, p.Force)
 }
 
-func (a *Agent) handleDockerNetworkList(ctx context.Context, params json.RawMessage) (interface{}, error) {
-	return a.docker.ListNetworks(ctx)
+func (a *Agent) handleDockerEventsHistory(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Container string `json:"container"`
+		Type      string `json:"type"`
+		Action    string `json:"action"`
+		Since     string `json:"since"` // e.g. "1h", RFC3339, or Unix seconds
+		Limit     int    `json:"limit"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+
+	since, err := docker.ParseSince(p.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	events := a.docker.EventHistory(docker.EventFilter{
+		Container: p.Container,
+		Type:      p.Type,
+		Action:    p.Action,
+		Since:     since,
+		Limit:     p.Limit,
+	})
+
+	return map[string]interface{}{
+		"events": events,
+		"count":  len(events),
+	}, nil
 }
 
 // System command handlers
@@ -666,6 +1673,36 @@ func (a *Agent) handleSystemProcesses(ctx context.Context, params json.RawMessag
 	return a.metrics.ListProcesses(ctx)
 }
 
+func (a *Agent) handleSystemProcessWatch(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Processes []string `json:"processes"` // Defaults to metrics.watch_processes
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+
+	entries := p.Processes
+	if len(entries) == 0 {
+		entries = a.config().Metrics.WatchProcesses
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no processes to watch: pass processes or set metrics.watch_processes")
+	}
+
+	patterns := make([]metrics.ProcessPattern, 0, len(entries))
+	for _, entry := range entries {
+		pattern, err := metrics.ParseProcessPattern(entry)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return a.metrics.WatchProcesses(ctx, patterns)
+}
+
 // Docker Compose command handlers
 
 func (a *Agent) handleDockerComposeList(ctx context.Context, params json.RawMessage) (interface{}, error) {
@@ -674,19 +1711,20 @@ func (a *Agent) handleDockerComposeList(ctx context.Context, params json.RawMess
 
 func (a *Agent) handleDockerComposePs(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		ProjectPath string `json:"project_path"`
+		docker.ComposeTarget
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-	return a.docker.ComposePsProject(ctx, p.ProjectPath)
+	return a.docker.ComposePsProject(ctx, p.ComposeTarget)
 }
 
 func (a *Agent) handleDockerComposeUp(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		ProjectPath string `json:"project_path"`
-		Detach      bool   `json:"detach"`
-		Build       bool   `json:"build"`
+		docker.ComposeTarget
+		Detach         bool `json:"detach"`
+		Build          bool `json:"build"`
+		SkipValidation bool `json:"skip_validation"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
@@ -697,12 +1735,24 @@ func (a *Agent) handleDockerComposeUp(ctx context.Context, params json.RawMessag
 		p.Detach = true
 	}
 
-	output, err := a.docker.ComposeUp(ctx, p.ProjectPath, p.Detach, p.Build)
+	output, err := a.docker.ComposeUp(ctx, p.ComposeTarget, p.Detach, p.Build, p.SkipValidation)
 	if err != nil {
+		// Report validation failures separately so the UI can point at the file
+		var validationErr *docker.ComposeValidationError
+		if errors.As(err, &validationErr) {
+			return map[string]interface{}{
+				"success":          false,
+				"error_type":       "validation",
+				"validation_error": validationErr.Output,
+				"error":            err.Error(),
+			}, nil
+		}
+
 		return map[string]interface{}{
-			"success": false,
-			"output":  output,
-			"error":   err.Error(),
+			"success":    false,
+			"error_type": "runtime",
+			"output":     output,
+			"error":      err.Error(),
 		}, nil
 	}
 
@@ -714,15 +1764,15 @@ func (a *Agent) handleDockerComposeUp(ctx context.Context, params json.RawMessag
 
 func (a *Agent) handleDockerComposeDown(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		ProjectPath   string `json:"project_path"`
-		Volumes       bool   `json:"volumes"`
-		RemoveOrphans bool   `json:"remove_orphans"`
+		docker.ComposeTarget
+		Volumes       bool `json:"volumes"`
+		RemoveOrphans bool `json:"remove_orphans"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
 
-	output, err := a.docker.ComposeDown(ctx, p.ProjectPath, p.Volumes, p.RemoveOrphans)
+	output, err := a.docker.ComposeDown(ctx, p.ComposeTarget, p.Volumes, p.RemoveOrphans)
 	if err != nil {
 		return map[string]interface{}{
 			"success": false,
@@ -739,9 +1789,9 @@ func (a *Agent) handleDockerComposeDown(ctx context.Context, params json.RawMess
 
 func (a *Agent) handleDockerComposeLogs(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		ProjectPath string `json:"project_path"`
-		Service     string `json:"service"`
-		Tail        int    `json:"tail"`
+		docker.ComposeTarget
+		Service string `json:"service"`
+		Tail    int    `json:"tail"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
@@ -752,7 +1802,7 @@ func (a *Agent) handleDockerComposeLogs(ctx context.Context, params json.RawMess
 		p.Tail = 100
 	}
 
-	logs, err := a.docker.ComposeLogs(ctx, p.ProjectPath, p.Service, p.Tail)
+	logs, err := a.docker.ComposeLogs(ctx, p.ComposeTarget, p.Service, p.Tail)
 	if err != nil {
 		return nil, err
 	}
@@ -764,14 +1814,14 @@ func (a *Agent) handleDockerComposeLogs(ctx context.Context, params json.RawMess
 
 func (a *Agent) handleDockerComposeRestart(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		ProjectPath string `json:"project_path"`
-		Service     string `json:"service"`
+		docker.ComposeTarget
+		Service string `json:"service"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
 
-	output, err := a.docker.ComposeRestart(ctx, p.ProjectPath, p.Service)
+	output, err := a.docker.ComposeRestart(ctx, p.ComposeTarget, p.Service)
 	if err != nil {
 		return map[string]interface{}{
 			"success": false,
@@ -786,16 +1836,108 @@ func (a *Agent) handleDockerComposeRestart(ctx context.Context, params json.RawM
 	}, nil
 }
 
+func (a *Agent) handleDockerComposeScale(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		docker.ComposeTarget
+		Service  string `json:"service"`
+		Replicas *int   `json:"replicas"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	if p.Replicas == nil {
+		return nil, fmt.Errorf("replicas is required")
+	}
+
+	output, err := a.docker.ComposeScale(ctx, p.ComposeTarget, p.Service, *p.Replicas)
+	return a.composeServiceResult(ctx, p.ComposeTarget, output, err), nil
+}
+
+func (a *Agent) handleDockerComposeStart(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		docker.ComposeTarget
+		Service string `json:"service"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	output, err := a.docker.ComposeStart(ctx, p.ComposeTarget, p.Service)
+	return a.composeServiceResult(ctx, p.ComposeTarget, output, err), nil
+}
+
+func (a *Agent) handleDockerComposeStop(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		docker.ComposeTarget
+		Service string `json:"service"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	output, err := a.docker.ComposeStop(ctx, p.ComposeTarget, p.Service)
+	return a.composeServiceResult(ctx, p.ComposeTarget, output, err), nil
+}
+
+// composeServiceResult builds the response for commands that change a
+// project's containers, including the container list after the change
+func (a *Agent) composeServiceResult(ctx context.Context, target docker.ComposeTarget, output string, err error) map[string]interface{} {
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"output":  output,
+			"error":   err.Error(),
+		}
+	}
+
+	result := map[string]interface{}{
+		"success": true,
+		"output":  output,
+	}
+	containers, err := a.docker.ComposePsProject(ctx, target)
+	if err != nil {
+		a.log.Warn("Failed to list compose containers", "project", target.String(), "error", err)
+	} else {
+		result["containers"] = containers
+	}
+	return result
+}
+
 func (a *Agent) handleDockerComposePull(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		ProjectPath string `json:"project_path"`
-		Service     string `json:"service"`
+		docker.ComposeTarget
+		Service string `json:"service"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	output, err := a.docker.ComposePull(ctx, p.ComposeTarget, p.Service)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"output":  output,
+			"error":   err.Error(),
+		}, nil
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"output":  output,
+	}, nil
+}
+
+func (a *Agent) handleDockerComposeBuild(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		docker.ComposeTarget
+		Service string `json:"service"`
+		NoCache bool   `json:"no_cache"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
 
-	output, err := a.docker.ComposePull(ctx, p.ProjectPath, p.Service)
+	output, err := a.docker.ComposeBuild(ctx, p.ComposeTarget, p.Service, p.NoCache)
 	if err != nil {
 		return map[string]interface{}{
 			"success": false,
@@ -810,13 +1952,71 @@ func (a *Agent) handleDockerComposePull(ctx context.Context, params json.RawMess
 	}, nil
 }
 
+func (a *Agent) handleDockerComposeConfig(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		docker.ComposeTarget
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	resolved, err := a.docker.ComposeConfig(ctx, p.ComposeTarget)
+	if err != nil {
+		var validationErr *docker.ComposeValidationError
+		if errors.As(err, &validationErr) {
+			return map[string]interface{}{
+				"success":          false,
+				"error_type":       "validation",
+				"validation_error": validationErr.Output,
+				"error":            err.Error(),
+			}, nil
+		}
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"config":  resolved,
+	}, nil
+}
+
+// handleDockerComposeValidate checks a compose file without creating
+// anything, so the UI can report YAML errors before a deploy
+func (a *Agent) handleDockerComposeValidate(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		docker.ComposeTarget
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	if err := a.docker.ComposeValidate(ctx, p.ComposeTarget); err != nil {
+		var validationErr *docker.ComposeValidationError
+		if errors.As(err, &validationErr) {
+			return map[string]interface{}{
+				"valid": false,
+				"error": validationErr.Output,
+			}, nil
+		}
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"valid": true,
+	}, nil
+}
+
 // Terminal command handlers
 
 func (a *Agent) handleTerminalCreate(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
-		SessionID string `json:"session_id"`
-		Cols      uint16 `json:"cols"`
-		Rows      uint16 `json:"rows"`
+		SessionID   string            `json:"session_id"`
+		Cols        uint16            `json:"cols"`
+		Rows        uint16            `json:"rows"`
+		Shell       string            `json:"shell"`
+		Cwd         string            `json:"cwd"`
+		Env         map[string]string `json:"env"`
+		ContainerID string            `json:"container_id"` // Open the shell inside this container
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
@@ -834,30 +2034,55 @@ func (a *Agent) handleTerminalCreate(ctx context.Context, params json.RawMessage
 		p.Rows = 24
 	}
 
-	// Create terminal session
-	session, err := a.terminal.CreateSession(p.SessionID, p.Cols, p.Rows)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create terminal session: %w", err)
+	var session *terminal.Session
+	var opts terminal.SessionOptions
+	shellFallback := false
+
+	if p.ContainerID != "" {
+		var err error
+		session, err = a.createContainerTerminal(ctx, p.SessionID, p.ContainerID, p.Shell, p.Cwd, p.Env, p.Cols, p.Rows)
+		if err != nil {
+			return nil, err
+		}
+		opts.Dir = p.Cwd
+	} else {
+		var err error
+		opts, shellFallback, err = a.terminalOptions(p.Shell, p.Cwd, p.Env)
+		if err != nil {
+			return nil, err
+		}
+
+		// Create terminal session
+		session, err = a.terminal.CreateSession(p.SessionID, p.Cols, p.Rows, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create terminal session: %w", err)
+		}
 	}
 
-	// Set up output handler to stream data back
+	// Set up output handler to stream data back. The send blocks while the
+	// WebSocket is congested, which in turn pauses reads from the PTY.
 	channel := fmt.Sprintf(protocol.ChannelTerminal, p.SessionID)
 	session.SetOutputHandler(func(data []byte) {
 		// Encode as base64 for safe transport
 		encoded := base64.StdEncoding.EncodeToString(data)
-		if err := a.ws.SendStream(channel, map[string]interface{}{
+		if err := a.ws.SendStreamWait(session.Context(), channel, map[string]interface{}{
 			"type": "output",
 			"data": encoded,
-		}); err != nil {
+		}); err != nil && err != context.Canceled {
 			a.log.Warn("Failed to send terminal output", "error", err)
 		}
 	})
 
 	// Set up close handler
 	session.SetCloseHandler(func() {
-		if err := a.ws.SendStream(channel, map[string]interface{}{
+		event := map[string]interface{}{
 			"type": "closed",
-		}); err != nil {
+		}
+		if reason := session.CloseReason(); reason != "" {
+			event["reason"] = reason
+			a.log.Info("Terminal session timed out", "session_id", p.SessionID, "reason", reason)
+		}
+		if err := a.ws.SendStream(channel, event); err != nil {
 			a.log.Warn("Failed to send terminal close event", "error", err)
 		}
 		// Clean up session
@@ -869,17 +2094,123 @@ func (a *Agent) handleTerminalCreate(ctx context.Context, params json.RawMessage
 		"cols", p.Cols,
 		"rows", p.Rows,
 		"shell", session.Shell,
+		"cwd", opts.Dir,
+		"container_id", p.ContainerID,
 	)
 
 	return map[string]interface{}{
-		"success":    true,
-		"session_id": p.SessionID,
-		"shell":      session.Shell,
-		"cols":       session.Cols,
-		"rows":       session.Rows,
+		"success":        true,
+		"session_id":     p.SessionID,
+		"shell":          session.Shell,
+		"shell_fallback": shellFallback,
+		"cwd":            opts.Dir,
+		"container_id":   p.ContainerID,
+		"cols":           session.Cols,
+		"rows":           session.Rows,
 	}, nil
 }
 
+// createContainerTerminal opens a terminal session on a shell exec'd inside
+// a container. The shell and working directory refer to the container's
+// filesystem, so they are not checked against the host.
+func (a *Agent) createContainerTerminal(ctx context.Context, sessionID, containerID, shell, cwd string, env map[string]string, cols, rows uint16) (*terminal.Session, error) {
+	if a.docker == nil {
+		return nil, fmt.Errorf("docker is not available")
+	}
+
+	vars, err := terminalEnv(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, exists := a.terminal.GetSession(sessionID); exists {
+		return nil, fmt.Errorf("failed to create terminal session: session %s already exists", sessionID)
+	}
+
+	proc, err := a.docker.ExecTTY(ctx, containerID, docker.ExecOptions{
+		Shell:      shell,
+		WorkingDir: cwd,
+		Env:        vars,
+	}, cols, rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create terminal session: %w", err)
+	}
+
+	if shell == "" {
+		shell = docker.DefaultExecShell
+	}
+
+	session, err := a.terminal.AttachSession(sessionID, shell, cols, rows, proc)
+	if err != nil {
+		proc.Close()
+		return nil, fmt.Errorf("failed to create terminal session: %w", err)
+	}
+	return session, nil
+}
+
+// terminalOptions validates the shell, working directory and environment
+// requested for a terminal. A missing shell falls back to the default shell
+// rather than failing; an invalid directory or variable is an error.
+func (a *Agent) terminalOptions(shell, cwd string, env map[string]string) (terminal.SessionOptions, bool, error) {
+	var opts terminal.SessionOptions
+	fallback := false
+
+	if shell != "" {
+		if _, err := exec.LookPath(shell); err != nil {
+			a.log.Warn("Requested shell not found, using default", "shell", shell)
+			fallback = true
+		} else {
+			opts.Shell = shell
+		}
+	}
+
+	if cwd != "" {
+		// Choosing a directory is file access; the allowed paths only
+		// narrow it once the feature is on
+		if !a.config().Features.FileAccess {
+			return opts, false, fmt.Errorf("file access disabled, cwd cannot be set")
+		}
+		info, err := os.Stat(cwd)
+		if err != nil {
+			return opts, false, fmt.Errorf("invalid cwd: %w", err)
+		}
+		if !info.IsDir() {
+			return opts, false, fmt.Errorf("invalid cwd: %s is not a directory", cwd)
+		}
+		if !a.config().Security.IsPathAllowed(cwd) {
+			return opts, false, fmt.Errorf("cwd %s is outside the allowed paths", cwd)
+		}
+		opts.Dir = cwd
+	}
+
+	vars, err := terminalEnv(env)
+	if err != nil {
+		return opts, false, err
+	}
+	opts.Env = vars
+
+	return opts, fallback, nil
+}
+
+// terminalEnv converts requested environment variables to KEY=VALUE
+// entries in a stable order
+func terminalEnv(env map[string]string) ([]string, error) {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		if key == "" || strings.ContainsAny(key, "=\x00") || strings.ContainsRune(env[key], 0) {
+			return nil, fmt.Errorf("invalid environment variable %q", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	vars := make([]string, 0, len(keys))
+	for _, key := range keys {
+		vars = append(vars, key+"="+env[key])
+	}
+	return vars, nil
+}
+
 func (a *Agent) handleTerminalInput(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
 		SessionID string `json:"session_id"`
@@ -966,15 +2297,34 @@ func (a *Agent) handleTerminalClose(ctx context.Context, params json.RawMessage)
 
 // GetStatus returns the current agent status for the IPC API
 func (a *Agent) GetStatus() ipc.AgentStatus {
+	cfg := a.config()
 	status := ipc.AgentStatus{
 		Running:    true,
 		Connected:  a.ws.IsConnected(),
-		Registered: a.cfg.Agent.ID != "",
-		AgentID:    a.cfg.Agent.ID,
-		AgentName:  a.cfg.Agent.Name,
-		ServerURL:  a.cfg.Server.URL,
+		Registered: cfg.Agent.ID != "",
+		AgentID:    cfg.Agent.ID,
+		AgentName:  cfg.Agent.Name,
+		ServerURL:  a.ws.URL(),
 		Uptime:     int64(time.Since(a.startTime).Seconds()),
 		Version:    Version,
+		Paused:     a.paused.Load(),
+
+		CommandsRunning: a.commands.running(),
+		CommandsQueued:  a.commands.waiting(),
+	}
+
+	a.connMu.Lock()
+	status.ConnectionState = string(a.connState)
+	a.connMu.Unlock()
+
+	if a.runState != nil {
+		status.LastRestartReason = string(a.runState.RestartReason())
+		status.RestartCount = a.runState.RestartCount()
+	}
+
+	status.UpdatePending = a.HasPendingUpdate()
+	if status.UpdatePending {
+		status.LatestVersion = a.GetLatestVersion()
 	}
 
 	// Collect current metrics if available
@@ -1023,10 +2373,13 @@ func (a *Agent) GetDetailedMetrics() *ipc.DetailedMetrics {
 			UsagePercent: sysMetrics.MemoryPercent,
 		},
 		Disk: ipc.DiskMetrics{
-			Total:        sysMetrics.DiskTotal,
-			Used:         sysMetrics.DiskUsed,
-			Free:         sysMetrics.DiskTotal - sysMetrics.DiskUsed,
-			UsagePercent: sysMetrics.DiskPercent,
+			Total:         sysMetrics.DiskTotal,
+			Used:          sysMetrics.DiskUsed,
+			Free:          sysMetrics.DiskTotal - sysMetrics.DiskUsed,
+			UsagePercent:  sysMetrics.DiskPercent,
+			InodesTotal:   sysMetrics.DiskInodesTotal,
+			InodesUsed:    sysMetrics.DiskInodesUsed,
+			InodesPercent: sysMetrics.DiskInodesPercent,
 		},
 		Network: ipc.NetworkMetrics{
 			BytesSent:   sysMetrics.NetworkTx,
@@ -1042,24 +2395,42 @@ func (a *Agent) GetDetailedMetrics() *ipc.DetailedMetrics {
 func (a *Agent) GetConnectionInfo() ipc.ConnectionInfo {
 	info := ipc.ConnectionInfo{
 		Connected:      a.ws.IsConnected(),
-		ServerURL:      a.cfg.Server.URL,
-		ReconnectCount: a.reconnectCount,
+		ServerURL:      a.ws.URL(),
+		ReconnectCount: a.ws.ReconnectCount(),
 	}
 
-	if !a.lastConnected.IsZero() {
-		info.LastConnected = a.lastConnected.UnixMilli()
+	if lastConnected := a.ws.LastConnected(); !lastConnected.IsZero() {
+		info.LastConnected = lastConnected.UnixMilli()
 	}
 
 	if session := a.ws.Session(); session != nil {
 		info.SessionExpires = session.ExpiresAt.UnixMilli()
 	}
 
+	a.connMu.Lock()
+	info.State = string(a.connState)
+	if !a.connStateSince.IsZero() {
+		info.StateSince = a.connStateSince.UnixMilli()
+	}
+	if !a.lastDisconnected.IsZero() {
+		info.LastDisconnected = a.lastDisconnected.UnixMilli()
+	}
+	a.connMu.Unlock()
+
+	last, avg := a.ws.Latency()
+	info.LatencyMs = float64(last.Microseconds()) / 1000
+	info.AvgLatencyMs = float64(avg.Microseconds()) / 1000
+
+	skew, known := a.ws.ClockSkew()
+	info.ClockSkewMs = skew.Milliseconds()
+	info.ClockSkewKnown = known
+
 	return info
 }
 
 // GetRecentLogs returns recent log lines from the log file
 func (a *Agent) GetRecentLogs(lines int) []string {
-	logFile := a.cfg.Logging.File
+	logFile := a.config().Logging.File
 	if logFile == "" {
 		return []string{}
 	}
@@ -1084,11 +2455,132 @@ func (a *Agent) GetRecentLogs(lines int) []string {
 	return allLines[len(allLines)-lines:]
 }
 
+// GetTerminalSessions returns the open terminal sessions for the IPC API
+func (a *Agent) GetTerminalSessions() []ipc.TerminalSession {
+	sessions := []ipc.TerminalSession{}
+	if a.terminal == nil {
+		return sessions
+	}
+
+	for _, info := range a.terminal.Sessions() {
+		sessions = append(sessions, ipc.TerminalSession{
+			ID:        info.ID,
+			Shell:     info.Shell,
+			Cols:      info.Cols,
+			Rows:      info.Rows,
+			CreatedAt: info.CreatedAt.UnixMilli(),
+		})
+	}
+	return sessions
+}
+
+// CloseTerminalSession forcibly closes a terminal session from the IPC API
+// and tells the server the session has ended
+func (a *Agent) CloseTerminalSession(id string) error {
+	if a.terminal == nil {
+		return fmt.Errorf("terminal support is disabled")
+	}
+
+	if err := a.terminal.CloseSession(id); err != nil {
+		return err
+	}
+
+	a.log.Info("Terminal session closed locally", "session_id", id)
+
+	channel := fmt.Sprintf(protocol.ChannelTerminal, id)
+	if err := a.ws.SendStream(channel, map[string]interface{}{
+		"type": "closed",
+	}); err != nil {
+		a.log.Warn("Failed to send terminal close event", "error", err)
+	}
+
+	return nil
+}
+
+// SetUpdateChecker makes pending-update state available over IPC and tells
+// the server when an installed update is about to stop the agent
+func (a *Agent) SetUpdateChecker(c *updater.UpdateChecker) {
+	a.updates = c
+	c.SetRestartHook(func() {
+		a.recordExit(runstate.ReasonUpdate)
+		a.ws.Shutdown(protocol.DisconnectReasonUpdate)
+	})
+}
+
+// SetRunState makes the restart count and reason available over IPC and
+// records why the agent stops when it restarts itself
+func (a *Agent) SetRunState(t *runstate.Tracker) {
+	a.runState = t
+}
+
+// recordExit stores why the agent is about to stop, if the state file is in
+// use
+func (a *Agent) recordExit(reason runstate.Reason) {
+	if err := a.runState.Exit(reason); err != nil {
+		a.log.Warn("Failed to record exit reason", "reason", reason, "error", err)
+	}
+}
+
+// HasPendingUpdate reports whether the update checker found a newer version
+func (a *Agent) HasPendingUpdate() bool {
+	return a.updates != nil && a.updates.HasPendingUpdate()
+}
+
+// GetLatestVersion returns the newest version seen by the update checker
+func (a *Agent) GetLatestVersion() string {
+	if a.updates == nil {
+		return ""
+	}
+	return a.updates.GetLatestVersion()
+}
+
+// InstallUpdate starts installing the pending update in the background.
+// A successful install restarts the agent.
+func (a *Agent) InstallUpdate() error {
+	if !a.HasPendingUpdate() {
+		return fmt.Errorf("no update pending")
+	}
+
+	a.log.Info("Update install requested via IPC", "version", a.GetLatestVersion())
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+		defer cancel()
+		if err := a.updates.Install(ctx); err != nil {
+			a.log.Error("Update install failed", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Pause stops heartbeats and metric streams. The connection stays up and
+// commands are still handled, so the agent remains manageable.
+func (a *Agent) Pause() error {
+	if a.paused.CompareAndSwap(false, true) {
+		a.log.Info("Agent paused")
+	}
+	return nil
+}
+
+// Resume restarts heartbeats and metric streams after Pause
+func (a *Agent) Resume() error {
+	if a.paused.CompareAndSwap(true, false) {
+		a.log.Info("Agent resumed")
+	}
+	return nil
+}
+
 // Restart initiates a graceful restart of the agent
 func (a *Agent) Restart() error {
 	a.log.Info("Restart requested via IPC")
+	return a.requestRestart(runstate.ReasonIPC)
+}
+
+// requestRestart stops Run so the service manager starts the agent again,
+// recording why
+func (a *Agent) requestRestart(reason runstate.Reason) error {
 	select {
 	case a.restartCh <- struct{}{}:
+		a.recordExit(reason)
 		return nil
 	default:
 		return fmt.Errorf("restart already in progress")
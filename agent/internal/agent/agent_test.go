@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/serverkit/agent/internal/config"
+	"github.com/serverkit/agent/internal/logger"
+	"github.com/serverkit/agent/pkg/protocol"
+)
+
+// newTestAgent builds an agent that is never connected, with state kept in
+// a temporary directory and Docker and IPC off
+func newTestAgent(t *testing.T) *Agent {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Server.URL = "ws://127.0.0.1:1/agent/ws"
+	cfg.Agent.ID = "test-agent"
+	cfg.Auth.KeyFile = filepath.Join(dir, "agent.key")
+	cfg.Features.Docker = false
+	cfg.IPC.Enabled = false
+	cfg.Logging.File = filepath.Join(dir, "agent.log")
+
+	a, err := New(cfg, logger.New(cfg.Logging))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return a
+}
+
+// A hand-written config with zero loop intervals must still start the
+// agent, with the loops falling back to config.MinInterval
+func TestZeroIntervalsStart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := []byte(`
+server:
+  url: ws://127.0.0.1:1/agent/ws
+  ping_interval: 0s
+agent:
+  id: test-agent
+auth:
+  key_file: ` + filepath.Join(dir, "agent.key") + `
+features:
+  docker: false
+metrics:
+  interval: 0s
+update:
+  enabled: true
+  check_interval: 0s
+logging:
+  file: ` + filepath.Join(dir, "agent.log") + `
+ipc:
+  enabled: false
+`)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate rejected zero intervals: %v", err)
+	}
+
+	a, err := New(cfg, logger.New(cfg.Logging))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for field, d := range map[string]time.Duration{
+		"server.ping_interval": cfg.Server.PingInterval,
+		"metrics.interval":     cfg.Metrics.Interval,
+	} {
+		if got := a.interval(field, d); got != config.MinInterval {
+			t.Errorf("%s: got %s, want %s", field, got, config.MinInterval)
+		}
+	}
+
+	// Run starts the heartbeat and metrics loops; a zero ticker interval
+	// would panic here
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := a.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run: got %v, want the context deadline", err)
+	}
+}
+
+// Input for a terminal session must reach it in arrival order, even while
+// long-running commands hold every command slot
+func TestTerminalInputOrder(t *testing.T) {
+	a := newTestAgent(t)
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	a.handlers[protocol.ActionTerminalInput] = func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p struct {
+			SessionID string `json:"session_id"`
+			Seq       int    `json:"seq"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		mu.Lock()
+		got[p.SessionID] = append(got[p.SessionID], p.Seq)
+		mu.Unlock()
+		return nil, nil
+	}
+
+	for i := 0; i < cap(a.commands.slots); i++ {
+		a.commands.slots <- struct{}{}
+	}
+
+	const n = 200
+	sessions := []string{"a", "b", "c"}
+	for seq := 0; seq < n; seq++ {
+		for _, session := range sessions {
+			data, err := json.Marshal(protocol.CommandMessage{
+				Message: protocol.NewMessage(protocol.TypeCommand, fmt.Sprintf("%s-%d", session, seq)),
+				Action:  protocol.ActionTerminalInput,
+				Params:  json.RawMessage(fmt.Sprintf(`{"session_id":%q,"seq":%d}`, session, seq)),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.handleMessage(protocol.TypeCommand, data)
+		}
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mu.Lock()
+		done := 0
+		for _, seqs := range got {
+			done += len(seqs)
+		}
+		mu.Unlock()
+		if done == n*len(sessions) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d inputs handled; terminal input is waiting for a command slot", done, n*len(sessions))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, session := range sessions {
+		for i, seq := range got[session] {
+			if seq != i {
+				t.Fatalf("session %s: input %d arrived as number %d", session, seq, i)
+			}
+		}
+	}
+}
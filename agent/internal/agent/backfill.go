@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/serverkit/agent/internal/metrics"
+	"github.com/serverkit/agent/pkg/protocol"
+)
+
+// metricsBuffer is a ring of metric samples taken while the agent is
+// disconnected, bounded by sample count and by their encoded size. Samples
+// are kept as JSON so the size bound is exact.
+type metricsBuffer struct {
+	mu       sync.Mutex
+	samples  []json.RawMessage
+	bytes    int
+	dropped  int // Samples pushed out since the last flush
+	maxCount int
+	maxBytes int
+}
+
+func newMetricsBuffer(maxCount, maxBytes int) *metricsBuffer {
+	return &metricsBuffer{maxCount: maxCount, maxBytes: maxBytes}
+}
+
+// add appends a sample, dropping the oldest until both limits hold. A
+// sample larger than the whole byte limit is dropped itself.
+func (b *metricsBuffer) add(sample json.RawMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(sample) > b.maxBytes {
+		b.dropped++
+		return
+	}
+	for len(b.samples) > 0 && (len(b.samples) >= b.maxCount || b.bytes+len(sample) > b.maxBytes) {
+		b.bytes -= len(b.samples[0])
+		b.samples[0] = nil
+		b.samples = b.samples[1:]
+		b.dropped++
+	}
+	b.samples = append(b.samples, sample)
+	b.bytes += len(sample)
+}
+
+// take empties the buffer, returning its samples oldest first and how many
+// were dropped to stay within the limits
+func (b *metricsBuffer) take() ([]json.RawMessage, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	samples, dropped := b.samples, b.dropped
+	b.samples, b.bytes, b.dropped = nil, 0, 0
+	return samples, dropped
+}
+
+// backfillLoop keeps collecting metrics while the agent is disconnected, so
+// the server can fill the gap in its history once the agent is back
+func (a *Agent) backfillLoop(ctx context.Context) {
+	a.metricsLoop(ctx, func() bool {
+		return !a.ws.IsConnected()
+	}, func(sysMetrics *metrics.SystemMetrics) {
+		data, err := json.Marshal(sysMetrics)
+		if err != nil {
+			a.log.Warn("Failed to buffer metrics sample", "error", err)
+			return
+		}
+		a.backfill.add(data)
+	})
+}
+
+// flushBackfill sends the samples buffered during an outage on the
+// metrics:backfill channel. Each carries its own collection timestamp.
+func (a *Agent) flushBackfill() {
+	if a.backfill == nil {
+		return
+	}
+	samples, dropped := a.backfill.take()
+	if len(samples) == 0 {
+		return
+	}
+
+	a.log.Info("Sending metrics collected while disconnected", "samples", len(samples), "dropped", dropped)
+	backfill := map[string]interface{}{
+		"samples": samples,
+		"count":   len(samples),
+		"dropped": dropped,
+	}
+	if err := a.ws.SendStream(protocol.ChannelBackfill, backfill); err != nil {
+		a.log.Warn("Failed to send metrics backfill", "error", err)
+	}
+}
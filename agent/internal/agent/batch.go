@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/serverkit/agent/pkg/protocol"
+)
+
+// batchWorkers bounds how many commands of a parallel batch run at once
+const batchWorkers = 4
+
+// handleBatchCommand runs every command in a batch through the regular
+// handler map and replies with a single batch_result. A failing command
+// only fails its own result; the rest of the batch still runs.
+func (a *Agent) handleBatchCommand(data []byte) {
+	var batch protocol.BatchCommandMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		a.log.Error("Failed to parse batch command", "error", err)
+		return
+	}
+
+	// The batch is signed as a whole, with its message type as the action
+	if err := a.verifyCommand(batch.Message, string(protocol.TypeBatchCommand)); err != nil {
+		results := make([]protocol.BatchCommandResult, len(batch.Commands))
+		for i, cmd := range batch.Commands {
+			results[i] = protocol.BatchCommandResult{CommandID: cmd.ID, Error: err.Error()}
+		}
+		a.ws.SendBatchResult(batch.ID, results, 0)
+		return
+	}
+
+	a.log.Info("Executing batch",
+		"id", batch.ID,
+		"commands", len(batch.Commands),
+		"parallel", batch.Parallel,
+	)
+
+	start := time.Now()
+	results := make([]protocol.BatchCommandResult, len(batch.Commands))
+
+	if batch.Parallel {
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < min(batchWorkers, len(batch.Commands)); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					results[i] = a.runBatchCommand(batch.Commands[i])
+				}
+			}()
+		}
+		for i := range batch.Commands {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+	} else {
+		for i, cmd := range batch.Commands {
+			results[i] = a.runBatchCommand(cmd)
+		}
+	}
+
+	duration := time.Since(start)
+	failed := 0
+	for _, r := range results {
+		if !r.Success {
+			failed++
+		}
+	}
+
+	a.log.Info("Batch completed",
+		"id", batch.ID,
+		"commands", len(results),
+		"failed", failed,
+		"duration", duration,
+	)
+	a.ws.SendBatchResult(batch.ID, results, duration)
+}
+
+// runBatchCommand executes one command of a batch, turning panics and
+// errors into a failed result
+func (a *Agent) runBatchCommand(cmd protocol.BatchCommand) (result protocol.BatchCommandResult) {
+	result.CommandID = cmd.ID
+
+	defer func() {
+		if r := recover(); r != nil {
+			a.log.Error("Batch command panicked", "id", cmd.ID, "action", cmd.Action, "panic", r)
+			result.Success = false
+			result.Data = nil
+			result.Error = "command panicked"
+		}
+	}()
+
+	data, duration, err := a.executeCommand(cmd.Action, cmd.Params, cmd.Timeout)
+	result.Duration = duration.Milliseconds()
+	if err != nil {
+		a.log.Warn("Batch command failed", "id", cmd.ID, "action", cmd.Action, "error", err)
+		result.Error = err.Error()
+		return result
+	}
+
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			result.Error = "failed to marshal data: " + err.Error()
+			return result
+		}
+		result.Data = encoded
+	}
+	result.Success = true
+	return result
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// errBusy is returned for commands that arrive while every slot is taken
+// and the queue is full
+var errBusy = errors.New("agent busy: too many commands in flight, retry later")
+
+// commandLimiter bounds how many commands run at once. Commands beyond the
+// limit wait in a bounded queue; past that they are rejected.
+type commandLimiter struct {
+	slots     chan struct{}
+	queued    atomic.Int32
+	maxQueued int32
+}
+
+func newCommandLimiter(maxConcurrent, maxQueued int) *commandLimiter {
+	// Validate rejects these, but a zero limit would hang every command
+	maxConcurrent = max(maxConcurrent, 1)
+	maxQueued = max(maxQueued, 0)
+	return &commandLimiter{
+		slots:     make(chan struct{}, maxConcurrent),
+		maxQueued: int32(maxQueued),
+	}
+}
+
+// acquire takes a slot, waiting in the queue if none is free. It returns
+// errBusy when the queue is full, or ctx's error if it ends first.
+func (l *commandLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if l.queued.Add(1) > l.maxQueued {
+		l.queued.Add(-1)
+		return errBusy
+	}
+	defer l.queued.Add(-1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire
+func (l *commandLimiter) release() {
+	<-l.slots
+}
+
+// running returns how many commands hold a slot
+func (l *commandLimiter) running() int {
+	return len(l.slots)
+}
+
+// waiting returns how many commands are queued for a slot
+func (l *commandLimiter) waiting() int {
+	return int(l.queued.Load())
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// projectFields reduces v to the dot-separated JSON paths in fields, e.g.
+// "State.Status" or "Config.Env", keeping the nesting of the full output so
+// callers read a projected result the same way as the original. Paths that
+// do not exist in v are left out.
+func projectFields(v interface{}, fields []string) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var full map[string]interface{}
+	if err := json.Unmarshal(data, &full); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{})
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		for _, key := range path {
+			if key == "" {
+				return nil, fmt.Errorf("invalid field %q", field)
+			}
+		}
+
+		value, ok := lookupPath(full, path)
+		if !ok {
+			continue
+		}
+		setPath(result, path, value)
+	}
+	return result, nil
+}
+
+// lookupPath follows path through nested JSON objects
+func lookupPath(obj map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = obj
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// setPath stores value under path in obj, creating intermediate objects.
+// A field nested under one that was already projected whole is already
+// present, so it is left alone.
+func setPath(obj map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			if _, taken := obj[key]; taken {
+				return
+			}
+			next = make(map[string]interface{})
+			obj[key] = next
+		}
+		obj = next
+	}
+	obj[path[len(path)-1]] = value
+}
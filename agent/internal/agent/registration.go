@@ -3,16 +3,17 @@ package agent
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/serverkit/agent/internal/auth"
 	"github.com/serverkit/agent/internal/config"
 	"github.com/serverkit/agent/internal/logger"
 	"github.com/serverkit/agent/internal/metrics"
@@ -20,7 +21,8 @@ import (
 
 // Registration handles agent registration with ServerKit
 type Registration struct {
-	log *logger.Logger
+	log       *logger.Logger
+	serverCfg config.ServerConfig
 }
 
 // RegistrationResult contains the result of registration
@@ -30,17 +32,60 @@ type RegistrationResult struct {
 	APIKey       string `json:"api_key"`
 	APISecret    string `json:"api_secret"`
 	WebSocketURL string `json:"websocket_url"`
+
+	// Optional regional relay to connect through, and further URLs to try
+	// when the preferred one keeps failing
+	RelayURL     string   `json:"relay_url,omitempty"`
+	FallbackURLs []string `json:"fallback_urls,omitempty"`
+
+	// Base64 Ed25519 seed generated for this agent in Ed25519 mode. It
+	// never leaves the host.
+	PrivateKey string `json:"-"`
 }
 
-// NewRegistration creates a new Registration handler
-func NewRegistration(log *logger.Logger) *Registration {
+// ConnectURLs returns the URL the agent should connect to and the ones to
+// fall back on, in order. A relay is preferred, with the control plane's
+// own URL as the first fallback.
+func (r *RegistrationResult) ConnectURLs() (string, []string) {
+	urls := []string{r.RelayURL, r.WebSocketURL}
+	urls = append(urls, r.FallbackURLs...)
+
+	var unique []string
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			unique = append(unique, u)
+		}
+	}
+	return unique[0], unique[1:]
+}
+
+// NewAuthenticator builds the authenticator for the configured auth mode
+func NewAuthenticator(cfg *config.Config) (*auth.Authenticator, error) {
+	if cfg.Auth.Mode != config.AuthModeEd25519 {
+		return auth.New(cfg.Agent.ID, cfg.Auth.APIKey, cfg.Auth.APISecret), nil
+	}
+
+	key, err := auth.ParsePrivateKey(cfg.Auth.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("auth.mode is ed25519 but %w; register the agent again", err)
+	}
+	return auth.NewEd25519(cfg.Agent.ID, cfg.Auth.APIKey, key), nil
+}
+
+// NewRegistration creates a new Registration handler. The server config
+// supplies outbound connection settings such as the proxy.
+func NewRegistration(log *logger.Logger, serverCfg config.ServerConfig) *Registration {
 	return &Registration{
-		log: log.WithComponent("registration"),
+		log:       log.WithComponent("registration"),
+		serverCfg: serverCfg,
 	}
 }
 
-// Register registers the agent with a ServerKit instance
-func (r *Registration) Register(serverURL, token, name string) (*RegistrationResult, error) {
+// Register registers the agent with a ServerKit instance. In Ed25519 auth
+// mode a key pair is generated and only the public key is sent.
+func (r *Registration) Register(serverURL, token, name, authMode string) (*RegistrationResult, error) {
 	// Normalize server URL
 	serverURL = strings.TrimSuffix(serverURL, "/")
 
@@ -86,20 +131,26 @@ func (r *Registration) Register(serverURL, token, name string) (*RegistrationRes
 		"agent_version": Version,
 	}
 
+	var privateKey string
+	if authMode == config.AuthModeEd25519 {
+		publicKey, seed, err := auth.GenerateKeyPair()
+		if err != nil {
+			return nil, err
+		}
+		privateKey = seed
+		reqBody["auth_mode"] = config.AuthModeEd25519
+		reqBody["public_key"] = publicKey
+	}
+
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP client
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				// Allow insecure for development - in production this should be strict
-				InsecureSkipVerify: strings.HasPrefix(serverURL, "http://") || strings.Contains(serverURL, "localhost"),
-			},
-		},
+	client, err := r.httpClient()
+	if err != nil {
+		return nil, err
 	}
 
 	// Make registration request
@@ -144,6 +195,8 @@ func (r *Registration) Register(serverURL, token, name string) (*RegistrationRes
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
+	result.PrivateKey = privateKey
+
 	// Construct WebSocket URL if not provided
 	if result.WebSocketURL == "" {
 		wsURL := serverURL
@@ -160,15 +213,18 @@ func (r *Registration) Register(serverURL, token, name string) (*RegistrationRes
 	return &result, nil
 }
 
-// Unregister unregisters the agent from ServerKit
-func (r *Registration) Unregister(serverURL, agentID, apiKey, apiSecret string) error {
+// Unregister unregisters the agent from ServerKit. With HMAC the API
+// secret is sent as before; in Ed25519 mode, which has no secret, the
+// request carries a signed timestamp and nonce instead.
+func (r *Registration) Unregister(serverURL, agentID string, authenticator *auth.Authenticator) error {
 	serverURL = strings.TrimSuffix(serverURL, "/")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	client, err := r.httpClient()
+	if err != nil {
+		return err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "DELETE", serverURL+"/api/v1/agents/"+agentID, nil)
@@ -176,8 +232,17 @@ func (r *Registration) Unregister(serverURL, agentID, apiKey, apiSecret string)
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("X-API-Key", apiKey)
-	req.Header.Set("X-API-Secret", apiSecret)
+	req.Header.Set("X-API-Key", authenticator.GetAPIKey())
+	if authenticator.Algorithm() == auth.AlgorithmHMACSHA256 {
+		req.Header.Set("X-API-Secret", authenticator.GetAPISecret())
+	} else {
+		timestamp := time.Now().UnixMilli()
+		nonce := auth.GenerateNonce()
+		req.Header.Set("X-Timestamp", strconv.FormatInt(timestamp, 10))
+		req.Header.Set("X-Nonce", nonce)
+		req.Header.Set("X-Signature", authenticator.SignMessageWithNonce(timestamp, nonce))
+		req.Header.Set("X-Signature-Algorithm", authenticator.Algorithm())
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -192,5 +257,21 @@ func (r *Registration) Unregister(serverURL, agentID, apiKey, apiSecret string)
 	return nil
 }
 
+// httpClient builds an HTTP client that honors the configured proxy and TLS settings
+func (r *Registration) httpClient() (*http.Client, error) {
+	tlsCfg, err := r.serverCfg.TLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           r.serverCfg.ProxyFunc(),
+			TLSClientConfig: tlsCfg,
+		},
+	}, nil
+}
+
 // Version is set during build
 var Version = "dev"
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/serverkit/agent/internal/auth"
+	"github.com/serverkit/agent/pkg/protocol"
+)
+
+const (
+	// rotationCheckInterval is how often the credential age is checked
+	rotationCheckInterval = time.Hour
+	// rotationRetryInterval spaces out rotation requests the server has
+	// not answered yet
+	rotationRetryInterval = 6 * time.Hour
+)
+
+// credentialRotationLoop asks the server for new credentials whenever the
+// current ones are older than security.rotate_every. The server answers
+// through the usual credential_update flow, which resets the age.
+func (a *Agent) credentialRotationLoop(ctx context.Context) {
+	ticker := time.NewTicker(rotationCheckInterval)
+	defer ticker.Stop()
+
+	var lastRequest time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cfg := a.config()
+		every := cfg.Security.RotateEvery
+		if every <= 0 || !a.ws.IsConnected() {
+			continue
+		}
+
+		issued := cfg.Auth.IssuedAt
+		if issued.IsZero() {
+			// Credentials saved before issue dates were tracked; start
+			// their clock now rather than rotating straight away
+			if err := a.saveCredentials(cfg.Auth.APIKey, cfg.Auth.APISecret); err != nil {
+				a.log.Warn("Failed to record credential issue date", "error", err)
+			}
+			continue
+		}
+
+		if time.Since(issued) < every || time.Since(lastRequest) < rotationRetryInterval {
+			continue
+		}
+		lastRequest = time.Now()
+
+		a.log.Info("Credentials due for rotation, requesting new ones",
+			"issued_at", issued.Format(time.RFC3339),
+			"rotate_every", every,
+		)
+		if err := a.ws.Send(protocol.RotateRequest{
+			Message:  protocol.NewMessage(protocol.TypeRotateRequest, auth.GenerateNonce()),
+			IssuedAt: issued.UnixMilli(),
+		}); err != nil {
+			a.log.Warn("Failed to send credential rotation request", "error", err)
+		}
+	}
+}
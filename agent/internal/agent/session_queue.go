@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/serverkit/agent/pkg/protocol"
+)
+
+// orderedTerminalActions must reach a terminal session in the order they
+// arrived, or keystrokes are scrambled and a close can overtake input
+var orderedTerminalActions = map[string]bool{
+	protocol.ActionTerminalInput:  true,
+	protocol.ActionTerminalResize: true,
+	protocol.ActionTerminalClose:  true,
+}
+
+// terminalSessionOf returns the session an ordered terminal command is
+// for, or false for any other command
+func terminalSessionOf(cmd protocol.CommandMessage) (string, bool) {
+	if !orderedTerminalActions[cmd.Action] {
+		return "", false
+	}
+	var p struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(cmd.Params, &p); err != nil || p.SessionID == "" {
+		return "", false
+	}
+	return p.SessionID, true
+}
+
+// sessionQueues runs work for each terminal session one item at a time, in
+// the order it was queued. Sessions do not wait for each other.
+type sessionQueues struct {
+	mu     sync.Mutex
+	queues map[string][]func() // Present while a goroutine drains it
+}
+
+func newSessionQueues() *sessionQueues {
+	return &sessionQueues{queues: make(map[string][]func())}
+}
+
+// run queues fn behind earlier work for session. A goroutine drains each
+// queue and exits once it is empty.
+func (q *sessionQueues) run(session string, fn func()) {
+	q.mu.Lock()
+	pending, draining := q.queues[session]
+	q.queues[session] = append(pending, fn)
+	q.mu.Unlock()
+
+	if !draining {
+		go q.drain(session)
+	}
+}
+
+func (q *sessionQueues) drain(session string) {
+	for {
+		q.mu.Lock()
+		pending := q.queues[session]
+		if len(pending) == 0 {
+			delete(q.queues, session)
+			q.mu.Unlock()
+			return
+		}
+		fn := pending[0]
+		q.queues[session] = pending[1:]
+		q.mu.Unlock()
+
+		fn()
+	}
+}
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/serverkit/agent/internal/config"
+	"github.com/serverkit/agent/internal/metrics"
+	"github.com/serverkit/agent/pkg/protocol"
+)
+
+const (
+	// systemInfoInterval is how often host facts are resent while
+	// connected, so OS upgrades and hardware changes reach the server
+	systemInfoInterval = 24 * time.Hour
+	// systemInfoTimeout bounds collecting the host facts
+	systemInfoTimeout = 30 * time.Second
+)
+
+// systemInfoLoop sends system info after every authentication and again
+// every systemInfoInterval while connected
+func (a *Agent) systemInfoLoop(ctx context.Context) {
+	ticker := time.NewTicker(systemInfoInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.sysInfoCh:
+			// Count the next daily send from this one
+			ticker.Reset(systemInfoInterval)
+		case <-ticker.C:
+			if !a.ws.IsConnected() {
+				continue
+			}
+		}
+
+		if err := a.sendSystemInfo(ctx); err != nil {
+			a.log.Warn("Failed to send system info", "error", err)
+		}
+	}
+}
+
+// requestSystemInfo asks systemInfoLoop to send system info now
+func (a *Agent) requestSystemInfo() {
+	select {
+	case a.sysInfoCh <- struct{}{}:
+	default:
+	}
+}
+
+// sendSystemInfo collects host facts and the Docker version and sends them
+// to the server
+func (a *Agent) sendSystemInfo(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, systemInfoTimeout)
+	defer cancel()
+
+	// The collector is only created with metrics enabled, but the host
+	// facts do not depend on its settings
+	collector := a.metrics
+	if collector == nil {
+		collector = metrics.NewCollector(config.MetricsConfig{}, a.log)
+	}
+	sysInfo, err := collector.GetSystemInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	info := protocol.SystemInfo{
+		Hostname:      sysInfo.Hostname,
+		OS:            sysInfo.OS,
+		OSVersion:     sysInfo.PlatformVersion,
+		Architecture:  sysInfo.Architecture,
+		CPUCores:      sysInfo.CPUCores,
+		TotalMemory:   sysInfo.TotalMemory,
+		TotalDisk:     sysInfo.TotalDisk,
+		AgentVersion:  Version,
+		Platform:      sysInfo.Platform,
+		KernelVersion: sysInfo.KernelVersion,
+		CPUModel:      sysInfo.CPUModel,
+		CPUThreads:    sysInfo.CPUThreads,
+	}
+	if a.docker != nil {
+		if version, err := a.docker.Version(ctx); err == nil {
+			info.DockerVersion = version
+		} else {
+			a.log.Debug("Docker version unavailable for system info", "error", err)
+		}
+	}
+
+	if err := a.ws.SendSystemInfo(info); err != nil {
+		return err
+	}
+	a.log.Debug("Sent system info",
+		"platform", info.Platform,
+		"os_version", info.OSVersion,
+		"kernel", info.KernelVersion,
+		"docker", info.DockerVersion,
+	)
+	return nil
+}
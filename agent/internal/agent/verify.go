@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/serverkit/agent/internal/auth"
+	"github.com/serverkit/agent/internal/config"
+	"github.com/serverkit/agent/pkg/protocol"
+)
+
+// errCommandRejected wraps every command verification failure
+var errCommandRejected = errors.New("command rejected")
+
+// newReplayCache sizes the cache for the verification window. A timestamp
+// is accepted up to maxAge either side of now, so an ID must be remembered
+// for twice that, however many commands arrive in the meantime.
+func newReplayCache(cfg config.SecurityConfig) *auth.ReplayCache {
+	return auth.NewReplayCache(2 * cfg.CommandMaxAge)
+}
+
+// verifyCommand checks a command's timestamp, signature and ID before it
+// runs. Failures are only logged unless security.command_verification is
+// "enforce", in which case the error must be returned to the server.
+func (a *Agent) verifyCommand(msg protocol.Message, action string) error {
+	cfg := a.config().Security
+	if cfg.CommandVerification == config.CommandVerificationOff {
+		return nil
+	}
+
+	err := a.checkCommand(msg, action, cfg.CommandMaxAge)
+	if err == nil {
+		return nil
+	}
+
+	if cfg.CommandVerification != config.CommandVerificationEnforce {
+		a.log.Warn("Command failed verification, running anyway", "id", msg.ID, "action", action, "error", err)
+		return nil
+	}
+	a.log.Warn("Rejecting command", "id", msg.ID, "action", action, "error", err)
+	return err
+}
+
+// checkCommand runs the individual checks. The ID is only recorded once
+// the rest pass, so a forged command cannot burn a real one's ID.
+func (a *Agent) checkCommand(msg protocol.Message, action string, maxAge time.Duration) error {
+	if msg.ID == "" {
+		return fmt.Errorf("%w: missing command id", errCommandRejected)
+	}
+	// With skew correction on, compare against the server's clock
+	timestamp := msg.Timestamp
+	if skew, ok := a.ws.ClockSkew(); ok && a.config().Server.ClockSkewCorrection {
+		timestamp -= skew.Milliseconds()
+	}
+	if !a.auth.VerifyTimestamp(timestamp, int64(maxAge/time.Second)) {
+		return fmt.Errorf("%w: timestamp outside the allowed %s window", errCommandRejected, maxAge)
+	}
+	if a.auth.Algorithm() == auth.AlgorithmHMACSHA256 {
+		if msg.Signature == "" {
+			return fmt.Errorf("%w: missing signature", errCommandRejected)
+		}
+		if !a.auth.VerifyCommand(msg.ID, action, msg.Timestamp, msg.Signature) {
+			return fmt.Errorf("%w: invalid signature", errCommandRejected)
+		}
+	}
+	if a.replay.Check(msg.ID) {
+		return fmt.Errorf("%w: command id already used", errCommandRejected)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/serverkit/agent/internal/runstate"
+	"github.com/serverkit/agent/internal/ws"
+)
+
+// watchdogMaxCheckInterval caps how long the watchdog waits between looks
+// at the connection
+const watchdogMaxCheckInterval = time.Minute
+
+// watchdogLoop restarts the agent once it has gone without an authenticated
+// connection for server.max_disconnected_before_restart. A fresh process
+// re-resolves DNS, reloads certificates and starts a new session, which
+// clears outages a reconnect alone does not.
+func (a *Agent) watchdogLoop(ctx context.Context) {
+	limit := a.config().Server.MaxDisconnectedBeforeRestart
+	if limit <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(min(limit/10, watchdogMaxCheckInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		down := a.disconnectedFor()
+		if down < limit {
+			continue
+		}
+
+		// Said plainly so the restart is not mistaken for a crash
+		a.log.Warn("Watchdog restarting the agent: no connection to the server for too long",
+			"disconnected_for", down.Round(time.Second),
+			"limit", limit,
+		)
+		if err := a.requestRestart(runstate.ReasonWatchdog); err != nil {
+			a.log.Warn("Watchdog restart not started", "error", err)
+		}
+		return
+	}
+}
+
+// disconnectedFor returns how long the agent has been without an
+// authenticated connection, counting from startup if it never had one
+func (a *Agent) disconnectedFor() time.Duration {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+
+	if a.connState == ws.StateAuthenticated {
+		return 0
+	}
+	since := a.lastDisconnected
+	if since.IsZero() {
+		since = a.startTime
+	}
+	return time.Since(since)
+}
@@ -1,48 +1,62 @@
 package auth
 
 import (
-	"crypto/hmac"
+	"crypto/ed25519"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"time"
 )
 
-// Authenticator handles HMAC-based authentication
+// Authenticator signs agent messages with HMAC (the default) or Ed25519
 type Authenticator struct {
 	agentID   string
 	apiKey    string
 	apiSecret string
+	signer    Signer
 }
 
-// New creates a new Authenticator
+// New creates an Authenticator that signs with HMAC using apiSecret
 func New(agentID, apiKey, apiSecret string) *Authenticator {
 	return &Authenticator{
 		agentID:   agentID,
 		apiKey:    apiKey,
 		apiSecret: apiSecret,
+		signer:    NewHMACSigner(apiSecret),
 	}
 }
 
-// SignMessage creates an HMAC signature for authentication
-// The signature is computed as: HMAC-SHA256(agent_id:timestamp, api_secret)
+// NewEd25519 creates an Authenticator that signs with privateKey. There is
+// no shared secret in this mode.
+func NewEd25519(agentID, apiKey string, privateKey ed25519.PrivateKey) *Authenticator {
+	return &Authenticator{
+		agentID: agentID,
+		apiKey:  apiKey,
+		signer:  NewEd25519Signer(privateKey),
+	}
+}
+
+// SignMessage creates a signature for authentication over agent_id:timestamp
 func (a *Authenticator) SignMessage(timestamp int64) string {
 	message := fmt.Sprintf("%s:%d", a.agentID, timestamp)
-	return a.computeHMAC(message)
+	return a.signer.Sign(message)
 }
 
-// SignMessageWithNonce creates an HMAC signature including a nonce for replay protection
-// The signature is computed as: HMAC-SHA256(agent_id:timestamp:nonce, api_secret)
+// SignMessageWithNonce creates a signature including a nonce for replay
+// protection, over agent_id:timestamp:nonce
 func (a *Authenticator) SignMessageWithNonce(timestamp int64, nonce string) string {
 	message := fmt.Sprintf("%s:%d:%s", a.agentID, timestamp, nonce)
-	return a.computeHMAC(message)
+	return a.signer.Sign(message)
 }
 
-// UpdateCredentials updates the API credentials
+// UpdateCredentials updates the API credentials. In Ed25519 mode only the
+// API key changes; the key pair is fixed at registration.
 func (a *Authenticator) UpdateCredentials(apiKey, apiSecret string) {
 	a.apiKey = apiKey
-	a.apiSecret = apiSecret
+	if a.signer.Algorithm() == AlgorithmHMACSHA256 {
+		a.apiSecret = apiSecret
+		a.signer = NewHMACSigner(apiSecret)
+	}
 }
 
 // GetAPIKey returns the full API key
@@ -50,22 +64,35 @@ func (a *Authenticator) GetAPIKey() string {
 	return a.apiKey
 }
 
-// GetAPISecret returns the API secret
+// GetAPISecret returns the API secret, empty in Ed25519 mode
 func (a *Authenticator) GetAPISecret() string {
 	return a.apiSecret
 }
 
-// SignCommand creates an HMAC signature for a command
+// Algorithm returns the signature algorithm in use
+func (a *Authenticator) Algorithm() string {
+	return a.signer.Algorithm()
+}
+
+// SignCommand creates a signature for a command
 // Used to verify commands weren't tampered with
 func (a *Authenticator) SignCommand(commandID string, action string, timestamp int64) string {
 	message := fmt.Sprintf("%s:%s:%d", commandID, action, timestamp)
-	return a.computeHMAC(message)
+	return a.signer.Sign(message)
+}
+
+// VerifyCommand checks a command signature made by SignCommand. Only HMAC
+// commands can be verified: in Ed25519 mode the server has no key of ours
+// to sign with.
+func (a *Authenticator) VerifyCommand(commandID string, action string, timestamp int64, signature string) bool {
+	message := fmt.Sprintf("%s:%s:%d", commandID, action, timestamp)
+	return a.signer.Verify(message, signature)
 }
 
-// VerifySignature verifies an HMAC signature
+// VerifySignature verifies a signature made with this authenticator's
+// credentials
 func (a *Authenticator) VerifySignature(message, signature string) bool {
-	expected := a.computeHMAC(message)
-	return hmac.Equal([]byte(expected), []byte(signature))
+	return a.signer.Verify(message, signature)
 }
 
 // VerifyTimestamp checks if a timestamp is within acceptable range
@@ -93,12 +120,6 @@ func (a *Authenticator) AgentID() string {
 	return a.agentID
 }
 
-func (a *Authenticator) computeHMAC(message string) string {
-	h := hmac.New(sha256.New, []byte(a.apiSecret))
-	h.Write([]byte(message))
-	return hex.EncodeToString(h.Sum(nil))
-}
-
 // GenerateNonce generates a cryptographically random nonce for request uniqueness
 func GenerateNonce() string {
 	b := make([]byte, 16)
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayCache remembers recently seen nonces so a captured message cannot
+// be sent again. Entries older than the window are forgotten; messages that
+// old are expected to fail the timestamp check instead. Nothing younger is
+// ever evicted, so memory grows with the message rate times the window.
+type ReplayCache struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	order  []string // Insertion order, oldest first, for pruning
+}
+
+// NewReplayCache creates a cache holding nonces for window
+func NewReplayCache(window time.Duration) *ReplayCache {
+	return &ReplayCache{
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Check records nonce and reports whether it was already seen within the
+// window
+func (c *ReplayCache) Check(nonce string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.prune(now)
+
+	if _, ok := c.seen[nonce]; ok {
+		return true
+	}
+
+	c.seen[nonce] = now
+	c.order = append(c.order, nonce)
+	return false
+}
+
+// prune drops entries older than the window
+func (c *ReplayCache) prune(now time.Time) {
+	drop := 0
+	for drop < len(c.order) && now.Sub(c.seen[c.order[drop]]) > c.window {
+		delete(c.seen, c.order[drop])
+		drop++
+	}
+	if drop > 0 {
+		c.order = append(c.order[:0], c.order[drop:]...)
+	}
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// Signature algorithms reported to the server in the auth message
+const (
+	AlgorithmHMACSHA256 = "hmac-sha256"
+	AlgorithmEd25519    = "ed25519"
+)
+
+// Signer signs and verifies messages for one authentication mode.
+// Signatures are hex encoded.
+type Signer interface {
+	Sign(message string) string
+	Verify(message, signature string) bool
+	Algorithm() string
+}
+
+// hmacSigner signs with a secret shared with the server
+type hmacSigner struct {
+	secret []byte
+}
+
+// NewHMACSigner returns a signer computing HMAC-SHA256 with secret
+func NewHMACSigner(secret string) Signer {
+	return &hmacSigner{secret: []byte(secret)}
+}
+
+func (s *hmacSigner) Sign(message string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(message))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *hmacSigner) Verify(message, signature string) bool {
+	expected := s.Sign(message)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func (s *hmacSigner) Algorithm() string {
+	return AlgorithmHMACSHA256
+}
+
+// ed25519Signer signs with a private key only the agent holds. The server
+// verifies with the public key submitted at registration.
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewEd25519Signer returns a signer using the given private key
+func NewEd25519Signer(key ed25519.PrivateKey) Signer {
+	return &ed25519Signer{key: key}
+}
+
+func (s *ed25519Signer) Sign(message string) string {
+	return hex.EncodeToString(ed25519.Sign(s.key, []byte(message)))
+}
+
+func (s *ed25519Signer) Verify(message, signature string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(s.key.Public().(ed25519.PublicKey), []byte(message), sig)
+}
+
+func (s *ed25519Signer) Algorithm() string {
+	return AlgorithmEd25519
+}
+
+// GenerateKeyPair creates an Ed25519 key pair for registration. The public
+// key is returned base64 encoded for the server, the private key as the
+// base64 seed that ParsePrivateKey reads.
+func GenerateKeyPair() (publicKey, privateKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate Ed25519 key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(pub),
+		base64.StdEncoding.EncodeToString(priv.Seed()), nil
+}
+
+// ParsePrivateKey decodes a base64 Ed25519 seed or full private key
+func ParsePrivateKey(encoded string) (ed25519.PrivateKey, error) {
+	if encoded == "" {
+		return nil, fmt.Errorf("no Ed25519 private key stored")
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ed25519 private key: %w", err)
+	}
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("invalid Ed25519 private key length %d", len(raw))
+	}
+}
@@ -5,29 +5,44 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
+	"github.com/zalando/go-keyring"
+	"golang.org/x/net/http/httpproxy"
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds all agent configuration
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Agent    AgentConfig    `yaml:"agent"`
-	Auth     AuthConfig     `yaml:"auth"`
-	Features FeaturesConfig `yaml:"features"`
-	Metrics  MetricsConfig  `yaml:"metrics"`
-	Docker   DockerConfig   `yaml:"docker"`
-	Security SecurityConfig `yaml:"security"`
-	Logging  LoggingConfig  `yaml:"logging"`
-	Update   UpdateConfig   `yaml:"update"`
-	IPC      IPCConfig      `yaml:"ipc"`
+	Server     ServerConfig     `yaml:"server"`
+	Agent      AgentConfig      `yaml:"agent"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Features   FeaturesConfig   `yaml:"features"`
+	Metrics    MetricsConfig    `yaml:"metrics"`
+	Docker     DockerConfig     `yaml:"docker"`
+	Security   SecurityConfig   `yaml:"security"`
+	Terminal   TerminalConfig   `yaml:"terminal"`
+	Logging    LoggingConfig    `yaml:"logging"`
+	Update     UpdateConfig     `yaml:"update"`
+	IPC        IPCConfig        `yaml:"ipc"`
+	Tray       TrayConfig       `yaml:"tray"`
+	Prometheus PrometheusConfig `yaml:"prometheus"`
 }
 
 // ServerConfig holds connection settings
@@ -35,23 +50,72 @@ type ServerConfig struct {
 	URL                  string        `yaml:"url"`
 	ReconnectInterval    time.Duration `yaml:"reconnect_interval"`
 	MaxReconnectInterval time.Duration `yaml:"max_reconnect_interval"`
+	ReconnectJitter      string        `yaml:"reconnect_jitter"` // full, decorrelated or none
 	PingInterval         time.Duration `yaml:"ping_interval"`
-	InsecureSkipVerify   bool          `yaml:"insecure_skip_verify"` // For dev only
+	InsecureSkipVerify   bool          `yaml:"insecure_skip_verify"`     // For dev only
+	Compression          bool          `yaml:"compression"`              // Negotiate permessage-deflate
+	Proxy                string        `yaml:"proxy,omitempty"`          // http://, https:// or socks5:// proxy URL
+	CACertFile           string        `yaml:"ca_cert_file,omitempty"`   // PEM bundle trusted instead of system roots
+	PinnedSHA256         string        `yaml:"pinned_sha256,omitempty"`  // Expected SHA-256 of the server's leaf certificate
+	ResultQueueSize      int           `yaml:"result_queue_size"`        // Command results kept for redelivery after a reconnect, 0 disables
+	ClockSkewCorrection  bool          `yaml:"clock_skew_correction"`    // Offset signed timestamps by the clock skew measured at auth
+	MaxMessageSize       int           `yaml:"max_message_size_mb"`      // Largest message accepted from the server, in megabytes
+	FallbackURLs         []string      `yaml:"fallback_urls,omitempty"`  // Tried in turn when URL keeps failing
+	CompressResultsOver  int           `yaml:"compress_results_over_kb"` // Gzip command result data larger than this, 0 disables
+
+	// Restart the agent when no authenticated connection has existed for
+	// this long; 0 disables the watchdog
+	MaxDisconnectedBeforeRestart time.Duration `yaml:"max_disconnected_before_restart"`
+}
+
+// Reconnect jitter strategies
+const (
+	JitterFull         = "full"         // Uniform between zero and the exponential backoff
+	JitterDecorrelated = "decorrelated" // Uniform between the base interval and 3x the previous delay
+	JitterNone         = "none"         // Plain exponential backoff, for deterministic tests
+)
+
+// ValidJitter reports whether strategy is a known reconnect jitter strategy
+func ValidJitter(strategy string) bool {
+	switch strategy {
+	case JitterFull, JitterDecorrelated, JitterNone:
+		return true
+	}
+	return false
 }
 
 // AgentConfig holds agent identity
 type AgentConfig struct {
-	ID   string `yaml:"id"`
-	Name string `yaml:"name"`
+	ID      string `yaml:"id"`
+	Name    string `yaml:"name"`
+	PIDFile string `yaml:"pid_file"` // Locked while the agent runs; stops a second instance
+
+	// StateFile records starts and exit reasons across runs
+	StateFile string `yaml:"state_file"`
 }
 
 // AuthConfig holds authentication credentials
 type AuthConfig struct {
-	KeyFile   string `yaml:"key_file"`
-	APIKey    string `yaml:"api_key,omitempty"`    // Not saved to config file
-	APISecret string `yaml:"api_secret,omitempty"` // Not saved to config file
+	Mode       string `yaml:"mode"` // hmac or ed25519, fixed at registration
+	KeyFile    string `yaml:"key_file"`
+	APIKey     string `yaml:"api_key,omitempty"`     // Not saved to config file
+	APISecret  string `yaml:"api_secret,omitempty"`  // Not saved to config file
+	PrivateKey string `yaml:"private_key,omitempty"` // Base64 Ed25519 seed; not saved to config file
+
+	// IssuedAt is when the credentials were issued, kept with them in the
+	// key file or keyring. Zero for credentials saved before it was tracked.
+	IssuedAt time.Time `yaml:"-"`
 }
 
+// Auth modes
+const (
+	// AuthModeHMAC signs with the API secret shared with the server
+	AuthModeHMAC = "hmac"
+	// AuthModeEd25519 signs with a private key only the agent holds; the
+	// server keeps the public key submitted at registration
+	AuthModeEd25519 = "ed25519"
+)
+
 // FeaturesConfig controls enabled features
 type FeaturesConfig struct {
 	Docker     bool `yaml:"docker"`
@@ -59,6 +123,11 @@ type FeaturesConfig struct {
 	Logs       bool `yaml:"logs"`
 	FileAccess bool `yaml:"file_access"`
 	Exec       bool `yaml:"exec"`
+	Prometheus bool `yaml:"prometheus"` // Serve /metrics for Prometheus scrapes
+
+	// ReadOnly keeps only commands that list, inspect or read, whatever
+	// else is enabled. See readOnlyActions in the agent package.
+	ReadOnly bool `yaml:"read_only"`
 }
 
 // MetricsConfig controls metrics collection
@@ -67,43 +136,157 @@ type MetricsConfig struct {
 	Interval          time.Duration `yaml:"interval"`
 	IncludePerCPU     bool          `yaml:"include_per_cpu"`
 	IncludeDockerStats bool         `yaml:"include_docker_stats"`
+	IncludePressure    bool         `yaml:"include_pressure"` // Linux PSI from /proc/pressure
+	WatchProcesses     []string     `yaml:"watch_processes,omitempty"` // "name" or "name:cmdline substring"
+	DiskPaths          []string     `yaml:"disk_paths,omitempty"`      // Mounts reported besides the root filesystem
+	AutoPush           bool         `yaml:"auto_push"`                 // Send metrics every interval without a subscription
+	IncludeConnections bool         `yaml:"include_connections"`       // TCP socket counts by state, on the metrics interval only
+
+	// How long each CPU reading samples for. 0 reports usage since the
+	// previous collection without blocking; a blocking read is more
+	// accurate for short spikes but delays every collection by this much.
+	CPUSampleInterval time.Duration `yaml:"cpu_sample_interval"`
+
+	// Samples kept while disconnected and sent on the metrics:backfill
+	// channel after reconnecting. The oldest are dropped once either limit
+	// is reached; 0 samples disables the buffer.
+	BackfillSamples int `yaml:"backfill_samples"`
+	BackfillMaxKB   int `yaml:"backfill_max_kb"`
 }
 
 // DockerConfig holds Docker connection settings
 type DockerConfig struct {
-	Socket  string        `yaml:"socket"`
-	Timeout time.Duration `yaml:"timeout"`
+	Socket           string        `yaml:"socket"`
+	Timeout          time.Duration `yaml:"timeout"`
+	EventHistorySize int           `yaml:"event_history_size"` // Recent events kept for docker:events:history
+
+	// Containers restarted this many times within the window are reported
+	// as crash-looping in heartbeats
+	CrashLoopRestarts int           `yaml:"crash_loop_restarts"`
+	CrashLoopWindow   time.Duration `yaml:"crash_loop_window"`
 }
 
 // SecurityConfig holds security settings
 type SecurityConfig struct {
 	AllowedPaths    []string      `yaml:"allowed_paths"`
 	BlockedCommands []string      `yaml:"blocked_commands"`
+	AllowedActions  []string      `yaml:"allowed_actions"` // Command actions this agent runs, empty allows all; "*" wildcards
+	DeniedActions   []string      `yaml:"denied_actions"`  // Command actions refused even if allowed
 	MaxExecTimeout  time.Duration `yaml:"max_exec_timeout"`
+	CredentialStore string        `yaml:"credential_store"` // "machine" or "keyring"
+	RotateEvery     time.Duration `yaml:"rotate_every"`     // Ask the server for new credentials this often, 0 disables
+
+	// Commands run at once, and how many more may wait before new ones
+	// are rejected as busy
+	MaxConcurrentCommands int `yaml:"max_concurrent_commands"`
+	MaxQueuedCommands     int `yaml:"max_queued_commands"`
+
+	// Check command signatures, timestamps and IDs before running them:
+	// "off", "log" to only report failures, or "enforce" to reject them
+	CommandVerification string        `yaml:"command_verification"`
+	CommandMaxAge       time.Duration `yaml:"command_max_age"` // Clock difference tolerated on command timestamps
+}
+
+// Command verification modes
+const (
+	CommandVerificationOff     = "off"
+	CommandVerificationLog     = "log"
+	CommandVerificationEnforce = "enforce"
+)
+
+// Credential store backends
+const (
+	// CredentialStoreMachine encrypts credentials with a key derived from the
+	// hostname and machine ID and stores them in Auth.KeyFile
+	CredentialStoreMachine = "machine"
+	// CredentialStoreKeyring stores credentials in the OS credential store
+	// (Secret Service, macOS Keychain, Windows Credential Manager)
+	CredentialStoreKeyring = "keyring"
+)
+
+// keyringService is the service name used for OS keyring entries
+const keyringService = "serverkit-agent"
+
+// TerminalConfig holds remote terminal session settings
+type TerminalConfig struct {
+	IdleTimeout time.Duration `yaml:"idle_timeout"` // Close sessions with no input for this long (0 = no limit)
+	MaxLifetime time.Duration `yaml:"max_lifetime"` // Close sessions older than this (0 = no limit)
+	AuditLog    string        `yaml:"audit_log"`    // Append session input to this file (empty = disabled)
+	AuditOutput bool          `yaml:"audit_output"` // Also record session output in the audit log
 }
 
 // LoggingConfig holds logging settings
 type LoggingConfig struct {
-	Level      string `yaml:"level"`
-	File       string `yaml:"file"`
-	MaxSize    int    `yaml:"max_size_mb"`
-	MaxBackups int    `yaml:"max_backups"`
-	MaxAge     int    `yaml:"max_age_days"`
-	Compress   bool   `yaml:"compress"`
+	Level        string `yaml:"level"`         // File level, and console level unless ConsoleLevel is set
+	ConsoleLevel string `yaml:"console_level"` // Stdout level; empty uses Level
+	Format       string `yaml:"format"`        // Console format: auto, text or json
+	Syslog       string `yaml:"syslog"`        // System log sink: off, syslog, journald or eventlog
+	File         string `yaml:"file"`
+	MaxSize      int    `yaml:"max_size_mb"`
+	MaxBackups   int    `yaml:"max_backups"`
+	MaxAge       int    `yaml:"max_age_days"`
+	Compress     bool   `yaml:"compress"`
 }
 
+// Console log formats. The log file is always JSON.
+const (
+	LogFormatAuto = "auto" // Text on a terminal, JSON otherwise
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// System log sinks for logging.syslog. They log at logging.level,
+// alongside the file.
+const (
+	LogSyslogOff = "off"
+	LogSyslog    = "syslog"   // Local syslog daemon (Unix)
+	LogJournald  = "journald" // systemd journal, with native severities (Linux)
+	LogEventLog  = "eventlog" // Windows Application event log
+)
+
 // UpdateConfig holds auto-update settings
 type UpdateConfig struct {
 	Enabled       bool          `yaml:"enabled"`
 	CheckInterval time.Duration `yaml:"check_interval"`
 	AutoInstall   bool          `yaml:"auto_install"`
+	Channel       string        `yaml:"channel"`    // "stable", "beta" or "nightly"
+	PublicKey     string        `yaml:"public_key"` // minisign public key; when set, updates must be signed
+}
+
+// Update channels
+const (
+	UpdateChannelStable  = "stable"
+	UpdateChannelBeta    = "beta"
+	UpdateChannelNightly = "nightly"
+)
+
+// ValidUpdateChannel reports whether channel is a known update channel
+func ValidUpdateChannel(channel string) bool {
+	switch channel {
+	case UpdateChannelStable, UpdateChannelBeta, UpdateChannelNightly:
+		return true
+	}
+	return false
 }
 
 // IPCConfig holds local IPC server settings for tray app communication
 type IPCConfig struct {
-	Enabled bool   `yaml:"enabled"`
+	Enabled   bool   `yaml:"enabled"`
+	Port      int    `yaml:"port"` // 0 lets the OS pick a free port
+	Address   string `yaml:"address"`
+	Socket    string `yaml:"socket"`     // Unix socket or named pipe; replaces address/port when set
+	TokenFile string `yaml:"token_file"` // Shared secret written at startup for the tray
+}
+
+// PrometheusConfig holds the Prometheus metrics endpoint settings
+type PrometheusConfig struct {
+	Address string `yaml:"address"` // Bind address; 0.0.0.0 allows scrapes from other hosts
 	Port    int    `yaml:"port"`
-	Address string `yaml:"address"`
+}
+
+// TrayConfig holds system tray app settings
+type TrayConfig struct {
+	Notifications bool `yaml:"notifications"` // Show desktop notifications for agent events
 }
 
 // Default returns default configuration
@@ -112,10 +295,20 @@ func Default() *Config {
 		Server: ServerConfig{
 			ReconnectInterval:    5 * time.Second,
 			MaxReconnectInterval: 5 * time.Minute,
+			ReconnectJitter:      JitterFull,
 			PingInterval:         30 * time.Second,
+			Compression:          true,
+			ResultQueueSize:      256,
+			CompressResultsOver:  64,
+			// Fits a base64 docker:container:copy upload of the largest archive
+			MaxMessageSize: 32,
+		},
+		Agent: AgentConfig{
+			PIDFile:   defaultPIDPath(),
+			StateFile: defaultStatePath(),
 		},
-		Agent: AgentConfig{},
 		Auth: AuthConfig{
+			Mode:    AuthModeHMAC,
 			KeyFile: defaultKeyPath(),
 		},
 		Features: FeaturesConfig{
@@ -124,24 +317,42 @@ func Default() *Config {
 			Logs:       true,
 			FileAccess: false,
 			Exec:       false,
+			Prometheus: false,
 		},
 		Metrics: MetricsConfig{
 			Enabled:           true,
 			Interval:          10 * time.Second,
 			IncludePerCPU:     true,
 			IncludeDockerStats: true,
+			BackfillSamples:    30,
+			BackfillMaxKB:      256,
 		},
 		Docker: DockerConfig{
-			Socket:  defaultDockerSocket(),
-			Timeout: 30 * time.Second,
+			Socket:           defaultDockerSocket(),
+			Timeout:          30 * time.Second,
+			EventHistorySize: 1000,
+
+			CrashLoopRestarts: 3,
+			CrashLoopWindow:   10 * time.Minute,
 		},
 		Security: SecurityConfig{
 			AllowedPaths:    []string{},
 			BlockedCommands: []string{},
+			AllowedActions:  []string{},
+			DeniedActions:   []string{},
 			MaxExecTimeout:  5 * time.Minute,
+			CredentialStore: CredentialStoreMachine,
+
+			MaxConcurrentCommands: 16,
+			MaxQueuedCommands:     64,
+
+			CommandVerification: CommandVerificationOff,
+			CommandMaxAge:       5 * time.Minute,
 		},
 		Logging: LoggingConfig{
 			Level:      "info",
+			Format:     LogFormatAuto,
+			Syslog:     LogSyslogOff,
 			File:       defaultLogPath(),
 			MaxSize:    100,
 			MaxBackups: 5,
@@ -152,17 +363,44 @@ func Default() *Config {
 			Enabled:       true,
 			CheckInterval: 1 * time.Hour,
 			AutoInstall:   false, // Require manual confirmation by default
+			Channel:       UpdateChannelStable,
 		},
 		IPC: IPCConfig{
-			Enabled: true,
-			Port:    19780,
+			Enabled:   true,
+			Port:      19780,
+			Address:   "127.0.0.1",
+			TokenFile: defaultIPCTokenPath(),
+		},
+		Tray: TrayConfig{
+			Notifications: true,
+		},
+		Prometheus: PrometheusConfig{
 			Address: "127.0.0.1",
+			Port:    19781,
 		},
 	}
 }
 
-// Load loads configuration from file
+// Load loads configuration from file. Values are layered as defaults, then
+// the file, then SERVERKIT_* environment variables; command line flags are
+// applied on top by the caller.
 func Load(path string) (*Config, error) {
+	cfg, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Environment variables override the file and stored credentials
+	if err := cfg.ApplyEnv(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// LoadFile loads configuration from file without environment overrides,
+// for callers that save the config back
+func LoadFile(path string) (*Config, error) {
 	if path == "" {
 		path = DefaultConfigPath()
 	}
@@ -205,6 +443,7 @@ func (c *Config) Save(path string) error {
 	safeCfg := *c
 	safeCfg.Auth.APIKey = ""
 	safeCfg.Auth.APISecret = ""
+	safeCfg.Auth.PrivateKey = ""
 
 	data, err := yaml.Marshal(&safeCfg)
 	if err != nil {
@@ -224,6 +463,9 @@ func (c *Config) Print() {
 	safeCfg := *c
 	safeCfg.Auth.APIKey = "[REDACTED]"
 	safeCfg.Auth.APISecret = "[REDACTED]"
+	if safeCfg.Auth.PrivateKey != "" {
+		safeCfg.Auth.PrivateKey = "[REDACTED]"
+	}
 
 	data, _ := yaml.Marshal(&safeCfg)
 	fmt.Println(string(data))
@@ -231,14 +473,84 @@ func (c *Config) Print() {
 
 // SaveCredentials saves API credentials securely
 func (c *Config) SaveCredentials() error {
-	if c.Auth.APIKey == "" || c.Auth.APISecret == "" {
+	if c.Auth.APIKey == "" || (c.Auth.APISecret == "" && c.Auth.PrivateKey == "") {
+		return nil
+	}
+
+	// Create credential data
+	stored := storedCredentials{
+		APIKey:     c.Auth.APIKey,
+		APISecret:  c.Auth.APISecret,
+		PrivateKey: c.Auth.PrivateKey,
+	}
+	if !c.Auth.IssuedAt.IsZero() {
+		stored.IssuedAt = c.Auth.IssuedAt.UnixMilli()
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	creds := string(data)
+
+	switch c.Security.CredentialStore {
+	case CredentialStoreKeyring:
+		if err := keyring.Set(keyringService, c.keyringUser(), creds); err != nil {
+			return fmt.Errorf("failed to save credentials to OS keyring: %w", err)
+		}
 		return nil
+	case CredentialStoreMachine, "":
+		return c.writeKeyFile(creds)
+	default:
+		return fmt.Errorf("unknown credential store: %s", c.Security.CredentialStore)
 	}
+}
 
-	keyPath := c.Auth.KeyFile
-	if keyPath == "" {
-		keyPath = defaultKeyPath()
+// LoadCredentials loads API credentials from secure storage
+func (c *Config) LoadCredentials() error {
+	switch c.Security.CredentialStore {
+	case CredentialStoreKeyring:
+		return c.loadKeyringCredentials()
+	case CredentialStoreMachine, "":
+		creds, err := c.readKeyFile()
+		if err != nil {
+			return err
+		}
+		return c.setCredentials(creds)
+	default:
+		return fmt.Errorf("unknown credential store: %s", c.Security.CredentialStore)
 	}
+}
+
+// loadKeyringCredentials reads credentials from the OS keyring. Credentials
+// still in a machine-key encrypted file are moved into the keyring on first load.
+func (c *Config) loadKeyringCredentials() error {
+	creds, err := keyring.Get(keyringService, c.keyringUser())
+	if err == nil {
+		return c.setCredentials(creds)
+	}
+	if !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("failed to read credentials from OS keyring: %w", err)
+	}
+
+	// Migrate from the machine-key store
+	creds, err = c.readKeyFile()
+	if err != nil {
+		return err
+	}
+	if err := c.setCredentials(creds); err != nil {
+		return err
+	}
+	if err := keyring.Set(keyringService, c.keyringUser(), creds); err != nil {
+		return fmt.Errorf("failed to migrate credentials to OS keyring: %w", err)
+	}
+	os.Remove(c.keyFilePath())
+
+	return nil
+}
+
+// writeKeyFile encrypts credentials with the machine key and writes the key file
+func (c *Config) writeKeyFile(creds string) error {
+	keyPath := c.keyFilePath()
 
 	// Ensure directory exists
 	dir := filepath.Dir(keyPath)
@@ -246,9 +558,6 @@ func (c *Config) SaveCredentials() error {
 		return fmt.Errorf("failed to create key directory: %w", err)
 	}
 
-	// Create credential data
-	creds := fmt.Sprintf("%s:%s", c.Auth.APIKey, c.Auth.APISecret)
-
 	// Encrypt credentials using machine-specific key
 	encrypted, err := encryptCredentials([]byte(creds))
 	if err != nil {
@@ -263,42 +572,269 @@ func (c *Config) SaveCredentials() error {
 	return nil
 }
 
-// LoadCredentials loads API credentials from secure storage
-func (c *Config) LoadCredentials() error {
-	keyPath := c.Auth.KeyFile
-	if keyPath == "" {
-		keyPath = defaultKeyPath()
-	}
-
-	data, err := os.ReadFile(keyPath)
+// readKeyFile reads and decrypts the machine-key encrypted key file
+func (c *Config) readKeyFile() (string, error) {
+	data, err := os.ReadFile(c.keyFilePath())
 	if err != nil {
-		return fmt.Errorf("failed to read key file: %w", err)
+		return "", fmt.Errorf("failed to read key file: %w", err)
 	}
 
 	// Decrypt credentials
 	decrypted, err := decryptCredentials(data)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt credentials: %w", err)
+		return "", fmt.Errorf("failed to decrypt credentials: %w", err)
+	}
+
+	return string(decrypted), nil
+}
+
+// storedCredentials is the credential blob kept in the key file or keyring
+type storedCredentials struct {
+	APIKey     string `json:"api_key"`
+	APISecret  string `json:"api_secret"`
+	PrivateKey string `json:"private_key,omitempty"` // Ed25519 mode only
+	IssuedAt   int64  `json:"issued_at,omitempty"`   // Unix milliseconds
+}
+
+// setCredentials parses stored credential data into the auth config
+func (c *Config) setCredentials(creds string) error {
+	stored, err := parseCredentials(creds)
+	if err != nil {
+		return err
 	}
 
-	// Parse credentials
-	var apiKey, apiSecret string
-	if _, err := fmt.Sscanf(string(decrypted), "%s:%s", &apiKey, &apiSecret); err != nil {
-		// Try splitting by colon
-		parts := splitFirst(string(decrypted), ':')
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid credentials format")
+	c.Auth.APIKey = stored.APIKey
+	c.Auth.APISecret = stored.APISecret
+	c.Auth.PrivateKey = stored.PrivateKey
+	c.Auth.IssuedAt = time.Time{}
+	if stored.IssuedAt > 0 {
+		c.Auth.IssuedAt = time.UnixMilli(stored.IssuedAt)
+	}
+
+	return nil
+}
+
+// parseCredentials decodes the JSON credential blob, falling back to the
+// legacy "key:secret" format. Keys never contain a colon, so the legacy form
+// is split on the first one and the secret keeps any others.
+func parseCredentials(creds string) (*storedCredentials, error) {
+	creds = strings.TrimSpace(creds)
+
+	if strings.HasPrefix(creds, "{") {
+		var stored storedCredentials
+		if err := json.Unmarshal([]byte(creds), &stored); err != nil {
+			return nil, fmt.Errorf("invalid credentials format: %w", err)
 		}
-		apiKey = parts[0]
-		apiSecret = parts[1]
+		if stored.APIKey == "" || (stored.APISecret == "" && stored.PrivateKey == "") {
+			return nil, fmt.Errorf("invalid credentials format: missing key or secret")
+		}
+		return &stored, nil
+	}
+
+	parts := splitFirst(creds, ':')
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid credentials format")
+	}
+	return &storedCredentials{APIKey: parts[0], APISecret: parts[1]}, nil
+}
+
+func (c *Config) keyFilePath() string {
+	if c.Auth.KeyFile == "" {
+		return defaultKeyPath()
+	}
+	return c.Auth.KeyFile
+}
+
+// keyringUser is the account name credentials are stored under in the OS keyring
+func (c *Config) keyringUser() string {
+	if c.Agent.ID == "" {
+		return "default"
 	}
+	return c.Agent.ID
+}
 
-	c.Auth.APIKey = apiKey
-	c.Auth.APISecret = apiSecret
+// DeleteCredentials removes stored API credentials from the key file and OS keyring
+func (c *Config) DeleteCredentials() error {
+	if err := os.Remove(c.keyFilePath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove key file: %w", err)
+	}
 
+	if c.Security.CredentialStore == CredentialStoreKeyring {
+		err := keyring.Delete(keyringService, c.keyringUser())
+		if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return fmt.Errorf("failed to remove credentials from OS keyring: %w", err)
+		}
+	}
+
+	c.Auth.APIKey = ""
+	c.Auth.APISecret = ""
+	c.Auth.PrivateKey = ""
+	c.Auth.IssuedAt = time.Time{}
 	return nil
 }
 
+// HTTPBaseURL derives the control plane's HTTP base URL from the WebSocket URL
+// (wss://example.com/agent/ws -> https://example.com)
+func (s ServerConfig) HTTPBaseURL() string {
+	base := s.URL
+	base = strings.Replace(base, "wss://", "https://", 1)
+	base = strings.Replace(base, "ws://", "http://", 1)
+	base = strings.TrimSuffix(base, "/")
+	base = strings.TrimSuffix(base, "/agent/ws")
+	base = strings.TrimSuffix(base, "/agent")
+	return base
+}
+
+// ProxyFunc returns the proxy selection function for outbound connections.
+// An explicit Proxy wins; otherwise HTTP_PROXY, HTTPS_PROXY and NO_PROXY are
+// honored, with ALL_PROXY as the fallback for both schemes.
+func (s ServerConfig) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	if s.Proxy != "" {
+		raw := s.Proxy
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw
+		}
+		proxyURL, err := url.Parse(raw)
+		if err != nil {
+			err = fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		return func(*http.Request) (*url.URL, error) {
+			return proxyURL, err
+		}
+	}
+
+	env := httpproxy.FromEnvironment()
+	allProxy := os.Getenv("ALL_PROXY")
+	if allProxy == "" {
+		allProxy = os.Getenv("all_proxy")
+	}
+	if env.HTTPProxy == "" {
+		env.HTTPProxy = allProxy
+	}
+	if env.HTTPSProxy == "" {
+		env.HTTPSProxy = allProxy
+	}
+
+	proxyFunc := env.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// TLSConfig builds the TLS settings for connections to the control plane.
+// CACertFile replaces the system roots with a private CA bundle. PinnedSHA256
+// requires the server's leaf certificate to have that fingerprint; without a
+// CA bundle the pin alone is trusted, so self-signed certificates work.
+func (s ServerConfig) TLSConfig() (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: s.InsecureSkipVerify,
+	}
+
+	if s.CACertFile != "" {
+		data, err := os.ReadFile(s.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no valid certificates found in %s", s.CACertFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if s.PinnedSHA256 != "" {
+		pin, err := parseFingerprint(s.PinnedSHA256)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.CACertFile == "" {
+			tlsCfg.InsecureSkipVerify = true
+		}
+
+		tlsCfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("server presented no certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if subtle.ConstantTimeCompare(sum[:], pin) != 1 {
+				return fmt.Errorf("server certificate fingerprint %s does not match pinned fingerprint",
+					hex.EncodeToString(sum[:]))
+			}
+			return nil
+		}
+	}
+
+	return tlsCfg, nil
+}
+
+// parseFingerprint decodes a hex SHA-256 fingerprint, allowing colon
+// separators as printed by openssl
+func parseFingerprint(value string) ([]byte, error) {
+	cleaned := strings.ReplaceAll(strings.TrimSpace(value), ":", "")
+	pin, err := hex.DecodeString(cleaned)
+	if err != nil || len(pin) != sha256.Size {
+		return nil, fmt.Errorf("invalid pinned_sha256: expected %d hex-encoded bytes", sha256.Size)
+	}
+	return pin, nil
+}
+
+// IsPathAllowed reports whether path lies within one of AllowedPaths.
+// An empty AllowedPaths list places no restriction. Symlinks are resolved
+// so a link cannot be used to escape an allowed directory.
+func (s SecurityConfig) IsPathAllowed(path string) bool {
+	if len(s.AllowedPaths) == 0 {
+		return true
+	}
+
+	resolved, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if real, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = real
+	}
+
+	for _, allowed := range s.AllowedPaths {
+		base, err := filepath.Abs(allowed)
+		if err != nil {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(base); err == nil {
+			base = real
+		}
+
+		rel, err := filepath.Rel(base, resolved)
+		if err != nil {
+			continue
+		}
+		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActionAllowed reports whether a command action may run. Patterns use
+// path.Match syntax, so "docker:container:*" covers every container action.
+// A denied match always wins; an empty AllowedActions list allows the rest.
+func (s SecurityConfig) IsActionAllowed(action string) bool {
+	if matchAction(s.DeniedActions, action) {
+		return false
+	}
+	return len(s.AllowedActions) == 0 || matchAction(s.AllowedActions, action)
+}
+
+// matchAction reports whether action matches any of patterns
+func matchAction(patterns []string, action string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, action); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // DefaultConfigPath returns the default config file path
 func DefaultConfigPath() string {
 	if runtime.GOOS == "windows" {
@@ -314,6 +850,27 @@ func defaultKeyPath() string {
 	return "/etc/serverkit-agent/agent.key"
 }
 
+func defaultIPCTokenPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "ServerKit", "Agent", "ipc.token")
+	}
+	return "/etc/serverkit-agent/ipc.token"
+}
+
+func defaultPIDPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "ServerKit", "Agent", "agent.pid")
+	}
+	return "/run/serverkit-agent/agent.pid"
+}
+
+func defaultStatePath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "ServerKit", "Agent", "state.json")
+	}
+	return "/etc/serverkit-agent/state.json"
+}
+
 func defaultDockerSocket() string {
 	if runtime.GOOS == "windows" {
 		return "npipe:////./pipe/docker_engine"
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseCredentialsSecretColons(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+	}{
+		{"no colons", "s3cr3t"},
+		{"one colon", "s3c:r3t"},
+		{"several colons", ":s3:c::r3t:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Current format, as written by SaveCredentials
+			data, err := json.Marshal(storedCredentials{APIKey: "sk_key", APISecret: tt.secret})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseCredentials(string(data))
+			if err != nil {
+				t.Fatalf("parseCredentials(json): %v", err)
+			}
+			if got.APIKey != "sk_key" || got.APISecret != tt.secret {
+				t.Errorf("json: got %q/%q, want %q/%q", got.APIKey, got.APISecret, "sk_key", tt.secret)
+			}
+
+			// Legacy key:secret format splits on the first colon only
+			got, err = parseCredentials("sk_key:" + tt.secret)
+			if err != nil {
+				t.Fatalf("parseCredentials(legacy): %v", err)
+			}
+			if got.APIKey != "sk_key" || got.APISecret != tt.secret {
+				t.Errorf("legacy: got %q/%q, want %q/%q", got.APIKey, got.APISecret, "sk_key", tt.secret)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvPrefix prefixes every environment variable the agent reads
+const EnvPrefix = "SERVERKIT_"
+
+// Environment variables read outside the config file overlay
+const (
+	// EnvAgentToken holds the registration token for `register`
+	EnvAgentToken = EnvPrefix + "AGENT_TOKEN"
+	// EnvServerURL is the server URL; `register` also reads it
+	EnvServerURL = EnvPrefix + "SERVER_URL"
+	// EnvAgentName is the display name; `register` also reads it
+	EnvAgentName = EnvPrefix + "AGENT_NAME"
+)
+
+// envOverride maps one environment variable onto a config field
+type envOverride struct {
+	name  string
+	apply func(c *Config, value string) error
+}
+
+// envOverrides lists the variables applied on top of the config file
+var envOverrides = []envOverride{
+	{EnvServerURL, func(c *Config, v string) error { c.Server.URL = v; return nil }},
+	{EnvPrefix + "SERVER_PROXY", func(c *Config, v string) error { c.Server.Proxy = v; return nil }},
+	{EnvPrefix + "SERVER_CA_CERT_FILE", func(c *Config, v string) error { c.Server.CACertFile = v; return nil }},
+	{EnvPrefix + "SERVER_PINNED_SHA256", func(c *Config, v string) error { c.Server.PinnedSHA256 = v; return nil }},
+	{EnvPrefix + "SERVER_PING_INTERVAL", func(c *Config, v string) error { return setDuration(&c.Server.PingInterval, v) }},
+	{EnvPrefix + "AGENT_ID", func(c *Config, v string) error { c.Agent.ID = v; return nil }},
+	{EnvAgentName, func(c *Config, v string) error { c.Agent.Name = v; return nil }},
+	{EnvPrefix + "API_KEY", func(c *Config, v string) error { c.Auth.APIKey = v; return nil }},
+	{EnvPrefix + "API_SECRET", func(c *Config, v string) error { c.Auth.APISecret = v; return nil }},
+	{EnvPrefix + "FEATURES_DOCKER", func(c *Config, v string) error { return setBool(&c.Features.Docker, v) }},
+	{EnvPrefix + "FEATURES_EXEC", func(c *Config, v string) error { return setBool(&c.Features.Exec, v) }},
+	{EnvPrefix + "FEATURES_PROMETHEUS", func(c *Config, v string) error { return setBool(&c.Features.Prometheus, v) }},
+	{EnvPrefix + "METRICS_INTERVAL", func(c *Config, v string) error { return setDuration(&c.Metrics.Interval, v) }},
+	{EnvPrefix + "DOCKER_SOCKET", func(c *Config, v string) error { c.Docker.Socket = v; return nil }},
+	{EnvPrefix + "LOG_LEVEL", func(c *Config, v string) error { c.Logging.Level = v; return nil }},
+	{EnvPrefix + "LOG_CONSOLE_LEVEL", func(c *Config, v string) error { c.Logging.ConsoleLevel = v; return nil }},
+	{EnvPrefix + "LOG_FORMAT", func(c *Config, v string) error { c.Logging.Format = v; return nil }},
+	{EnvPrefix + "LOG_SYSLOG", func(c *Config, v string) error { c.Logging.Syslog = v; return nil }},
+	{EnvPrefix + "LOG_FILE", func(c *Config, v string) error { c.Logging.File = v; return nil }},
+	{EnvPrefix + "UPDATE_ENABLED", func(c *Config, v string) error { return setBool(&c.Update.Enabled, v) }},
+	{EnvPrefix + "UPDATE_CHANNEL", func(c *Config, v string) error { c.Update.Channel = v; return nil }},
+	{EnvPrefix + "IPC_ENABLED", func(c *Config, v string) error { return setBool(&c.IPC.Enabled, v) }},
+}
+
+// ApplyEnv overrides config values with SERVERKIT_* environment variables.
+// Unset and empty variables leave the value from the file in place.
+func (c *Config) ApplyEnv() error {
+	for _, o := range envOverrides {
+		value := os.Getenv(o.name)
+		if value == "" {
+			continue
+		}
+		if err := o.apply(c, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", o.name, err)
+		}
+	}
+	return nil
+}
+
+func setDuration(dst *time.Duration, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*dst = d
+	return nil
+}
+
+func setBool(dst *bool, value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*dst = b
+	return nil
+}
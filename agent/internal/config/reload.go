@@ -0,0 +1,53 @@
+package config
+
+import "reflect"
+
+// Reloaded returns a copy of c with the settings that can change while the
+// agent runs taken from next: log levels, metrics interval, update settings,
+// allowed paths and allowed or denied actions. Everything else keeps its
+// running value.
+func (c *Config) Reloaded(next *Config) *Config {
+	updated := *c
+	updated.Logging.Level = next.Logging.Level
+	updated.Logging.ConsoleLevel = next.Logging.ConsoleLevel
+	updated.Metrics.Interval = next.Metrics.Interval
+	updated.Update = next.Update
+	updated.Security.AllowedPaths = next.Security.AllowedPaths
+	updated.Security.AllowedActions = next.Security.AllowedActions
+	updated.Security.DeniedActions = next.Security.DeniedActions
+	return &updated
+}
+
+// RestartRequired lists the config sections that differ between c and next
+// in ways a reload cannot apply
+func (c *Config) RestartRequired(next *Config) []string {
+	// Compare with the reloadable settings already applied, so only
+	// the remaining differences are reported
+	applied := c.Reloaded(next)
+
+	sections := []struct {
+		name      string
+		old, next interface{}
+	}{
+		{"server", applied.Server, next.Server},
+		{"agent", applied.Agent, next.Agent},
+		{"auth", applied.Auth, next.Auth},
+		{"features", applied.Features, next.Features},
+		{"metrics", applied.Metrics, next.Metrics},
+		{"docker", applied.Docker, next.Docker},
+		{"security", applied.Security, next.Security},
+		{"terminal", applied.Terminal, next.Terminal},
+		{"logging", applied.Logging, next.Logging},
+		{"ipc", applied.IPC, next.IPC},
+		{"tray", applied.Tray, next.Tray},
+		{"prometheus", applied.Prometheus, next.Prometheus},
+	}
+
+	var changed []string
+	for _, s := range sections {
+		if !reflect.DeepEqual(s.old, s.next) {
+			changed = append(changed, s.name)
+		}
+	}
+	return changed
+}
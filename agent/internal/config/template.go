@@ -0,0 +1,204 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateHeader opens the file written by Template
+const templateHeader = `# ServerKit Agent configuration
+#
+# Every setting is listed with its default. Values can also be set with
+# SERVERKIT_* environment variables, which take precedence over this file.
+# Check changes with: serverkit-agent config validate
+`
+
+// templateOmit lists fields left out of the template. Credentials are
+// written by register to the key file or keyring, never to this file.
+var templateOmit = map[string]bool{
+	"auth.api_key":     true,
+	"auth.api_secret":  true,
+	"auth.private_key": true,
+}
+
+// fieldDocs is the description Template writes above each setting, keyed
+// by YAML path. Add an entry here with every new config field.
+var fieldDocs = map[string]string{
+	"server":                                 "Connection to the ServerKit control plane",
+	"server.url":                             "WebSocket URL of the control plane, set by register",
+	"server.reconnect_interval":              "First delay before reconnecting after the connection drops",
+	"server.max_reconnect_interval":          "Longest delay between reconnect attempts",
+	"server.reconnect_jitter":                "Randomization of reconnect delays: full, decorrelated or none",
+	"server.ping_interval":                   "How often a heartbeat is sent",
+	"server.insecure_skip_verify":            "Skip TLS certificate verification; for development only",
+	"server.compression":                     "Negotiate permessage-deflate compression",
+	"server.proxy":                           "http://, https:// or socks5:// proxy URL; empty uses HTTP(S)_PROXY / ALL_PROXY",
+	"server.ca_cert_file":                    "PEM bundle trusted instead of the system roots",
+	"server.pinned_sha256":                   "Expected SHA-256 fingerprint of the server's certificate",
+	"server.result_queue_size":               "Command results kept for redelivery after a reconnect, 0 disables",
+	"server.clock_skew_correction":           "Offset signed timestamps by the clock skew measured at auth",
+	"server.max_message_size_mb":             "Largest message accepted from the server, in megabytes",
+	"server.fallback_urls":                   "URLs tried in turn after 3 failed attempts on the current one",
+	"server.compress_results_over_kb":        "Gzip command results larger than this for servers that support it, 0 disables",
+	"server.max_disconnected_before_restart": "Restart the agent after this long without a connection, 0 disables",
+
+	"agent":            "Identity of this agent",
+	"agent.id":         "Agent ID assigned at registration",
+	"agent.name":       "Display name in the dashboard",
+	"agent.pid_file":   "Locked while the agent runs so a second instance refuses to start",
+	"agent.state_file": "Restart count and last restart reason, kept across runs",
+
+	"auth":          "Credentials; the API key and secret are kept in key_file or the OS keyring",
+	"auth.mode":     "Signing mode, hmac or ed25519, fixed at registration",
+	"auth.key_file": "Encrypted credential file used by the machine credential store",
+
+	"features":             "Capabilities the agent offers to the server",
+	"features.docker":      "Manage Docker containers, images, volumes and networks",
+	"features.metrics":     "Collect and stream system metrics",
+	"features.logs":        "Stream container and agent logs",
+	"features.file_access": "Allow copying files in and out of containers and choosing a terminal's cwd",
+	"features.exec":        "Allow command execution and remote terminals",
+	"features.prometheus":  "Serve /metrics for Prometheus scrapes",
+	"features.read_only":   "Keep only list, inspect, stats, logs and metrics commands",
+
+	"metrics":                      "System metrics collection",
+	"metrics.enabled":              "Collect system metrics",
+	"metrics.interval":             "How often metrics are collected for streams",
+	"metrics.include_per_cpu":      "Report usage of each CPU core",
+	"metrics.include_docker_stats": "Include container stats in Prometheus metrics",
+	"metrics.include_pressure":     "Report CPU, memory and IO pressure stall info (Linux only)",
+	"metrics.watch_processes":      `Processes reported with every sample, as "name" or "name:cmdline substring"`,
+	"metrics.disk_paths":           "Mounts reported besides the root filesystem; missing ones are skipped",
+	"metrics.auto_push":            "Send metrics every interval without a subscription",
+	"metrics.include_connections":  "Count TCP sockets by state with each interval sample",
+	"metrics.cpu_sample_interval":  "How long each CPU reading blocks; 0 reports usage since the last sample",
+	"metrics.backfill_samples":     "Samples kept while disconnected and sent after reconnecting, 0 disables",
+	"metrics.backfill_max_kb":      "Memory cap for those samples; the oldest are dropped first",
+
+	"docker":                     "Docker daemon connection",
+	"docker.socket":              "Docker socket or named pipe",
+	"docker.timeout":             "Timeout for each Docker API request; log and event streams have none",
+	"docker.event_history_size":  "Recent Docker events kept for docker:events:history",
+	"docker.crash_loop_restarts": "Restarts within crash_loop_window that mark a container as crash-looping",
+	"docker.crash_loop_window":   "Window in which crash_loop_restarts are counted",
+
+	"security":                         "Limits on what the server may ask the agent to do",
+	"security.allowed_paths":           "Absolute host paths file commands may touch",
+	"security.blocked_commands":        "Commands refused by exec",
+	"security.allowed_actions":         `Command actions this agent runs, empty allows all; "*" wildcards`,
+	"security.denied_actions":          "Command actions refused even if allowed",
+	"security.max_exec_timeout":        "Longest time an exec command may run",
+	"security.credential_store":        "Where credentials are stored: machine or keyring",
+	"security.rotate_every":            "Ask the server for new credentials this often, 0 disables",
+	"security.max_concurrent_commands": "Commands run at once",
+	"security.max_queued_commands":     "Commands waiting for a free slot before new ones are rejected as busy",
+	"security.command_verification":    "Check command signatures, timestamps and IDs: off, log or enforce",
+	"security.command_max_age":         "Clock difference tolerated on command timestamps",
+
+	"terminal":              "Remote terminal sessions",
+	"terminal.idle_timeout": "Close sessions with no input for this long, 0 never",
+	"terminal.max_lifetime": "Close sessions older than this, 0 never",
+	"terminal.audit_log":    "Append session input to this file, empty disables",
+	"terminal.audit_output": "Also record session output in the audit log",
+
+	"logging":               "Agent logs",
+	"logging.level":         "Log level: debug, info, warn or error",
+	"logging.console_level": "Level for stdout; empty uses level",
+	"logging.format":        "Console format: auto (text on a terminal), text or json",
+	"logging.syslog":        "Also log to: off, syslog, journald (Linux) or eventlog (Windows)",
+	"logging.file":          "Log file, always JSON",
+	"logging.max_size_mb":   "Rotate the log file at this size",
+	"logging.max_backups":   "Rotated log files kept",
+	"logging.max_age_days":  "Delete rotated log files older than this",
+	"logging.compress":      "Gzip rotated log files",
+
+	"update":                "Agent self-update",
+	"update.enabled":        "Check for new versions",
+	"update.check_interval": "How often to check",
+	"update.auto_install":   "Install updates without confirmation",
+	"update.channel":        "Release channel: stable, beta or nightly",
+	"update.public_key":     "minisign public key; when set, updates must carry a valid signature",
+
+	"ipc":            "Local API used by the tray app and status commands",
+	"ipc.enabled":    "Serve the local API",
+	"ipc.port":       "Localhost port; 0 lets the OS pick a free one",
+	"ipc.address":    "Bind address; only localhost is allowed",
+	"ipc.socket":     "Unix socket or named pipe; replaces address and port when set",
+	"ipc.token_file": "Shared secret written at startup for the tray",
+
+	"tray":               "System tray app",
+	"tray.notifications": "Show desktop notifications for agent events",
+
+	"prometheus":         "Prometheus endpoint, enabled by features.prometheus",
+	"prometheus.address": "Bind address; 0.0.0.0 allows scrapes from other hosts",
+	"prometheus.port":    "Port of the /metrics endpoint",
+}
+
+// Template returns the default configuration as YAML, with a comment
+// describing every setting
+func Template() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(templateHeader)
+
+	cfg := reflect.ValueOf(*Default())
+	for i := 0; i < cfg.NumField(); i++ {
+		section := &yaml.Node{Kind: yaml.MappingNode}
+		if err := addTemplateField(section, cfg.Type().Field(i), cfg.Field(i), ""); err != nil {
+			return nil, err
+		}
+
+		// One document per section, so sections are separated by a
+		// blank line
+		buf.WriteString("\n")
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(section); err != nil {
+			return nil, fmt.Errorf("failed to encode config template: %w", err)
+		}
+		if err := enc.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode config template: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// addTemplateField appends field to mapping as a key, with its
+// description as a comment, and its value. Nested config sections are
+// expanded field by field so fields left out by omitempty still appear.
+func addTemplateField(mapping *yaml.Node, field reflect.StructField, value reflect.Value, prefix string) error {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" || name == "-" {
+		return nil
+	}
+	path := name
+	if prefix != "" {
+		path = prefix + "." + name
+	}
+	if templateOmit[path] {
+		return nil
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Value: name, HeadComment: fieldDocs[path]}
+	var node *yaml.Node
+	if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+		node = &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < value.NumField(); i++ {
+			if err := addTemplateField(node, field.Type.Field(i), value.Field(i), path); err != nil {
+				return err
+			}
+		}
+	} else {
+		node = &yaml.Node{}
+		if err := node.Encode(value.Interface()); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", path, err)
+		}
+	}
+
+	mapping.Content = append(mapping.Content, key, node)
+	return nil
+}
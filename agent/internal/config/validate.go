@@ -0,0 +1,280 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// MinInterval replaces unset or non-positive loop intervals at runtime, so
+// a hand-written config cannot make time.NewTicker panic
+const MinInterval = 5 * time.Second
+
+// EffectiveInterval returns d, or MinInterval when d is not positive. The
+// second result reports whether d had to be replaced.
+func EffectiveInterval(d time.Duration) (time.Duration, bool) {
+	if d <= 0 {
+		return MinInterval, true
+	}
+	return d, false
+}
+
+// LogLevels are the accepted logging.level values
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// Validate checks the configuration for values that would make the agent
+// misbehave at runtime. Every problem is reported, each prefixed with the
+// YAML path of the offending field.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+	positive := func(field string, d time.Duration) {
+		if d <= 0 {
+			add(field, "must be a positive duration, got %s", d)
+		}
+	}
+
+	// Server
+	if c.Server.URL != "" {
+		if err := validateWebSocketURL(c.Server.URL); err != nil {
+			add("server.url", "%v", err)
+		}
+	}
+	for i, u := range c.Server.FallbackURLs {
+		if err := validateWebSocketURL(u); err != nil {
+			add(fmt.Sprintf("server.fallback_urls[%d]", i), "%v", err)
+		}
+	}
+	positive("server.reconnect_interval", c.Server.ReconnectInterval)
+	positive("server.max_reconnect_interval", c.Server.MaxReconnectInterval)
+	if c.Server.MaxReconnectInterval > 0 && c.Server.MaxReconnectInterval < c.Server.ReconnectInterval {
+		add("server.max_reconnect_interval", "must not be less than server.reconnect_interval (%s)", c.Server.ReconnectInterval)
+	}
+	// server.ping_interval, metrics.interval and update.check_interval are
+	// not checked here: the loops fall back to MinInterval with a warning
+	// instead of refusing to start
+	if d := c.Server.MaxDisconnectedBeforeRestart; d < 0 {
+		add("server.max_disconnected_before_restart", "must not be negative, got %s", d)
+	} else if d > 0 && d < c.Server.MaxReconnectInterval {
+		add("server.max_disconnected_before_restart", "must not be less than server.max_reconnect_interval (%s)", c.Server.MaxReconnectInterval)
+	}
+	if c.Server.ReconnectJitter != "" && !ValidJitter(c.Server.ReconnectJitter) {
+		add("server.reconnect_jitter", "unknown strategy %q: use full, decorrelated or none", c.Server.ReconnectJitter)
+	}
+	if c.Server.ResultQueueSize < 0 {
+		add("server.result_queue_size", "must not be negative")
+	}
+	if c.Server.CompressResultsOver < 0 {
+		add("server.compress_results_over_kb", "must not be negative")
+	}
+	if c.Server.MaxMessageSize <= 0 {
+		add("server.max_message_size_mb", "must be positive")
+	}
+
+	// Metrics
+	for i, entry := range c.Metrics.WatchProcesses {
+		if name, _, _ := strings.Cut(entry, ":"); strings.TrimSpace(name) == "" {
+			add(fmt.Sprintf("metrics.watch_processes[%d]", i), "%q has no process name", entry)
+		}
+	}
+	for i, p := range c.Metrics.DiskPaths {
+		if !filepath.IsAbs(p) {
+			add(fmt.Sprintf("metrics.disk_paths[%d]", i), "%q must be an absolute path", p)
+		}
+	}
+	if c.Metrics.CPUSampleInterval < 0 {
+		add("metrics.cpu_sample_interval", "must not be negative, got %s", c.Metrics.CPUSampleInterval)
+	} else if c.Metrics.Interval > 0 && c.Metrics.CPUSampleInterval >= c.Metrics.Interval {
+		add("metrics.cpu_sample_interval", "must be shorter than metrics.interval (%s), got %s", c.Metrics.Interval, c.Metrics.CPUSampleInterval)
+	}
+	if c.Metrics.BackfillSamples < 0 {
+		add("metrics.backfill_samples", "must not be negative")
+	}
+	if c.Metrics.BackfillSamples > 0 && c.Metrics.BackfillMaxKB <= 0 {
+		add("metrics.backfill_max_kb", "must be positive when metrics.backfill_samples is set")
+	}
+
+	// Docker
+	if c.Docker.Socket != "" {
+		if err := validateDockerSocket(c.Docker.Socket); err != nil {
+			add("docker.socket", "%v", err)
+		}
+	}
+	if c.Docker.Timeout < 0 {
+		add("docker.timeout", "must not be negative, got %s", c.Docker.Timeout)
+	}
+	if c.Docker.CrashLoopRestarts < 1 {
+		add("docker.crash_loop_restarts", "must be at least 1, got %d", c.Docker.CrashLoopRestarts)
+	}
+	positive("docker.crash_loop_window", c.Docker.CrashLoopWindow)
+
+	// Auth
+	switch c.Auth.Mode {
+	case "", AuthModeHMAC, AuthModeEd25519:
+	default:
+		add("auth.mode", "unknown mode %q: use hmac or ed25519", c.Auth.Mode)
+	}
+
+	// Security
+	for i, path := range c.Security.AllowedPaths {
+		if !filepath.IsAbs(path) {
+			add(fmt.Sprintf("security.allowed_paths[%d]", i), "%q must be an absolute path", path)
+		}
+	}
+	actionPatterns := func(field string, patterns []string) {
+		for i, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				add(fmt.Sprintf("%s[%d]", field, i), "invalid pattern %q", pattern)
+			}
+		}
+	}
+	actionPatterns("security.allowed_actions", c.Security.AllowedActions)
+	actionPatterns("security.denied_actions", c.Security.DeniedActions)
+	positive("security.max_exec_timeout", c.Security.MaxExecTimeout)
+	switch c.Security.CredentialStore {
+	case "", CredentialStoreMachine, CredentialStoreKeyring:
+	default:
+		add("security.credential_store", "unknown store %q: use machine or keyring", c.Security.CredentialStore)
+	}
+	if c.Security.RotateEvery < 0 {
+		add("security.rotate_every", "must not be negative, got %s", c.Security.RotateEvery)
+	} else if c.Security.RotateEvery > 0 && c.Security.RotateEvery < time.Hour {
+		add("security.rotate_every", "must be at least 1h, got %s", c.Security.RotateEvery)
+	}
+	if c.Security.MaxConcurrentCommands < 1 {
+		add("security.max_concurrent_commands", "must be at least 1, got %d", c.Security.MaxConcurrentCommands)
+	}
+	if c.Security.MaxQueuedCommands < 0 {
+		add("security.max_queued_commands", "must not be negative, got %d", c.Security.MaxQueuedCommands)
+	}
+	switch c.Security.CommandVerification {
+	case CommandVerificationOff, CommandVerificationLog, CommandVerificationEnforce:
+	default:
+		add("security.command_verification", "unknown mode %q: use off, log or enforce", c.Security.CommandVerification)
+	}
+	// The server holds no key of ours to sign with in ed25519 mode, so
+	// enforcing would run unsigned commands while claiming otherwise
+	if c.Security.CommandVerification == CommandVerificationEnforce && c.Auth.Mode == AuthModeEd25519 {
+		add("security.command_verification", "enforce needs signed commands, which auth.mode ed25519 cannot verify: use log or off")
+	}
+	positive("security.command_max_age", c.Security.CommandMaxAge)
+
+	// Terminal limits are optional, zero disables them
+	if c.Terminal.IdleTimeout < 0 {
+		add("terminal.idle_timeout", "must not be negative, got %s", c.Terminal.IdleTimeout)
+	}
+	if c.Terminal.MaxLifetime < 0 {
+		add("terminal.max_lifetime", "must not be negative, got %s", c.Terminal.MaxLifetime)
+	}
+
+	// Logging
+	if !validLogLevel(c.Logging.Level) {
+		add("logging.level", "unknown level %q: use %s", c.Logging.Level, strings.Join(LogLevels, ", "))
+	}
+	if c.Logging.ConsoleLevel != "" && !validLogLevel(c.Logging.ConsoleLevel) {
+		add("logging.console_level", "unknown level %q: use %s", c.Logging.ConsoleLevel, strings.Join(LogLevels, ", "))
+	}
+	switch c.Logging.Syslog {
+	case LogSyslogOff:
+	case LogSyslog, LogJournald:
+		if runtime.GOOS == "windows" {
+			add("logging.syslog", "%q is not available on Windows: use %s", c.Logging.Syslog, LogEventLog)
+		}
+	case LogEventLog:
+		if runtime.GOOS != "windows" {
+			add("logging.syslog", "%q is only available on Windows: use %s or %s", c.Logging.Syslog, LogSyslog, LogJournald)
+		}
+	default:
+		add("logging.syslog", "unknown sink %q: use %s, %s, %s or %s", c.Logging.Syslog, LogSyslogOff, LogSyslog, LogJournald, LogEventLog)
+	}
+	switch c.Logging.Format {
+	case LogFormatAuto, LogFormatText, LogFormatJSON:
+	default:
+		add("logging.format", "unknown format %q: use %s, %s or %s", c.Logging.Format, LogFormatAuto, LogFormatText, LogFormatJSON)
+	}
+
+	// Update
+	if c.Update.Channel != "" && !ValidUpdateChannel(c.Update.Channel) {
+		add("update.channel", "unknown channel %q: use stable, beta or nightly", c.Update.Channel)
+	}
+
+	// Prometheus
+	if c.Features.Prometheus {
+		if c.Prometheus.Port < 1 || c.Prometheus.Port > 65535 {
+			add("prometheus.port", "must be between 1 and 65535, got %d", c.Prometheus.Port)
+		}
+		if c.Prometheus.Address != "" && net.ParseIP(c.Prometheus.Address) == nil && c.Prometheus.Address != "localhost" {
+			add("prometheus.address", "%q must be an IP address or localhost", c.Prometheus.Address)
+		}
+	}
+
+	// IPC
+	if c.IPC.Enabled && c.IPC.Socket == "" && (c.IPC.Port < 0 || c.IPC.Port > 65535) {
+		add("ipc.port", "must be between 0 and 65535, got %d", c.IPC.Port)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateWebSocketURL checks that raw is an absolute ws:// or wss:// URL
+func validateWebSocketURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return fmt.Errorf("%q must use ws:// or wss://", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
+
+// validateDockerSocket checks the socket is an absolute Unix socket path or
+// a unix://, npipe:// or tcp:// address
+func validateDockerSocket(socket string) error {
+	if strings.HasPrefix(socket, "/") {
+		return nil
+	}
+
+	scheme, rest, ok := strings.Cut(socket, "://")
+	if !ok {
+		return fmt.Errorf("%q must be an absolute path or a unix://, npipe:// or tcp:// address", socket)
+	}
+	switch scheme {
+	case "unix":
+		if !strings.HasPrefix(rest, "/") {
+			return fmt.Errorf("%q must point to an absolute socket path", socket)
+		}
+	case "npipe":
+		if rest == "" {
+			return fmt.Errorf("%q has no pipe name", socket)
+		}
+	case "tcp":
+		if _, _, err := net.SplitHostPort(rest); err != nil {
+			return fmt.Errorf("%q must be tcp://host:port", socket)
+		}
+	default:
+		return fmt.Errorf("unsupported scheme %q: use unix, npipe or tcp", scheme)
+	}
+	return nil
+}
+
+// validLogLevel reports whether level is a known log level
+func validLogLevel(level string) bool {
+	for _, l := range LogLevels {
+		if level == l {
+			return true
+		}
+	}
+	return false
+}
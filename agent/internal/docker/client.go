@@ -1,16 +1,21 @@
 package docker
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	containertypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	networktypes "github.com/docker/docker/api/types/network"
 	volumetypes "github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/serverkit/agent/internal/config"
@@ -19,14 +24,19 @@ import (
 
 // Client wraps the Docker client with additional functionality
 type Client struct {
-	cli *client.Client
-	cfg config.DockerConfig
-	log *logger.Logger
+	cli     *client.Client
+	cfg     config.DockerConfig
+	log     *logger.Logger
+	history *EventHistory
+	procs   processTracker
+	df      diskUsageCache
+	crashes crashTracker
 }
 
 // ContainerInfo represents container information
 type ContainerInfo struct {
-	ID      string            `json:"id"`
+	ID      string            `json:"id"`      // Short ID, for display
+	FullID  string            `json:"full_id"` // Full 64-character ID
 	Name    string            `json:"name"`
 	Image   string            `json:"image"`
 	ImageID string            `json:"image_id"`
@@ -48,7 +58,8 @@ type PortMapping struct {
 
 // ContainerStats represents container statistics
 type ContainerStats struct {
-	ID            string  `json:"id"`
+	ID            string  `json:"id"`      // Short ID, for display
+	FullID        string  `json:"full_id"` // Full 64-character ID
 	Name          string  `json:"name"`
 	CPUPercent    float64 `json:"cpu_percent"`
 	MemoryUsage   uint64  `json:"memory_usage"`
@@ -80,9 +91,25 @@ type VolumeInfo struct {
 	CreatedAt  string            `json:"created_at"`
 }
 
+// VolumeDetails represents detailed volume information
+type VolumeDetails struct {
+	VolumeInfo
+	Options map[string]string      `json:"options"`
+	Status  map[string]interface{} `json:"status,omitempty"`
+	Usage   *VolumeUsage           `json:"usage,omitempty"`
+}
+
+// VolumeUsage represents disk usage of a volume. A value of -1 means the
+// daemon has not calculated it.
+type VolumeUsage struct {
+	Size     int64 `json:"size"`
+	RefCount int64 `json:"ref_count"`
+}
+
 // NetworkInfo represents network information
 type NetworkInfo struct {
-	ID         string            `json:"id"`
+	ID         string            `json:"id"`      // Short ID, for display
+	FullID     string            `json:"full_id"` // Full 64-character ID
 	Name       string            `json:"name"`
 	Driver     string            `json:"driver"`
 	Scope      string            `json:"scope"`
@@ -91,6 +118,18 @@ type NetworkInfo struct {
 	Containers int               `json:"containers"`
 }
 
+// shortIDLength is how many characters of an ID Docker shows by default
+const shortIDLength = 12
+
+// shortID truncates a container or network ID for display. Shorter
+// strings are returned unchanged.
+func shortID(id string) string {
+	if len(id) <= shortIDLength {
+		return id
+	}
+	return id[:shortIDLength]
+}
+
 // NewClient creates a new Docker client
 func NewClient(cfg config.DockerConfig, log *logger.Logger) (*Client, error) {
 	var opts []client.Opt
@@ -105,32 +144,58 @@ func NewClient(cfg config.DockerConfig, log *logger.Logger) (*Client, error) {
 		opts = append(opts, client.WithHost(host))
 	}
 
+	// No client-wide timeout: it would also cut off log and event
+	// streams. Request/response calls are bounded by apiContext.
 	opts = append(opts, client.WithAPIVersionNegotiation())
 
-	if cfg.Timeout > 0 {
-		opts = append(opts, client.WithTimeout(cfg.Timeout))
-	}
-
 	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
 	}
 
 	return &Client{
-		cli: cli,
-		cfg: cfg,
-		log: log.WithComponent("docker"),
+		cli:     cli,
+		cfg:     cfg,
+		log:     log.WithComponent("docker"),
+		history: NewEventHistory(cfg.EventHistorySize),
 	}, nil
 }
 
+// apiContext bounds a request/response API call by docker.timeout. Streams
+// (logs, events, pulls, pushes, copies and exec sessions) get no deadline
+// and end with their caller's context instead.
+func (c *Client) apiContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.cfg.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.cfg.Timeout)
+}
+
+// stopContext is apiContext for stop and restart calls, which also wait
+// for the container's stop timeout, Docker's default of 10s if nil
+func (c *Client) stopContext(ctx context.Context, timeout *int) (context.Context, context.CancelFunc) {
+	if c.cfg.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	wait := 10 * time.Second
+	if timeout != nil && *timeout > 0 {
+		wait = time.Duration(*timeout) * time.Second
+	}
+	return context.WithTimeout(ctx, c.cfg.Timeout+wait)
+}
+
 // Ping checks if Docker is available
 func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	_, err := c.cli.Ping(ctx)
 	return err
 }
 
 // Version returns the Docker version
 func (c *Client) Version(ctx context.Context) (string, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	info, err := c.cli.ServerVersion(ctx)
 	if err != nil {
 		return "", err
@@ -140,6 +205,8 @@ func (c *Client) Version(ctx context.Context) (string, error) {
 
 // Info returns Docker system info
 func (c *Client) Info(ctx context.Context) (*types.Info, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	info, err := c.cli.Info(ctx)
 	if err != nil {
 		return nil, err
@@ -147,13 +214,57 @@ func (c *Client) Info(ctx context.Context) (*types.Info, error) {
 	return &info, nil
 }
 
-// ListContainers lists all containers
-func (c *Client) ListContainers(ctx context.Context, all bool) ([]ContainerInfo, error) {
+// ContainerFilters narrows a container list. The zero value lists running
+// containers, like docker ps.
+type ContainerFilters struct {
+	All      bool     `json:"all"`                // Include stopped containers
+	Status   []string `json:"status,omitempty"`   // e.g. running, exited, paused; any of them matches
+	Labels   []string `json:"labels,omitempty"`   // label or label=value; all must match
+	Name     string   `json:"name,omitempty"`     // Substring of the container name
+	Ancestor string   `json:"ancestor,omitempty"` // Image the container was created from, or a descendant of it
+	Limit    int      `json:"limit,omitempty"`    // Return at most this many, 0 for no limit
+	Offset   int      `json:"offset,omitempty"`   // Skip this many first
+}
+
+// args converts the filters for the Docker API
+func (f ContainerFilters) args() filters.Args {
+	args := filters.NewArgs()
+	for _, s := range f.Status {
+		args.Add("status", s)
+	}
+	for _, l := range f.Labels {
+		args.Add("label", l)
+	}
+	if f.Name != "" {
+		args.Add("name", regexp.QuoteMeta(f.Name))
+	}
+	if f.Ancestor != "" {
+		args.Add("ancestor", f.Ancestor)
+	}
+	return args
+}
+
+// ListContainers lists containers matching f, newest first. The total
+// is the number of matches before Limit and Offset are applied.
+func (c *Client) ListContainers(ctx context.Context, f ContainerFilters) ([]ContainerInfo, int, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	if f.Limit < 0 || f.Offset < 0 {
+		return nil, 0, fmt.Errorf("limit and offset must not be negative")
+	}
+
 	containers, err := c.cli.ContainerList(ctx, types.ContainerListOptions{
-		All: all,
+		All:     f.All,
+		Filters: f.args(),
 	})
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	total := len(containers)
+	containers = containers[min(f.Offset, total):]
+	if f.Limit > 0 && f.Limit < len(containers) {
+		containers = containers[:f.Limit]
 	}
 
 	result := make([]ContainerInfo, len(containers))
@@ -174,7 +285,8 @@ func (c *Client) ListContainers(ctx context.Context, all bool) ([]ContainerInfo,
 		}
 
 		result[i] = ContainerInfo{
-			ID:      cont.ID[:12],
+			ID:      shortID(cont.ID),
+			FullID:  cont.ID,
 			Name:    name,
 			Image:   cont.Image,
 			ImageID: cont.ImageID,
@@ -187,11 +299,13 @@ func (c *Client) ListContainers(ctx context.Context, all bool) ([]ContainerInfo,
 		}
 	}
 
-	return result, nil
+	return result, total, nil
 }
 
 // InspectContainer inspects a container
 func (c *Client) InspectContainer(ctx context.Context, id string) (*types.ContainerJSON, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	cont, err := c.cli.ContainerInspect(ctx, id)
 	if err != nil {
 		return nil, err
@@ -201,6 +315,8 @@ func (c *Client) InspectContainer(ctx context.Context, id string) (*types.Contai
 
 // StartContainer starts a container
 func (c *Client) StartContainer(ctx context.Context, id string) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	return c.cli.ContainerStart(ctx, id, types.ContainerStartOptions{})
 }
 
@@ -210,6 +326,8 @@ func (c *Client) StopContainer(ctx context.Context, id string, timeout *int) err
 	if timeout != nil {
 		stopOpts.Timeout = timeout
 	}
+	ctx, cancel := c.stopContext(ctx, timeout)
+	defer cancel()
 	return c.cli.ContainerStop(ctx, id, stopOpts)
 }
 
@@ -219,11 +337,220 @@ func (c *Client) RestartContainer(ctx context.Context, id string, timeout *int)
 	if timeout != nil {
 		stopOpts.Timeout = timeout
 	}
+	ctx, cancel := c.stopContext(ctx, timeout)
+	defer cancel()
 	return c.cli.ContainerRestart(ctx, id, stopOpts)
 }
 
+// PauseContainer freezes all processes in a container
+func (c *Client) PauseContainer(ctx context.Context, id string) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	return c.cli.ContainerPause(ctx, id)
+}
+
+// UnpauseContainer resumes a paused container
+func (c *Client) UnpauseContainer(ctx context.Context, id string) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	return c.cli.ContainerUnpause(ctx, id)
+}
+
+// ContainerState returns a container's state, e.g. "running" or "paused"
+func (c *Client) ContainerState(ctx context.Context, id string) (string, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	cont, err := c.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if cont.State == nil {
+		return "", nil
+	}
+	return cont.State.Status, nil
+}
+
+// containerNamePattern is the charset Docker accepts for container names
+var containerNamePattern = regexp.MustCompile(`^/?[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// RenameContainer renames a container. The name is checked against
+// Docker's rules first so callers get a readable error.
+func (c *Client) RenameContainer(ctx context.Context, id, newName string) error {
+	if !containerNamePattern.MatchString(newName) {
+		return fmt.Errorf("invalid container name %q: must start with a letter or digit and contain only letters, digits, '_', '.' or '-'", newName)
+	}
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	return c.cli.ContainerRename(ctx, id, newName)
+}
+
+// psArgsPattern limits ps arguments for ContainerTop to option letters,
+// column lists and their separators
+var psArgsPattern = regexp.MustCompile(`^[a-zA-Z0-9 ,=%_-]{0,128}$`)
+
+// ContainerProcesses lists the processes running inside a container
+type ContainerProcesses struct {
+	Titles    []string   `json:"titles"`
+	Processes [][]string `json:"processes"` // One row per process, in Titles order
+}
+
+// ContainerTop lists the processes inside a running container, like
+// docker top. psArgs is passed to ps on the host, e.g. "aux"; empty uses
+// Docker's default of "-ef".
+func (c *Client) ContainerTop(ctx context.Context, id, psArgs string) (*ContainerProcesses, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	if !psArgsPattern.MatchString(psArgs) {
+		return nil, fmt.Errorf("invalid ps args %q: only letters, digits, spaces and ,=%%_- are allowed", psArgs)
+	}
+
+	state, err := c.ContainerState(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if state != "running" && state != "paused" {
+		return nil, fmt.Errorf("container %s is not running (state: %s)", id, state)
+	}
+
+	top, err := c.cli.ContainerTop(ctx, id, strings.Fields(psArgs))
+	if err != nil {
+		return nil, err
+	}
+	return &ContainerProcesses{Titles: top.Titles, Processes: top.Processes}, nil
+}
+
+// HealthStatusNone is reported for containers without a healthcheck
+const HealthStatusNone = "none"
+
+// healthLogEntries is how many of the most recent healthcheck results
+// ContainerHealth returns
+const healthLogEntries = 3
+
+// ContainerHealth summarizes a container's health for status badges
+type ContainerHealth struct {
+	ID            string              `json:"id"`      // Short ID, for display
+	FullID        string              `json:"full_id"` // Full 64-character ID
+	Name          string              `json:"name"`
+	State         string              `json:"state"`
+	Health        string              `json:"health"` // "healthy", "unhealthy", "starting" or "none"
+	FailingStreak int                 `json:"failing_streak"`
+	Log           []HealthCheckResult `json:"log,omitempty"` // Most recent last
+	RestartCount  int                 `json:"restart_count"`
+	OOMKilled     bool                `json:"oom_killed"`
+	ExitCode      int                 `json:"exit_code"`
+}
+
+// HealthCheckResult is one healthcheck run
+type HealthCheckResult struct {
+	Start    int64  `json:"start"` // Unix milliseconds
+	End      int64  `json:"end"`   // Unix milliseconds
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+}
+
+// ContainerHealth returns a container's healthcheck status with its latest
+// results, restart count and whether it was OOM-killed. Containers without
+// a healthcheck report HealthStatusNone.
+func (c *Client) ContainerHealth(ctx context.Context, id string) (*ContainerHealth, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	cont, err := c.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	health := &ContainerHealth{
+		ID:           shortID(cont.ID),
+		FullID:       cont.ID,
+		Name:         strings.TrimPrefix(cont.Name, "/"),
+		Health:       HealthStatusNone,
+		RestartCount: cont.RestartCount,
+	}
+	if cont.State == nil {
+		return health, nil
+	}
+
+	health.State = cont.State.Status
+	health.OOMKilled = cont.State.OOMKilled
+	health.ExitCode = cont.State.ExitCode
+
+	if h := cont.State.Health; h != nil && h.Status != "" && h.Status != types.NoHealthcheck {
+		health.Health = h.Status
+		health.FailingStreak = h.FailingStreak
+
+		results := h.Log
+		if len(results) > healthLogEntries {
+			results = results[len(results)-healthLogEntries:]
+		}
+		for _, r := range results {
+			if r == nil {
+				continue
+			}
+			health.Log = append(health.Log, HealthCheckResult{
+				Start:    r.Start.UnixMilli(),
+				End:      r.End.UnixMilli(),
+				ExitCode: r.ExitCode,
+				Output:   strings.TrimSpace(r.Output),
+			})
+		}
+	}
+
+	return health, nil
+}
+
+// ContainerResources are the limits UpdateContainer can change on a
+// running container. Zero values leave a setting unchanged.
+type ContainerResources struct {
+	CPUShares         int64  `json:"cpu_shares,omitempty"`          // Relative CPU weight
+	NanoCPUs          int64  `json:"nano_cpus,omitempty"`           // CPU quota in billionths of a CPU
+	Memory            int64  `json:"memory,omitempty"`              // Memory limit in bytes
+	MemorySwap        int64  `json:"memory_swap,omitempty"`         // Memory plus swap in bytes; -1 for unlimited
+	MemoryReservation int64  `json:"memory_reservation,omitempty"`  // Soft memory limit in bytes
+	RestartPolicy     string `json:"restart_policy,omitempty"`      // no, always, unless-stopped or on-failure
+	MaximumRetryCount int    `json:"maximum_retry_count,omitempty"` // Only with on-failure
+}
+
+// UpdateContainer changes a container's resource limits and restart
+// policy without recreating it. Docker's warnings are returned.
+func (c *Client) UpdateContainer(ctx context.Context, id string, res ContainerResources) ([]string, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	update := containertypes.UpdateConfig{
+		Resources: containertypes.Resources{
+			CPUShares:         res.CPUShares,
+			NanoCPUs:          res.NanoCPUs,
+			Memory:            res.Memory,
+			MemorySwap:        res.MemorySwap,
+			MemoryReservation: res.MemoryReservation,
+		},
+	}
+
+	if res.RestartPolicy != "" {
+		switch res.RestartPolicy {
+		case "no", "always", "unless-stopped", "on-failure":
+		default:
+			return nil, fmt.Errorf("invalid restart policy %q", res.RestartPolicy)
+		}
+		if res.MaximumRetryCount != 0 && res.RestartPolicy != "on-failure" {
+			return nil, fmt.Errorf("maximum_retry_count is only valid with the on-failure restart policy")
+		}
+		update.RestartPolicy = containertypes.RestartPolicy{
+			Name:              res.RestartPolicy,
+			MaximumRetryCount: res.MaximumRetryCount,
+		}
+	}
+
+	resp, err := c.cli.ContainerUpdate(ctx, id, update)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Warnings, nil
+}
+
 // RemoveContainer removes a container
 func (c *Client) RemoveContainer(ctx context.Context, id string, force, removeVolumes bool) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	return c.cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{
 		Force:         force,
 		RemoveVolumes: removeVolumes,
@@ -252,6 +579,8 @@ func (c *Client) ContainerLogs(ctx context.Context, id string, tail string, sinc
 
 // ContainerStats returns container stats
 func (c *Client) ContainerStats(ctx context.Context, id string) (*ContainerStats, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	resp, err := c.cli.ContainerStats(ctx, id, false)
 	if err != nil {
 		return nil, err
@@ -289,12 +618,17 @@ func (c *Client) ContainerStats(ctx context.Context, id string) (*ContainerStats
 		}
 	}
 
-	// Get container name
+	// Get container name, and the ID in case id is a name
 	inspect, _ := c.cli.ContainerInspect(ctx, id)
 	name := strings.TrimPrefix(inspect.Name, "/")
+	fullID := id
+	if inspect.ContainerJSONBase != nil && inspect.ID != "" {
+		fullID = inspect.ID
+	}
 
 	return &ContainerStats{
-		ID:            id[:12],
+		ID:            shortID(fullID),
+		FullID:        fullID,
 		Name:          name,
 		CPUPercent:    cpuPercent,
 		MemoryUsage:   stats.MemoryStats.Usage,
@@ -310,6 +644,8 @@ func (c *Client) ContainerStats(ctx context.Context, id string) (*ContainerStats
 
 // ListImages lists all images
 func (c *Client) ListImages(ctx context.Context) ([]ImageInfo, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	images, err := c.cli.ImageList(ctx, types.ImageListOptions{})
 	if err != nil {
 		return nil, err
@@ -329,13 +665,49 @@ func (c *Client) ListImages(ctx context.Context) ([]ImageInfo, error) {
 	return result, nil
 }
 
-// PullImage pulls an image
-func (c *Client) PullImage(ctx context.Context, imageName string) (io.ReadCloser, error) {
-	return c.cli.ImagePull(ctx, imageName, types.ImagePullOptions{})
+// PullImage pulls an image. registryAuth is the base64 encoded auth config;
+// when empty, credentials from the host's docker config are used if present.
+func (c *Client) PullImage(ctx context.Context, imageName, registryAuth string) (io.ReadCloser, error) {
+	auth := c.registryAuth(imageName, registryAuth)
+	reader, err := c.cli.ImagePull(ctx, imageName, types.ImagePullOptions{
+		RegistryAuth: auth,
+	})
+	return reader, redactError(err, auth)
+}
+
+// TagImage adds the target reference to the source image
+func (c *Client) TagImage(ctx context.Context, source, target string) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	return c.cli.ImageTag(ctx, source, target)
+}
+
+// PushImage pushes an image to its registry. registryAuth is the base64
+// encoded auth config sent as X-Registry-Auth, falling back to the host's
+// docker config; the caller must close the returned progress stream.
+func (c *Client) PushImage(ctx context.Context, ref, registryAuth string) (io.ReadCloser, error) {
+	auth := c.registryAuth(ref, registryAuth)
+	reader, err := c.cli.ImagePush(ctx, ref, types.ImagePushOptions{
+		RegistryAuth: auth,
+	})
+	return reader, redactError(err, auth)
+}
+
+// InspectImage inspects an image
+func (c *Client) InspectImage(ctx context.Context, id string) (*types.ImageInspect, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	img, _, err := c.cli.ImageInspectWithRaw(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &img, nil
 }
 
 // RemoveImage removes an image
 func (c *Client) RemoveImage(ctx context.Context, id string, force bool) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	_, err := c.cli.ImageRemove(ctx, id, types.ImageRemoveOptions{
 		Force: force,
 	})
@@ -344,6 +716,8 @@ func (c *Client) RemoveImage(ctx context.Context, id string, force bool) error {
 
 // ListVolumes lists all volumes
 func (c *Client) ListVolumes(ctx context.Context) ([]VolumeInfo, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	resp, err := c.cli.VolumeList(ctx, volumetypes.ListOptions{})
 	if err != nil {
 		return nil, err
@@ -365,11 +739,14 @@ func (c *Client) ListVolumes(ctx context.Context) ([]VolumeInfo, error) {
 }
 
 // CreateVolume creates a volume
-func (c *Client) CreateVolume(ctx context.Context, name, driver string, labels map[string]string) (*VolumeInfo, error) {
+func (c *Client) CreateVolume(ctx context.Context, name, driver string, labels, driverOpts map[string]string) (*VolumeInfo, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	vol, err := c.cli.VolumeCreate(ctx, volumetypes.CreateOptions{
-		Name:   name,
-		Driver: driver,
-		Labels: labels,
+		Name:       name,
+		Driver:     driver,
+		Labels:     labels,
+		DriverOpts: driverOpts,
 	})
 	if err != nil {
 		return nil, err
@@ -385,13 +762,49 @@ func (c *Client) CreateVolume(ctx context.Context, name, driver string, labels m
 	}, nil
 }
 
+// InspectVolume returns detailed information about a volume
+func (c *Client) InspectVolume(ctx context.Context, name string) (*VolumeDetails, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	vol, err := c.cli.VolumeInspect(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &VolumeDetails{
+		VolumeInfo: VolumeInfo{
+			Name:       vol.Name,
+			Driver:     vol.Driver,
+			Mountpoint: vol.Mountpoint,
+			Labels:     vol.Labels,
+			Scope:      vol.Scope,
+			CreatedAt:  vol.CreatedAt,
+		},
+		Options: vol.Options,
+		Status:  vol.Status,
+	}
+	// The daemon only fills in usage for local volumes it has measured
+	if vol.UsageData != nil {
+		details.Usage = &VolumeUsage{
+			Size:     vol.UsageData.Size,
+			RefCount: vol.UsageData.RefCount,
+		}
+	}
+
+	return details, nil
+}
+
 // RemoveVolume removes a volume
 func (c *Client) RemoveVolume(ctx context.Context, name string, force bool) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	return c.cli.VolumeRemove(ctx, name, force)
 }
 
 // ListNetworks lists all networks
 func (c *Client) ListNetworks(ctx context.Context) ([]NetworkInfo, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	networks, err := c.cli.NetworkList(ctx, types.NetworkListOptions{})
 	if err != nil {
 		return nil, err
@@ -400,7 +813,8 @@ func (c *Client) ListNetworks(ctx context.Context) ([]NetworkInfo, error) {
 	result := make([]NetworkInfo, len(networks))
 	for i, net := range networks {
 		result[i] = NetworkInfo{
-			ID:         net.ID[:12],
+			ID:         shortID(net.ID),
+			FullID:     net.ID,
 			Name:       net.Name,
 			Driver:     net.Driver,
 			Scope:      net.Scope,
@@ -413,8 +827,71 @@ func (c *Client) ListNetworks(ctx context.Context) ([]NetworkInfo, error) {
 	return result, nil
 }
 
+// networkDrivers are the drivers a network can be created with
+var networkDrivers = map[string]bool{
+	"bridge":  true,
+	"overlay": true,
+	"macvlan": true,
+	"ipvlan":  true,
+}
+
+// CreateNetwork creates a network and returns its ID. The driver defaults
+// to bridge.
+func (c *Client) CreateNetwork(ctx context.Context, name, driver string, options map[string]string) (string, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	if name == "" {
+		return "", fmt.Errorf("network name is required")
+	}
+	if driver == "" {
+		driver = "bridge"
+	}
+	if !networkDrivers[driver] {
+		return "", fmt.Errorf("unsupported network driver %q: use bridge, overlay, macvlan or ipvlan", driver)
+	}
+
+	resp, err := c.cli.NetworkCreate(ctx, name, types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         driver,
+		Options:        options,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.Warning != "" {
+		c.log.Warn("Network created with warning", "name", name, "warning", resp.Warning)
+	}
+	return resp.ID, nil
+}
+
+// RemoveNetwork removes a network
+func (c *Client) RemoveNetwork(ctx context.Context, id string) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	return c.cli.NetworkRemove(ctx, id)
+}
+
+// ConnectContainer attaches a container to a network with optional
+// DNS aliases
+func (c *Client) ConnectContainer(ctx context.Context, networkID, containerID string, aliases []string) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	return c.cli.NetworkConnect(ctx, networkID, containerID, &networktypes.EndpointSettings{
+		Aliases: aliases,
+	})
+}
+
+// DisconnectContainer detaches a container from a network
+func (c *Client) DisconnectContainer(ctx context.Context, networkID, containerID string, force bool) error {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	return c.cli.NetworkDisconnect(ctx, networkID, containerID, force)
+}
+
 // GetContainerCount returns the number of containers
 func (c *Client) GetContainerCount(ctx context.Context) (total int, running int, err error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
 	allContainers, err := c.cli.ContainerList(ctx, types.ContainerListOptions{All: true})
 	if err != nil {
 		return 0, 0, err
@@ -430,8 +907,9 @@ func (c *Client) GetContainerCount(ctx context.Context) (total int, running int,
 	return len(allContainers), len(runningContainers), nil
 }
 
-// Close closes the Docker client
+// Close stops any running docker CLI commands and closes the Docker client
 func (c *Client) Close() error {
+	c.procs.stopAll()
 	return c.cli.Close()
 }
 
@@ -469,8 +947,8 @@ type ComposeContainer struct {
 
 // ComposeList lists all compose projects
 func (c *Client) ComposeList(ctx context.Context) ([]ComposeProject, error) {
-	cmd := exec.CommandContext(ctx, "docker", "compose", "ls", "--format", "json")
-	output, err := cmd.Output()
+	cmd := c.command(ctx, "compose", "ls", "--format", "json")
+	output, err := c.output(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list compose projects: %w", err)
 	}
@@ -483,14 +961,103 @@ func (c *Client) ComposeList(ctx context.Context) ([]ComposeProject, error) {
 	return projects, nil
 }
 
+// composeProjectPattern matches the project names compose accepts
+var composeProjectPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ComposeTarget identifies the project a compose command runs against:
+// either a single compose file (ProjectPath), or a project directory with
+// any number of compose files, an env file and a project name. All paths
+// must be absolute; compose files may be relative to ProjectDir.
+type ComposeTarget struct {
+	ProjectPath  string   `json:"project_path"`
+	ProjectDir   string   `json:"project_dir"`
+	ComposeFiles []string `json:"compose_files"`
+	EnvFile      string   `json:"env_file"`
+	ProjectName  string   `json:"project_name"`
+}
+
+// resolvePath makes a path relative to the project directory absolute.
+// Traversal is rejected before joining, since Join would clean it away.
+func (t ComposeTarget) resolvePath(path string) (string, error) {
+	if strings.Contains(path, "..") {
+		return "", fmt.Errorf("invalid project path: path traversal not allowed")
+	}
+	if t.ProjectDir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(t.ProjectDir, path)
+	}
+	if err := validateProjectPath(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// args validates the target and returns the leading `docker compose`
+// arguments that select it
+func (t ComposeTarget) args() ([]string, error) {
+	if t.ProjectPath == "" && t.ProjectDir == "" && len(t.ComposeFiles) == 0 && t.ProjectName == "" {
+		return nil, fmt.Errorf("project path is required")
+	}
+
+	args := []string{"compose"}
+	if t.ProjectDir != "" {
+		if err := validateProjectPath(t.ProjectDir); err != nil {
+			return nil, err
+		}
+		args = append(args, "--project-directory", t.ProjectDir)
+	}
+
+	files := t.ComposeFiles
+	if t.ProjectPath != "" {
+		files = append([]string{t.ProjectPath}, files...)
+	}
+	for _, file := range files {
+		file, err := t.resolvePath(file)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-f", file)
+	}
+
+	if t.EnvFile != "" {
+		envFile, err := t.resolvePath(t.EnvFile)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--env-file", envFile)
+	}
+
+	if t.ProjectName != "" {
+		if !composeProjectPattern.MatchString(t.ProjectName) {
+			return nil, fmt.Errorf("invalid compose project name %q", t.ProjectName)
+		}
+		args = append(args, "-p", t.ProjectName)
+	}
+
+	return args, nil
+}
+
+// String returns a short description of the target for logging
+func (t ComposeTarget) String() string {
+	switch {
+	case t.ProjectPath != "":
+		return t.ProjectPath
+	case t.ProjectDir != "":
+		return t.ProjectDir
+	case len(t.ComposeFiles) > 0:
+		return t.ComposeFiles[0]
+	}
+	return t.ProjectName
+}
+
 // ComposePsProject lists containers for a specific compose project
-func (c *Client) ComposePsProject(ctx context.Context, projectPath string) ([]ComposeContainer, error) {
-	if err := validateProjectPath(projectPath); err != nil {
+func (c *Client) ComposePsProject(ctx context.Context, target ComposeTarget) ([]ComposeContainer, error) {
+	args, err := target.args()
+	if err != nil {
 		return nil, err
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", "compose", "-f", projectPath, "ps", "--format", "json", "-a")
-	output, err := cmd.Output()
+	cmd := c.command(ctx, append(args, "ps", "--format", "json", "-a")...)
+	output, err := c.output(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list compose containers: %w", err)
 	}
@@ -523,13 +1090,104 @@ func (c *Client) ComposePsProject(ctx context.Context, projectPath string) ([]Co
 	return containers, nil
 }
 
-// ComposeUp starts a compose project
-func (c *Client) ComposeUp(ctx context.Context, projectPath string, detach, build bool) (string, error) {
-	if err := validateProjectPath(projectPath); err != nil {
+// ComposeValidationError is returned when a compose file fails validation
+// before any resources are created
+type ComposeValidationError struct {
+	Output string
+	Err    error
+}
+
+func (e *ComposeValidationError) Error() string {
+	return fmt.Sprintf("compose file validation failed: %v: %s", e.Err, e.Output)
+}
+
+func (e *ComposeValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ComposeValidate checks that a compose file exists and parses, using
+// `docker compose config -q`
+func (c *Client) ComposeValidate(ctx context.Context, target ComposeTarget) error {
+	args, err := target.args()
+	if err != nil {
+		return err
+	}
+
+	cmd := c.command(ctx, append(args, "config", "-q")...)
+	output, err := c.combinedOutput(cmd)
+	if err != nil {
+		return &ComposeValidationError{
+			Output: strings.TrimSpace(string(output)),
+			Err:    err,
+		}
+	}
+
+	return nil
+}
+
+// ComposeConfig returns the resolved compose configuration, with
+// overrides merged and variables interpolated, as YAML
+func (c *Client) ComposeConfig(ctx context.Context, target ComposeTarget) (string, error) {
+	args, err := target.args()
+	if err != nil {
+		return "", err
+	}
+
+	// Keep warnings on stderr out of the returned YAML
+	var stderr bytes.Buffer
+	cmd := c.command(ctx, append(args, "config")...)
+	cmd.Stderr = &stderr
+	output, err := c.output(cmd)
+	if err != nil {
+		return "", &ComposeValidationError{
+			Output: strings.TrimSpace(stderr.String()),
+			Err:    err,
+		}
+	}
+
+	return string(output), nil
+}
+
+// ComposeBuild builds images for a compose project or specific service
+func (c *Client) ComposeBuild(ctx context.Context, target ComposeTarget, service string, noCache bool) (string, error) {
+	args, err := target.args()
+	if err != nil {
+		return "", err
+	}
+
+	args = append(args, "build")
+	if noCache {
+		args = append(args, "--no-cache")
+	}
+	if service != "" {
+		args = append(args, service)
+	}
+
+	cmd := c.command(ctx, args...)
+	output, err := c.combinedOutput(cmd)
+	if err != nil {
+		return string(output), fmt.Errorf("compose build failed: %w: %s", err, output)
+	}
+
+	return string(output), nil
+}
+
+// ComposeUp starts a compose project. Unless skipValidation is set, the
+// compose file is validated first so a malformed stack fails before any
+// containers are created.
+func (c *Client) ComposeUp(ctx context.Context, target ComposeTarget, detach, build, skipValidation bool) (string, error) {
+	args, err := target.args()
+	if err != nil {
 		return "", err
 	}
 
-	args := []string{"compose", "-f", projectPath, "up"}
+	if !skipValidation {
+		if err := c.ComposeValidate(ctx, target); err != nil {
+			return "", err
+		}
+	}
+
+	args = append(args, "up")
 	if detach {
 		args = append(args, "-d")
 	}
@@ -537,8 +1195,8 @@ func (c *Client) ComposeUp(ctx context.Context, projectPath string, detach, buil
 		args = append(args, "--build")
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	cmd := c.command(ctx, args...)
+	output, err := c.combinedOutput(cmd)
 	if err != nil {
 		return string(output), fmt.Errorf("compose up failed: %w: %s", err, output)
 	}
@@ -547,12 +1205,13 @@ func (c *Client) ComposeUp(ctx context.Context, projectPath string, detach, buil
 }
 
 // ComposeDown stops a compose project
-func (c *Client) ComposeDown(ctx context.Context, projectPath string, volumes, removeOrphans bool) (string, error) {
-	if err := validateProjectPath(projectPath); err != nil {
+func (c *Client) ComposeDown(ctx context.Context, target ComposeTarget, volumes, removeOrphans bool) (string, error) {
+	args, err := target.args()
+	if err != nil {
 		return "", err
 	}
 
-	args := []string{"compose", "-f", projectPath, "down"}
+	args = append(args, "down")
 	if volumes {
 		args = append(args, "-v")
 	}
@@ -560,8 +1219,8 @@ func (c *Client) ComposeDown(ctx context.Context, projectPath string, volumes, r
 		args = append(args, "--remove-orphans")
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	cmd := c.command(ctx, args...)
+	output, err := c.combinedOutput(cmd)
 	if err != nil {
 		return string(output), fmt.Errorf("compose down failed: %w: %s", err, output)
 	}
@@ -570,12 +1229,13 @@ func (c *Client) ComposeDown(ctx context.Context, projectPath string, volumes, r
 }
 
 // ComposeLogs gets logs from a compose project
-func (c *Client) ComposeLogs(ctx context.Context, projectPath, service string, tail int) (string, error) {
-	if err := validateProjectPath(projectPath); err != nil {
+func (c *Client) ComposeLogs(ctx context.Context, target ComposeTarget, service string, tail int) (string, error) {
+	args, err := target.args()
+	if err != nil {
 		return "", err
 	}
 
-	args := []string{"compose", "-f", projectPath, "logs", "--no-color"}
+	args = append(args, "logs", "--no-color")
 	if tail > 0 {
 		args = append(args, "--tail", fmt.Sprintf("%d", tail))
 	}
@@ -583,8 +1243,8 @@ func (c *Client) ComposeLogs(ctx context.Context, projectPath, service string, t
 		args = append(args, service)
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	cmd := c.command(ctx, args...)
+	output, err := c.combinedOutput(cmd)
 	if err != nil {
 		return string(output), fmt.Errorf("compose logs failed: %w: %s", err, output)
 	}
@@ -592,19 +1252,73 @@ func (c *Client) ComposeLogs(ctx context.Context, projectPath, service string, t
 	return string(output), nil
 }
 
+// ComposeLogsFollow runs `docker compose logs -f` for a project and calls
+// fn with each output line until ctx is cancelled or compose exits.
+// Cancelling ctx stops the compose process group.
+func (c *Client) ComposeLogsFollow(ctx context.Context, target ComposeTarget, service string, tail int, fn func(line string)) error {
+	args, err := target.args()
+	if err != nil {
+		return err
+	}
+	args = append(args, "logs", "--no-color", "-f")
+	if tail > 0 {
+		args = append(args, "--tail", fmt.Sprintf("%d", tail))
+	}
+	if service != "" {
+		args = append(args, service)
+	}
+
+	// stdout and stderr share one pipe, which is closed once the process
+	// has exited so the scanner below never blocks on a dead child
+	pr, pw := io.Pipe()
+	cmd := c.command(ctx, args...)
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := c.procs.start(cmd); err != nil {
+		return fmt.Errorf("failed to start compose logs: %w", err)
+	}
+
+	waitErr := make(chan error, 1)
+	go func() {
+		err := c.procs.wait(cmd)
+		pw.Close()
+		waitErr <- err
+	}()
+
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+	// Drain so Wait is never stuck writing to the pipe
+	if scanner.Err() != nil {
+		io.Copy(io.Discard, pr)
+	}
+
+	err = <-waitErr
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("compose logs exited: %w", err)
+	}
+	return nil
+}
+
 // ComposeRestart restarts a compose project or specific service
-func (c *Client) ComposeRestart(ctx context.Context, projectPath, service string) (string, error) {
-	if err := validateProjectPath(projectPath); err != nil {
+func (c *Client) ComposeRestart(ctx context.Context, target ComposeTarget, service string) (string, error) {
+	args, err := target.args()
+	if err != nil {
 		return "", err
 	}
 
-	args := []string{"compose", "-f", projectPath, "restart"}
+	args = append(args, "restart")
 	if service != "" {
 		args = append(args, service)
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	cmd := c.command(ctx, args...)
+	output, err := c.combinedOutput(cmd)
 	if err != nil {
 		return string(output), fmt.Errorf("compose restart failed: %w: %s", err, output)
 	}
@@ -612,19 +1326,90 @@ func (c *Client) ComposeRestart(ctx context.Context, projectPath, service string
 	return string(output), nil
 }
 
+// ComposeScale sets the number of replicas of a service, starting or
+// removing containers as needed without recreating the others
+func (c *Client) ComposeScale(ctx context.Context, target ComposeTarget, service string, replicas int) (string, error) {
+	args, err := target.args()
+	if err != nil {
+		return "", err
+	}
+	if service == "" {
+		return "", fmt.Errorf("service is required")
+	}
+	if replicas < 0 {
+		return "", fmt.Errorf("replicas must not be negative")
+	}
+
+	args = append(args, "up", "-d", "--no-deps", "--no-recreate",
+		"--scale", fmt.Sprintf("%s=%d", service, replicas), service)
+
+	cmd := c.command(ctx, args...)
+	output, err := c.combinedOutput(cmd)
+	if err != nil {
+		return string(output), fmt.Errorf("compose scale failed: %w: %s", err, output)
+	}
+
+	return string(output), nil
+}
+
+// ComposeStart starts the existing containers of a compose project or
+// specific service
+func (c *Client) ComposeStart(ctx context.Context, target ComposeTarget, service string) (string, error) {
+	args, err := target.args()
+	if err != nil {
+		return "", err
+	}
+
+	args = append(args, "start")
+	if service != "" {
+		args = append(args, service)
+	}
+
+	cmd := c.command(ctx, args...)
+	output, err := c.combinedOutput(cmd)
+	if err != nil {
+		return string(output), fmt.Errorf("compose start failed: %w: %s", err, output)
+	}
+
+	return string(output), nil
+}
+
+// ComposeStop stops a compose project or specific service without
+// removing its containers
+func (c *Client) ComposeStop(ctx context.Context, target ComposeTarget, service string) (string, error) {
+	args, err := target.args()
+	if err != nil {
+		return "", err
+	}
+
+	args = append(args, "stop")
+	if service != "" {
+		args = append(args, service)
+	}
+
+	cmd := c.command(ctx, args...)
+	output, err := c.combinedOutput(cmd)
+	if err != nil {
+		return string(output), fmt.Errorf("compose stop failed: %w: %s", err, output)
+	}
+
+	return string(output), nil
+}
+
 // ComposePull pulls images for a compose project
-func (c *Client) ComposePull(ctx context.Context, projectPath, service string) (string, error) {
-	if err := validateProjectPath(projectPath); err != nil {
+func (c *Client) ComposePull(ctx context.Context, target ComposeTarget, service string) (string, error) {
+	args, err := target.args()
+	if err != nil {
 		return "", err
 	}
 
-	args := []string{"compose", "-f", projectPath, "pull"}
+	args = append(args, "pull")
 	if service != "" {
 		args = append(args, service)
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	cmd := c.command(ctx, args...)
+	output, err := c.combinedOutput(cmd)
 	if err != nil {
 		return string(output), fmt.Errorf("compose pull failed: %w: %s", err, output)
 	}
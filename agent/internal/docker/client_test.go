@@ -0,0 +1,82 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/serverkit/agent/internal/config"
+	"github.com/serverkit/agent/internal/logger"
+)
+
+// newFakeDaemon starts an HTTP server answering the Docker API's version
+// ping, with eventsHandler serving /events, and a client connected to it
+func newFakeDaemon(t *testing.T, timeout time.Duration, eventsHandler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/_ping":
+			w.Header().Set("API-Version", "1.43")
+			w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/events"):
+			eventsHandler(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(config.DockerConfig{
+		Socket:  "tcp://" + strings.TrimPrefix(srv.URL, "http://"),
+		Timeout: timeout,
+	}, logger.New(config.LoggingConfig{Level: "error"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// writeEvent sends one container event on a streaming response
+func writeEvent(w http.ResponseWriter, id string, at time.Time) {
+	json.NewEncoder(w).Encode(events.Message{
+		Type:     events.ContainerEventType,
+		Action:   "start",
+		Actor:    events.Actor{ID: id},
+		Time:     at.Unix(),
+		TimeNano: at.UnixNano(),
+	})
+	w.(http.Flusher).Flush()
+}
+
+// docker.timeout bounds API requests, not streams that run longer
+func TestEventStreamOutlivesTimeout(t *testing.T) {
+	c := newFakeDaemon(t, 100*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+		writeEvent(w, "first", time.Now())
+		time.Sleep(300 * time.Millisecond)
+		writeEvent(w, "second", time.Now())
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	eventCh, errCh := c.Events(ctx, EventStreamFilter{}, time.Time{})
+
+	for _, want := range []string{"first", "second"} {
+		select {
+		case ev := <-eventCh:
+			if ev.ActorID != want {
+				t.Fatalf("got event %q, want %q", ev.ActorID, want)
+			}
+		case err := <-errCh:
+			t.Fatalf("stream ended before event %q: %v", want, err)
+		case <-ctx.Done():
+			t.Fatalf("no event %q", want)
+		}
+	}
+}
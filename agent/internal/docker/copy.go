@@ -0,0 +1,124 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// MaxCopySize caps the tar archive moved by a single container copy, which
+// travels base64-encoded inside one WebSocket message
+const MaxCopySize = 16 * 1024 * 1024
+
+// ContainerPathStat describes a file or directory inside a container
+type ContainerPathStat struct {
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`
+	Mode       string `json:"mode"` // e.g. "-rw-r--r--" or "drwxr-xr-x"
+	IsDir      bool   `json:"is_dir"`
+	Mtime      int64  `json:"mtime"` // Unix milliseconds
+	LinkTarget string `json:"link_target,omitempty"`
+}
+
+// CopyFromContainer opens a tar archive of srcPath inside a container,
+// with the stat of the copied path. The caller must close the reader.
+func (c *Client) CopyFromContainer(ctx context.Context, id, srcPath string) (io.ReadCloser, *ContainerPathStat, error) {
+	srcPath, err := containerPath(srcPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, stat, err := c.cli.CopyFromContainer(ctx, id, srcPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader, &ContainerPathStat{
+		Name:       stat.Name,
+		Size:       stat.Size,
+		Mode:       stat.Mode.String(),
+		IsDir:      stat.Mode.IsDir(),
+		Mtime:      stat.Mtime.UnixMilli(),
+		LinkTarget: stat.LinkTarget,
+	}, nil
+}
+
+// CopyToContainer extracts a tar archive into destPath, which must be an
+// existing directory inside the container. Use ValidateArchive first on
+// archives from outside the agent.
+func (c *Client) CopyToContainer(ctx context.Context, id, destPath string, archive io.Reader) error {
+	destPath, err := containerPath(destPath)
+	if err != nil {
+		return err
+	}
+	return c.cli.CopyToContainer(ctx, id, destPath, archive, types.CopyToContainerOptions{})
+}
+
+// containerPath checks that p is an absolute path inside a container with
+// no ".." segments, and returns it cleaned
+func containerPath(p string) (string, error) {
+	if p == "" || strings.ContainsRune(p, 0) {
+		return "", fmt.Errorf("invalid container path %q", p)
+	}
+	if !path.IsAbs(p) {
+		return "", fmt.Errorf("container path %q must be absolute", p)
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("container path %q must not contain '..'", p)
+		}
+	}
+	return path.Clean(p), nil
+}
+
+// ValidateArchive reads a tar archive and rejects entries that could land
+// outside the destination directory: absolute names, ".." segments, and
+// links pointing out of the archive.
+func ValidateArchive(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+
+		if err := archivePath(header.Name); err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeLink:
+			if err := archivePath(header.Linkname); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			target := header.Linkname
+			if !path.IsAbs(target) {
+				target = path.Join(path.Dir(header.Name), target)
+			}
+			if path.IsAbs(header.Linkname) || target == ".." || strings.HasPrefix(target, "../") {
+				return fmt.Errorf("archive entry %q links outside the destination", header.Name)
+			}
+		}
+	}
+}
+
+// archivePath checks one archive entry name
+func archivePath(name string) error {
+	if path.IsAbs(name) || strings.ContainsRune(name, 0) {
+		return fmt.Errorf("archive entry %q must be a relative path", name)
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return fmt.Errorf("archive entry %q must not contain '..'", name)
+		}
+	}
+	return nil
+}
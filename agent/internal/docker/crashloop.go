@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+// CrashReport lists containers that look unhealthy since the previous report
+type CrashReport struct {
+	CrashLooping []string // Restarted at least crash_loop_restarts times within crash_loop_window
+	OOMKilled    []string // Killed for running out of memory since the previous report
+}
+
+// restartSample is a container's restart count at one point in time
+type restartSample struct {
+	at    time.Time
+	count int
+}
+
+// crashTracker remembers restart counts of containers that stopped or
+// started recently, so restarts can be counted over a window
+type crashTracker struct {
+	mu       sync.Mutex
+	restarts map[string][]restartSample // By container name
+}
+
+// CrashReport inspects the containers that died, started or ran out of
+// memory since the given time, according to the event history. Containers
+// without such events are not inspected, which keeps this cheap enough for
+// every heartbeat.
+func (c *Client) CrashReport(ctx context.Context, since time.Time) *CrashReport {
+	changed := make(map[string]bool) // Container ID to whether an oom event was seen
+	for _, ev := range c.history.Query(EventFilter{Type: events.ContainerEventType, Since: since}) {
+		switch ev.Action {
+		case "oom":
+			changed[ev.ActorID] = true
+		case "die", "start", "restart":
+			if _, ok := changed[ev.ActorID]; !ok {
+				changed[ev.ActorID] = false
+			}
+		}
+	}
+
+	report := &CrashReport{}
+	now := time.Now()
+
+	c.crashes.mu.Lock()
+	defer c.crashes.mu.Unlock()
+	if c.crashes.restarts == nil {
+		c.crashes.restarts = make(map[string][]restartSample)
+	}
+
+	for id, oomEvent := range changed {
+		cont, err := c.InspectContainer(ctx, id)
+		if err != nil || cont.ContainerJSONBase == nil {
+			continue // Removed since
+		}
+		name := containerName(cont.Name, id)
+
+		// The flag describes the last exit, so only trust it while stopped
+		if oomEvent || (cont.State != nil && cont.State.OOMKilled && !cont.State.Running) {
+			report.OOMKilled = append(report.OOMKilled, name)
+		}
+		c.crashes.restarts[name] = append(c.crashes.restarts[name], restartSample{at: now, count: cont.RestartCount})
+	}
+
+	window := c.cfg.CrashLoopWindow
+	for name, samples := range c.crashes.restarts {
+		// Keep the samples inside the window; the oldest is the baseline
+		keep := 0
+		for keep < len(samples) && now.Sub(samples[keep].at) > window {
+			keep++
+		}
+		samples = samples[keep:]
+		if len(samples) == 0 {
+			delete(c.crashes.restarts, name)
+			continue
+		}
+		c.crashes.restarts[name] = samples
+
+		if samples[len(samples)-1].count-samples[0].count >= c.cfg.CrashLoopRestarts {
+			report.CrashLooping = append(report.CrashLooping, name)
+		}
+	}
+
+	sort.Strings(report.CrashLooping)
+	sort.Strings(report.OOMKilled)
+	return report
+}
+
+// containerName returns a container's name without the leading slash, or
+// its short ID when unnamed
+func containerName(name, id string) string {
+	if len(name) > 1 && name[0] == '/' {
+		return name[1:]
+	}
+	if name != "" {
+		return name
+	}
+	return shortID(id)
+}
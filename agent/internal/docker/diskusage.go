@@ -0,0 +1,162 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// diskUsageTTL is how long a DiskUsage result is reused. Computing it makes
+// the daemon walk every layer and volume.
+const diskUsageTTL = 30 * time.Second
+
+// DiskUsage is a storage breakdown like docker system df -v
+type DiskUsage struct {
+	Images     DiskUsageSummary `json:"images"`
+	Containers DiskUsageSummary `json:"containers"`
+	Volumes    DiskUsageSummary `json:"volumes"`
+	BuildCache DiskUsageSummary `json:"build_cache"`
+	LayersSize int64            `json:"layers_size"` // Disk used by all image layers, shared ones counted once
+	Timestamp  int64            `json:"timestamp"`   // When it was fetched from the daemon, Unix milliseconds
+}
+
+// DiskUsageSummary totals one kind of resource
+type DiskUsageSummary struct {
+	Count       int             `json:"count"`
+	Active      int             `json:"active"` // In use by a container, or running
+	Size        int64           `json:"size"`
+	Reclaimable int64           `json:"reclaimable"` // Size a prune could free
+	Items       []DiskUsageItem `json:"items"`
+}
+
+// DiskUsageItem is the size of one image, container, volume or cache record
+type DiskUsageItem struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Size   int64  `json:"size"`
+	Shared int64  `json:"shared,omitempty"` // Images: bytes in layers shared with other images
+	Active bool   `json:"active"`
+}
+
+// diskUsageCache holds the last DiskUsage result. The lock is held while
+// fetching so concurrent callers share one daemon request.
+type diskUsageCache struct {
+	mu      sync.Mutex
+	usage   *DiskUsage
+	fetched time.Time
+}
+
+// DiskUsage returns image, container, volume and build cache sizes. Results
+// are cached for a short while; refresh skips the cache.
+func (c *Client) DiskUsage(ctx context.Context, refresh bool) (*DiskUsage, error) {
+	c.df.mu.Lock()
+	defer c.df.mu.Unlock()
+
+	if !refresh && c.df.usage != nil && time.Since(c.df.fetched) < diskUsageTTL {
+		return c.df.usage, nil
+	}
+
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	du, err := c.cli.DiskUsage(ctx, types.DiskUsageOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &DiskUsage{
+		Images:     DiskUsageSummary{Items: []DiskUsageItem{}},
+		Containers: DiskUsageSummary{Items: []DiskUsageItem{}},
+		Volumes:    DiskUsageSummary{Items: []DiskUsageItem{}},
+		BuildCache: DiskUsageSummary{Items: []DiskUsageItem{}},
+		LayersSize: du.LayersSize,
+		Timestamp:  time.Now().UnixMilli(),
+	}
+
+	usage.Images.Size = du.LayersSize
+	for _, img := range du.Images {
+		if img == nil {
+			continue
+		}
+		item := DiskUsageItem{
+			ID:     img.ID,
+			Size:   img.Size,
+			Active: img.Containers > 0,
+		}
+		if len(img.RepoTags) > 0 {
+			item.Name = img.RepoTags[0]
+		}
+		if img.SharedSize > 0 {
+			item.Shared = img.SharedSize
+		}
+		usage.Images.add(item, img.Size-item.Shared)
+	}
+
+	for _, cont := range du.Containers {
+		if cont == nil {
+			continue
+		}
+		item := DiskUsageItem{
+			ID:     cont.ID,
+			Size:   cont.SizeRw,
+			Active: cont.State == "running",
+		}
+		if len(cont.Names) > 0 {
+			item.Name = strings.TrimPrefix(cont.Names[0], "/")
+		}
+		usage.Containers.Size += item.Size
+		usage.Containers.add(item, item.Size)
+	}
+
+	for _, vol := range du.Volumes {
+		if vol == nil {
+			continue
+		}
+		// Size and RefCount are -1 when the driver cannot report them
+		item := DiskUsageItem{ID: vol.Name, Size: -1}
+		if vol.UsageData != nil {
+			item.Size = vol.UsageData.Size
+			item.Active = vol.UsageData.RefCount > 0
+		}
+		if item.Size > 0 {
+			usage.Volumes.Size += item.Size
+		}
+		usage.Volumes.add(item, max(item.Size, 0))
+	}
+
+	for _, rec := range du.BuildCache {
+		if rec == nil {
+			continue
+		}
+		item := DiskUsageItem{
+			ID:     rec.ID,
+			Name:   rec.Description,
+			Size:   rec.Size,
+			Active: rec.InUse,
+		}
+		usage.BuildCache.Size += item.Size
+		reclaimable := item.Size
+		if rec.Shared {
+			reclaimable = 0
+		}
+		usage.BuildCache.add(item, reclaimable)
+	}
+
+	c.df.usage = usage
+	c.df.fetched = time.Now()
+	return usage, nil
+}
+
+// add appends an item, counting reclaimable toward the total when the item
+// is not in use
+func (s *DiskUsageSummary) add(item DiskUsageItem, reclaimable int64) {
+	s.Count++
+	s.Items = append(s.Items, item)
+	if item.Active {
+		s.Active++
+	} else {
+		s.Reclaimable += reclaimable
+	}
+}
@@ -0,0 +1,325 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/serverkit/agent/internal/config"
+)
+
+// Event represents a recorded Docker event
+type Event struct {
+	Type       string            `json:"type"`
+	Action     string            `json:"action"`
+	ActorID    string            `json:"actor_id"`
+	Name       string            `json:"name,omitempty"`
+	Image      string            `json:"image,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Time       int64             `json:"time"` // Unix milliseconds
+	TimeNano   int64             `json:"-"`    // Full precision, for EventCursor
+}
+
+// EventCursor tracks the position in an event stream across
+// resubscriptions. Resuming by since has second granularity, so a new
+// subscription replays events already delivered; Seen skips exactly those.
+// Distinct events with the same timestamp all pass.
+type EventCursor struct {
+	nano int64
+	seen map[string]bool // Identities of the events delivered at nano
+}
+
+// Seen reports whether ev was already delivered, recording it if not
+func (c *EventCursor) Seen(ev Event) bool {
+	if ev.TimeNano < c.nano {
+		return true
+	}
+	key := ev.Type + "/" + ev.Action + "/" + ev.ActorID
+	if ev.TimeNano == c.nano {
+		if c.seen[key] {
+			return true
+		}
+		c.seen[key] = true
+		return false
+	}
+	c.nano = ev.TimeNano
+	c.seen = map[string]bool{key: true}
+	return false
+}
+
+// Since is where a resubscription should start, or the zero time before
+// any event was delivered
+func (c *EventCursor) Since() time.Time {
+	if c.nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, c.nano)
+}
+
+// EventFilter selects events from the history
+type EventFilter struct {
+	Container string    // Container ID (or prefix) or name
+	Type      string    // Event type, e.g. "container", "image"
+	Action    string    // Event action, e.g. "die", "restart"
+	Since     time.Time // Only events at or after this time
+	Limit     int       // Maximum number of (most recent) events to return
+}
+
+// EventHistory is a bounded ring buffer of recent Docker events
+type EventHistory struct {
+	mu     sync.RWMutex
+	events []Event
+	next   int
+	full   bool
+}
+
+// NewEventHistory creates an event history holding up to size events
+func NewEventHistory(size int) *EventHistory {
+	if size <= 0 {
+		size = config.Default().Docker.EventHistorySize
+	}
+	return &EventHistory{
+		events: make([]Event, size),
+	}
+}
+
+// Add records an event, overwriting the oldest one when full
+func (h *EventHistory) Add(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.events[h.next] = ev
+	h.next = (h.next + 1) % len(h.events)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Query returns the events matching the filter, oldest first
+func (h *EventHistory) Query(f EventFilter) []Event {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := h.next
+	start := 0
+	if h.full {
+		count = len(h.events)
+		start = h.next
+	}
+
+	result := make([]Event, 0)
+	for i := 0; i < count; i++ {
+		ev := h.events[(start+i)%len(h.events)]
+		if f.matches(ev) {
+			result = append(result, ev)
+		}
+	}
+
+	if f.Limit > 0 && len(result) > f.Limit {
+		result = result[len(result)-f.Limit:]
+	}
+	return result
+}
+
+func (f EventFilter) matches(ev Event) bool {
+	if f.Type != "" && ev.Type != f.Type {
+		return false
+	}
+	if f.Action != "" && ev.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && ev.Time < f.Since.UnixMilli() {
+		return false
+	}
+	if f.Container != "" {
+		if ev.Type != events.ContainerEventType {
+			return false
+		}
+		name := strings.TrimPrefix(f.Container, "/")
+		if ev.Name != name && !strings.HasPrefix(ev.ActorID, f.Container) {
+			return false
+		}
+	}
+	return true
+}
+
+// WatchEvents records Docker events into the event history until ctx is
+// cancelled, from startup onwards
+func (c *Client) WatchEvents(ctx context.Context) {
+	c.FollowEvents(ctx, EventStreamFilter{}, time.Now(), c.history.Add)
+}
+
+// EventStreamFilter selects the events delivered by Events
+type EventStreamFilter struct {
+	Types  []string `json:"types,omitempty"`  // Event types, e.g. "container", "image"
+	Labels []string `json:"labels,omitempty"` // Container labels, label or label=value
+}
+
+// FollowEvents calls fn with every Docker event matching the filter from
+// since onwards (from now when since is zero) until ctx is cancelled. The
+// stream is cut by daemon restarts, so it is resubscribed with backoff,
+// resuming from the last delivered event, or from the first subscription
+// when there was none; events already delivered are skipped. fn is never
+// called concurrently.
+func (c *Client) FollowEvents(ctx context.Context, f EventStreamFilter, since time.Time, fn func(Event)) {
+	resume := since
+	if resume.IsZero() {
+		resume = time.Now()
+	}
+	var cursor EventCursor
+	backoff := time.Second
+
+	for {
+		eventCh, errCh := c.Events(ctx, f, since)
+
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-eventCh:
+				if !ok {
+					break stream
+				}
+				// Resuming by since replays the last second; skip what
+				// was already delivered
+				if cursor.Seen(ev) {
+					continue
+				}
+				backoff = time.Second
+				fn(ev)
+			}
+		}
+		err := <-errCh
+		if ctx.Err() != nil {
+			return
+		}
+		c.log.Debug("Docker event stream ended", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+
+		since = cursor.Since()
+		if since.IsZero() {
+			since = resume
+		}
+	}
+}
+
+// Events streams Docker events matching the filter from since onwards (or
+// from now when since is zero). The event channel is closed when the
+// stream ends, after which the error channel holds the reason: ctx's
+// error, or the stream's. Resubscribing is up to the caller; see
+// FollowEvents.
+func (c *Client) Events(ctx context.Context, f EventStreamFilter, since time.Time) (<-chan Event, <-chan error) {
+	args := filters.NewArgs()
+	for _, t := range f.Types {
+		args.Add("type", t)
+	}
+	for _, l := range f.Labels {
+		args.Add("label", l)
+	}
+
+	opts := types.EventsOptions{Filters: args}
+	if !since.IsZero() {
+		opts.Since = strconv.FormatInt(since.Unix(), 10)
+	}
+
+	// The SDK never closes msgCh, and its stream goroutine only exits
+	// once its context ends or the stream fails
+	ctx, cancel := context.WithCancel(ctx)
+	msgCh, sdkErrCh := c.cli.Events(ctx, opts)
+	out := make(chan Event)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+				return
+			case err := <-sdkErrCh:
+				if err == nil {
+					err = io.EOF
+				}
+				errCh <- err
+				return
+			case msg := <-msgCh:
+				select {
+				case out <- eventFromMessage(msg):
+				case <-ctx.Done():
+					errCh <- ctx.Err()
+					return
+				}
+			}
+		}
+	}()
+	return out, errCh
+}
+
+// EventHistory returns recorded events matching the filter
+func (c *Client) EventHistory(f EventFilter) []Event {
+	return c.history.Query(f)
+}
+
+func eventFromMessage(msg events.Message) Event {
+	ev := Event{
+		Type:       msg.Type,
+		Action:     msg.Action,
+		ActorID:    msg.Actor.ID,
+		Attributes: msg.Actor.Attributes,
+		Time:       msg.TimeNano / int64(time.Millisecond),
+		TimeNano:   msg.TimeNano,
+	}
+	if msg.Actor.Attributes != nil {
+		ev.Name = msg.Actor.Attributes["name"]
+		ev.Image = msg.Actor.Attributes["image"]
+	}
+	return ev
+}
+
+// ParseSince parses a point in time given as a duration ago ("10m", "1h"),
+// an RFC3339 timestamp, a date (local midnight, as Docker reads it), or
+// Unix seconds. "0" is the Unix epoch, i.e. everything.
+func ParseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	// Before durations: "0" is also a valid duration, meaning now
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			d = -d
+		}
+		return time.Now().Add(-d), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid since value %q: use a duration (10m), RFC3339 time, date (2006-01-02), or Unix seconds", value)
+}
@@ -0,0 +1,153 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		ago     time.Duration // Checked against now instead of want
+		wantErr bool
+	}{
+		{value: "", want: time.Time{}},
+		{value: "0", want: time.Unix(0, 0)},
+		{value: "1700000000", want: time.Unix(1700000000, 0)},
+		{value: "10m", ago: 10 * time.Minute},
+		{value: "-1h", ago: time.Hour},
+		{value: "2024-01-02T03:04:05Z", want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{value: "2024-01-02", want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)},
+		{value: "yesterday", wantErr: true},
+		{value: "2024-13-01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSince(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.ago != 0 {
+				if diff := time.Since(got) - tt.ago; diff < 0 || diff > time.Minute {
+					t.Errorf("got %v, want about %s ago", got, tt.ago)
+				}
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The event channel closes when the daemon ends the stream, rather than
+// leaving the forwarding goroutine behind
+func TestEventsClosesWhenStreamEnds(t *testing.T) {
+	c := newFakeDaemon(t, time.Second, func(w http.ResponseWriter, r *http.Request) {
+		writeEvent(w, "only", time.Now())
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	eventCh, errCh := c.Events(ctx, EventStreamFilter{}, time.Time{})
+
+	if ev := <-eventCh; ev.ActorID != "only" {
+		t.Fatalf("got event %q, want %q", ev.ActorID, "only")
+	}
+	select {
+	case _, ok := <-eventCh:
+		if ok {
+			t.Fatal("got a second event")
+		}
+	case <-ctx.Done():
+		t.Fatal("event channel not closed after the stream ended")
+	}
+	if err := <-errCh; err == nil || ctx.Err() != nil {
+		t.Fatalf("got error %v, want the stream's", err)
+	}
+}
+
+// After the stream ends, FollowEvents resubscribes from the first
+// subscription while no event was seen, then from the last event, and
+// delivers each event once
+func TestFollowEventsResumes(t *testing.T) {
+	start := time.Now()
+	a := time.Now().Add(time.Millisecond)
+	b := a.Add(time.Millisecond)
+
+	var mu sync.Mutex
+	var sinces []string
+	c := newFakeDaemon(t, time.Second, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sinces = append(sinces, r.URL.Query().Get("since"))
+		n := len(sinces)
+		mu.Unlock()
+
+		switch n {
+		case 1: // Ends before any event
+		case 2:
+			writeEvent(w, "a", a)
+		default:
+			writeEvent(w, "a", a) // Replayed by since
+			writeEvent(w, "b", b)
+			<-r.Context().Done()
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	got := make(chan string, 10)
+	go c.FollowEvents(ctx, EventStreamFilter{}, time.Time{}, func(ev Event) {
+		got <- ev.ActorID
+		if ev.ActorID == "b" {
+			cancel()
+		}
+	})
+
+	<-ctx.Done()
+	if ctx.Err() == context.DeadlineExceeded {
+		t.Fatal("event b never arrived")
+	}
+	close(got)
+	var ids []string
+	for id := range got {
+		ids = append(ids, id)
+	}
+	if strings.Join(ids, ",") != "a,b" {
+		t.Errorf("got events %v, want [a b]", ids)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sinces) < 3 {
+		t.Fatalf("got %d subscriptions, want 3", len(sinces))
+	}
+	// The SDK sends since as seconds with a fractional part
+	secs := func(since string) int64 {
+		whole, _, _ := strings.Cut(since, ".")
+		n, _ := strconv.ParseInt(whole, 10, 64)
+		return n
+	}
+	if sinces[0] != "" {
+		t.Errorf("first subscription: since %q, want none", sinces[0])
+	}
+	if got := secs(sinces[1]); got < start.Unix() || got > start.Unix()+1 {
+		t.Errorf("second subscription: since %q, want the first subscription's time %d", sinces[1], start.Unix())
+	}
+	if got := secs(sinces[2]); got != a.Unix() {
+		t.Errorf("third subscription: since %q, want event a's time %d", sinces[2], a.Unix())
+	}
+}
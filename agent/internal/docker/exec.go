@@ -0,0 +1,98 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// DefaultExecShell is the shell started in a container when none is requested
+const DefaultExecShell = "/bin/sh"
+
+// ExecOptions customizes the process started by ExecTTY
+type ExecOptions struct {
+	Shell      string   // Shell to run; DefaultExecShell if empty
+	WorkingDir string   // Working directory inside the container
+	Env        []string // Extra KEY=VALUE entries
+}
+
+// ExecSession is an interactive process running inside a container with a
+// TTY. It reads and writes the hijacked exec connection and satisfies
+// terminal.Process.
+type ExecSession struct {
+	client *Client
+	id     string
+	conn   types.HijackedResponse
+	once   sync.Once
+}
+
+// ExecTTY starts a shell inside a container with a TTY of the given size
+// and attaches to it
+func (c *Client) ExecTTY(ctx context.Context, containerID string, opts ExecOptions, cols, rows uint16) (*ExecSession, error) {
+	shell := opts.Shell
+	if shell == "" {
+		shell = DefaultExecShell
+	}
+
+	size := &[2]uint{uint(rows), uint(cols)}
+	createCtx, cancel := c.apiContext(ctx)
+	created, err := c.cli.ContainerExecCreate(createCtx, containerID, types.ExecConfig{
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		ConsoleSize:  size,
+		Env:          append([]string{"TERM=xterm-256color", "COLORTERM=truecolor"}, opts.Env...),
+		WorkingDir:   opts.WorkingDir,
+		Cmd:          []string{shell},
+	})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	conn, err := c.cli.ContainerExecAttach(ctx, created.ID, types.ExecStartCheck{
+		Tty:         true,
+		ConsoleSize: size,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+
+	return &ExecSession{
+		client: c,
+		id:     created.ID,
+		conn:   conn,
+	}, nil
+}
+
+// Read reads TTY output. With a TTY the stream is raw, not multiplexed.
+func (e *ExecSession) Read(b []byte) (int, error) {
+	return e.conn.Reader.Read(b)
+}
+
+// Write sends input to the process
+func (e *ExecSession) Write(b []byte) (int, error) {
+	return e.conn.Conn.Write(b)
+}
+
+// Resize changes the exec TTY size
+func (e *ExecSession) Resize(cols, rows uint16) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return e.client.cli.ContainerExecResize(ctx, e.id, types.ResizeOptions{
+		Height: uint(rows),
+		Width:  uint(cols),
+	})
+}
+
+// Close detaches from the exec. Dropping the connection hangs up the TTY,
+// which ends an interactive shell.
+func (e *ExecSession) Close() error {
+	e.once.Do(e.conn.Close)
+	return nil
+}
@@ -0,0 +1,229 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// DefaultLogMaxBytes caps a container log read when no limit is given
+const DefaultLogMaxBytes = 1024 * 1024
+
+// maxLogLineBytes splits overlong lines when following logs, so a process
+// writing without newlines cannot grow the buffer without bound
+const maxLogLineBytes = 64 * 1024
+
+// ContainerLogOutput holds container logs split by stream
+type ContainerLogOutput struct {
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Logs      string `json:"logs"`      // Both streams in the order they were written
+	Truncated bool   `json:"truncated"` // Older output was dropped to stay within the limit
+}
+
+// ContainerLogOptions selects which container logs to read
+type ContainerLogOptions struct {
+	Tail       string // Number of lines from the end, or "all"
+	Since      string // RFC 3339 time, Unix timestamp or duration ago such as "10m"; see ParseSince
+	Timestamps bool   // Prefix every line with its timestamp
+	MaxBytes   int64  // Newest bytes kept across both streams; DefaultLogMaxBytes if not positive
+}
+
+// ReadContainerLogs reads container logs into separate stdout and stderr
+// text. Docker's stream headers are stripped; containers with a TTY have
+// a single raw stream, which is reported as stdout. Past the byte limit
+// the oldest output is dropped, so the newest lines are always returned.
+func (c *Client) ReadContainerLogs(ctx context.Context, id string, opts ContainerLogOptions) (*ContainerLogOutput, error) {
+	reader, tty, err := c.openContainerLogs(ctx, id, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	limit := opts.MaxBytes
+	if limit <= 0 {
+		limit = DefaultLogMaxBytes
+	}
+	capture := &logCapture{limit: limit}
+	stdout := &logStream{capture: capture}
+	stderr := &logStream{capture: capture, stderr: true}
+
+	if tty {
+		_, err = io.Copy(stdout, reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return capture.output(), nil
+}
+
+// FollowContainerLogs streams container logs line by line until ctx is
+// cancelled or the container stops. fn receives the stream name, "stdout"
+// or "stderr", with each line; it is never called concurrently.
+func (c *Client) FollowContainerLogs(ctx context.Context, id string, opts ContainerLogOptions, follow bool, fn func(stream, line string)) error {
+	reader, tty, err := c.openContainerLogs(ctx, id, opts, follow)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	stdout := &lineWriter{stream: "stdout", fn: fn}
+	stderr := &lineWriter{stream: "stderr", fn: fn}
+	if tty {
+		_, err = io.Copy(stdout, reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	}
+	stdout.flush()
+	stderr.flush()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// openContainerLogs opens the log stream of a container and reports
+// whether it has a TTY, in which case the stream is raw rather than
+// multiplexed
+func (c *Client) openContainerLogs(ctx context.Context, id string, opts ContainerLogOptions, follow bool) (io.ReadCloser, bool, error) {
+	since, err := sinceTimestamp(opts.Since)
+	if err != nil {
+		return nil, false, err
+	}
+
+	inspect, err := c.InspectContainer(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	tty := inspect.Config != nil && inspect.Config.Tty
+
+	reader, err := c.cli.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		Timestamps: opts.Timestamps,
+		Tail:       opts.Tail,
+		Since:      since,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return reader, tty, nil
+}
+
+// sinceTimestamp converts a since value accepted by ParseSince into the
+// Unix timestamp form Docker's log API takes. Empty stays empty, meaning
+// from the beginning.
+func sinceTimestamp(value string) (string, error) {
+	t, err := ParseSince(value)
+	if err != nil || t.IsZero() {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond()), nil
+}
+
+// lineWriter calls fn for every complete line written to it
+type lineWriter struct {
+	stream string
+	fn     func(stream, line string)
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(w.stream, string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) >= maxLogLineBytes {
+		w.flush()
+	}
+	return len(p), nil
+}
+
+// flush emits a trailing partial line
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.fn(w.stream, string(w.buf))
+		w.buf = nil
+	}
+}
+
+// logCapture keeps the newest limit bytes written to either stream, in
+// the order they were written
+type logCapture struct {
+	limit     int64
+	size      int64
+	truncated bool
+	chunks    []logChunk
+}
+
+// logChunk is one write to a stream
+type logChunk struct {
+	stderr bool
+	data   []byte
+}
+
+func (c *logCapture) add(stderr bool, p []byte) {
+	c.chunks = append(c.chunks, logChunk{stderr: stderr, data: bytes.Clone(p)})
+	c.size += int64(len(p))
+
+	for c.size > c.limit {
+		c.truncated = true
+		first := &c.chunks[0]
+		cut := int(c.size - c.limit)
+		// Start at the next line when the cut falls inside one
+		if cut < len(first.data) && first.data[cut-1] != '\n' {
+			if i := bytes.IndexByte(first.data[cut:], '\n'); i >= 0 {
+				cut += i + 1
+			}
+		}
+		if cut >= len(first.data) {
+			c.size -= int64(len(first.data))
+			c.chunks = c.chunks[1:]
+			continue
+		}
+		first.data = first.data[cut:]
+		c.size -= int64(cut)
+	}
+}
+
+func (c *logCapture) output() *ContainerLogOutput {
+	var stdout, stderr, combined bytes.Buffer
+	for _, chunk := range c.chunks {
+		if chunk.stderr {
+			stderr.Write(chunk.data)
+		} else {
+			stdout.Write(chunk.data)
+		}
+		combined.Write(chunk.data)
+	}
+	return &ContainerLogOutput{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Logs:      combined.String(),
+		Truncated: c.truncated,
+	}
+}
+
+// logStream writes one stream into the shared capture
+type logStream struct {
+	capture *logCapture
+	stderr  bool
+}
+
+func (s *logStream) Write(p []byte) (int, error) {
+	s.capture.add(s.stderr, p)
+	return len(p), nil
+}
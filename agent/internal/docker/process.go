@@ -0,0 +1,106 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// processGracePeriod is how long a stopped docker CLI command gets to exit
+// after being asked before its whole process group is killed
+const processGracePeriod = 10 * time.Second
+
+// processTracker records the docker CLI commands that are running so the
+// client can stop them on Close
+type processTracker struct {
+	mu      sync.Mutex
+	running map[*exec.Cmd]struct{}
+	wg      sync.WaitGroup
+}
+
+// command builds a docker CLI command that runs in its own process group.
+// Cancelling ctx stops the whole group, so `docker compose` plugins and
+// the builds they start are not orphaned.
+func (c *Client) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	stopOnCancel(cmd)
+	// Grandchildren can keep the output pipes open after the CLI is gone
+	cmd.WaitDelay = processGracePeriod + 5*time.Second
+	return cmd
+}
+
+// output runs cmd and returns its stdout
+func (c *Client) output(cmd *exec.Cmd) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := c.run(cmd)
+	return stdout.Bytes(), err
+}
+
+// combinedOutput runs cmd and returns its stdout and stderr
+func (c *Client) combinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := c.run(cmd)
+	return out.Bytes(), err
+}
+
+// run starts cmd and waits for it, tracking it meanwhile
+func (c *Client) run(cmd *exec.Cmd) error {
+	if err := c.procs.start(cmd); err != nil {
+		return err
+	}
+	return c.procs.wait(cmd)
+}
+
+// start starts cmd and tracks it until wait
+func (t *processTracker) start(cmd *exec.Cmd) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if t.running == nil {
+		t.running = make(map[*exec.Cmd]struct{})
+	}
+	t.running[cmd] = struct{}{}
+	t.wg.Add(1)
+	return nil
+}
+
+// wait waits for a command started with start
+func (t *processTracker) wait(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+
+	t.mu.Lock()
+	delete(t.running, cmd)
+	t.mu.Unlock()
+	t.wg.Done()
+
+	return err
+}
+
+// stopAll stops every running command and waits for them to exit, killing
+// those that outlast the grace period
+func (t *processTracker) stopAll() {
+	t.mu.Lock()
+	for cmd := range t.running {
+		terminate(cmd)
+	}
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(processGracePeriod + time.Second):
+	}
+}
@@ -0,0 +1,63 @@
+//go:build !windows
+
+package docker
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// groupKills holds the pending SIGKILL of each terminated command's
+// process group, so a command cancelled twice gets only one
+var groupKills = struct {
+	sync.Mutex
+	timers map[*exec.Cmd]*time.Timer
+}{timers: make(map[*exec.Cmd]*time.Timer)}
+
+// stopOnCancel runs cmd in its own process group and stops the whole group
+// when its context is cancelled. `docker compose` runs as a plugin child of
+// the docker CLI, so stopping only the CLI would orphan it.
+func stopOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return terminate(cmd)
+	}
+}
+
+// terminate sends SIGTERM to cmd's process group, so compose can stop the
+// containers it started, and SIGKILL once processGracePeriod has passed
+func terminate(cmd *exec.Cmd) error {
+	pgid := cmd.Process.Pid
+	err := syscall.Kill(-pgid, syscall.SIGTERM)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+
+	// The group outlives the CLI if a child ignores SIGTERM, so it is
+	// killed even when the CLI itself has exited by then
+	groupKills.Lock()
+	if _, ok := groupKills.timers[cmd]; !ok {
+		groupKills.timers[cmd] = time.AfterFunc(processGracePeriod, func() {
+			killGroup(pgid)
+			groupKills.Lock()
+			delete(groupKills.timers, cmd)
+			groupKills.Unlock()
+		})
+	}
+	groupKills.Unlock()
+	return err
+}
+
+// killGroup sends SIGKILL to the process group pgid unless it is empty.
+// A group ID is not reused while any member is alive, so a group that
+// still exists is the command's.
+func killGroup(pgid int) {
+	if err := syscall.Kill(-pgid, 0); errors.Is(err, syscall.ESRCH) {
+		return
+	}
+	syscall.Kill(-pgid, syscall.SIGKILL)
+}
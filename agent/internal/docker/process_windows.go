@@ -0,0 +1,24 @@
+package docker
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// stopOnCancel stops cmd and its children when its context is cancelled
+func stopOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return terminate(cmd)
+	}
+}
+
+// terminate kills cmd's process tree. Console programs on Windows have no
+// equivalent of SIGTERM, so there is no graceful step.
+func terminate(cmd *exec.Cmd) error {
+	pid := strconv.Itoa(cmd.Process.Pid)
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", pid).Run(); err != nil {
+		// Still take down the CLI itself
+		return cmd.Process.Kill()
+	}
+	return nil
+}
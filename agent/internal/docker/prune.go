@@ -0,0 +1,160 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/filters"
+)
+
+// PruneFilters narrows what a prune removes
+type PruneFilters struct {
+	Until    string   `json:"until,omitempty"`    // Only resources created before this, e.g. "24h" or a timestamp
+	Dangling *bool    `json:"dangling,omitempty"` // Images only: false also removes unused tagged images
+	Labels   []string `json:"labels,omitempty"`   // label, label=value, label!=value
+}
+
+// pruneResource is the kind of object a prune endpoint removes
+type pruneResource int
+
+const (
+	pruneContainers pruneResource = iota
+	pruneImages
+	pruneNetworks
+	pruneVolumes
+)
+
+// args converts the filters for one prune endpoint. Docker rejects filters
+// an endpoint does not know: until is not accepted for volumes, and
+// dangling only for images.
+func (f PruneFilters) args(resource pruneResource) filters.Args {
+	args := filters.NewArgs()
+	if f.Until != "" && resource != pruneVolumes {
+		args.Add("until", f.Until)
+	}
+	if f.Dangling != nil && resource == pruneImages {
+		args.Add("dangling", fmt.Sprintf("%t", *f.Dangling))
+	}
+	for _, l := range f.Labels {
+		if len(l) > 1 && l[0] == '!' {
+			args.Add("label!", l[1:])
+		} else {
+			args.Add("label", l)
+		}
+	}
+	return args
+}
+
+// PruneReport lists what a prune removed
+type PruneReport struct {
+	Removed        []string `json:"removed"`
+	SpaceReclaimed uint64   `json:"space_reclaimed"`
+}
+
+// SystemPruneReport combines the per-resource prune reports
+type SystemPruneReport struct {
+	Containers     *PruneReport `json:"containers"`
+	Images         *PruneReport `json:"images"`
+	Networks       *PruneReport `json:"networks"`
+	Volumes        *PruneReport `json:"volumes,omitempty"`
+	SpaceReclaimed uint64       `json:"space_reclaimed"`
+}
+
+// PruneContainers removes stopped containers
+func (c *Client) PruneContainers(ctx context.Context, f PruneFilters) (*PruneReport, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	report, err := c.cli.ContainersPrune(ctx, f.args(pruneContainers))
+	if err != nil {
+		return nil, err
+	}
+	return &PruneReport{
+		Removed:        nonNil(report.ContainersDeleted),
+		SpaceReclaimed: report.SpaceReclaimed,
+	}, nil
+}
+
+// PruneImages removes dangling images, or all unused images when
+// f.Dangling is false
+func (c *Client) PruneImages(ctx context.Context, f PruneFilters) (*PruneReport, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	report, err := c.cli.ImagesPrune(ctx, f.args(pruneImages))
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	for _, item := range report.ImagesDeleted {
+		if item.Deleted != "" {
+			removed = append(removed, item.Deleted)
+		}
+	}
+	return &PruneReport{
+		Removed:        removed,
+		SpaceReclaimed: report.SpaceReclaimed,
+	}, nil
+}
+
+// PruneVolumes removes volumes not used by any container. This deletes
+// data, so callers must ask for it explicitly.
+func (c *Client) PruneVolumes(ctx context.Context, f PruneFilters) (*PruneReport, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	report, err := c.cli.VolumesPrune(ctx, f.args(pruneVolumes))
+	if err != nil {
+		return nil, err
+	}
+	return &PruneReport{
+		Removed:        nonNil(report.VolumesDeleted),
+		SpaceReclaimed: report.SpaceReclaimed,
+	}, nil
+}
+
+// PruneNetworks removes networks not used by any container
+func (c *Client) PruneNetworks(ctx context.Context, f PruneFilters) (*PruneReport, error) {
+	ctx, cancel := c.apiContext(ctx)
+	defer cancel()
+	report, err := c.cli.NetworksPrune(ctx, f.args(pruneNetworks))
+	if err != nil {
+		return nil, err
+	}
+	return &PruneReport{
+		Removed: nonNil(report.NetworksDeleted),
+	}, nil
+}
+
+// SystemPrune prunes containers, networks and images, in that order so
+// images freed by removed containers are reclaimed too. Volumes are only
+// pruned when includeVolumes is set.
+func (c *Client) SystemPrune(ctx context.Context, f PruneFilters, includeVolumes bool) (*SystemPruneReport, error) {
+	var report SystemPruneReport
+	var err error
+
+	if report.Containers, err = c.PruneContainers(ctx, f); err != nil {
+		return nil, fmt.Errorf("failed to prune containers: %w", err)
+	}
+	if report.Networks, err = c.PruneNetworks(ctx, f); err != nil {
+		return nil, fmt.Errorf("failed to prune networks: %w", err)
+	}
+	if includeVolumes {
+		if report.Volumes, err = c.PruneVolumes(ctx, f); err != nil {
+			return nil, fmt.Errorf("failed to prune volumes: %w", err)
+		}
+		report.SpaceReclaimed += report.Volumes.SpaceReclaimed
+	}
+	if report.Images, err = c.PruneImages(ctx, f); err != nil {
+		return nil, fmt.Errorf("failed to prune images: %w", err)
+	}
+
+	report.SpaceReclaimed += report.Containers.SpaceReclaimed + report.Images.SpaceReclaimed
+	return &report, nil
+}
+
+// nonNil keeps empty lists as [] rather than null in JSON
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,230 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	networktypes "github.com/docker/docker/api/types/network"
+)
+
+// ImageUpdateResult describes the outcome of pulling and recreating a container
+type ImageUpdateResult struct {
+	Image          string `json:"image"`
+	OldImageID     string `json:"old_image_id"`
+	NewImageID     string `json:"new_image_id"`
+	OldDigest      string `json:"old_digest,omitempty"`
+	NewDigest      string `json:"new_digest,omitempty"`
+	ImageChanged   bool   `json:"image_changed"`
+	Recreated      bool   `json:"recreated"`
+	DryRun         bool   `json:"dry_run"`
+	OldContainerID string `json:"old_container_id"`
+	NewContainerID string `json:"new_container_id,omitempty"`
+}
+
+// UpdateContainerImage pulls the image tag a container was created from and,
+// if a newer image was fetched (or force is set), recreates the container
+// with the same configuration, mounts and networks. With dryRun the image is
+// pulled but the container is left untouched.
+func (c *Client) UpdateContainerImage(ctx context.Context, id string, force, dryRun bool) (*ImageUpdateResult, error) {
+	cont, err := c.InspectContainer(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	imageRef := cont.Config.Image
+	result := &ImageUpdateResult{
+		Image:          imageRef,
+		OldImageID:     cont.Image,
+		OldDigest:      c.imageDigest(ctx, cont.Image),
+		DryRun:         dryRun,
+		OldContainerID: cont.ID,
+	}
+
+	// Pull the tag and wait for the pull to finish
+	auth := c.registryAuth(imageRef, "")
+	reader, err := c.cli.ImagePull(ctx, imageRef, types.ImagePullOptions{
+		RegistryAuth: auth,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %w", imageRef, redactError(err, auth))
+	}
+	_, err = io.Copy(io.Discard, reader)
+	reader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %w", imageRef, err)
+	}
+
+	newImage, err := c.InspectImage(ctx, imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect pulled image: %w", err)
+	}
+	result.NewImageID = newImage.ID
+	result.NewDigest = firstDigest(newImage.RepoDigests)
+	result.ImageChanged = newImage.ID != cont.Image
+
+	if (!result.ImageChanged && !force) || dryRun {
+		return result, nil
+	}
+
+	newID, err := c.recreateContainer(ctx, *cont)
+	if err != nil {
+		return result, err
+	}
+
+	result.Recreated = true
+	result.NewContainerID = newID
+	return result, nil
+}
+
+// recreateContainer replaces a container with a new one built from the same
+// configuration. The old container is renamed out of the way and only removed
+// once the replacement is running, so a failure rolls back to it.
+func (c *Client) recreateContainer(ctx context.Context, cont types.ContainerJSON) (string, error) {
+	name := strings.TrimPrefix(cont.Name, "/")
+	wasRunning := cont.State != nil && cont.State.Running
+
+	if wasRunning {
+		if err := c.StopContainer(ctx, cont.ID, nil); err != nil {
+			return "", fmt.Errorf("failed to stop container: %w", err)
+		}
+	}
+
+	backupName := fmt.Sprintf("%s-old-%d", name, time.Now().Unix())
+	if err := c.RenameContainer(ctx, cont.ID, backupName); err != nil {
+		c.restoreContainer(ctx, cont.ID, "", wasRunning)
+		return "", fmt.Errorf("failed to rename old container: %w", err)
+	}
+
+	config := *cont.Config
+	// A hostname equal to the old short ID was generated, not user-set
+	if config.Hostname == shortID(cont.ID) {
+		config.Hostname = ""
+	}
+
+	// Only one network can be attached at create time; the rest are connected after
+	endpoints := make(map[string]*networktypes.EndpointSettings)
+	if cont.NetworkSettings != nil {
+		for netName, ep := range cont.NetworkSettings.Networks {
+			endpoints[netName] = cleanEndpoint(ep, cont.ID)
+		}
+	}
+
+	var networkingConfig *networktypes.NetworkingConfig
+	primary := ""
+	if cont.HostConfig != nil {
+		primary = string(cont.HostConfig.NetworkMode)
+	}
+	// Containers created without a network report "default", which
+	// Docker attaches as "bridge"
+	if primary == "" || primary == "default" {
+		primary = "bridge"
+	}
+	if ep, ok := endpoints[primary]; ok {
+		networkingConfig = &networktypes.NetworkingConfig{
+			EndpointsConfig: map[string]*networktypes.EndpointSettings{primary: ep},
+		}
+	}
+
+	createCtx, cancel := c.apiContext(ctx)
+	created, err := c.cli.ContainerCreate(createCtx, &config, cont.HostConfig, networkingConfig, nil, name)
+	cancel()
+	if err != nil {
+		c.restoreContainer(ctx, cont.ID, name, wasRunning)
+		return "", fmt.Errorf("failed to create new container: %w", err)
+	}
+
+	rollback := func(cause error) (string, error) {
+		c.RemoveContainer(ctx, created.ID, true, false)
+		c.restoreContainer(ctx, cont.ID, name, wasRunning)
+		return "", cause
+	}
+
+	// The new container is already attached to the network it was
+	// created on
+	for netName, ep := range endpoints {
+		if netName == primary {
+			continue
+		}
+		connectCtx, cancel := c.apiContext(ctx)
+		err := c.cli.NetworkConnect(connectCtx, netName, created.ID, ep)
+		cancel()
+		if err != nil {
+			return rollback(fmt.Errorf("failed to connect network %s: %w", netName, err))
+		}
+	}
+
+	if wasRunning {
+		if err := c.StartContainer(ctx, created.ID); err != nil {
+			return rollback(fmt.Errorf("failed to start new container: %w", err))
+		}
+	}
+
+	if err := c.RemoveContainer(ctx, cont.ID, true, false); err != nil {
+		c.log.Warn("Failed to remove old container", "id", cont.ID, "error", err)
+	}
+
+	c.log.Info("Container recreated",
+		"name", name,
+		"old_id", shortID(cont.ID),
+		"new_id", shortID(created.ID),
+	)
+
+	return created.ID, nil
+}
+
+// restoreContainer puts the original container back after a failed recreate
+func (c *Client) restoreContainer(ctx context.Context, id, name string, start bool) {
+	if name != "" {
+		if err := c.RenameContainer(ctx, id, name); err != nil {
+			c.log.Error("Failed to restore container name", "id", id, "error", err)
+		}
+	}
+	if start {
+		if err := c.StartContainer(ctx, id); err != nil {
+			c.log.Error("Failed to restart original container", "id", id, "error", err)
+		}
+	}
+}
+
+// imageDigest returns the first repo digest of an image, if known
+func (c *Client) imageDigest(ctx context.Context, imageID string) string {
+	img, err := c.InspectImage(ctx, imageID)
+	if err != nil {
+		return ""
+	}
+	return firstDigest(img.RepoDigests)
+}
+
+func firstDigest(digests []string) string {
+	if len(digests) == 0 {
+		return ""
+	}
+	return digests[0]
+}
+
+// cleanEndpoint keeps the user configuration of an endpoint and drops the
+// operational data and the alias Docker adds for the old container ID
+func cleanEndpoint(ep *networktypes.EndpointSettings, containerID string) *networktypes.EndpointSettings {
+	if ep == nil {
+		return &networktypes.EndpointSettings{}
+	}
+
+	aliases := make([]string, 0, len(ep.Aliases))
+	for _, alias := range ep.Aliases {
+		if alias == shortID(containerID) {
+			continue
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return &networktypes.EndpointSettings{
+		IPAMConfig: ep.IPAMConfig,
+		Links:      ep.Links,
+		Aliases:    aliases,
+		DriverOpts: ep.DriverOpts,
+	}
+}
@@ -0,0 +1,139 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+)
+
+// dockerHubConfigKey is the key docker login uses for Docker Hub
+const dockerHubConfigKey = "https://index.docker.io/v1/"
+
+// redacted replaces credentials in output sent back to the server
+const redacted = "[REDACTED]"
+
+// dockerConfigFile is the subset of ~/.docker/config.json the agent reads
+type dockerConfigFile struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+}
+
+// registryAuth returns the X-Registry-Auth value for ref. Credentials
+// supplied with the command win; otherwise the host's docker config is
+// consulted. An empty string means the pull or push is anonymous.
+func (c *Client) registryAuth(ref, supplied string) string {
+	if supplied != "" {
+		return supplied
+	}
+
+	auth, err := hostRegistryAuth(ref)
+	if err != nil {
+		c.log.Debug("No host registry credentials", "image", ref, "error", err)
+		return ""
+	}
+	return auth
+}
+
+// hostRegistryAuth looks up ref's registry in the host's docker config.
+// Credential helpers and stores are not supported.
+func hostRegistryAuth(ref string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", err
+	}
+	domain := reference.Domain(named)
+
+	data, err := os.ReadFile(dockerConfigPath())
+	if err != nil {
+		return "", err
+	}
+	var cfg dockerConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", fmt.Errorf("invalid docker config: %w", err)
+	}
+
+	key := domain
+	if domain == "docker.io" {
+		key = dockerHubConfigKey
+	}
+	entry, ok := cfg.Auths[key]
+	if !ok {
+		// Older logins store the registry as a URL
+		entry, ok = cfg.Auths["https://"+domain]
+	}
+	if !ok {
+		return "", fmt.Errorf("no credentials for %s", domain)
+	}
+
+	authConfig := registry.AuthConfig{
+		ServerAddress: key,
+		IdentityToken: entry.IdentityToken,
+	}
+	if entry.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return "", fmt.Errorf("invalid credentials for %s", domain)
+		}
+		user, pass, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return "", fmt.Errorf("invalid credentials for %s", domain)
+		}
+		authConfig.Username = user
+		authConfig.Password = pass
+	}
+
+	return registry.EncodeAuthConfig(authConfig)
+}
+
+// dockerConfigPath honours DOCKER_CONFIG like the docker CLI does
+func dockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// RedactRegistryAuth removes an encoded auth value and the secrets inside
+// it from text that is about to be logged or sent to the server
+func RedactRegistryAuth(text, registryAuth string) string {
+	if registryAuth == "" {
+		return text
+	}
+
+	secrets := []string{registryAuth}
+	if authConfig, err := registry.DecodeAuthConfig(registryAuth); err == nil {
+		secrets = append(secrets,
+			authConfig.Password,
+			authConfig.Auth,
+			authConfig.IdentityToken,
+			authConfig.RegistryToken,
+		)
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, redacted)
+		}
+	}
+	return text
+}
+
+// redactError wraps err with its credentials redacted
+func redactError(err error, registryAuth string) error {
+	if err == nil || registryAuth == "" {
+		return err
+	}
+	msg := RedactRegistryAuth(err.Error(), registryAuth)
+	if msg == err.Error() {
+		return err
+	}
+	return fmt.Errorf("%s", msg)
+}
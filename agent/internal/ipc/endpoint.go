@@ -0,0 +1,53 @@
+package ipc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Endpoint is where the running agent's IPC server listens. The agent
+// records it at startup because the live endpoint can differ from the
+// config the tray loaded: the port may have been picked by the OS, or
+// the agent may have been started with another config file.
+type Endpoint struct {
+	Socket  string `json:"socket,omitempty"`  // Unix socket or named pipe
+	Address string `json:"address,omitempty"` // host:port of the TCP listener
+	PID     int    `json:"pid"`
+}
+
+// EndpointFile returns where the agent records its endpoint, next to the
+// token file
+func EndpointFile(tokenFile string) string {
+	return filepath.Join(filepath.Dir(tokenFile), "ipc.endpoint.json")
+}
+
+// ReadEndpoint reads the endpoint recorded by the running agent
+func ReadEndpoint(tokenFile string) (*Endpoint, error) {
+	data, err := os.ReadFile(EndpointFile(tokenFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var ep Endpoint
+	if err := json.Unmarshal(data, &ep); err != nil {
+		return nil, fmt.Errorf("invalid IPC endpoint file: %w", err)
+	}
+	if ep.Socket == "" && ep.Address == "" {
+		return nil, fmt.Errorf("IPC endpoint file has no address")
+	}
+	return &ep, nil
+}
+
+// writeEndpoint records the live endpoint for the tray
+func writeEndpoint(tokenFile string, ep Endpoint) error {
+	data, err := json.Marshal(ep)
+	if err != nil {
+		return err
+	}
+	if err := writeRuntimeFile(EndpointFile(tokenFile), string(data)); err != nil {
+		return fmt.Errorf("failed to write IPC endpoint file: %w", err)
+	}
+	return nil
+}
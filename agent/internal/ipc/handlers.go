@@ -82,6 +82,130 @@ func (h *Handlers) HandleLogs(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HandleSessions returns the active terminal sessions
+func (h *Handlers) HandleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessions := h.provider.GetTerminalSessions()
+	h.writeJSON(w, map[string]interface{}{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// HandleSessionClose forcibly closes a terminal session
+func (h *Handlers) HandleSessionClose(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	h.log.Info("Terminal session close requested via IPC", "session_id", id)
+
+	if err := h.provider.CloseTerminalSession(id); err != nil {
+		h.writeJSON(w, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	h.writeJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleUpdate returns pending-update information
+func (h *Handlers) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := h.provider.GetStatus()
+	h.writeJSON(w, map[string]interface{}{
+		"pending":         h.provider.HasPendingUpdate(),
+		"current_version": status.Version,
+		"latest_version":  h.provider.GetLatestVersion(),
+	})
+}
+
+// HandleUpdateInstall installs the pending update
+func (h *Handlers) HandleUpdateInstall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.log.Info("Update install requested via IPC")
+
+	if err := h.provider.InstallUpdate(); err != nil {
+		h.writeJSON(w, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	h.writeJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "Update started",
+	})
+}
+
+// HandlePause stops heartbeats and metric streams
+func (h *Handlers) HandlePause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.log.Info("Pause requested via IPC")
+
+	if err := h.provider.Pause(); err != nil {
+		h.writeJSON(w, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	h.writeJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// HandleResume restarts heartbeats and metric streams
+func (h *Handlers) HandleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.log.Info("Resume requested via IPC")
+
+	if err := h.provider.Resume(); err != nil {
+		h.writeJSON(w, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	h.writeJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
 // HandleRestart triggers a graceful agent restart
 func (h *Handlers) HandleRestart(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -0,0 +1,31 @@
+//go:build !windows
+
+package ipc
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/serverkit/agent/internal/logger"
+)
+
+// createPrivate creates path, replacing any existing file, readable and
+// writable only by the agent's user
+func createPrivate(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// OpenFile keeps the mode of an existing file
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to set permissions: %w", err)
+	}
+	return f, nil
+}
+
+// followTrayUser does nothing here: the tray runs as the agent's user or
+// as root, both of which can read a 0600 file
+func followTrayUser(ctx context.Context, log *logger.Logger, paths ...string) {}
@@ -0,0 +1,136 @@
+package ipc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/serverkit/agent/internal/logger"
+)
+
+// trayUserPoll is how often the console user is checked, so a tray
+// started by a later login can still read the token
+const trayUserPoll = 10 * time.Second
+
+// runtimeFileSecurity builds the security descriptor of files the tray
+// reads. SYSTEM, Administrators and the agent's own user get full access
+// and the tray user, when known, read access. The DACL is protected, so
+// inherited entries such as BUILTIN\Users read under C:\ProgramData do
+// not apply; mode 0600 means nothing on Windows.
+func runtimeFileSecurity(tray *windows.SID) (*windows.SECURITY_DESCRIPTOR, error) {
+	self, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the agent's user: %w", err)
+	}
+
+	sddl := "D:P(A;;FA;;;SY)(A;;FA;;;BA)(A;;FA;;;" + self.User.Sid.String() + ")"
+	if tray != nil && !tray.Equals(self.User.Sid) {
+		sddl += "(A;;FR;;;" + tray.String() + ")"
+	}
+	return windows.SecurityDescriptorFromString(sddl)
+}
+
+// trayUser returns the user logged on at the console, whose tray reads
+// the token while the agent runs as a service. It is nil when nobody is
+// logged on or the agent lacks the privilege to ask, as when it runs in
+// the user's own session; the tray then shares the agent's user.
+func trayUser() *windows.SID {
+	session := windows.WTSGetActiveConsoleSessionId()
+	if session == 0xFFFFFFFF {
+		return nil
+	}
+
+	var token windows.Token
+	if err := windows.WTSQueryUserToken(session, &token); err != nil {
+		return nil
+	}
+	defer token.Close()
+
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return nil
+	}
+	sid, err := user.User.Sid.Copy()
+	if err != nil {
+		return nil
+	}
+	return sid
+}
+
+// createPrivate creates path, replacing any existing file, with the
+// runtime file DACL set from the start so the content is never readable
+// by other users
+func createPrivate(path string) (*os.File, error) {
+	sd, err := runtimeFileSecurity(trayUser())
+	if err != nil {
+		return nil, fmt.Errorf("failed to set permissions: %w", err)
+	}
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// CreateFile ignores the security descriptor when the file exists
+	os.Remove(path)
+	sa := &windows.SecurityAttributes{
+		Length:             uint32(unsafe.Sizeof(windows.SecurityAttributes{})),
+		SecurityDescriptor: sd,
+	}
+	h, err := windows.CreateFile(name, windows.GENERIC_WRITE, 0, sa,
+		windows.CREATE_NEW, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
+
+// grantTrayUser replaces the DACL of paths with one that lets tray read
+func grantTrayUser(tray *windows.SID, paths ...string) error {
+	sd, err := runtimeFileSecurity(tray)
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		err := windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+			windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION,
+			nil, nil, dacl, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// followTrayUser grants read access on paths to whoever logs on at the
+// console, until ctx is cancelled. A service usually starts before
+// anyone logs on, and the tray starts with the login.
+func followTrayUser(ctx context.Context, log *logger.Logger, paths ...string) {
+	current := trayUser()
+	ticker := time.NewTicker(trayUserPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		user := trayUser()
+		if user == nil || (current != nil && user.Equals(current)) {
+			continue
+		}
+		current = user
+		if err := grantTrayUser(user, paths...); err != nil {
+			log.Warn("Failed to give the tray user access to the IPC token", "error", err)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/serverkit/agent/internal/config"
@@ -17,12 +19,20 @@ type StatusProvider interface {
 	GetDetailedMetrics() *DetailedMetrics
 	GetConnectionInfo() ConnectionInfo
 	GetRecentLogs(lines int) []string
+	GetTerminalSessions() []TerminalSession
+	CloseTerminalSession(id string) error
+	HasPendingUpdate() bool
+	GetLatestVersion() string
+	InstallUpdate() error
+	Pause() error
+	Resume() error
 	Restart() error
 }
 
 // AgentStatus represents the current agent status
 type AgentStatus struct {
 	Running     bool    `json:"running"`
+	Paused      bool    `json:"paused"`
 	Connected   bool    `json:"connected"`
 	Registered  bool    `json:"registered"`
 	AgentID     string  `json:"agent_id"`
@@ -33,6 +43,22 @@ type AgentStatus struct {
 	CPUPercent  float64 `json:"cpu_percent"`
 	MemPercent  float64 `json:"mem_percent"`
 	DiskPercent float64 `json:"disk_percent"`
+
+	// ConnectionState is the latest WebSocket state transition, e.g.
+	// "authenticated" or "reconnecting"
+	ConnectionState string `json:"connection_state,omitempty"`
+
+	// Why the agent last restarted (signal, update, ipc, watchdog, error
+	// or crash-recovery) and how many times it has since install
+	LastRestartReason string `json:"last_restart_reason,omitempty"`
+	RestartCount      int    `json:"restart_count"`
+
+	// Commands executing now and waiting for a free slot
+	CommandsRunning int `json:"commands_running"`
+	CommandsQueued  int `json:"commands_queued"`
+
+	UpdatePending bool   `json:"update_pending"`
+	LatestVersion string `json:"latest_version,omitempty"`
 }
 
 // DetailedMetrics contains detailed system metrics
@@ -65,6 +91,11 @@ type DiskMetrics struct {
 	Used         uint64  `json:"used"`
 	Free         uint64  `json:"free"`
 	UsagePercent float64 `json:"usage_percent"`
+
+	// Inode usage; zero where the filesystem has no inodes (Windows)
+	InodesTotal   uint64  `json:"inodes_total,omitempty"`
+	InodesUsed    uint64  `json:"inodes_used,omitempty"`
+	InodesPercent float64 `json:"inodes_percent,omitempty"`
 }
 
 // NetworkMetrics contains network information
@@ -82,15 +113,40 @@ type ConnectionInfo struct {
 	ReconnectCount int    `json:"reconnect_count"`
 	LastConnected  int64  `json:"last_connected,omitempty"`
 	SessionExpires int64  `json:"session_expires,omitempty"`
+
+	// Latest connection state transition and when it happened
+	State            string `json:"state,omitempty"`
+	StateSince       int64  `json:"state_since,omitempty"`
+	LastDisconnected int64  `json:"last_disconnected,omitempty"`
+
+	// Heartbeat round-trip time in milliseconds
+	LatencyMs    float64 `json:"latency_ms,omitempty"`
+	AvgLatencyMs float64 `json:"avg_latency_ms,omitempty"`
+
+	// How far the server's clock is ahead of the agent's, measured at
+	// authentication; negative when it is behind
+	ClockSkewMs    int64 `json:"clock_skew_ms"`
+	ClockSkewKnown bool  `json:"clock_skew_known"`
+}
+
+// TerminalSession describes an open remote terminal
+type TerminalSession struct {
+	ID        string `json:"id"`
+	Shell     string `json:"shell"`
+	Cols      uint16 `json:"cols"`
+	Rows      uint16 `json:"rows"`
+	CreatedAt int64  `json:"created_at"` // Unix milliseconds
 }
 
 // Server is the IPC HTTP server for tray app communication
 type Server struct {
-	cfg      config.IPCConfig
-	log      *logger.Logger
-	server   *http.Server
-	provider StatusProvider
+	cfg       config.IPCConfig
+	log       *logger.Logger
+	server    *http.Server
+	provider  StatusProvider
 	startTime time.Time
+	done      chan struct{} // Closed on shutdown to end /ws streams
+	doneOnce  sync.Once
 }
 
 // NewServer creates a new IPC server
@@ -100,6 +156,7 @@ func NewServer(cfg config.IPCConfig, log *logger.Logger, provider StatusProvider
 		log:       log.WithComponent("ipc"),
 		provider:  provider,
 		startTime: time.Now(),
+		done:      make(chan struct{}),
 	}
 }
 
@@ -118,44 +175,62 @@ func (s *Server) Start(ctx context.Context) error {
 	mux.HandleFunc("/metrics", handlers.HandleMetrics)
 	mux.HandleFunc("/connection", handlers.HandleConnection)
 	mux.HandleFunc("/logs", handlers.HandleLogs)
+	mux.HandleFunc("/sessions", handlers.HandleSessions)
+	mux.HandleFunc("/sessions/close", handlers.HandleSessionClose)
+	mux.HandleFunc("/update", handlers.HandleUpdate)
+	mux.HandleFunc("/update/install", handlers.HandleUpdateInstall)
+	mux.HandleFunc("/pause", handlers.HandlePause)
+	mux.HandleFunc("/resume", handlers.HandleResume)
 	mux.HandleFunc("/restart", handlers.HandleRestart)
 	mux.HandleFunc("/health", handlers.HandleHealth)
+	mux.HandleFunc("/ws", s.handleStream)
 
-	addr := fmt.Sprintf("%s:%d", s.cfg.Address, s.cfg.Port)
+	if s.cfg.TokenFile == "" {
+		return fmt.Errorf("IPC token file is not configured")
+	}
+	token, err := generateToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate IPC token: %w", err)
+	}
+	if err := writeTokenFile(s.cfg.TokenFile, token); err != nil {
+		return err
+	}
 
-	// Verify we're only binding to localhost for security
-	host, _, err := net.SplitHostPort(addr)
-	if err != nil || (host != "127.0.0.1" && host != "localhost" && host != "::1") {
-		s.log.Warn("IPC server can only bind to localhost, forcing 127.0.0.1")
-		addr = fmt.Sprintf("127.0.0.1:%d", s.cfg.Port)
+	ln, addr, err := s.listen()
+	if err != nil {
+		return fmt.Errorf("IPC server failed to start: %w", err)
+	}
+	// The tray dials whatever is recorded here, which may differ from its
+	// own copy of the config
+	endpoint := Endpoint{PID: os.Getpid()}
+	if s.cfg.Socket != "" {
+		endpoint.Socket = addr
+	} else {
+		endpoint.Address = addr
 	}
+	if err := writeEndpoint(s.cfg.TokenFile, endpoint); err != nil {
+		ln.Close()
+		return err
+	}
+	go followTrayUser(ctx, s.log, s.cfg.TokenFile, EndpointFile(s.cfg.TokenFile))
 
 	s.server = &http.Server{
-		Addr:         addr,
-		Handler:      corsMiddleware(mux),
+		Handler:      corsMiddleware(authMiddleware(token, mux)),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	// Shutdown does not track hijacked connections
+	s.server.RegisterOnShutdown(func() { s.doneOnce.Do(func() { close(s.done) }) })
 
-	s.log.Info("Starting IPC server", "address", addr)
+	s.log.Info("Starting IPC server", "address", addr, "token_file", s.cfg.TokenFile)
 
-	// Start server in goroutine
-	errCh := make(chan error, 1)
 	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errCh <- err
+		if err := s.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.log.Error("IPC server stopped", "error", err)
 		}
 	}()
 
-	// Check for immediate startup errors
-	select {
-	case err := <-errCh:
-		return fmt.Errorf("IPC server failed to start: %w", err)
-	case <-time.After(100 * time.Millisecond):
-		// Server started successfully
-	}
-
 	// Wait for context cancellation
 	go func() {
 		<-ctx.Done()
@@ -165,6 +240,36 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// listen opens the configured socket, or a localhost TCP port when no
+// socket is set. Port 0, or a configured port that is already taken, lets
+// the OS pick a free one.
+func (s *Server) listen() (net.Listener, string, error) {
+	if s.cfg.Socket != "" {
+		ln, err := listenSocket(s.cfg.Socket)
+		return ln, s.cfg.Socket, err
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.cfg.Address, s.cfg.Port)
+
+	// Verify we're only binding to localhost for security
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || (host != "127.0.0.1" && host != "localhost" && host != "::1") {
+		s.log.Warn("IPC server can only bind to localhost, forcing 127.0.0.1")
+		addr = fmt.Sprintf("127.0.0.1:%d", s.cfg.Port)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil && s.cfg.Port != 0 {
+		host, _, _ := net.SplitHostPort(addr)
+		s.log.Warn("IPC port unavailable, letting the OS pick one", "port", s.cfg.Port, "error", err)
+		ln, err = net.Listen("tcp", net.JoinHostPort(host, "0"))
+	}
+	if err != nil {
+		return nil, addr, err
+	}
+	return ln, ln.Addr().String(), nil
+}
+
 // Stop gracefully stops the IPC server
 func (s *Server) Stop() error {
 	if s.server == nil {
@@ -173,6 +278,12 @@ func (s *Server) Stop() error {
 
 	s.log.Info("Stopping IPC server")
 
+	// The token is regenerated on every start
+	if s.cfg.TokenFile != "" {
+		os.Remove(s.cfg.TokenFile)
+		os.Remove(EndpointFile(s.cfg.TokenFile))
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -187,7 +298,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 		if origin == "" || isLocalhost(origin) {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+TokenHeader)
 		}
 
 		if r.Method == "OPTIONS" {
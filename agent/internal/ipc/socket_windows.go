@@ -0,0 +1,199 @@
+package ipc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+)
+
+// pipeSecurity builds the named pipe counterpart of a 0600 socket.
+// SYSTEM, Administrators and the agent's own user get full access and the
+// tray user, when known, read/write so a tray run by a non-admin can
+// connect to an agent running as a service.
+func pipeSecurity(tray *windows.SID) (string, error) {
+	self, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the agent's user: %w", err)
+	}
+
+	sddl := "D:P(A;;GA;;;SY)(A;;GA;;;BA)(A;;GA;;;" + self.User.Sid.String() + ")"
+	if tray != nil && !tray.Equals(self.User.Sid) {
+		sddl += "(A;;GRGW;;;" + tray.String() + ")"
+	}
+	return sddl, nil
+}
+
+// listenSocket listens on a named pipe such as \\.\pipe\serverkit-agent
+// that the console user can connect to
+func listenSocket(path string) (net.Listener, error) {
+	tray := trayUser()
+	ln, err := listenPipe(path, tray)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &pipeListener{
+		path:  path,
+		ln:    ln,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go l.follow(tray)
+	return l, nil
+}
+
+// listenPipe creates the pipe with tray granted access
+func listenPipe(path string, tray *windows.SID) (net.Listener, error) {
+	sddl, err := pipeSecurity(tray)
+	if err != nil {
+		return nil, err
+	}
+	return winio.ListenPipe(path, &winio.PipeConfig{
+		SecurityDescriptor: sddl,
+	})
+}
+
+// pipeListener serves a named pipe whose DACL follows the console user.
+// A pipe's security is fixed when it is created, so a new user means a
+// new pipe; connections already accepted are not affected.
+type pipeListener struct {
+	path string
+
+	mu    sync.Mutex
+	ln    net.Listener  // nil while the pipe is recreated
+	ready chan struct{} // closed once ln is set again
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Accept waits for a connection, carrying on across a recreated pipe
+func (l *pipeListener) Accept() (net.Conn, error) {
+	for {
+		l.mu.Lock()
+		ln, ready := l.ln, l.ready
+		l.mu.Unlock()
+
+		if ln == nil {
+			select {
+			case <-ready:
+				continue
+			case <-l.done:
+				return nil, net.ErrClosed
+			}
+		}
+
+		conn, err := ln.Accept()
+		if err == nil {
+			return conn, nil
+		}
+		select {
+		case <-l.done:
+			return nil, net.ErrClosed
+		default:
+		}
+
+		l.mu.Lock()
+		replaced := l.ln != ln
+		l.mu.Unlock()
+		if !replaced {
+			return nil, err
+		}
+	}
+}
+
+// Close closes the pipe and stops following the console user
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ln == nil {
+		return nil
+	}
+	return l.ln.Close()
+}
+
+// Addr returns the pipe's address
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr(l.path)
+}
+
+// follow recreates the pipe for whoever logs on at the console, until the
+// listener is closed. A pipe that could not be recreated, because a
+// connection to the old one is still open, is retried on the next poll.
+func (l *pipeListener) follow(current *windows.SID) {
+	ticker := time.NewTicker(trayUserPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+		}
+
+		user := trayUser()
+		changed := user != nil && (current == nil || !user.Equals(current))
+		if changed {
+			current = user
+		}
+		if changed || !l.open() {
+			l.reopen(current)
+		}
+	}
+}
+
+// open reports whether the pipe is listening
+func (l *pipeListener) open() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ln != nil
+}
+
+// reopen replaces the pipe with one that grants tray access. The old
+// pipe has to go first, since only one can exist under the name.
+func (l *pipeListener) reopen(tray *windows.SID) {
+	l.mu.Lock()
+	old := l.ln
+	if old != nil {
+		l.ln = nil
+		l.ready = make(chan struct{})
+	}
+	l.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+
+	ln, err := listenPipe(l.path, tray)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.done:
+		ln.Close()
+		return
+	default:
+	}
+	l.ln = ln
+	close(l.ready)
+}
+
+// pipeAddr is the net.Addr of a named pipe
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// DialSocket connects to the agent's IPC named pipe
+func DialSocket(ctx context.Context, path string) (net.Conn, error) {
+	return winio.DialPipeContext(ctx, path)
+}
@@ -0,0 +1,80 @@
+package ipc
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// StreamInterval is how often /ws pushes a status update
+const StreamInterval = 1 * time.Second
+
+// StreamUpdate is the message pushed to /ws clients
+type StreamUpdate struct {
+	Status           AgentStatus `json:"status"`
+	TerminalSessions int         `json:"terminal_sessions"`
+	Timestamp        int64       `json:"timestamp"` // Unix milliseconds
+}
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || isLocalhost(origin)
+	},
+}
+
+// handleStream upgrades to a WebSocket and pushes a StreamUpdate every
+// StreamInterval until the client goes away or the server stops
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response
+		s.log.Debug("IPC stream upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// The server's read and write timeouts still apply to the hijacked
+	// connection, so clear them and set a deadline per write instead
+	conn.SetReadDeadline(time.Time{})
+
+	// Clients never send anything; reading only detects the close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(StreamInterval)
+	defer ticker.Stop()
+
+	for {
+		update := StreamUpdate{
+			Status:           s.provider.GetStatus(),
+			TerminalSessions: len(s.provider.GetTerminalSessions()),
+			Timestamp:        time.Now().UnixMilli(),
+		}
+		conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.WriteJSON(update); err != nil {
+			return
+		}
+
+		select {
+		case <-closed:
+			return
+		case <-s.done:
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "agent stopping"),
+				time.Now().Add(time.Second))
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package logger
+
+import "os"
+
+// enableColor reports whether f can display ANSI colors. Unix terminals
+// handle them natively.
+func enableColor(f *os.File) bool {
+	return true
+}
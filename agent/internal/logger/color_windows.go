@@ -0,0 +1,21 @@
+package logger
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableColor switches the console to virtual terminal processing so ANSI
+// colors render, reporting false on consoles that cannot
+func enableColor(f *os.File) bool {
+	handle := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
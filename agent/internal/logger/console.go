@@ -0,0 +1,208 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/serverkit/agent/internal/config"
+)
+
+// ANSI colors for console levels
+const (
+	colorReset  = "\033[0m"
+	colorGray   = "\033[90m"
+	colorCyan   = "\033[36m"
+	colorYellow = "\033[33m"
+	colorRed    = "\033[31m"
+)
+
+// newConsoleHandler returns the handler for stdout in the given format
+func newConsoleHandler(w *os.File, format string, opts *slog.HandlerOptions) slog.Handler {
+	tty := isTerminal(w)
+	switch format {
+	case config.LogFormatText:
+	case config.LogFormatAuto, "":
+		if !tty {
+			return slog.NewJSONHandler(w, opts)
+		}
+	default:
+		return slog.NewJSONHandler(w, opts)
+	}
+
+	// NO_COLOR is the common convention for opting out of colors
+	color := tty && os.Getenv("NO_COLOR") == "" && enableColor(w)
+	return &textHandler{opts: *opts, color: color, header: true, write: lineWriter(w)}
+}
+
+// lineWriter writes whole lines to w, one record at a time
+func lineWriter(w io.Writer) func(slog.Level, string) error {
+	var mu sync.Mutex
+	return func(_ slog.Level, line string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := io.WriteString(w, line+"\n")
+		return err
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// textHandler formats one human-readable line per record:
+// "15:04:05.000 INFO  message key=value ...", with the level colored
+// on terminals. System log sinks record the time and level themselves,
+// so they get the line without that header.
+type textHandler struct {
+	opts   slog.HandlerOptions
+	color  bool
+	header bool                                      // Start lines with the time and level
+	write  func(level slog.Level, line string) error // Shared by derived handlers
+	prefix string                                    // Key prefix from WithGroup, e.g. "request."
+	attrs  string                                    // Attributes from WithAttrs, already formatted
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+
+	if h.header {
+		if !r.Time.IsZero() {
+			b.WriteString(r.Time.Format("15:04:05.000"))
+			b.WriteByte(' ')
+		}
+		h.writeLevel(&b, r.Level)
+		b.WriteByte(' ')
+	}
+	b.WriteString(redactString(r.Message))
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.writeAttr(&b, h.prefix, a)
+		return true
+	})
+
+	return h.write(r.Level, b.String())
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		h.writeAttr(&b, h.prefix, a)
+	}
+	next := *h
+	next.attrs += b.String()
+	return &next
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := *h
+	next.prefix += name + "."
+	return &next
+}
+
+// writeLevel writes the level padded to a fixed width
+func (h *textHandler) writeLevel(b *strings.Builder, level slog.Level) {
+	name := level.String()
+	color := colorGray
+	switch {
+	case level >= slog.LevelError:
+		color = colorRed
+	case level >= slog.LevelWarn:
+		color = colorYellow
+	case level >= slog.LevelInfo:
+		color = colorCyan
+	}
+
+	if h.color {
+		b.WriteString(color)
+	}
+	b.WriteString(name)
+	if h.color {
+		b.WriteString(colorReset)
+	}
+	b.WriteString(strings.Repeat(" ", max(5-len(name), 0)))
+}
+
+// writeAttr writes " key=value", flattening groups into dotted keys
+func (h *textHandler) writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range group {
+			h.writeAttr(b, prefix, ga)
+		}
+		return
+	}
+	if h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(nil, a)
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	b.WriteByte(' ')
+	if h.color {
+		b.WriteString(colorGray)
+	}
+	b.WriteString(prefix + a.Key + "=")
+	if h.color {
+		b.WriteString(colorReset)
+	}
+	b.WriteString(quoteValue(formatValue(a.Value)))
+}
+
+// formatValue renders a value as text; structured values as JSON
+func formatValue(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return x.Error()
+		case json.RawMessage:
+			return string(x)
+		case []byte:
+			return string(x)
+		case []string, map[string]interface{}, map[string]string:
+			if data, err := json.Marshal(x); err == nil {
+				return string(data)
+			}
+		}
+	}
+	return v.String()
+}
+
+// quoteValue quotes values that would otherwise be ambiguous on the line.
+// JSON objects and arrays are left as they are.
+func quoteValue(s string) string {
+	if json.Valid([]byte(s)) && (strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")) {
+		return s
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\r\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
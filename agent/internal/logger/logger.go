@@ -1,7 +1,6 @@
 package logger
 
 import (
-	"io"
 	"os"
 	"path/filepath"
 
@@ -13,32 +12,59 @@ import (
 // Logger wraps slog.Logger with additional context
 type Logger struct {
 	*slog.Logger
+	// Shared with every logger derived from this one
+	level        *slog.LevelVar // File
+	consoleLevel *slog.LevelVar // Stdout
 }
 
-// New creates a new logger with the given configuration
-func New(cfg config.LoggingConfig) *Logger {
-	var level slog.Level
-	switch cfg.Level {
+// parseLevel maps a config log level to slog, defaulting to info
+func parseLevel(name string) slog.Level {
+	switch name {
 	case "debug":
-		level = slog.LevelDebug
+		return slog.LevelDebug
 	case "info":
-		level = slog.LevelInfo
+		return slog.LevelInfo
 	case "warn":
-		level = slog.LevelWarn
+		return slog.LevelWarn
 	case "error":
-		level = slog.LevelError
+		return slog.LevelError
 	default:
-		level = slog.LevelInfo
+		return slog.LevelInfo
 	}
+}
 
-	opts := &slog.HandlerOptions{
-		Level: level,
-	}
+// New creates a new logger with the given configuration
+func New(cfg config.LoggingConfig) *Logger {
+	l := &Logger{level: new(slog.LevelVar), consoleLevel: new(slog.LevelVar)}
+	l.SetLevel(cfg.Level, cfg.ConsoleLevel)
 
-	var writers []io.Writer
+	var handlers []slog.Handler
+
+	// The system log records at the file level
+	var sinkErr error
+	journal := false
+	if cfg.Syslog != "" && cfg.Syslog != config.LogSyslogOff {
+		h, err := newSystemHandler(cfg.Syslog, &slog.HandlerOptions{
+			Level:       l.level,
+			ReplaceAttr: redactAttr,
+		})
+		if err != nil {
+			sinkErr = err
+		} else {
+			handlers = append(handlers, h)
+			journal = cfg.Syslog == config.LogJournald
+		}
+	}
 
-	// Always write to stdout
-	writers = append(writers, os.Stdout)
+	// The console is for people; the rotated file stays JSON for tooling.
+	// Under systemd stdout already goes to the journal, so it is skipped
+	// when writing there directly to avoid every line appearing twice.
+	if !journal || os.Getenv("JOURNAL_STREAM") == "" {
+		handlers = append(handlers, newConsoleHandler(os.Stdout, cfg.Format, &slog.HandlerOptions{
+			Level:       l.consoleLevel,
+			ReplaceAttr: redactAttr,
+		}))
+	}
 
 	// Also write to file if configured
 	if cfg.File != "" {
@@ -53,22 +79,43 @@ func New(cfg config.LoggingConfig) *Logger {
 				MaxAge:     cfg.MaxAge, // days
 				Compress:   cfg.Compress,
 			}
-			writers = append(writers, fileWriter)
+			handlers = append(handlers, slog.NewJSONHandler(fileWriter, &slog.HandlerOptions{
+				Level:       l.level,
+				ReplaceAttr: redactAttr,
+			}))
 		}
 	}
 
-	// Create multi-writer
-	multiWriter := io.MultiWriter(writers...)
+	var handler slog.Handler = multiHandler(handlers)
+	if len(handlers) == 1 {
+		handler = handlers[0]
+	}
+	l.Logger = slog.New(handler)
 
-	handler := slog.NewJSONHandler(multiWriter, opts)
-	logger := slog.New(handler)
+	if sinkErr != nil {
+		l.Warn("System log unavailable", "sink", cfg.Syslog, "error", sinkErr)
+	}
 
-	return &Logger{Logger: logger}
+	return l
 }
 
 // With returns a new logger with additional attributes
 func (l *Logger) With(args ...any) *Logger {
-	return &Logger{Logger: l.Logger.With(args...)}
+	return &Logger{Logger: l.Logger.With(args...), level: l.level, consoleLevel: l.consoleLevel}
+}
+
+// SetLevel changes the file and console levels of this logger and every
+// logger derived from the same root, e.g. on a config reload. An empty
+// console level follows the file level.
+func (l *Logger) SetLevel(name, console string) {
+	if l.level == nil {
+		return
+	}
+	if console == "" {
+		console = name
+	}
+	l.level.Set(parseLevel(name))
+	l.consoleLevel.Set(parseLevel(console))
 }
 
 // WithComponent returns a logger with a component name
@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secret values in log output
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute and field names whose values are never
+// logged. A key matches when it equals one of these or ends with "_" and
+// one of these, after lowercasing and turning '-' into '_', so
+// "X-API-Secret" and "registry_auth" match but "token_file" does not.
+var sensitiveKeys = []string{
+	"token",
+	"secret",
+	"api_key",
+	"password",
+	"passwd",
+	"signature",
+	"registry_auth",
+	"private_key",
+	"authorization",
+	"cookie",
+}
+
+var (
+	// jsonFieldPattern finds "key": "value" pairs inside serialized JSON
+	jsonFieldPattern = regexp.MustCompile(`"([A-Za-z0-9_-]+)"\s*:\s*"((?:[^"\\]|\\.)*)"`)
+	// urlUserinfoPattern finds passwords in URLs such as proxy addresses
+	urlUserinfoPattern = regexp.MustCompile(`(://[^/\s:@]+:)[^@\s/]+@`)
+	// queryParamPattern finds secrets passed as query parameters
+	queryParamPattern = regexp.MustCompile(`(?i)([?&](?:token|secret|signature|password|api_key|api_secret)=)[^&\s"]+`)
+)
+
+// isSensitiveKey reports whether values under key must be redacted
+func isSensitiveKey(key string) bool {
+	key = strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	for _, s := range sensitiveKeys {
+		if key == s || strings.HasSuffix(key, "_"+s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString masks secrets embedded in free text: JSON fields with
+// sensitive names, URL passwords and secret query parameters
+func redactString(s string) string {
+	s = jsonFieldPattern.ReplaceAllStringFunc(s, func(field string) string {
+		m := jsonFieldPattern.FindStringSubmatch(field)
+		if !isSensitiveKey(m[1]) {
+			return field
+		}
+		return `"` + m[1] + `":"` + redacted + `"`
+	})
+	s = urlUserinfoPattern.ReplaceAllString(s, "${1}"+redacted+"@")
+	return queryParamPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// redactAttr is the slog ReplaceAttr hook. Sensitive keys lose their value
+// outright; strings, errors and structured values are scanned for embedded
+// secrets.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redactString(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(redactString(v.Error()))
+		case json.RawMessage:
+			a.Value = slog.AnyValue(json.RawMessage(redactString(string(v))))
+		case []byte:
+			a.Value = slog.StringValue(redactString(string(v)))
+		default:
+			// Maps and structs are checked in their serialized form
+			data, err := json.Marshal(v)
+			if err != nil {
+				return a
+			}
+			if clean := redactString(string(data)); clean != string(data) {
+				a.Value = slog.AnyValue(json.RawMessage(clean))
+			}
+		}
+	}
+	return a
+}
@@ -0,0 +1,92 @@
+//go:build !windows
+
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"log/syslog"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/serverkit/agent/internal/config"
+)
+
+// syslogTag identifies the agent in the system log
+const syslogTag = "serverkit-agent"
+
+// journalSocket is where journald accepts native protocol datagrams
+const journalSocket = "/run/systemd/journal/socket"
+
+// newSystemHandler returns a handler writing to the given system log sink
+func newSystemHandler(sink string, opts *slog.HandlerOptions) (slog.Handler, error) {
+	var write func(slog.Level, string) error
+	switch sink {
+	case config.LogSyslog:
+		w, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, syslogTag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		write = func(level slog.Level, line string) error {
+			switch syslogPriority(level) {
+			case syslog.LOG_ERR:
+				return w.Err(line)
+			case syslog.LOG_WARNING:
+				return w.Warning(line)
+			case syslog.LOG_INFO:
+				return w.Info(line)
+			default:
+				return w.Debug(line)
+			}
+		}
+	case config.LogJournald:
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to journald: %w", err)
+		}
+		write = func(level slog.Level, line string) error {
+			_, err := conn.Write(journalEntry(syslogPriority(level), line))
+			return err
+		}
+	default:
+		return nil, fmt.Errorf("system log %q is not supported on this platform", sink)
+	}
+
+	return &textHandler{opts: *opts, write: write}, nil
+}
+
+// syslogPriority maps a slog level to a syslog severity
+func syslogPriority(level slog.Level) syslog.Priority {
+	switch {
+	case level >= slog.LevelError:
+		return syslog.LOG_ERR
+	case level >= slog.LevelWarn:
+		return syslog.LOG_WARNING
+	case level >= slog.LevelInfo:
+		return syslog.LOG_INFO
+	default:
+		return syslog.LOG_DEBUG
+	}
+}
+
+// journalEntry encodes a record in the journald native protocol. Values
+// containing newlines use the length-prefixed binary form.
+func journalEntry(priority syslog.Priority, message string) []byte {
+	var b bytes.Buffer
+	field := func(name, value string) {
+		if !strings.Contains(value, "\n") {
+			b.WriteString(name + "=" + value + "\n")
+			return
+		}
+		b.WriteString(name + "\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(value)))
+		b.WriteString(value + "\n")
+	}
+	field("PRIORITY", strconv.Itoa(int(priority)))
+	field("SYSLOG_IDENTIFIER", syslogTag)
+	field("MESSAGE", message)
+	return b.Bytes()
+}
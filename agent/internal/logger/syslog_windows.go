@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+
+	"github.com/serverkit/agent/internal/config"
+)
+
+// eventSource is the Application event log source; it matches the one
+// registered by "service install"
+const eventSource = "ServerKitAgent"
+
+// eventID is the ID used for every entry. Sources registered as
+// EventCreate accept IDs 1 to 1000.
+const eventID = 1
+
+// newSystemHandler returns a handler writing to the Windows event log
+func newSystemHandler(sink string, opts *slog.HandlerOptions) (slog.Handler, error) {
+	if sink != config.LogEventLog {
+		return nil, fmt.Errorf("system log %q is not supported on Windows", sink)
+	}
+
+	el, err := eventlog.Open(eventSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the event log: %w", err)
+	}
+
+	// The event log has no debug severity, so debug is logged as info
+	write := func(level slog.Level, line string) error {
+		switch {
+		case level >= slog.LevelError:
+			return el.Error(eventID, line)
+		case level >= slog.LevelWarn:
+			return el.Warning(eventID, line)
+		default:
+			return el.Info(eventID, line)
+		}
+	}
+	return &textHandler{opts: *opts, write: write}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/cpu"
@@ -21,12 +22,31 @@ type Collector struct {
 	cfg config.MetricsConfig
 	log *logger.Logger
 
+	// mu serializes collections: the agent's loops, IPC requests and
+	// Prometheus scrapes all collect, and each updates the rate baselines
+	mu     sync.Mutex
+	latest *SystemMetrics // Copy of the most recent sample, for Recent
+
 	// Previous values for rate calculations
 	prevNetworkRx uint64
 	prevNetworkTx uint64
+	prevDiskIO    *diskIOTotals // Nil until the first disk I/O sample
 	prevTime      time.Time
+	cpuWarm       bool // A CPU sample has been taken, so deltas are meaningful
+	missing       missingDisks
+
+	// Handles of watched processes, kept between WatchProcesses calls so
+	// CPU usage is measured since the previous call. procMu is separate
+	// from mu because collect calls WatchProcesses with mu held.
+	procMu sync.Mutex
+	procs  map[processKey]*process.Process
 }
 
+// cpuWarmup is how long the first CPU reading blocks when no sample
+// interval is configured. Without an earlier sample to compare against,
+// a non-blocking read covers only the moments since startup.
+const cpuWarmup = time.Second
+
 // SystemMetrics contains all collected metrics
 type SystemMetrics struct {
 	Timestamp     int64   `json:"timestamp"`
@@ -41,14 +61,25 @@ type SystemMetrics struct {
 	DiskTotal     uint64  `json:"disk_total"`
 	DiskUsed      uint64  `json:"disk_used"`
 	DiskPercent   float64 `json:"disk_percent"`
+	DiskInodesTotal   uint64  `json:"disk_inodes_total,omitempty"` // Zero where the filesystem has no inodes (Windows)
+	DiskInodesUsed    uint64  `json:"disk_inodes_used,omitempty"`
+	DiskInodesPercent float64 `json:"disk_inodes_percent,omitempty"`
 	NetworkRx     uint64  `json:"network_rx"`      // Bytes received (total)
 	NetworkTx     uint64  `json:"network_tx"`      // Bytes transmitted (total)
 	NetworkRxRate float64 `json:"network_rx_rate"` // Bytes/sec
 	NetworkTxRate float64 `json:"network_tx_rate"` // Bytes/sec
+	DiskReadRate  float64 `json:"disk_read_rate"`  // Bytes/sec, summed over all disks
+	DiskWriteRate float64 `json:"disk_write_rate"` // Bytes/sec, summed over all disks
+	DiskReadIOPS  float64 `json:"disk_read_iops"`  // Read operations/sec
+	DiskWriteIOPS float64 `json:"disk_write_iops"` // Write operations/sec
 	Uptime        uint64  `json:"uptime"`
 	LoadAvg1     float64 `json:"load_avg_1,omitempty"`
 	LoadAvg5     float64 `json:"load_avg_5,omitempty"`
 	LoadAvg15    float64 `json:"load_avg_15,omitempty"`
+	Pressure     *PressureMetrics `json:"pressure,omitempty"` // Linux PSI, when enabled
+	Processes    []ProcessWatchStatus `json:"processes,omitempty"` // Watched processes, when configured
+	Disks        []DiskUsage          `json:"disks,omitempty"`     // metrics.disk_paths, when configured
+	ConnectionStates map[string]int   `json:"connection_states,omitempty"` // TCP sockets by state, when enabled
 }
 
 // SystemInfo contains static system information
@@ -64,6 +95,7 @@ type SystemInfo struct {
 	CPUThreads   int    `json:"cpu_threads"`
 	TotalMemory  uint64 `json:"total_memory"`
 	TotalDisk    uint64 `json:"total_disk"`
+	Disks        []DiskUsage `json:"disks,omitempty"` // metrics.disk_paths, when configured
 }
 
 // ProcessInfo contains process information
@@ -88,25 +120,77 @@ func NewCollector(cfg config.MetricsConfig, log *logger.Logger) *Collector {
 	}
 }
 
+// cpuSampleInterval returns how long the next CPU reading should block:
+// the configured interval, or a one-time warm-up on the first collection
+func (c *Collector) cpuSampleInterval() time.Duration {
+	if c.cfg.CPUSampleInterval > 0 {
+		return c.cfg.CPUSampleInterval
+	}
+	if !c.cpuWarm {
+		c.cpuWarm = true
+		return cpuWarmup
+	}
+	return 0
+}
+
 // Collect collects current system metrics
 func (c *Collector) Collect(ctx context.Context) (*SystemMetrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics, err := c.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest := *metrics
+	c.latest = &latest
+	return metrics, nil
+}
+
+// Recent returns the most recent sample when it is younger than
+// metrics.interval, and collects a new one otherwise. Readers that poll on
+// their own schedule, such as Prometheus scrapes, reuse what the agent
+// already collected instead of taking extra samples.
+func (c *Collector) Recent(ctx context.Context) (*SystemMetrics, error) {
+	c.mu.Lock()
+	latest := c.latest
+	c.mu.Unlock()
+
+	maxAge, _ := config.EffectiveInterval(c.cfg.Interval)
+	if latest != nil && time.Since(time.UnixMilli(latest.Timestamp)) < maxAge {
+		sample := *latest
+		return &sample, nil
+	}
+	return c.Collect(ctx)
+}
+
+// collect takes one sample; c.mu must be held
+func (c *Collector) collect(ctx context.Context) (*SystemMetrics, error) {
 	now := time.Now()
 	metrics := &SystemMetrics{
 		Timestamp: now.UnixMilli(),
 	}
 
-	// CPU usage
-	cpuPercent, err := cpu.PercentWithContext(ctx, 0, false)
+	// CPU usage. Per-core CPU (optional) is sampled alongside, so a
+	// blocking sample only delays the collection once.
+	sample := c.cpuSampleInterval()
+	var perCore []float64
+	var perCoreErr error
+	perCoreDone := make(chan struct{})
+	go func() {
+		defer close(perCoreDone)
+		if c.cfg.IncludePerCPU {
+			perCore, perCoreErr = cpu.PercentWithContext(ctx, sample, true)
+		}
+	}()
+
+	cpuPercent, err := cpu.PercentWithContext(ctx, sample, false)
 	if err == nil && len(cpuPercent) > 0 {
 		metrics.CPUPercent = cpuPercent[0]
 	}
-
-	// Per-core CPU (optional)
-	if c.cfg.IncludePerCPU {
-		perCore, err := cpu.PercentWithContext(ctx, 0, true)
-		if err == nil {
-			metrics.CPUPerCore = perCore
-		}
+	<-perCoreDone
+	if c.cfg.IncludePerCPU && perCoreErr == nil {
+		metrics.CPUPerCore = perCore
 	}
 
 	// Memory
@@ -125,17 +209,17 @@ func (c *Collector) Collect(ctx context.Context) (*SystemMetrics, error) {
 		metrics.SwapPercent = swapInfo.UsedPercent
 	}
 
-	// Disk (root partition)
-	diskPath := "/"
-	if runtime.GOOS == "windows" {
-		diskPath = "C:\\"
-	}
-	diskInfo, err := disk.UsageWithContext(ctx, diskPath)
+	// Disk (root partition), kept alongside the configured disks
+	diskInfo, err := disk.UsageWithContext(ctx, rootDiskPath())
 	if err == nil {
 		metrics.DiskTotal = diskInfo.Total
 		metrics.DiskUsed = diskInfo.Used
 		metrics.DiskPercent = diskInfo.UsedPercent
+		metrics.DiskInodesTotal = diskInfo.InodesTotal
+		metrics.DiskInodesUsed = diskInfo.InodesUsed
+		metrics.DiskInodesPercent = diskInfo.InodesUsedPercent
 	}
+	metrics.Disks = c.collectDisks(ctx)
 
 	// Network I/O
 	netIO, err := net.IOCountersWithContext(ctx, false)
@@ -154,6 +238,20 @@ func (c *Collector) Collect(ctx context.Context) (*SystemMetrics, error) {
 		c.prevNetworkTx = netIO[0].BytesSent
 	}
 
+	// Disk I/O, as rates since the previous collection; the first sample
+	// only sets the baseline
+	diskIO, err := collectDiskIO(ctx)
+	if err == nil {
+		if prev := c.prevDiskIO; prev != nil {
+			elapsed := now.Sub(c.prevTime).Seconds()
+			metrics.DiskReadRate = counterRate(diskIO.readBytes, prev.readBytes, elapsed)
+			metrics.DiskWriteRate = counterRate(diskIO.writeBytes, prev.writeBytes, elapsed)
+			metrics.DiskReadIOPS = counterRate(diskIO.reads, prev.reads, elapsed)
+			metrics.DiskWriteIOPS = counterRate(diskIO.writes, prev.writes, elapsed)
+		}
+		c.prevDiskIO = &diskIO
+	}
+
 	// Uptime
 	hostInfo, err := host.InfoWithContext(ctx)
 	if err == nil {
@@ -169,6 +267,18 @@ func (c *Collector) Collect(ctx context.Context) (*SystemMetrics, error) {
 		}
 	}
 
+	// Pressure stall information (Linux only)
+	if c.cfg.IncludePressure {
+		metrics.Pressure = collectPressure()
+	}
+
+	// Watched processes
+	if patterns := c.watchPatterns(); len(patterns) > 0 {
+		if watched, err := c.WatchProcesses(ctx, patterns); err == nil {
+			metrics.Processes = watched
+		}
+	}
+
 	c.prevTime = now
 	return metrics, nil
 }
@@ -218,14 +328,11 @@ func (c *Collector) GetSystemInfo(ctx context.Context) (*SystemInfo, error) {
 	}
 
 	// Disk
-	diskPath := "/"
-	if runtime.GOOS == "windows" {
-		diskPath = "C:\\"
-	}
-	diskInfo, err := disk.UsageWithContext(ctx, diskPath)
+	diskInfo, err := disk.UsageWithContext(ctx, rootDiskPath())
 	if err == nil {
 		info.TotalDisk = diskInfo.Total
 	}
+	info.Disks = c.collectDisks(ctx)
 
 	return info, nil
 }
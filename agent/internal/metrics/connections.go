@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// ConnectionStates tallies TCP sockets (IPv4 and IPv6) by state, e.g.
+// ESTABLISHED, TIME_WAIT or LISTEN. Listing every socket is expensive on
+// busy hosts, so it is not part of Collect: the agent adds it on the
+// metrics interval only. Returns nil when metrics.include_connections is
+// off or the sockets cannot be read.
+func (c *Collector) ConnectionStates(ctx context.Context) map[string]int {
+	if !c.cfg.IncludeConnections {
+		return nil
+	}
+
+	conns, err := net.ConnectionsWithContext(ctx, "tcp")
+	if err != nil {
+		c.log.Debug("Failed to list TCP connections", "error", err)
+		return nil
+	}
+
+	states := make(map[string]int)
+	for _, conn := range conns {
+		state := conn.Status
+		if state == "" {
+			state = "UNKNOWN"
+		}
+		states[state]++
+	}
+	return states
+}
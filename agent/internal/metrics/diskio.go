@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// sysBlockDir lists whole block devices on Linux; partitions live below them
+const sysBlockDir = "/sys/block"
+
+// virtualDiskPrefixes are Linux block devices whose I/O is already counted
+// on the disks underneath them (device mapper, software RAID) or that are
+// not backed by a disk at all
+var virtualDiskPrefixes = []string{"loop", "ram", "zram", "dm-", "md"}
+
+// diskIOTotals holds cumulative disk I/O counters summed over all disks
+type diskIOTotals struct {
+	readBytes  uint64
+	writeBytes uint64
+	reads      uint64
+	writes     uint64
+}
+
+// collectDiskIO sums the I/O counters of every physical disk. On Linux,
+// partitions and virtual devices are skipped so no I/O is counted twice.
+func collectDiskIO(ctx context.Context) (diskIOTotals, error) {
+	var totals diskIOTotals
+
+	counters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return totals, err
+	}
+
+	for name, io := range counters {
+		if runtime.GOOS == "linux" && !isPhysicalDisk(name) {
+			continue
+		}
+		totals.readBytes += io.ReadBytes
+		totals.writeBytes += io.WriteBytes
+		totals.reads += io.ReadCount
+		totals.writes += io.WriteCount
+	}
+	return totals, nil
+}
+
+// isPhysicalDisk reports whether a Linux block device is a whole disk
+func isPhysicalDisk(name string) bool {
+	for _, prefix := range virtualDiskPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	_, err := os.Stat(filepath.Join(sysBlockDir, name))
+	return err == nil
+}
+
+// counterRate returns the per-second rate between two samples of a
+// cumulative counter, or zero when the counter went backwards (a device
+// was removed or the counter wrapped)
+func counterRate(current, previous uint64, elapsed float64) float64 {
+	if current < previous || elapsed <= 0 {
+		return 0
+	}
+	return float64(current-previous) / elapsed
+}
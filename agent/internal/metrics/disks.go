@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// DiskUsage is the usage of one monitored mount from metrics.disk_paths
+type DiskUsage struct {
+	Path          string  `json:"path"`
+	Fstype        string  `json:"fstype,omitempty"`
+	Total         uint64  `json:"total"`
+	Used          uint64  `json:"used"`
+	Free          uint64  `json:"free"`
+	UsagePercent  float64 `json:"usage_percent"`
+	InodesTotal   uint64  `json:"inodes_total,omitempty"` // Zero where the filesystem has no inodes (Windows)
+	InodesUsed    uint64  `json:"inodes_used,omitempty"`
+	InodesPercent float64 `json:"inodes_percent,omitempty"`
+}
+
+// missingDisks remembers configured disk paths that do not exist, so each
+// is warned about once rather than on every collection
+type missingDisks struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+// update records whether path is missing and reports whether that changed
+func (m *missingDisks) update(path string, missing bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.paths == nil {
+		m.paths = make(map[string]bool)
+	}
+	changed := m.paths[path] != missing
+	m.paths[path] = missing
+	return changed
+}
+
+// rootDiskPath is the filesystem reported in the root disk summary
+func rootDiskPath() string {
+	if runtime.GOOS == "windows" {
+		return "C:\\"
+	}
+	return "/"
+}
+
+// collectDisks reports usage for each configured disk path. Paths that do
+// not exist are skipped with a warning.
+func (c *Collector) collectDisks(ctx context.Context) []DiskUsage {
+	if len(c.cfg.DiskPaths) == 0 {
+		return nil
+	}
+
+	disks := make([]DiskUsage, 0, len(c.cfg.DiskPaths))
+	for _, path := range c.cfg.DiskPaths {
+		_, err := os.Stat(path)
+		if missing := os.IsNotExist(err); c.missing.update(path, missing) {
+			if missing {
+				c.log.Warn("Monitored disk path does not exist, skipping it", "path", path)
+			} else {
+				c.log.Info("Monitored disk path is available again", "path", path)
+			}
+		}
+		if err != nil {
+			continue
+		}
+
+		usage, err := disk.UsageWithContext(ctx, path)
+		if err != nil {
+			c.log.Debug("Failed to read disk usage", "path", path, "error", err)
+			continue
+		}
+		disks = append(disks, DiskUsage{
+			Path:          path,
+			Fstype:        usage.Fstype,
+			Total:         usage.Total,
+			Used:          usage.Used,
+			Free:          usage.Free,
+			UsagePercent:  usage.UsedPercent,
+			InodesTotal:   usage.InodesTotal,
+			InodesUsed:    usage.InodesUsed,
+			InodesPercent: usage.InodesUsedPercent,
+		})
+	}
+	return disks
+}
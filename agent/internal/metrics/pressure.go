@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// procPressureDir is where the kernel exposes Pressure Stall Information (PSI)
+const procPressureDir = "/proc/pressure"
+
+// PressureStall holds the share of wall time tasks were stalled on a resource
+type PressureStall struct {
+	Avg10  float64 `json:"avg10"`  // Percent over the last 10 seconds
+	Avg60  float64 `json:"avg60"`  // Percent over the last 60 seconds
+	Avg300 float64 `json:"avg300"` // Percent over the last 300 seconds
+	Total  uint64  `json:"total"`  // Cumulative stall time in microseconds
+}
+
+// ResourcePressure holds the "some" and "full" stall lines for a resource.
+// "some" means at least one task was stalled, "full" means all non-idle
+// tasks were stalled at the same time.
+type ResourcePressure struct {
+	Some PressureStall  `json:"some"`
+	Full *PressureStall `json:"full,omitempty"`
+}
+
+// PressureMetrics contains PSI data for CPU, memory and I/O (Linux only)
+type PressureMetrics struct {
+	CPU    *ResourcePressure `json:"cpu,omitempty"`
+	Memory *ResourcePressure `json:"memory,omitempty"`
+	IO     *ResourcePressure `json:"io,omitempty"`
+}
+
+// collectPressure reads PSI for all resources. It returns nil when PSI is
+// unavailable (non-Linux, kernels before 4.20, or psi=0 on the command line).
+func collectPressure() *PressureMetrics {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+
+	pressure := &PressureMetrics{}
+	pressure.CPU, _ = readPressureFile(filepath.Join(procPressureDir, "cpu"))
+	pressure.Memory, _ = readPressureFile(filepath.Join(procPressureDir, "memory"))
+	pressure.IO, _ = readPressureFile(filepath.Join(procPressureDir, "io"))
+
+	if pressure.CPU == nil && pressure.Memory == nil && pressure.IO == nil {
+		return nil
+	}
+	return pressure
+}
+
+// readPressureFile parses a PSI file of the form:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func readPressureFile(path string) (*ResourcePressure, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := &ResourcePressure{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		stall, err := parsePressureFields(fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		switch fields[0] {
+		case "some":
+			result.Some = stall
+		case "full":
+			result.Full = &stall
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func parsePressureFields(fields []string) (PressureStall, error) {
+	var stall PressureStall
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch key {
+		case "avg10":
+			stall.Avg10, err = strconv.ParseFloat(value, 64)
+		case "avg60":
+			stall.Avg60, err = strconv.ParseFloat(value, 64)
+		case "avg300":
+			stall.Avg300, err = strconv.ParseFloat(value, 64)
+		case "total":
+			stall.Total, err = strconv.ParseUint(value, 10, 64)
+		}
+		if err != nil {
+			return stall, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return stall, nil
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// ProcessPattern selects the processes reported under one watch entry
+type ProcessPattern struct {
+	Name    string // Process name, compared case-insensitively
+	Cmdline string // Optional substring the command line must contain
+}
+
+// ParseProcessPattern parses a watch entry of the form "name" or
+// "name:cmdline substring", e.g. "java:orders-service.jar"
+func ParseProcessPattern(entry string) (ProcessPattern, error) {
+	name, cmdline, _ := strings.Cut(entry, ":")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ProcessPattern{}, fmt.Errorf("watch entry %q has no process name", entry)
+	}
+	return ProcessPattern{Name: name, Cmdline: strings.TrimSpace(cmdline)}, nil
+}
+
+// String returns the pattern in the form ParseProcessPattern accepts
+func (p ProcessPattern) String() string {
+	if p.Cmdline == "" {
+		return p.Name
+	}
+	return p.Name + ":" + p.Cmdline
+}
+
+// matchesName reports whether a process name matches the pattern. The
+// ".exe" suffix is ignored, so "nginx" also matches nginx.exe on Windows.
+func (p ProcessPattern) matchesName(name string) bool {
+	return strings.EqualFold(
+		strings.TrimSuffix(strings.ToLower(name), ".exe"),
+		strings.TrimSuffix(strings.ToLower(p.Name), ".exe"),
+	)
+}
+
+// ProcessWatchStatus aggregates the processes matching one watch entry
+type ProcessWatchStatus struct {
+	Pattern    string  `json:"pattern"`
+	Up         bool    `json:"up"` // At least one matching process is running
+	Count      int     `json:"count"`
+	PIDs       []int32 `json:"pids,omitempty"`
+	CPUPercent float64 `json:"cpu_percent"` // Summed over matching processes
+	MemRSS     uint64  `json:"mem_rss"`     // Summed over matching processes
+}
+
+// processKey identifies a process across collections; the create time
+// tells apart a process that reused the PID of an earlier one
+type processKey struct {
+	pid     int32
+	created int64
+}
+
+// WatchProcesses reports, for each pattern, whether matching processes are
+// running and how much CPU and memory they use together. Only process
+// names are read for non-matching processes, so this is much cheaper than
+// ListProcesses. CPU usage covers the time since the process was last
+// measured, by any caller.
+func (c *Collector) WatchProcesses(ctx context.Context, patterns []ProcessPattern) ([]ProcessWatchStatus, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.procMu.Lock()
+	defer c.procMu.Unlock()
+	// Callers watch different patterns, so a handle is kept until its
+	// process exits rather than until it goes unmatched
+	running := make(map[int32]bool, len(procs))
+	for _, p := range procs {
+		running[p.Pid] = true
+	}
+	for key := range c.procs {
+		if !running[key.pid] {
+			delete(c.procs, key)
+		}
+	}
+	if c.procs == nil {
+		c.procs = make(map[processKey]*process.Process)
+	}
+
+	result := make([]ProcessWatchStatus, len(patterns))
+	for i, pattern := range patterns {
+		result[i].Pattern = pattern.String()
+	}
+
+	for _, p := range procs {
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+
+		var cmdline string
+		cmdlineRead := false
+		var cpuPct float64
+		cpuRead := false
+		for i, pattern := range patterns {
+			if !pattern.matchesName(name) {
+				continue
+			}
+			if pattern.Cmdline != "" {
+				// Read lazily; most processes match no pattern
+				if !cmdlineRead {
+					cmdline, _ = p.CmdlineWithContext(ctx)
+					cmdlineRead = true
+				}
+				if !strings.Contains(cmdline, pattern.Cmdline) {
+					continue
+				}
+			}
+
+			status := &result[i]
+			status.Up = true
+			status.Count++
+			status.PIDs = append(status.PIDs, p.Pid)
+			// Read once; a second delta right after the first is ~0
+			if !cpuRead {
+				cpuPct = c.processCPU(ctx, p)
+				cpuRead = true
+			}
+			status.CPUPercent += cpuPct
+			if memInfo, err := p.MemoryInfoWithContext(ctx); err == nil && memInfo != nil {
+				status.MemRSS += memInfo.RSS
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// processCPU returns a process's CPU usage since it was last measured,
+// keeping its handle for the next WatchProcesses call. Process.CPUPercent
+// would give the average over the process's whole lifetime instead, which
+// shows a long-running daemon as idle. A process seen for the first time
+// reports 0 until the next call.
+func (c *Collector) processCPU(ctx context.Context, p *process.Process) float64 {
+	created, err := p.CreateTimeWithContext(ctx)
+	if err != nil {
+		return 0
+	}
+	key := processKey{pid: p.Pid, created: created}
+	if prev, ok := c.procs[key]; ok {
+		p = prev
+	} else {
+		c.procs[key] = p
+	}
+
+	pct, err := p.PercentWithContext(ctx, 0)
+	if err != nil {
+		return 0
+	}
+	return pct
+}
+
+// watchPatterns parses the configured watch entries, skipping invalid ones
+// (config validation reports them at startup)
+func (c *Collector) watchPatterns() []ProcessPattern {
+	patterns := make([]ProcessPattern, 0, len(c.cfg.WatchProcesses))
+	for _, entry := range c.cfg.WatchProcesses {
+		if pattern, err := ParseProcessPattern(entry); err == nil {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
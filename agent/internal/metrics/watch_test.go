@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/serverkit/agent/internal/config"
+	"github.com/serverkit/agent/internal/logger"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+func newTestCollector(t *testing.T) *Collector {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Logging.File = filepath.Join(t.TempDir(), "agent.log")
+	return NewCollector(cfg.Metrics, logger.New(cfg.Logging))
+}
+
+// selfPattern matches the test binary
+func selfPattern(t *testing.T) ProcessPattern {
+	t.Helper()
+	self, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		t.Fatalf("NewProcess: %v", err)
+	}
+	name, err := self.Name()
+	if err != nil {
+		t.Fatalf("Name: %v", err)
+	}
+	return ProcessPattern{Name: name}
+}
+
+// burnCPU keeps this process busy so it shows CPU usage
+func burnCPU(d time.Duration) {
+	for end := time.Now().Add(d); time.Now().Before(end); {
+	}
+}
+
+// A caller watching other patterns must not reset the CPU baseline of
+// processes another caller watches
+func TestWatchProcessesKeepsOtherBaselines(t *testing.T) {
+	c := newTestCollector(t)
+	ctx := context.Background()
+	self := []ProcessPattern{selfPattern(t)}
+	other := []ProcessPattern{{Name: "no-such-process-serverkit"}}
+
+	if _, err := c.WatchProcesses(ctx, self); err != nil {
+		t.Fatalf("WatchProcesses: %v", err)
+	}
+	if _, err := c.WatchProcesses(ctx, other); err != nil {
+		t.Fatalf("WatchProcesses: %v", err)
+	}
+	burnCPU(200 * time.Millisecond)
+
+	result, err := c.WatchProcesses(ctx, self)
+	if err != nil {
+		t.Fatalf("WatchProcesses: %v", err)
+	}
+	if !result[0].Up {
+		t.Fatalf("test process not found by %q", result[0].Pattern)
+	}
+	if result[0].CPUPercent <= 0 {
+		t.Errorf("CPU = %v after another caller's watch, want the usage since the first call", result[0].CPUPercent)
+	}
+}
+
+// Concurrent callers with different patterns share the baselines safely
+// (run with -race)
+func TestWatchProcessesConcurrent(t *testing.T) {
+	c := newTestCollector(t)
+	sets := [][]ProcessPattern{
+		{selfPattern(t)},
+		{{Name: "no-such-process-serverkit"}},
+	}
+
+	var wg sync.WaitGroup
+	for _, patterns := range sets {
+		wg.Add(1)
+		go func(patterns []ProcessPattern) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				if _, err := c.WatchProcesses(context.Background(), patterns); err != nil {
+					t.Errorf("WatchProcesses: %v", err)
+					return
+				}
+			}
+		}(patterns)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package pidfile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openFile opens the PID file at path, creating it if needed
+func openFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+}
+
+// lockFile takes an exclusive advisory lock without blocking
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLocked
+	}
+	return err
+}
+
+func unlockFile(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
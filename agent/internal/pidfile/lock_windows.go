@@ -0,0 +1,45 @@
+package pidfile
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockOffsetHigh places the locked byte past any PID, since Windows locks
+// are mandatory and would otherwise stop `status` from reading the file
+const lockOffsetHigh = 1
+
+// openFile opens the PID file at path, creating it if needed. Unlike
+// os.OpenFile it shares delete access, so Release can remove the file
+// before unlocking it.
+func openFile(path string) (*os.File, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_ALWAYS, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
+
+// lockFile takes an exclusive lock without blocking
+func lockFile(f *os.File) error {
+	ol := &windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	err := windows.LockFileEx(windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errLocked
+	}
+	return err
+}
+
+func unlockFile(f *os.File) {
+	ol := &windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+}
@@ -0,0 +1,160 @@
+// Package pidfile records the running agent's PID in a locked file so a
+// second instance refuses to start and `status` can tell whether the
+// agent is up.
+package pidfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// errLocked is returned by lockFile when another process holds the lock
+var errLocked = errors.New("pid file is locked")
+
+// AlreadyRunningError is returned by Acquire when a live agent holds the
+// PID file
+type AlreadyRunningError struct {
+	PID  int
+	Path string
+}
+
+func (e *AlreadyRunningError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("agent is already running (lock held on %s)", e.Path)
+	}
+	return fmt.Sprintf("agent is already running (PID %d, %s)", e.PID, e.Path)
+}
+
+// File is a held PID file. The lock lasts until Release or process exit,
+// so a crash never leaves a file that blocks the next start.
+type File struct {
+	path string
+	f    *os.File
+}
+
+// Acquire creates the PID file at path, locks it and writes the current
+// PID. It returns an *AlreadyRunningError if another process holds it.
+func Acquire(path string) (*File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create PID file directory: %w", err)
+	}
+
+	f, err := lockPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace whatever a previous run left behind
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write PID file: %w", err)
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write PID file: %w", err)
+	}
+
+	return &File{path: path, f: f}, nil
+}
+
+// lockPath opens and locks the file at path. A previous holder removes
+// the file before it unlocks, so a lock taken on a file that is no longer
+// at path is retried on the new one.
+func lockPath(path string) (*os.File, error) {
+	for {
+		f, err := openFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PID file: %w", err)
+		}
+
+		if err := lockFile(f); err != nil {
+			f.Close()
+			if errors.Is(err, errLocked) {
+				pid, _ := Read(path)
+				return nil, &AlreadyRunningError{PID: pid, Path: path}
+			}
+			return nil, fmt.Errorf("failed to lock PID file: %w", err)
+		}
+
+		locked, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock PID file: %w", err)
+		}
+		if current, err := os.Stat(path); err == nil && os.SameFile(locked, current) {
+			return f, nil
+		}
+		unlockFile(f)
+		f.Close()
+	}
+}
+
+// Release removes the PID file and drops the lock. The file is removed
+// while still locked, so another instance cannot take it over in between.
+func (p *File) Release() error {
+	defer p.f.Close()
+	defer unlockFile(p.f)
+
+	// Leave the file alone if it no longer holds our PID
+	if pid, err := Read(p.path); err == nil && pid != os.Getpid() {
+		return nil
+	}
+	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Read returns the PID recorded in the file at path
+func Read(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid PID file %s: %w", path, err)
+	}
+	return pid, nil
+}
+
+// Running reports the PID of the agent holding the file at path, or zero
+// when no agent is running. A file left behind by a crash is not running:
+// its lock died with the process.
+func Running(path string) (int, error) {
+	pid, err := Read(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		// Can't probe the lock, so settle for the process existing
+		if alive, _ := process.PidExists(int32(pid)); alive {
+			return pid, nil
+		}
+		return 0, nil
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		if errors.Is(err, errLocked) {
+			return pid, nil
+		}
+		return 0, fmt.Errorf("failed to check PID file lock: %w", err)
+	}
+	unlockFile(f)
+	return 0, nil
+}
@@ -0,0 +1,77 @@
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Metric types used in TYPE lines
+const (
+	typeGauge   = "gauge"
+	typeCounter = "counter"
+)
+
+// label is a single name="value" pair on a sample
+type label struct {
+	name, value string
+}
+
+// expositionWriter builds a response in the Prometheus text format
+// (version 0.0.4). Each family is declared once with declare, followed
+// by its samples.
+type expositionWriter struct {
+	buf bytes.Buffer
+}
+
+// declare writes the HELP and TYPE lines of a metric family
+func (w *expositionWriter) declare(name, typ, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(&w.buf, "# TYPE %s %s\n", name, typ)
+}
+
+// sample writes one sample line
+func (w *expositionWriter) sample(name string, value float64, labels ...label) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(l.name)
+			w.buf.WriteString(`="`)
+			w.buf.WriteString(escapeLabelValue(l.value))
+			w.buf.WriteByte('"')
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.buf.WriteByte('\n')
+}
+
+// single declares a family with one unlabelled sample
+func (w *expositionWriter) single(name, typ, help string, value float64) {
+	w.declare(name, typ, help)
+	w.sample(name, value)
+}
+
+// Bytes returns the response body
+func (w *expositionWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,268 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/serverkit/agent/internal/config"
+	"github.com/serverkit/agent/internal/docker"
+	"github.com/serverkit/agent/internal/logger"
+	"github.com/serverkit/agent/internal/metrics"
+)
+
+const (
+	// contentType is the Prometheus text exposition format
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+	// scrapeTimeout bounds collecting one scrape
+	scrapeTimeout = 20 * time.Second
+	// statsWorkers is how many container stats requests run at once;
+	// each one takes about a second while Docker samples CPU usage
+	statsWorkers = 4
+)
+
+// Server exposes host and container metrics on /metrics for Prometheus
+type Server struct {
+	cfg       config.PrometheusConfig
+	log       *logger.Logger
+	collector *metrics.Collector
+	docker    *docker.Client // Nil when Docker is disabled or unavailable
+	version   string
+	server    *http.Server
+
+	// containerStats enables per-container samples (metrics.include_docker_stats)
+	containerStats bool
+}
+
+// NewServer creates a Prometheus exposition server. dockerClient may be nil.
+func NewServer(cfg config.PrometheusConfig, collector *metrics.Collector, dockerClient *docker.Client, containerStats bool, version string, log *logger.Logger) *Server {
+	return &Server{
+		cfg:            cfg,
+		log:            log.WithComponent("prometheus"),
+		collector:      collector,
+		docker:         dockerClient,
+		version:        version,
+		containerStats: containerStats,
+	}
+}
+
+// Start starts serving /metrics until ctx is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	addr := net.JoinHostPort(s.cfg.Address, strconv.Itoa(s.cfg.Port))
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics endpoint failed to start: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.handleMetrics)
+
+	s.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: scrapeTimeout + 10*time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	s.log.Info("Starting Prometheus metrics endpoint", "address", addr)
+
+	go func() {
+		if err := s.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.log.Error("Prometheus metrics endpoint stopped", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		s.Stop()
+	}()
+
+	return nil
+}
+
+// Stop gracefully stops the server
+func (s *Server) Stop() error {
+	if s.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), scrapeTimeout)
+	defer cancel()
+
+	sysMetrics, err := s.collector.Recent(ctx)
+	if err != nil {
+		s.log.Warn("Failed to collect metrics for scrape", "error", err)
+		http.Error(w, "failed to collect metrics", http.StatusInternalServerError)
+		return
+	}
+
+	out := &expositionWriter{}
+	out.declare("serverkit_agent_info", typeGauge, "Agent build information.")
+	out.sample("serverkit_agent_info", 1, label{"version", s.version})
+	writeSystemMetrics(out, sysMetrics)
+
+	if s.docker != nil && s.containerStats {
+		stats, err := s.collectContainerStats(ctx)
+		if err != nil {
+			s.log.Warn("Failed to collect container stats for scrape", "error", err)
+		}
+		writeContainerStats(out, stats)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(out.Bytes())
+}
+
+// collectContainerStats fetches stats for every running container, a few
+// at a time. Containers that stop mid-scrape are left out.
+func (s *Server) collectContainerStats(ctx context.Context) ([]*docker.ContainerStats, error) {
+	containers, _, err := s.docker.ListContainers(ctx, docker.ContainerFilters{})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*docker.ContainerStats, len(containers))
+	sem := make(chan struct{}, statsWorkers)
+	var wg sync.WaitGroup
+	for i, c := range containers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if st, err := s.docker.ContainerStats(ctx, id); err == nil {
+				stats[i] = st
+			}
+		}(i, c.ID)
+	}
+	wg.Wait()
+
+	result := stats[:0]
+	for _, st := range stats {
+		if st != nil {
+			result = append(result, st)
+		}
+	}
+	return result, nil
+}
+
+// writeSystemMetrics writes host metrics. Byte totals since boot are
+// counters; everything else is a gauge.
+func writeSystemMetrics(out *expositionWriter, m *metrics.SystemMetrics) {
+	out.single("serverkit_cpu_usage_percent", typeGauge, "CPU usage across all cores.", m.CPUPercent)
+	if len(m.CPUPerCore) > 0 {
+		out.declare("serverkit_cpu_core_usage_percent", typeGauge, "CPU usage per core.")
+		for i, pct := range m.CPUPerCore {
+			out.sample("serverkit_cpu_core_usage_percent", pct, label{"core", strconv.Itoa(i)})
+		}
+	}
+
+	out.single("serverkit_memory_total_bytes", typeGauge, "Total physical memory.", float64(m.MemoryTotal))
+	out.single("serverkit_memory_used_bytes", typeGauge, "Used physical memory.", float64(m.MemoryUsed))
+	out.single("serverkit_memory_usage_percent", typeGauge, "Used physical memory as a percentage.", m.MemoryPercent)
+	out.single("serverkit_swap_total_bytes", typeGauge, "Total swap space.", float64(m.SwapTotal))
+	out.single("serverkit_swap_used_bytes", typeGauge, "Used swap space.", float64(m.SwapUsed))
+
+	out.single("serverkit_disk_total_bytes", typeGauge, "Size of the root filesystem.", float64(m.DiskTotal))
+	out.single("serverkit_disk_used_bytes", typeGauge, "Used space on the root filesystem.", float64(m.DiskUsed))
+	out.single("serverkit_disk_usage_percent", typeGauge, "Used space on the root filesystem as a percentage.", m.DiskPercent)
+	if m.DiskInodesTotal > 0 {
+		out.single("serverkit_disk_inodes_total", typeGauge, "Inodes on the root filesystem.", float64(m.DiskInodesTotal))
+		out.single("serverkit_disk_inodes_used", typeGauge, "Used inodes on the root filesystem.", float64(m.DiskInodesUsed))
+	}
+	if len(m.Disks) > 0 {
+		families := []struct {
+			name, help string
+			value      func(d metrics.DiskUsage) float64
+		}{
+			{"serverkit_mount_total_bytes", "Size of a monitored filesystem.", func(d metrics.DiskUsage) float64 { return float64(d.Total) }},
+			{"serverkit_mount_used_bytes", "Used space on a monitored filesystem.", func(d metrics.DiskUsage) float64 { return float64(d.Used) }},
+			{"serverkit_mount_usage_percent", "Used space on a monitored filesystem as a percentage.", func(d metrics.DiskUsage) float64 { return d.UsagePercent }},
+			{"serverkit_mount_inodes_total", "Inodes on a monitored filesystem.", func(d metrics.DiskUsage) float64 { return float64(d.InodesTotal) }},
+			{"serverkit_mount_inodes_used", "Used inodes on a monitored filesystem.", func(d metrics.DiskUsage) float64 { return float64(d.InodesUsed) }},
+		}
+		for _, f := range families {
+			out.declare(f.name, typeGauge, f.help)
+			for _, d := range m.Disks {
+				out.sample(f.name, f.value(d), label{"path", d.Path})
+			}
+		}
+	}
+	out.single("serverkit_disk_read_bytes_per_second", typeGauge, "Disk read throughput since the previous collection.", m.DiskReadRate)
+	out.single("serverkit_disk_write_bytes_per_second", typeGauge, "Disk write throughput since the previous collection.", m.DiskWriteRate)
+	out.single("serverkit_disk_read_iops", typeGauge, "Disk read operations per second since the previous collection.", m.DiskReadIOPS)
+	out.single("serverkit_disk_write_iops", typeGauge, "Disk write operations per second since the previous collection.", m.DiskWriteIOPS)
+
+	out.single("serverkit_network_receive_bytes_total", typeCounter, "Bytes received on all interfaces.", float64(m.NetworkRx))
+	out.single("serverkit_network_transmit_bytes_total", typeCounter, "Bytes sent on all interfaces.", float64(m.NetworkTx))
+
+	out.single("serverkit_uptime_seconds", typeGauge, "Host uptime.", float64(m.Uptime))
+
+	if len(m.Processes) > 0 {
+		families := []struct {
+			name, help string
+			value      func(p metrics.ProcessWatchStatus) float64
+		}{
+			{"serverkit_process_up", "Whether a watched process is running.", func(p metrics.ProcessWatchStatus) float64 { return boolValue(p.Up) }},
+			{"serverkit_process_count", "Number of processes matching a watch entry.", func(p metrics.ProcessWatchStatus) float64 { return float64(p.Count) }},
+			{"serverkit_process_cpu_usage_percent", "CPU usage of processes matching a watch entry.", func(p metrics.ProcessWatchStatus) float64 { return p.CPUPercent }},
+			{"serverkit_process_resident_memory_bytes", "Resident memory of processes matching a watch entry.", func(p metrics.ProcessWatchStatus) float64 { return float64(p.MemRSS) }},
+		}
+		for _, f := range families {
+			out.declare(f.name, typeGauge, f.help)
+			for _, p := range m.Processes {
+				out.sample(f.name, f.value(p), label{"pattern", p.Pattern})
+			}
+		}
+	}
+}
+
+// writeContainerStats writes per-container samples labelled by ID and name
+func writeContainerStats(out *expositionWriter, stats []*docker.ContainerStats) {
+	if len(stats) == 0 {
+		return
+	}
+
+	families := []struct {
+		name, typ, help string
+		value           func(st *docker.ContainerStats) float64
+	}{
+		{"serverkit_container_cpu_usage_percent", typeGauge, "Container CPU usage.", func(st *docker.ContainerStats) float64 { return st.CPUPercent }},
+		{"serverkit_container_memory_usage_bytes", typeGauge, "Container memory usage.", func(st *docker.ContainerStats) float64 { return float64(st.MemoryUsage) }},
+		{"serverkit_container_memory_limit_bytes", typeGauge, "Container memory limit.", func(st *docker.ContainerStats) float64 { return float64(st.MemoryLimit) }},
+		{"serverkit_container_network_receive_bytes_total", typeCounter, "Bytes received by the container.", func(st *docker.ContainerStats) float64 { return float64(st.NetworkRx) }},
+		{"serverkit_container_network_transmit_bytes_total", typeCounter, "Bytes sent by the container.", func(st *docker.ContainerStats) float64 { return float64(st.NetworkTx) }},
+		{"serverkit_container_block_read_bytes_total", typeCounter, "Bytes read from block devices by the container.", func(st *docker.ContainerStats) float64 { return float64(st.BlockRead) }},
+		{"serverkit_container_block_write_bytes_total", typeCounter, "Bytes written to block devices by the container.", func(st *docker.ContainerStats) float64 { return float64(st.BlockWrite) }},
+		{"serverkit_container_pids", typeGauge, "Processes running in the container.", func(st *docker.ContainerStats) float64 { return float64(st.PIDs) }},
+	}
+	for _, f := range families {
+		out.declare(f.name, f.typ, f.help)
+		for _, st := range stats {
+			out.sample(f.name, f.value(st), label{"id", st.ID}, label{"name", st.Name})
+		}
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+// Package runstate keeps a small record of agent starts and exits across
+// runs, so a freshly restarted agent can be told apart from one that has
+// been up for days and the cause of the restart reported.
+package runstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Reason is why the agent last stopped, and so why the current run started
+type Reason string
+
+const (
+	ReasonFirstStart Reason = "first-start"    // No earlier run recorded
+	ReasonSignal     Reason = "signal"         // Stopped by a signal or the service manager
+	ReasonUpdate     Reason = "update"         // Restarted to install or roll back an update
+	ReasonIPC        Reason = "ipc"            // Restarted from the tray or IPC API
+	ReasonWatchdog   Reason = "watchdog"       // Restarted after being disconnected for too long
+	ReasonError      Reason = "error"          // Exited with an error
+	ReasonCrash      Reason = "crash-recovery" // The previous run ended without recording an exit
+)
+
+// State is the persisted record
+type State struct {
+	Starts         int       `json:"starts"`
+	Running        bool      `json:"running"` // Cleared on exit; still set after a crash
+	LastStartAt    time.Time `json:"last_start_at"`
+	LastExitAt     time.Time `json:"last_exit_at,omitempty"`
+	LastExitReason Reason    `json:"last_exit_reason,omitempty"`
+}
+
+// Tracker records the current run in the state file
+type Tracker struct {
+	path string
+
+	mu     sync.Mutex
+	state  State
+	reason Reason // Why this run started
+	exited bool   // An exit reason was recorded; later ones are ignored
+}
+
+// Start reads the state left by the previous run, works out why this run
+// started and records the start. The tracker is usable even when the file
+// cannot be written; the error says so.
+func Start(path string) (*Tracker, error) {
+	t := &Tracker{path: path, reason: ReasonFirstStart}
+
+	prev, err := Read(path)
+	switch {
+	case err == nil && prev.Running:
+		t.reason = ReasonCrash
+	case err == nil && prev.LastExitReason != "":
+		t.reason = prev.LastExitReason
+	}
+	if prev != nil {
+		t.state = *prev
+	}
+
+	t.state.Starts++
+	t.state.Running = true
+	t.state.LastStartAt = time.Now()
+	return t, t.save()
+}
+
+// Read loads the state file
+func Read(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// RestartReason returns why the current run started
+func (t *Tracker) RestartReason() Reason {
+	return t.reason
+}
+
+// RestartCount returns how many times the agent has restarted since the
+// state file was created, normally at install
+func (t *Tracker) RestartCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return max(t.state.Starts-1, 0)
+}
+
+// Exit records why the agent is stopping. Only the first reason counts:
+// an update that restarts the service also delivers a signal. A nil
+// tracker records nothing.
+func (t *Tracker) Exit(reason Reason) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.exited {
+		return nil
+	}
+	t.exited = true
+	t.state.Running = false
+	t.state.LastExitAt = time.Now()
+	t.state.LastExitReason = reason
+	return t.save()
+}
+
+// save writes the state beside the target and renames it into place, so a
+// crash mid-write never leaves a truncated file. The caller holds t.mu, or
+// has the tracker to itself.
+func (t *Tracker) save() error {
+	data, err := json.MarshalIndent(t.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("failed to create run state directory: %w", err)
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	return nil
+}
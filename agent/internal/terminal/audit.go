@@ -0,0 +1,119 @@
+package terminal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Audit record directions
+const (
+	AuditInput  = "input"
+	AuditOutput = "output"
+	AuditOpen   = "open"
+	AuditClose  = "close"
+)
+
+// AuditRecord is a single line in the terminal audit log
+type AuditRecord struct {
+	Time      string `json:"time"`
+	SessionID string `json:"session_id"`
+	Type      string `json:"type"`
+	Shell     string `json:"shell,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Data      string `json:"data,omitempty"`
+}
+
+// AuditLog appends terminal activity to a dedicated file as JSON lines.
+// It is shared by all sessions and safe for concurrent use; a nil AuditLog
+// records nothing.
+type AuditLog struct {
+	mu            sync.Mutex
+	file          *os.File
+	includeOutput bool
+}
+
+// OpenAuditLog opens (or creates) the audit file with owner-only permissions
+func OpenAuditLog(path string, includeOutput bool) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	// An existing file keeps its mode on open, so tighten it explicitly
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to set audit log permissions: %w", err)
+	}
+
+	return &AuditLog{
+		file:          file,
+		includeOutput: includeOutput,
+	}, nil
+}
+
+// Input records bytes written to a session
+func (l *AuditLog) Input(sessionID string, data []byte) {
+	l.write(AuditRecord{SessionID: sessionID, Type: AuditInput, Data: string(data)})
+}
+
+// Output records bytes produced by a session, if output auditing is enabled
+func (l *AuditLog) Output(sessionID string, data []byte) {
+	if l == nil || !l.includeOutput {
+		return
+	}
+	l.write(AuditRecord{SessionID: sessionID, Type: AuditOutput, Data: string(data)})
+}
+
+// Opened records the start of a session
+func (l *AuditLog) Opened(sessionID, shell string) {
+	l.write(AuditRecord{SessionID: sessionID, Type: AuditOpen, Shell: shell})
+}
+
+// Closed records the end of a session
+func (l *AuditLog) Closed(sessionID, reason string) {
+	l.write(AuditRecord{SessionID: sessionID, Type: AuditClose, Reason: reason})
+}
+
+func (l *AuditLog) write(rec AuditRecord) {
+	if l == nil {
+		return
+	}
+	rec.Time = time.Now().UTC().Format(time.RFC3339Nano)
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		l.file.Write(line)
+	}
+}
+
+// Close closes the audit file
+func (l *AuditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
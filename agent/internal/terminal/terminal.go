@@ -8,42 +8,111 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/creack/pty"
+	"github.com/serverkit/agent/internal/config"
+)
+
+const (
+	// readBufferSize is the size of a single PTY read
+	readBufferSize = 4096
+	// maxPendingOutput is how much unsent output a session buffers before
+	// PTY reads are paused until the consumer catches up
+	maxPendingOutput = 256 * 1024
+	// maxOutputChunk caps how much buffered output is coalesced into one
+	// call to the output handler
+	maxOutputChunk = 32 * 1024
+	// maxReapInterval bounds how often the reaper checks session timeouts
+	maxReapInterval = 30 * time.Second
+)
+
+// Reasons a session was closed by the manager
+const (
+	CloseReasonIdle     = "idle_timeout"
+	CloseReasonLifetime = "max_lifetime"
 )
 
 // Session represents an active terminal session
 type Session struct {
-	ID       string
-	Shell    string
-	Cols     uint16
-	Rows     uint16
-	cmd      *exec.Cmd
-	pty      *os.File
-	ctx      context.Context
-	cancel   context.CancelFunc
-	mu       sync.Mutex
-	closed   bool
-	onOutput func(data []byte)
-	onClose  func()
+	ID        string
+	Shell     string
+	Cols      uint16
+	Rows      uint16
+	CreatedAt time.Time
+	lastInput time.Time
+	reason    string
+	audit     *AuditLog
+	proc      Process
+	ctx       context.Context
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+	closed    bool
+	onOutput  func(data []byte)
+	onClose   func()
+
+	// Output buffering between the PTY reader and the output handler
+	outMu   sync.Mutex
+	outCond *sync.Cond
+	pending []byte
+	outEOF  bool
+}
+
+// SessionInfo is a point-in-time description of a session
+type SessionInfo struct {
+	ID        string
+	Shell     string
+	Cols      uint16
+	Rows      uint16
+	CreatedAt time.Time
+}
+
+// Process is the interactive program behind a session: a local shell on a
+// PTY, or a process reached through another transport such as docker exec
+type Process interface {
+	io.ReadWriter
+	Resize(cols, rows uint16) error
+	Close() error
+}
+
+// SessionOptions customizes the process started for a session
+type SessionOptions struct {
+	Shell string   // Requested shell; the default shell is used if it can't be found
+	Dir   string   // Working directory; the agent's directory if empty
+	Env   []string // Extra KEY=VALUE entries added to the environment
 }
 
 // Manager manages terminal sessions
 type Manager struct {
+	cfg      config.TerminalConfig
+	audit    *AuditLog
 	sessions map[string]*Session
 	mu       sync.RWMutex
 }
 
-// NewManager creates a new terminal manager
-func NewManager() *Manager {
-	return &Manager{
+// NewManager creates a new terminal manager, opening the audit log if one
+// is configured
+func NewManager(cfg config.TerminalConfig) (*Manager, error) {
+	m := &Manager{
+		cfg:      cfg,
 		sessions: make(map[string]*Session),
 	}
+
+	if cfg.AuditLog != "" {
+		audit, err := OpenAuditLog(cfg.AuditLog, cfg.AuditOutput)
+		if err != nil {
+			return nil, err
+		}
+		m.audit = audit
+	}
+
+	return m, nil
 }
 
 // CreateSession creates a new terminal session
-func (m *Manager) CreateSession(id string, cols, rows uint16) (*Session, error) {
+func (m *Manager) CreateSession(id string, cols, rows uint16, opts SessionOptions) (*Session, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -53,29 +122,57 @@ func (m *Manager) CreateSession(id string, cols, rows uint16) (*Session, error)
 	}
 
 	// Determine the shell to use
-	shell := getDefaultShell()
-
-	ctx, cancel := context.WithCancel(context.Background())
-
-	session := &Session{
-		ID:     id,
-		Shell:  shell,
-		Cols:   cols,
-		Rows:   rows,
-		ctx:    ctx,
-		cancel: cancel,
-	}
+	shell := resolveShell(opts.Shell)
+	session := m.newSession(id, shell, cols, rows)
 
 	// Start the shell with PTY
-	if err := session.start(); err != nil {
-		cancel()
+	proc, err := startPTY(session.ctx, shell, opts, cols, rows)
+	if err != nil {
+		session.cancel()
 		return nil, fmt.Errorf("failed to start shell: %w", err)
 	}
 
+	session.start(proc)
 	m.sessions[id] = session
+	m.audit.Opened(id, shell)
 	return session, nil
 }
 
+// AttachSession creates a session around an already started process, such
+// as a shell exec'd inside a container. The session owns proc from here on.
+func (m *Manager) AttachSession(id, shell string, cols, rows uint16, proc Process) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.sessions[id]; exists {
+		return nil, fmt.Errorf("session %s already exists", id)
+	}
+
+	session := m.newSession(id, shell, cols, rows)
+	session.start(proc)
+	m.sessions[id] = session
+	m.audit.Opened(id, shell)
+	return session, nil
+}
+
+// newSession builds an unstarted session
+func (m *Manager) newSession(id, shell string, cols, rows uint16) *Session {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+
+	return &Session{
+		ID:        id,
+		Shell:     shell,
+		Cols:      cols,
+		Rows:      rows,
+		CreatedAt: now,
+		lastInput: now,
+		audit:     m.audit,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
 // GetSession retrieves a session by ID
 func (m *Manager) GetSession(id string) (*Session, bool) {
 	m.mu.RLock()
@@ -100,7 +197,7 @@ func (m *Manager) CloseSession(id string) error {
 	return session.Close()
 }
 
-// CloseAll closes all sessions
+// CloseAll closes all sessions and the audit log
 func (m *Manager) CloseAll() {
 	m.mu.Lock()
 	sessions := make([]*Session, 0, len(m.sessions))
@@ -113,6 +210,8 @@ func (m *Manager) CloseAll() {
 	for _, s := range sessions {
 		s.Close()
 	}
+
+	m.audit.Close()
 }
 
 // ListSessions returns all active session IDs
@@ -127,86 +226,213 @@ func (m *Manager) ListSessions() []string {
 	return ids
 }
 
-// start initializes the PTY and starts the shell
-func (s *Session) start() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Sessions returns information about all active sessions, oldest first
+func (m *Manager) Sessions() []SessionInfo {
+	m.mu.RLock()
+	infos := make([]SessionInfo, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		infos = append(infos, s.Info())
+	}
+	m.mu.RUnlock()
 
-	// Create the command
-	s.cmd = exec.CommandContext(s.ctx, s.Shell)
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+	return infos
+}
 
-	// Set up environment
-	s.cmd.Env = append(os.Environ(),
-		"TERM=xterm-256color",
-		"COLORTERM=truecolor",
-	)
+// RunReaper closes sessions that exceed the idle timeout or maximum lifetime
+// until ctx is cancelled. Each reaped session's close handler is fired so the
+// server is told the session ended. It returns immediately when neither limit
+// is configured.
+func (m *Manager) RunReaper(ctx context.Context) {
+	interval := reapInterval(m.cfg.IdleTimeout, m.cfg.MaxLifetime)
+	if interval == 0 {
+		return
+	}
 
-	// Start with PTY
-	ptmx, err := pty.StartWithSize(s.cmd, &pty.Winsize{
-		Cols: s.Cols,
-		Rows: s.Rows,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to start pty: %w", err)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.reap(now)
+		}
 	}
+}
 
-	s.pty = ptmx
+// reap closes and removes every expired session
+func (m *Manager) reap(now time.Time) {
+	m.mu.Lock()
+	var expired []*Session
+	for id, s := range m.sessions {
+		if reason := s.expired(now, m.cfg.IdleTimeout, m.cfg.MaxLifetime); reason != "" {
+			s.mu.Lock()
+			s.reason = reason
+			s.mu.Unlock()
+			expired = append(expired, s)
+			delete(m.sessions, id)
+		}
+	}
+	m.mu.Unlock()
 
-	// Start reading output in background
-	go s.readLoop()
+	for _, s := range expired {
+		s.Close()
+		if s.onClose != nil {
+			s.onClose()
+		}
+	}
+}
 
-	return nil
+// reapInterval picks a check interval fine enough for the shortest limit
+func reapInterval(limits ...time.Duration) time.Duration {
+	var interval time.Duration
+	for _, limit := range limits {
+		if limit <= 0 {
+			continue
+		}
+		check := limit / 10
+		if check < time.Second {
+			check = time.Second
+		}
+		if check > maxReapInterval {
+			check = maxReapInterval
+		}
+		if interval == 0 || check < interval {
+			interval = check
+		}
+	}
+	return interval
 }
 
-// readLoop continuously reads from the PTY and calls the output handler
+// start attaches the session to its process and begins streaming output
+func (s *Session) start(proc Process) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.proc = proc
+	s.outCond = sync.NewCond(&s.outMu)
+
+	// Start reading output in background and delivering it to the handler
+	go s.readLoop()
+	go s.flushLoop()
+}
+
+// readLoop continuously reads from the process into the pending output buffer.
+// When the buffer is full, reads pause so the shell blocks on its own writes
+// instead of output being dropped.
 func (s *Session) readLoop() {
-	buf := make([]byte, 4096)
+	buf := make([]byte, readBufferSize)
 
 	for {
 		select {
 		case <-s.ctx.Done():
+			s.finishOutput()
 			return
 		default:
 		}
 
-		n, err := s.pty.Read(buf)
+		n, err := s.proc.Read(buf)
+		if n > 0 {
+			s.outMu.Lock()
+			for len(s.pending) >= maxPendingOutput && !s.outEOF {
+				s.outCond.Wait()
+			}
+			s.pending = append(s.pending, buf[:n]...)
+			s.outCond.Broadcast()
+			s.outMu.Unlock()
+		}
+
 		if err != nil {
 			if err != io.EOF {
 				// Log error but don't break - might be temporary
 			}
-			// Check if we should exit
-			s.mu.Lock()
-			closed := s.closed
-			s.mu.Unlock()
-			if closed {
-				return
-			}
-			// Shell might have exited
-			if s.onClose != nil {
-				s.onClose()
-			}
+			// Shell might have exited; the flush loop drains what's left
+			s.finishOutput()
 			return
 		}
+	}
+}
 
-		if n > 0 && s.onOutput != nil {
-			// Make a copy of the data
-			data := make([]byte, n)
-			copy(data, buf[:n])
+// flushLoop delivers buffered output to the handler, coalescing whatever
+// accumulated while the previous delivery was in flight
+func (s *Session) flushLoop() {
+	for {
+		s.outMu.Lock()
+		for len(s.pending) == 0 && !s.outEOF {
+			s.outCond.Wait()
+		}
+		if len(s.pending) == 0 {
+			s.outMu.Unlock()
+			break
+		}
+
+		n := len(s.pending)
+		if n > maxOutputChunk {
+			n = maxOutputChunk
+		}
+		data := make([]byte, n)
+		copy(data, s.pending[:n])
+		s.pending = s.pending[n:]
+		if len(s.pending) == 0 {
+			s.pending = nil
+		}
+		s.outCond.Broadcast()
+		s.outMu.Unlock()
+
+		s.audit.Output(s.ID, data)
+
+		// The handler may block; that is what pauses the reader
+		if s.onOutput != nil {
 			s.onOutput(data)
 		}
 	}
+
+	// Check if we should exit quietly
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return
+	}
+
+	if s.onClose != nil {
+		s.onClose()
+	}
+}
+
+// finishOutput marks the output stream as ended and wakes both loops
+func (s *Session) finishOutput() {
+	s.outMu.Lock()
+	s.outEOF = true
+	s.outCond.Broadcast()
+	s.outMu.Unlock()
+}
+
+// Context returns a context that is cancelled when the session closes.
+// Output handlers should use it to bound blocking sends.
+func (s *Session) Context() context.Context {
+	return s.ctx
 }
 
-// Write sends input to the terminal
+// Write sends input to the terminal. The write itself happens without
+// s.mu: it blocks while the PTY is full, and Close must still be able to
+// end the session, which also unblocks the write.
 func (s *Session) Write(data []byte) (int, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.closed || s.pty == nil {
+	if s.closed || s.proc == nil {
+		s.mu.Unlock()
 		return 0, fmt.Errorf("session is closed")
 	}
+	proc := s.proc
+	s.lastInput = time.Now()
+	s.audit.Input(s.ID, data)
+	s.mu.Unlock()
 
-	return s.pty.Write(data)
+	return proc.Write(data)
 }
 
 // Resize changes the terminal size
@@ -214,17 +440,14 @@ func (s *Session) Resize(cols, rows uint16) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.closed || s.pty == nil {
+	if s.closed || s.proc == nil {
 		return fmt.Errorf("session is closed")
 	}
 
 	s.Cols = cols
 	s.Rows = rows
 
-	return pty.Setsize(s.pty, &pty.Winsize{
-		Cols: cols,
-		Rows: rows,
-	})
+	return s.proc.Resize(cols, rows)
 }
 
 // SetOutputHandler sets the callback for terminal output
@@ -248,25 +471,52 @@ func (s *Session) Close() error {
 
 	s.closed = true
 	s.cancel()
+	s.audit.Closed(s.ID, s.reason)
 
-	var errs []error
+	if s.outCond != nil {
+		s.finishOutput()
+	}
 
-	if s.pty != nil {
-		if err := s.pty.Close(); err != nil {
-			errs = append(errs, fmt.Errorf("failed to close pty: %w", err))
-		}
+	if s.proc != nil {
+		return s.proc.Close()
 	}
+	return nil
+}
 
-	if s.cmd != nil && s.cmd.Process != nil {
-		if err := s.cmd.Process.Kill(); err != nil {
-			// Process might have already exited
-		}
+// Info returns the session's current description
+func (s *Session) Info() SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return SessionInfo{
+		ID:        s.ID,
+		Shell:     s.Shell,
+		Cols:      s.Cols,
+		Rows:      s.Rows,
+		CreatedAt: s.CreatedAt,
 	}
+}
+
+// CloseReason returns why the manager closed the session, or "" if it
+// was closed by request or the shell exited
+func (s *Session) CloseReason() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reason
+}
+
+// expired reports which limit, if any, the session has exceeded
+func (s *Session) expired(now time.Time, idleTimeout, maxLifetime time.Duration) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if len(errs) > 0 {
-		return errs[0]
+	if maxLifetime > 0 && now.Sub(s.CreatedAt) >= maxLifetime {
+		return CloseReasonLifetime
 	}
-	return nil
+	if idleTimeout > 0 && now.Sub(s.lastInput) >= idleTimeout {
+		return CloseReasonIdle
+	}
+	return ""
 }
 
 // IsClosed returns whether the session is closed
@@ -276,6 +526,80 @@ func (s *Session) IsClosed() bool {
 	return s.closed
 }
 
+// ptyProcess is a local shell running on a pseudo-terminal
+type ptyProcess struct {
+	cmd *exec.Cmd
+	pty *os.File
+}
+
+// startPTY starts shell on a new PTY of the given size
+func startPTY(ctx context.Context, shell string, opts SessionOptions, cols, rows uint16) (*ptyProcess, error) {
+	// Create the command
+	cmd := exec.CommandContext(ctx, shell)
+	cmd.Dir = opts.Dir
+
+	// Set up environment; requested variables come last so they take precedence
+	cmd.Env = append(os.Environ(),
+		"TERM=xterm-256color",
+		"COLORTERM=truecolor",
+	)
+	cmd.Env = append(cmd.Env, opts.Env...)
+
+	// Start with PTY
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{
+		Cols: cols,
+		Rows: rows,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start pty: %w", err)
+	}
+
+	return &ptyProcess{cmd: cmd, pty: ptmx}, nil
+}
+
+func (p *ptyProcess) Read(b []byte) (int, error) {
+	return p.pty.Read(b)
+}
+
+func (p *ptyProcess) Write(b []byte) (int, error) {
+	return p.pty.Write(b)
+}
+
+func (p *ptyProcess) Resize(cols, rows uint16) error {
+	return pty.Setsize(p.pty, &pty.Winsize{
+		Cols: cols,
+		Rows: rows,
+	})
+}
+
+// Close closes the PTY and kills the shell
+func (p *ptyProcess) Close() error {
+	err := p.pty.Close()
+
+	if p.cmd.Process != nil {
+		// Process might have already exited
+		p.cmd.Process.Kill()
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to close pty: %w", err)
+	}
+	return nil
+}
+
+// resolveShell returns the requested shell if it can be found on this host,
+// otherwise the platform default
+func resolveShell(requested string) string {
+	if requested == "" {
+		return getDefaultShell()
+	}
+	path, err := exec.LookPath(requested)
+	if err != nil {
+		return getDefaultShell()
+	}
+	return path
+}
+
 // getDefaultShell returns the default shell for the current platform
 func getDefaultShell() string {
 	if runtime.GOOS == "windows" {
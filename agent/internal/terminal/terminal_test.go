@@ -0,0 +1,75 @@
+package terminal
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stuckProcess is a shell whose input is never read, like one stopped
+// with a full PTY: Read and Write block until Close
+type stuckProcess struct {
+	writing chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newStuckProcess() *stuckProcess {
+	return &stuckProcess{writing: make(chan struct{}, 1), done: make(chan struct{})}
+}
+
+func (p *stuckProcess) Read(b []byte) (int, error) {
+	<-p.done
+	return 0, io.EOF
+}
+
+func (p *stuckProcess) Write(b []byte) (int, error) {
+	select {
+	case p.writing <- struct{}{}:
+	default:
+	}
+	<-p.done
+	return 0, io.ErrClosedPipe
+}
+
+func (p *stuckProcess) Resize(cols, rows uint16) error { return nil }
+
+func (p *stuckProcess) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+// A write blocked on the PTY must not stop the session from closing
+func TestCloseDuringBlockedWrite(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Session{ID: "test", CreatedAt: time.Now(), ctx: ctx, cancel: cancel}
+	proc := newStuckProcess()
+	s.start(proc)
+
+	writeErr := make(chan error, 1)
+	go func() {
+		_, err := s.Write([]byte("ls\n"))
+		writeErr <- err
+	}()
+	<-proc.writing
+
+	closed := make(chan struct{})
+	go func() {
+		s.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked behind a stuck Write")
+	}
+	if err := <-writeErr; err == nil {
+		t.Error("Write succeeded on a closed session")
+	}
+	if err := s.Resize(80, 24); err == nil {
+		t.Error("Resize succeeded on a closed session")
+	}
+}
@@ -1,33 +1,154 @@
 package tray
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/gorilla/websocket"
+
+	"github.com/serverkit/agent/internal/config"
 	"github.com/serverkit/agent/internal/ipc"
 )
 
 // Client communicates with the agent's IPC server
 type Client struct {
-	baseURL    string
+	fallback   ipc.Endpoint // From the config, used until the agent records its own
+	tokenFile  string
 	httpClient *http.Client
+	dialer     *websocket.Dialer
 }
 
-// NewClient creates a new IPC client
-func NewClient(address string, port int) *Client {
-	return &Client{
-		baseURL: fmt.Sprintf("http://%s:%d", address, port),
+// baseURL is a placeholder host; dial picks the real endpoint
+const baseURL = "http://ipc"
+
+// NewClient creates a new IPC client. It dials the endpoint the running
+// agent recorded beside cfg.TokenFile, and falls back to cfg.Socket or
+// cfg.Address:cfg.Port when there is none. The token and endpoint are read
+// on every connection because the agent writes new ones each time it
+// starts.
+func NewClient(cfg config.IPCConfig) *Client {
+	c := &Client{
+		fallback: ipc.Endpoint{
+			Socket:  cfg.Socket,
+			Address: net.JoinHostPort(cfg.Address, strconv.Itoa(cfg.Port)),
+		},
+		tokenFile: cfg.TokenFile,
 		httpClient: &http.Client{
 			Timeout: 5 * time.Second,
 		},
+		dialer: &websocket.Dialer{
+			HandshakeTimeout: 5 * time.Second,
+		},
+	}
+
+	c.httpClient.Transport = &http.Transport{DialContext: c.dial}
+	c.dialer.NetDialContext = c.dial
+	return c
+}
+
+// endpoint returns where the agent is listening
+func (c *Client) endpoint() ipc.Endpoint {
+	if ep, err := ipc.ReadEndpoint(c.tokenFile); err == nil {
+		return *ep
+	}
+	return c.fallback
+}
+
+// dial connects to the agent's current endpoint, ignoring the URL host
+func (c *Client) dial(ctx context.Context, _, _ string) (net.Conn, error) {
+	ep := c.endpoint()
+	if ep.Socket != "" {
+		return ipc.DialSocket(ctx, ep.Socket)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", ep.Address)
+}
+
+// do sends an authenticated request to the IPC server
+func (c *Client) do(method, path string) (*http.Response, error) {
+	req, err := http.NewRequest(method, baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := ipc.ReadToken(c.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IPC token: %w", err)
+	}
+	req.Header.Set(ipc.TokenHeader, token)
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, fmt.Errorf("IPC token rejected by agent")
+	}
+	return resp, nil
+}
+
+// StreamStatus connects to the agent's /ws endpoint and calls fn for every
+// pushed update. It blocks until the stream ends or quit is closed, and
+// returns nil only when quit was closed.
+func (c *Client) StreamStatus(quit <-chan struct{}, fn func(*ipc.StreamUpdate)) error {
+	token, err := ipc.ReadToken(c.tokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read IPC token: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set(ipc.TokenHeader, token)
+
+	conn, resp, err := c.dialer.Dial("ws"+strings.TrimPrefix(baseURL, "http")+"/ws", header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("stream upgrade failed: %d", resp.StatusCode)
+		}
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-quit:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		// A few missed updates means the agent is hung or gone
+		conn.SetReadDeadline(time.Now().Add(3 * ipc.StreamInterval))
+
+		var update ipc.StreamUpdate
+		if err := conn.ReadJSON(&update); err != nil {
+			select {
+			case <-quit:
+				return nil
+			default:
+				return err
+			}
+		}
+		fn(&update)
 	}
 }
 
 // GetStatus fetches the agent status
 func (c *Client) GetStatus() (*ipc.AgentStatus, error) {
-	resp, err := c.httpClient.Get(c.baseURL + "/status")
+	resp, err := c.do(http.MethodGet, "/status")
 	if err != nil {
 		return nil, err
 	}
@@ -47,7 +168,7 @@ func (c *Client) GetStatus() (*ipc.AgentStatus, error) {
 
 // GetMetrics fetches detailed system metrics
 func (c *Client) GetMetrics() (*ipc.DetailedMetrics, error) {
-	resp, err := c.httpClient.Get(c.baseURL + "/metrics")
+	resp, err := c.do(http.MethodGet, "/metrics")
 	if err != nil {
 		return nil, err
 	}
@@ -67,7 +188,7 @@ func (c *Client) GetMetrics() (*ipc.DetailedMetrics, error) {
 
 // GetConnection fetches WebSocket connection info
 func (c *Client) GetConnection() (*ipc.ConnectionInfo, error) {
-	resp, err := c.httpClient.Get(c.baseURL + "/connection")
+	resp, err := c.do(http.MethodGet, "/connection")
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +208,7 @@ func (c *Client) GetConnection() (*ipc.ConnectionInfo, error) {
 
 // GetLogs fetches recent log lines
 func (c *Client) GetLogs(lines int) ([]string, error) {
-	resp, err := c.httpClient.Get(fmt.Sprintf("%s/logs?lines=%d", c.baseURL, lines))
+	resp, err := c.do(http.MethodGet, fmt.Sprintf("/logs?lines=%d", lines))
 	if err != nil {
 		return nil, err
 	}
@@ -107,9 +228,83 @@ func (c *Client) GetLogs(lines int) ([]string, error) {
 	return result.Lines, nil
 }
 
+// GetSessions fetches the open terminal sessions
+func (c *Client) GetSessions() ([]ipc.TerminalSession, error) {
+	resp, err := c.do(http.MethodGet, "/sessions")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Sessions []ipc.TerminalSession `json:"sessions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Sessions, nil
+}
+
+// CloseSession forcibly closes a terminal session
+func (c *Client) CloseSession(id string) error {
+	return c.post("/sessions/close?id="+url.QueryEscape(id), "close")
+}
+
+// UpdateInfo describes a pending agent update
+type UpdateInfo struct {
+	Pending        bool   `json:"pending"`
+	CurrentVersion string `json:"current_version"`
+	LatestVersion  string `json:"latest_version"`
+}
+
+// GetUpdate fetches pending-update information
+func (c *Client) GetUpdate() (*UpdateInfo, error) {
+	resp, err := c.do(http.MethodGet, "/update")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var info UpdateInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// InstallUpdate asks the agent to install the pending update
+func (c *Client) InstallUpdate() error {
+	return c.post("/update/install", "update")
+}
+
 // Restart requests agent restart
 func (c *Client) Restart() error {
-	resp, err := c.httpClient.Post(c.baseURL+"/restart", "application/json", nil)
+	return c.post("/restart", "restart")
+}
+
+// Pause stops the agent's heartbeats and metric streams
+func (c *Client) Pause() error {
+	return c.post("/pause", "pause")
+}
+
+// Resume restarts the agent's heartbeats and metric streams
+func (c *Client) Resume() error {
+	return c.post("/resume", "resume")
+}
+
+// post sends an action request and checks its success response
+func (c *Client) post(path, action string) error {
+	resp, err := c.do(http.MethodPost, path)
 	if err != nil {
 		return err
 	}
@@ -128,7 +323,7 @@ func (c *Client) Restart() error {
 	}
 
 	if !result.Success {
-		return fmt.Errorf("restart failed: %s", result.Error)
+		return fmt.Errorf("%s failed: %s", action, result.Error)
 	}
 
 	return nil
@@ -136,7 +331,7 @@ func (c *Client) Restart() error {
 
 // IsAgentRunning checks if the agent is reachable
 func (c *Client) IsAgentRunning() bool {
-	resp, err := c.httpClient.Get(c.baseURL + "/health")
+	resp, err := c.httpClient.Get(baseURL + "/health")
 	if err != nil {
 		return false
 	}
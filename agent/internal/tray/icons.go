@@ -23,6 +23,9 @@ var iconError []byte
 //go:embed icons/stopped.ico
 var iconStopped []byte
 
+//go:embed icons/paused.ico
+var iconPaused []byte
+
 // IconState represents the current icon state
 type IconState int
 
@@ -31,6 +34,7 @@ const (
 	IconStateDisconnected                  // Yellow - running but disconnected
 	IconStateError                         // Red - error state
 	IconStateStopped                       // Gray - agent not running
+	IconStatePaused                        // Blue - running but paused for maintenance
 )
 
 // GetIcon returns the icon bytes for a given state
@@ -44,6 +48,8 @@ func GetIcon(state IconState) []byte {
 		return iconError
 	case IconStateStopped:
 		return iconStopped
+	case IconStatePaused:
+		return iconPaused
 	default:
 		return iconStopped
 	}
@@ -4,20 +4,26 @@ import (
 	"fmt"
 	"os/exec"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
 	"fyne.io/systray"
+	"github.com/gen2brain/beeep"
+	"github.com/serverkit/agent/internal/config"
+	"github.com/serverkit/agent/internal/ipc"
+	"github.com/serverkit/agent/internal/logger"
 )
 
 // AppConfig holds tray app configuration
 type AppConfig struct {
 	Version      string
-	IPCAddress   string
-	IPCPort      int
+	IPC          config.IPCConfig
 	ServerURL    string
 	DashboardURL string
 	LogFile      string
+	Notify       bool           // Show desktop notifications
+	Log          *logger.Logger // Tray diagnostics; not the agent log file
 }
 
 // App is the system tray application
@@ -29,6 +35,7 @@ type App struct {
 	mu              sync.RWMutex
 	connected       bool
 	agentRunning    bool
+	paused          bool
 	lastStatus      string
 	cpuPercent      float64
 	memPercent      float64
@@ -37,9 +44,13 @@ type App struct {
 	menuStatus      *systray.MenuItem
 	menuCPU         *systray.MenuItem
 	menuMem         *systray.MenuItem
+	menuSessions    *systray.MenuItem
+	menuUptime      *systray.MenuItem
 	menuStartAgent  *systray.MenuItem
 	menuStopAgent   *systray.MenuItem
 	menuRestartAgent *systray.MenuItem
+	menuPause       *systray.MenuItem
+	menuUpdate      *systray.MenuItem
 	menuViewLogs    *systray.MenuItem
 	menuDashboard   *systray.MenuItem
 	menuAbout       *systray.MenuItem
@@ -53,7 +64,7 @@ type App struct {
 func NewApp(config AppConfig) *App {
 	return &App{
 		config: config,
-		client: NewClient(config.IPCAddress, config.IPCPort),
+		client: NewClient(config.IPC),
 		quitCh: make(chan struct{}),
 	}
 }
@@ -85,11 +96,21 @@ func (a *App) onReady() {
 	a.menuMem = systray.AddMenuItem("Memory: --", "Memory usage")
 	a.menuMem.Disable()
 
+	a.menuSessions = systray.AddMenuItem("Terminal Sessions: --", "Open remote terminal sessions")
+	a.menuSessions.Disable()
+
+	a.menuUptime = systray.AddMenuItem("Uptime: --", "Time since the agent started and why it last restarted")
+	a.menuUptime.Disable()
+
 	systray.AddSeparator()
 
 	a.menuStartAgent = systray.AddMenuItem("Start Agent", "Start the ServerKit agent service")
 	a.menuStopAgent = systray.AddMenuItem("Stop Agent", "Stop the ServerKit agent service")
 	a.menuRestartAgent = systray.AddMenuItem("Restart Agent", "Restart the ServerKit agent service")
+	a.menuPause = systray.AddMenuItem("Pause Agent", "Stop heartbeats and metrics during maintenance")
+	a.menuPause.Disable()
+	a.menuUpdate = systray.AddMenuItem("No Update Available", "Install the pending agent update")
+	a.menuUpdate.Disable()
 
 	systray.AddSeparator()
 
@@ -117,6 +138,9 @@ func (a *App) onExit() {
 	// Cleanup on exit
 }
 
+// refreshLoop keeps the menu current. Updates are streamed from the agent
+// when possible; while the stream is unavailable (agent stopped, or an
+// agent without /ws) the status is polled and the stream retried.
 func (a *App) refreshLoop() {
 	// Initial fetch
 	a.refresh()
@@ -125,6 +149,13 @@ func (a *App) refreshLoop() {
 	defer ticker.Stop()
 
 	for {
+		err := a.client.StreamStatus(a.quitCh, func(update *ipc.StreamUpdate) {
+			a.applyStatus(&update.Status, update.TerminalSessions)
+		})
+		if err == nil {
+			return
+		}
+
 		select {
 		case <-a.quitCh:
 			return
@@ -136,11 +167,35 @@ func (a *App) refreshLoop() {
 
 func (a *App) refresh() {
 	status, err := a.client.GetStatus()
+	if err != nil {
+		a.applyStatus(nil, -1)
+		return
+	}
+
+	sessions := -1
+	if list, err := a.client.GetSessions(); err == nil {
+		sessions = len(list)
+	}
+	a.applyStatus(status, sessions)
+}
 
+// uptimeTitle describes how long the agent has run and, after a restart,
+// why it restarted, e.g. "Uptime: 2h5m (restart 3: update)"
+func uptimeTitle(status *ipc.AgentStatus) string {
+	title := fmt.Sprintf("Uptime: %s", (time.Duration(status.Uptime) * time.Second).String())
+	if status.LastRestartReason != "" && status.RestartCount > 0 {
+		title += fmt.Sprintf(" (restart %d: %s)", status.RestartCount, status.LastRestartReason)
+	}
+	return title
+}
+
+// applyStatus updates the icon and menu. A nil status means the agent is
+// not reachable; a negative session count leaves the count unchanged.
+func (a *App) applyStatus(status *ipc.AgentStatus, sessions int) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	if err != nil {
+	if status == nil {
 		// Agent not reachable
 		a.agentRunning = false
 		a.connected = false
@@ -150,23 +205,37 @@ func (a *App) refresh() {
 		a.menuStatus.SetTitle("Status: Agent Not Running")
 		a.menuCPU.SetTitle("CPU: --")
 		a.menuMem.SetTitle("Memory: --")
+		a.menuSessions.SetTitle("Terminal Sessions: --")
+		a.menuUptime.SetTitle("Uptime: --")
 		a.menuStartAgent.Enable()
 		a.menuStopAgent.Disable()
 		a.menuRestartAgent.Disable()
+		a.menuPause.Disable()
+		a.menuUpdate.SetTitle("No Update Available")
+		a.menuUpdate.Disable()
 		return
 	}
 
 	a.agentRunning = status.Running
+	a.paused = status.Paused
 	a.connected = status.Connected
 	a.cpuPercent = status.CPUPercent
 	a.memPercent = status.MemPercent
 
 	// Update icon based on connection state
-	if status.Connected {
+	if status.Paused {
+		a.lastStatus = "Paused"
+		systray.SetIcon(GetIcon(IconStatePaused))
+		systray.SetTooltip("ServerKit Agent - Paused")
+	} else if status.Connected {
 		a.lastStatus = "Connected"
 		systray.SetIcon(GetIcon(IconStateConnected))
 		systray.SetTooltip(fmt.Sprintf("ServerKit Agent - Connected | CPU: %.1f%% | Mem: %.1f%%",
 			status.CPUPercent, status.MemPercent))
+	} else if status.Running && status.ConnectionState == "reconnecting" {
+		a.lastStatus = "Reconnecting"
+		systray.SetIcon(GetIcon(IconStateDisconnected))
+		systray.SetTooltip("ServerKit Agent - Reconnecting to server")
 	} else if status.Running {
 		a.lastStatus = "Disconnected"
 		systray.SetIcon(GetIcon(IconStateDisconnected))
@@ -181,6 +250,23 @@ func (a *App) refresh() {
 	a.menuStatus.SetTitle(fmt.Sprintf("Status: %s", a.lastStatus))
 	a.menuCPU.SetTitle(fmt.Sprintf("CPU: %.1f%%", status.CPUPercent))
 	a.menuMem.SetTitle(fmt.Sprintf("Memory: %.1f%%", status.MemPercent))
+	if sessions >= 0 {
+		a.menuSessions.SetTitle(fmt.Sprintf("Terminal Sessions: %d", sessions))
+	}
+	a.menuUptime.SetTitle(uptimeTitle(status))
+	if status.Paused {
+		a.menuPause.SetTitle("Resume Agent")
+	} else {
+		a.menuPause.SetTitle("Pause Agent")
+	}
+	a.menuPause.Enable()
+	if status.UpdatePending {
+		a.menuUpdate.SetTitle(fmt.Sprintf("Update available: v%s", strings.TrimPrefix(status.LatestVersion, "v")))
+		a.menuUpdate.Enable()
+	} else {
+		a.menuUpdate.SetTitle("No Update Available")
+		a.menuUpdate.Disable()
+	}
 
 	// Enable/disable service controls
 	if status.Running {
@@ -205,6 +291,10 @@ func (a *App) handleMenuClicks() {
 			a.stopAgent()
 		case <-a.menuRestartAgent.ClickedCh:
 			a.restartAgent()
+		case <-a.menuPause.ClickedCh:
+			a.togglePause()
+		case <-a.menuUpdate.ClickedCh:
+			a.installUpdate()
 		case <-a.menuViewLogs.ClickedCh:
 			a.viewLogs()
 		case <-a.menuDashboard.ClickedCh:
@@ -257,6 +347,37 @@ func (a *App) restartAgent() {
 	a.refresh()
 }
 
+func (a *App) togglePause() {
+	a.mu.RLock()
+	paused := a.paused
+	a.mu.RUnlock()
+
+	if paused {
+		if err := a.client.Resume(); err != nil {
+			a.showNotification("Failed to Resume", err.Error())
+			return
+		}
+		a.showNotification("Agent Resumed", "Heartbeats and metrics resumed")
+	} else {
+		if err := a.client.Pause(); err != nil {
+			a.showNotification("Failed to Pause", err.Error())
+			return
+		}
+		a.showNotification("Agent Paused", "Heartbeats and metrics paused; commands are still handled")
+	}
+	a.refresh()
+}
+
+func (a *App) installUpdate() {
+	if err := a.client.InstallUpdate(); err != nil {
+		a.showNotification("Update Failed", err.Error())
+		return
+	}
+	a.menuUpdate.SetTitle("Installing Update...")
+	a.menuUpdate.Disable()
+	a.showNotification("Updating Agent", "The agent will restart when the update is installed")
+}
+
 func (a *App) viewLogs() {
 	if a.config.LogFile == "" {
 		return
@@ -271,10 +392,23 @@ func (a *App) openDashboard() {
 	openURL(a.config.DashboardURL)
 }
 
+// showNotification shows a desktop notification. Failures are logged and
+// never take down the tray.
 func (a *App) showNotification(title, message string) {
-	// Note: systray doesn't have built-in notification support
-	// For now, this is a placeholder - could use beeep or toast libraries
-	fmt.Printf("Notification: %s - %s\n", title, message)
+	a.config.Log.Info("Notification", "title", title, "message", message)
+	if !a.config.Notify {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			a.config.Log.Warn("Notification panicked", "error", r)
+		}
+	}()
+
+	if err := beeep.Notify(title, message, ""); err != nil {
+		a.config.Log.Warn("Failed to show notification", "error", err)
+	}
 }
 
 // Platform-specific helpers
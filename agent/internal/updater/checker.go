@@ -2,7 +2,10 @@ package updater
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/serverkit/agent/internal/config"
@@ -11,49 +14,92 @@ import (
 
 // UpdateChecker runs periodic update checks
 type UpdateChecker struct {
-	updater *Updater
-	cfg     *config.Config
-	log     *logger.Logger
+	updater  *Updater
+	log      *logger.Logger
+	reloaded chan struct{} // Signalled when the update settings change
 
+	// busy is held for a whole check or install, which can take minutes
+	// of network I/O; mu only guards the results, so status reads never
+	// wait on a download
+	busy          atomic.Bool
 	mu            sync.Mutex
 	lastCheck     time.Time
 	latestVersion string
 	updatePending bool
 }
 
+// errBusy is returned when a check or install is already running
+var errBusy = errors.New("an update check or install is already in progress")
+
+// progressLogInterval is how often auto-install downloads log progress
+const progressLogInterval = 5 * time.Second
+
 // NewChecker creates a new update checker
 func NewChecker(cfg *config.Config, log *logger.Logger, currentVersion string) *UpdateChecker {
-	return &UpdateChecker{
-		updater: New(cfg, log, currentVersion),
-		cfg:     cfg,
-		log:     log,
+	c := &UpdateChecker{
+		updater:  New(cfg, log, currentVersion),
+		log:      log,
+		reloaded: make(chan struct{}, 1),
 	}
+	c.updater.SetProgress(c.logProgress())
+	return c
 }
 
-// Start begins the periodic update check routine
-func (c *UpdateChecker) Start(ctx context.Context) {
-	if !c.cfg.Update.Enabled {
-		c.log.Info("Auto-update checks disabled")
-		return
-	}
-
-	c.log.Info("Starting update checker",
-		"interval", c.cfg.Update.CheckInterval,
-		"auto_install", c.cfg.Update.AutoInstall,
-	)
+// SetRestartHook sets a callback run right before an installed update
+// stops the agent
+func (c *UpdateChecker) SetRestartHook(fn func()) {
+	c.updater.SetRestartHook(fn)
+}
 
-	// Do initial check after a short delay
-	go func() {
-		select {
-		case <-ctx.Done():
+// logProgress returns a progress callback that logs at Debug level
+// every progressLogInterval
+func (c *UpdateChecker) logProgress() ProgressFunc {
+	var last time.Time
+	return func(downloaded, total int64) {
+		done := total > 0 && downloaded >= total
+		if !done && time.Since(last) < progressLogInterval {
 			return
-		case <-time.After(1 * time.Minute):
-			c.checkAndNotify(ctx)
 		}
-	}()
+		last = time.Now()
+
+		if total > 0 {
+			c.log.Debug("Downloading update",
+				"downloaded", downloaded,
+				"total", total,
+				"percent", downloaded*100/total,
+			)
+		} else {
+			c.log.Debug("Downloading update", "downloaded", downloaded)
+		}
+	}
+}
+
+// Start begins the periodic update check routine. Checks are skipped while
+// updates are disabled, so a reload can turn them on.
+func (c *UpdateChecker) Start(ctx context.Context) {
+	cfg := c.updater.UpdateConfig()
+	if !cfg.Enabled {
+		c.log.Info("Auto-update checks disabled")
+	} else {
+		c.log.Info("Starting update checker",
+			"interval", cfg.CheckInterval,
+			"auto_install", cfg.AutoInstall,
+			"channel", c.updater.Channel(),
+		)
+
+		// Do initial check after a short delay
+		go func() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(1 * time.Minute):
+				c.checkAndNotify(ctx)
+			}
+		}()
+	}
 
 	// Start periodic checks
-	ticker := time.NewTicker(c.cfg.Update.CheckInterval)
+	ticker := time.NewTicker(c.checkInterval())
 	defer ticker.Stop()
 
 	for {
@@ -61,19 +107,48 @@ func (c *UpdateChecker) Start(ctx context.Context) {
 		case <-ctx.Done():
 			c.log.Debug("Update checker stopped")
 			return
+		case <-c.reloaded:
+			ticker.Reset(c.checkInterval())
 		case <-ticker.C:
-			c.checkAndNotify(ctx)
+			if c.updater.UpdateConfig().Enabled {
+				c.checkAndNotify(ctx)
+			}
 		}
 	}
 }
 
-func (c *UpdateChecker) checkAndNotify(ctx context.Context) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// checkInterval returns the configured check interval, falling back to
+// config.MinInterval when it is not positive
+func (c *UpdateChecker) checkInterval() time.Duration {
+	configured := c.updater.UpdateConfig().CheckInterval
+	interval, clamped := config.EffectiveInterval(configured)
+	if clamped {
+		c.log.Warn("Interval must be positive, using the minimum instead",
+			"field", "update.check_interval",
+			"configured", configured,
+			"using", interval,
+		)
+	}
+	return interval
+}
 
-	c.lastCheck = time.Now()
+// Reload applies new update settings to the running checker
+func (c *UpdateChecker) Reload(update config.UpdateConfig) {
+	c.updater.SetUpdateConfig(update)
+	select {
+	case c.reloaded <- struct{}{}:
+	default:
+	}
+}
 
-	info, err := c.updater.CheckForUpdate(ctx)
+func (c *UpdateChecker) checkAndNotify(ctx context.Context) {
+	if !c.busy.CompareAndSwap(false, true) {
+		c.log.Debug("Skipping update check, another is in progress")
+		return
+	}
+	defer c.busy.Store(false)
+
+	info, err := c.check(ctx)
 	if err != nil {
 		c.log.Warn("Update check failed", "error", err)
 		return
@@ -81,20 +156,17 @@ func (c *UpdateChecker) checkAndNotify(ctx context.Context) {
 
 	if !info.UpdateAvailable {
 		c.log.Debug("No update available")
-		c.updatePending = false
 		return
 	}
 
-	c.latestVersion = info.LatestVersion
-	c.updatePending = true
-
 	c.log.Info("Update available",
 		"current", info.CurrentVersion,
 		"latest", info.LatestVersion,
+		"channel", c.updater.Channel(),
 	)
 
 	// Auto-install if enabled
-	if c.cfg.Update.AutoInstall {
+	if c.updater.UpdateConfig().AutoInstall {
 		c.log.Info("Auto-install enabled, downloading update...")
 		if err := c.installUpdate(ctx, info); err != nil {
 			c.log.Error("Auto-update failed", "error", err)
@@ -102,6 +174,27 @@ func (c *UpdateChecker) checkAndNotify(ctx context.Context) {
 	}
 }
 
+// check fetches the release feed without holding mu, then records the
+// result. c.busy must be held.
+func (c *UpdateChecker) check(ctx context.Context) (*VersionInfo, error) {
+	c.mu.Lock()
+	c.lastCheck = time.Now()
+	c.mu.Unlock()
+
+	info, err := c.updater.CheckForUpdate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.updatePending = info.UpdateAvailable
+	if info.UpdateAvailable {
+		c.latestVersion = info.LatestVersion
+	}
+	c.mu.Unlock()
+	return info, nil
+}
+
 func (c *UpdateChecker) installUpdate(ctx context.Context, info *VersionInfo) error {
 	binaryPath, err := c.updater.DownloadUpdate(ctx, info)
 	if err != nil {
@@ -116,13 +209,39 @@ func (c *UpdateChecker) installUpdate(ctx context.Context, info *VersionInfo) er
 	return nil
 }
 
-// CheckNow performs an immediate update check
+// Install checks for the latest version and installs it regardless of
+// auto_install, the same as "update --force". It fails if a check or
+// install is already running.
+func (c *UpdateChecker) Install(ctx context.Context) error {
+	if !c.busy.CompareAndSwap(false, true) {
+		return errBusy
+	}
+	defer c.busy.Store(false)
+
+	info, err := c.check(ctx)
+	if err != nil {
+		return err
+	}
+	if !info.UpdateAvailable {
+		return fmt.Errorf("no update available")
+	}
+
+	c.log.Info("Installing update",
+		"current", info.CurrentVersion,
+		"latest", info.LatestVersion,
+	)
+	return c.installUpdate(ctx, info)
+}
+
+// CheckNow performs an immediate update check. It fails if a check or
+// install is already running.
 func (c *UpdateChecker) CheckNow(ctx context.Context) (*VersionInfo, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	if !c.busy.CompareAndSwap(false, true) {
+		return nil, errBusy
+	}
+	defer c.busy.Store(false)
 
-	c.lastCheck = time.Now()
-	return c.updater.CheckForUpdate(ctx)
+	return c.check(ctx)
 }
 
 // GetUpdater returns the underlying updater for manual operations
@@ -0,0 +1,42 @@
+package updater
+
+import (
+	"io"
+	"time"
+)
+
+// progressInterval is the minimum time between progress callbacks
+const progressInterval = 200 * time.Millisecond
+
+// ProgressFunc receives download progress. total is -1 when the server did
+// not send a Content-Length.
+type ProgressFunc func(downloaded, total int64)
+
+// progressReader reports bytes read through it to a ProgressFunc
+type progressReader struct {
+	r          io.Reader
+	total      int64
+	downloaded int64
+	report     ProgressFunc
+	lastReport time.Time
+}
+
+func newProgressReader(r io.Reader, total int64, report ProgressFunc) *progressReader {
+	if total <= 0 {
+		total = -1
+	}
+	return &progressReader{r: r, total: total, report: report}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.downloaded += int64(n)
+
+	// Throttle callbacks, but always report completion
+	now := time.Now()
+	if err == io.EOF || now.Sub(p.lastReport) >= progressInterval {
+		p.lastReport = now
+		p.report(p.downloaded, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,146 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisign signature algorithms
+var (
+	// sigAlgPure signs the file contents directly (legacy minisign -l)
+	sigAlgPure = [2]byte{'E', 'd'}
+	// sigAlgHashed signs the BLAKE2b-512 hash of the file (minisign default)
+	sigAlgHashed = [2]byte{'E', 'D'}
+)
+
+// minisignKey is a decoded minisign public key
+type minisignKey struct {
+	keyID [8]byte
+	key   ed25519.PublicKey
+}
+
+// minisignSignature is a decoded minisign signature file
+type minisignSignature struct {
+	algorithm       [2]byte
+	keyID           [8]byte
+	signature       []byte
+	trustedComment  string
+	globalSignature []byte
+}
+
+// parseMinisignKey decodes a minisign public key. It accepts the key line
+// on its own or the full contents of a .pub file.
+func parseMinisignKey(value string) (*minisignKey, error) {
+	line := ""
+	for _, l := range strings.Split(strings.TrimSpace(value), "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			line = l
+			break
+		}
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid minisign public key")
+	}
+	if raw[0] != 'E' || raw[1] != 'd' {
+		return nil, fmt.Errorf("unsupported public key algorithm %q", raw[:2])
+	}
+
+	k := &minisignKey{key: ed25519.PublicKey(raw[10:])}
+	copy(k.keyID[:], raw[2:10])
+	return k, nil
+}
+
+// parseMinisignSignature decodes a minisign .minisig file
+func parseMinisignSignature(data []byte) (*minisignSignature, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if len(lines) < 4 {
+		return nil, fmt.Errorf("invalid signature file: expected 4 lines")
+	}
+	if !strings.HasPrefix(lines[0], "untrusted comment:") {
+		return nil, fmt.Errorf("invalid signature file: missing untrusted comment")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature file: malformed signature")
+	}
+
+	const trustedPrefix = "trusted comment: "
+	if !strings.HasPrefix(lines[2], trustedPrefix) {
+		return nil, fmt.Errorf("invalid signature file: missing trusted comment")
+	}
+
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature file: malformed global signature")
+	}
+
+	sig := &minisignSignature{
+		signature:       raw[10:],
+		trustedComment:  strings.TrimPrefix(lines[2], trustedPrefix),
+		globalSignature: global,
+	}
+	copy(sig.algorithm[:], raw[:2])
+	copy(sig.keyID[:], raw[2:10])
+	return sig, nil
+}
+
+// verifyMinisign checks a minisign signature of the file at path
+func verifyMinisign(path string, key *minisignKey, sig *minisignSignature) error {
+	if sig.keyID != key.keyID {
+		return fmt.Errorf("signature was made with key %X, expected %X", reverse(sig.keyID), reverse(key.keyID))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var message []byte
+	switch sig.algorithm {
+	case sigAlgHashed:
+		h, _ := blake2b.New512(nil)
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		message = h.Sum(nil)
+	case sigAlgPure:
+		if message, err = io.ReadAll(f); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", sig.algorithm[:])
+	}
+
+	if !ed25519.Verify(key.key, message, sig.signature) {
+		return fmt.Errorf("signature does not match")
+	}
+
+	// The global signature binds the trusted comment to the file signature
+	global := append(bytes.Clone(sig.signature), sig.trustedComment...)
+	if !ed25519.Verify(key.key, global, sig.globalSignature) {
+		return fmt.Errorf("trusted comment signature does not match")
+	}
+
+	return nil
+}
+
+// reverse returns a key ID in the byte order minisign prints it
+func reverse(id [8]byte) []byte {
+	out := make([]byte, len(id))
+	for i := range id {
+		out[i] = id[len(id)-1-i]
+	}
+	return out
+}
@@ -6,16 +6,23 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/serverkit/agent/internal/config"
@@ -29,43 +36,90 @@ type VersionInfo struct {
 	LatestVersion   string `json:"latest_version"`
 	DownloadURL     string `json:"download_url"`
 	ChecksumsURL    string `json:"checksums_url"`
+	SignatureURL    string `json:"signature_url,omitempty"` // minisign signature of the archive
 	ReleaseNotesURL string `json:"release_notes_url"`
 	PublishedAt     string `json:"published_at"`
 }
 
 // Updater handles agent self-updates
 type Updater struct {
-	cfg            *config.Config
+	update         atomic.Pointer[config.UpdateConfig] // Swapped on config reload
+	logFile        string
 	log            *logger.Logger
 	currentVersion string
 	serverURL      string
 	httpClient     *http.Client
+	progress       ProgressFunc
+	beforeRestart  func()
 }
 
 // New creates a new Updater instance
 func New(cfg *config.Config, log *logger.Logger, currentVersion string) *Updater {
 	// Derive HTTP URL from WebSocket URL
-	serverURL := cfg.Server.URL
-	serverURL = strings.Replace(serverURL, "wss://", "https://", 1)
-	serverURL = strings.Replace(serverURL, "ws://", "http://", 1)
-	// Remove /agent/ws path if present
-	serverURL = strings.TrimSuffix(serverURL, "/agent/ws")
-	serverURL = strings.TrimSuffix(serverURL, "/agent")
-
-	return &Updater{
-		cfg:            cfg,
+	serverURL := cfg.Server.HTTPBaseURL()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = cfg.Server.ProxyFunc()
+
+	u := &Updater{
+		logFile:        cfg.Logging.File,
 		log:            log,
 		currentVersion: currentVersion,
 		serverURL:      serverURL,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: transport,
 		},
 	}
+	u.SetUpdateConfig(cfg.Update)
+	return u
+}
+
+// SetUpdateConfig replaces the update settings, e.g. on a config reload
+func (u *Updater) SetUpdateConfig(update config.UpdateConfig) {
+	u.update.Store(&update)
+}
+
+// UpdateConfig returns the current update settings
+func (u *Updater) UpdateConfig() config.UpdateConfig {
+	return *u.update.Load()
+}
+
+// SetProgress sets a callback that receives download progress
+func (u *Updater) SetProgress(fn ProgressFunc) {
+	u.progress = fn
+}
+
+// SetRestartHook sets a callback run once the agent is certain to be
+// stopped for an update or rollback, right before that happens
+func (u *Updater) SetRestartHook(fn func()) {
+	u.beforeRestart = fn
+}
+
+// notifyRestart runs the restart hook, if one is set
+func (u *Updater) notifyRestart() {
+	if u.beforeRestart != nil {
+		u.beforeRestart()
+	}
+}
+
+// Channel returns the release channel update checks ask for
+func (u *Updater) Channel() string {
+	channel := u.UpdateConfig().Channel
+	if channel == "" {
+		return config.UpdateChannelStable
+	}
+	return channel
 }
 
 // CheckForUpdate checks if a new version is available
 func (u *Updater) CheckForUpdate(ctx context.Context) (*VersionInfo, error) {
-	u.log.Debug("Checking for updates", "current_version", u.currentVersion)
+	channel := u.Channel()
+	if !config.ValidUpdateChannel(channel) {
+		return nil, fmt.Errorf("unknown update channel %q", channel)
+	}
+
+	u.log.Debug("Checking for updates", "current_version", u.currentVersion, "channel", channel)
 
 	url := fmt.Sprintf("%s/api/servers/agent/version/check", u.serverURL)
 
@@ -73,6 +127,7 @@ func (u *Updater) CheckForUpdate(ctx context.Context) (*VersionInfo, error) {
 		"current_version": u.currentVersion,
 		"os":              runtime.GOOS,
 		"arch":            runtime.GOARCH,
+		"channel":         channel,
 	}
 
 	body, err := json.Marshal(payload)
@@ -106,6 +161,7 @@ func (u *Updater) CheckForUpdate(ctx context.Context) (*VersionInfo, error) {
 	u.log.Debug("Update check complete",
 		"update_available", info.UpdateAvailable,
 		"latest_version", info.LatestVersion,
+		"channel", channel,
 	)
 
 	return &info, nil
@@ -139,9 +195,19 @@ func (u *Updater) DownloadUpdate(ctx context.Context, info *VersionInfo) (string
 		return "", fmt.Errorf("failed to download update: %w", err)
 	}
 
+	// Verify the signature when a public key is configured; a failure
+	// aborts the update and removes the download
+	if u.UpdateConfig().PublicKey != "" {
+		if err := u.verifySignature(ctx, archivePath, info.SignatureURL); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", fmt.Errorf("signature verification failed: %w", err)
+		}
+		u.log.Info("Signature verified successfully")
+	}
+
 	// Verify checksum if available
 	if info.ChecksumsURL != "" {
-		if err := u.verifyChecksum(ctx, archivePath, info.ChecksumsURL); err != nil {
+		if err := u.verifyChecksum(ctx, archivePath, info.ChecksumsURL, info.DownloadURL); err != nil {
 			os.RemoveAll(tmpDir)
 			return "", fmt.Errorf("checksum verification failed: %w", err)
 		}
@@ -155,22 +221,67 @@ func (u *Updater) DownloadUpdate(ctx context.Context, info *VersionInfo) (string
 		return "", fmt.Errorf("failed to extract update: %w", err)
 	}
 
+	// Make sure the new binary actually runs on this host before it is
+	// allowed anywhere near the installed one
+	if err := u.smokeTest(ctx, binaryPath, info.LatestVersion); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", fmt.Errorf("new binary failed smoke test: %w", err)
+	}
+
 	u.log.Info("Update downloaded and verified", "path", binaryPath)
 	return binaryPath, nil
 }
 
+// smokeTestTimeout bounds how long the new binary may take to print its version
+const smokeTestTimeout = 10 * time.Second
+
+// smokeTest runs "<binary> version" and checks it reports the expected version
+func (u *Updater) smokeTest(ctx context.Context, binaryPath, expectedVersion string) error {
+	ctx, cancel := context.WithTimeout(ctx, smokeTestTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, binaryPath, "version").CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("version command timed out after %s", smokeTestTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("version command failed: %w", err)
+	}
+
+	reported := parseVersionOutput(string(output))
+	if reported == "" {
+		return fmt.Errorf("version command printed no version")
+	}
+	if expectedVersion != "" && normalizeVersion(reported) != normalizeVersion(expectedVersion) {
+		return fmt.Errorf("binary reports version %s, expected %s", reported, expectedVersion)
+	}
+
+	u.log.Debug("New binary passed smoke test", "version", reported)
+	return nil
+}
+
+// parseVersionOutput extracts the version from the "version" command output
+func parseVersionOutput(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), ":"); ok && key == "Version" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func normalizeVersion(v string) string {
+	return strings.TrimPrefix(strings.TrimSpace(v), "v")
+}
+
 // InstallUpdate installs the new binary and restarts the agent
 func (u *Updater) InstallUpdate(newBinaryPath string) error {
 	u.log.Info("Installing update", "new_binary", newBinaryPath)
 
 	// Get current binary path
-	currentBinary, err := os.Executable()
+	currentBinary, err := currentExecutable()
 	if err != nil {
-		return fmt.Errorf("failed to get current executable: %w", err)
-	}
-	currentBinary, err = filepath.EvalSymlinks(currentBinary)
-	if err != nil {
-		return fmt.Errorf("failed to resolve symlinks: %w", err)
+		return err
 	}
 
 	// Create backup path
@@ -207,48 +318,101 @@ func (u *Updater) installUnix(currentBinary, newBinaryPath, backupPath string) e
 	}
 
 	u.log.Info("Update installed successfully, restarting agent...")
+	return u.restart(currentBinary)
+}
+
+// Rollback restores the binary saved by the last InstallUpdate and restarts
+// the agent. The binary being replaced is kept as <binary>.failed so the
+// bad release can be inspected.
+func (u *Updater) Rollback() error {
+	currentBinary, err := currentExecutable()
+	if err != nil {
+		return err
+	}
+
+	backupPath := currentBinary + ".backup"
+	failedPath := currentBinary + ".failed"
+
+	if _, err := os.Stat(backupPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no backup found at %s", backupPath)
+		}
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	// Refuse a backup that could not run here
+	goos, goarch, err := binaryPlatform(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to inspect backup binary: %w", err)
+	}
+	if goos != runtime.GOOS || goarch != runtime.GOARCH {
+		return fmt.Errorf("backup binary is built for %s/%s, this host is %s/%s",
+			goos, goarch, runtime.GOOS, runtime.GOARCH)
+	}
+
+	u.log.Info("Rolling back to previous version", "backup", backupPath, "failed", failedPath)
+
+	if runtime.GOOS == "windows" {
+		return u.rollbackWindows(currentBinary, backupPath, failedPath)
+	}
+
+	return u.rollbackUnix(currentBinary, backupPath, failedPath)
+}
+
+func (u *Updater) rollbackUnix(currentBinary, backupPath, failedPath string) error {
+	// Keep only the most recent failed binary
+	os.Remove(failedPath)
+
+	if err := os.Rename(currentBinary, failedPath); err != nil {
+		return fmt.Errorf("failed to move current binary aside: %w", err)
+	}
+
+	if err := os.Rename(backupPath, currentBinary); err != nil {
+		// Put the current binary back so the agent keeps working
+		os.Rename(failedPath, currentBinary)
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	u.log.Info("Rollback complete, restarting agent...")
+	return u.restart(currentBinary)
+}
+
+func (u *Updater) rollbackWindows(currentBinary, backupPath, failedPath string) error {
+	// The running binary can't be replaced in place, so the swap happens
+	// from a script once the agent has exited
+	if err := u.scheduleWindowsSwap(backupPath, currentBinary, failedPath, true); err != nil {
+		return err
+	}
+	u.notifyRestart()
 
+	u.log.Info("Rollback scheduled, agent will restart shortly")
+	return nil
+}
+
+// restart restarts the agent after its binary was replaced
+func (u *Updater) restart(binaryPath string) error {
 	// Restart via systemd if available
 	if u.isSystemd() {
 		cmd := exec.Command("systemctl", "restart", "serverkit-agent")
 		if err := cmd.Start(); err != nil {
 			u.log.Warn("Failed to restart via systemd", "error", err)
+			return nil
 		}
+		u.notifyRestart()
 		return nil
 	}
 
 	// Self-restart
-	return u.selfRestart(currentBinary)
+	return u.selfRestart(binaryPath)
 }
 
 func (u *Updater) installWindows(currentBinary, newBinaryPath, backupPath string) error {
-	// On Windows, create a batch script to:
-	// 1. Wait for current process to exit
-	// 2. Replace the binary
-	// 3. Start the new binary/service
-
-	batchScript := fmt.Sprintf(`@echo off
-timeout /t 2 /nobreak > nul
-del "%s.backup" 2>nul
-move "%s" "%s.backup"
-copy "%s" "%s"
-net start ServerKitAgent
-del "%%~f0"
-`, currentBinary, currentBinary, currentBinary, newBinaryPath, currentBinary)
-
-	scriptPath := filepath.Join(os.TempDir(), "serverkit-update.bat")
-	if err := os.WriteFile(scriptPath, []byte(batchScript), 0755); err != nil {
-		return fmt.Errorf("failed to create update script: %w", err)
-	}
-
-	// Stop the service
-	exec.Command("net", "stop", "ServerKitAgent").Run()
-
-	// Run the batch script detached
-	cmd := exec.Command("cmd", "/c", "start", "/b", scriptPath)
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start update script: %w", err)
+	// The running binary can't be replaced in place, so the swap happens
+	// from a script once the agent has exited
+	if err := u.scheduleWindowsSwap(newBinaryPath, currentBinary, backupPath, false); err != nil {
+		return err
 	}
+	u.notifyRestart()
 
 	u.log.Info("Update scheduled, agent will restart shortly")
 	return nil
@@ -278,11 +442,58 @@ func (u *Updater) downloadFile(ctx context.Context, url, destPath string) error
 	}
 	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	var body io.Reader = resp.Body
+	if u.progress != nil {
+		body = newProgressReader(resp.Body, resp.ContentLength, u.progress)
+	}
+
+	_, err = io.Copy(out, body)
 	return err
 }
 
-func (u *Updater) verifyChecksum(ctx context.Context, filePath, checksumsURL string) error {
+// maxSignatureSize bounds the size of a downloaded signature file
+const maxSignatureSize = 64 * 1024
+
+func (u *Updater) verifySignature(ctx context.Context, filePath, signatureURL string) error {
+	key, err := parseMinisignKey(u.UpdateConfig().PublicKey)
+	if err != nil {
+		return fmt.Errorf("update.public_key: %w", err)
+	}
+
+	if signatureURL == "" {
+		return fmt.Errorf("server did not provide a signature for this release")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", signatureURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("ServerKit-Agent/%s", u.currentVersion))
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download signature: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("signature download failed with status: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize))
+	if err != nil {
+		return fmt.Errorf("failed to download signature: %w", err)
+	}
+
+	sig, err := parseMinisignSignature(data)
+	if err != nil {
+		return err
+	}
+
+	return verifyMinisign(filePath, key, sig)
+}
+
+func (u *Updater) verifyChecksum(ctx context.Context, filePath, checksumsURL, downloadURL string) error {
 	// Download checksums file
 	req, err := http.NewRequestWithContext(ctx, "GET", checksumsURL, nil)
 	if err != nil {
@@ -300,12 +511,12 @@ func (u *Updater) verifyChecksum(ctx context.Context, filePath, checksumsURL str
 		return err
 	}
 
-	// Parse checksums
+	// Parse checksums ("<hash>  <name>", or "<hash> *<name>" in binary mode)
 	checksums := make(map[string]string)
 	for _, line := range strings.Split(string(checksumsData), "\n") {
 		parts := strings.Fields(line)
 		if len(parts) >= 2 {
-			checksums[parts[1]] = parts[0]
+			checksums[strings.TrimPrefix(parts[1], "*")] = parts[0]
 		}
 	}
 
@@ -323,21 +534,9 @@ func (u *Updater) verifyChecksum(ctx context.Context, filePath, checksumsURL str
 	actualHash := hex.EncodeToString(hasher.Sum(nil))
 
 	// Find expected hash
-	fileName := filepath.Base(filePath)
-	// Try to match by looking for platform-specific name
-	expectedHash := ""
-	for name, hash := range checksums {
-		if strings.Contains(name, runtime.GOOS) && strings.Contains(name, runtime.GOARCH) {
-			expectedHash = hash
-			break
-		}
-	}
-
-	if expectedHash == "" {
-		// Try exact match
-		if hash, ok := checksums[fileName]; ok {
-			expectedHash = hash
-		}
+	expectedHash, err := findChecksum(checksums, artifactName(downloadURL))
+	if err != nil {
+		return err
 	}
 
 	if expectedHash == "" {
@@ -345,13 +544,73 @@ func (u *Updater) verifyChecksum(ctx context.Context, filePath, checksumsURL str
 		return nil
 	}
 
-	if actualHash != expectedHash {
+	if !strings.EqualFold(actualHash, expectedHash) {
 		return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedHash, actualHash)
 	}
 
 	return nil
 }
 
+// artifactName returns the file name of the release artifact at downloadURL
+func artifactName(downloadURL string) string {
+	parsed, err := url.Parse(downloadURL)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(parsed.Path)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// findChecksum picks the checksum for the downloaded artifact. The exact
+// artifact name wins; otherwise a single entry whose name has this host's
+// OS and architecture as separate components is used, so "linux-arm" does
+// not match "linux-arm64". It returns "" when nothing matches.
+func findChecksum(checksums map[string]string, artifact string) (string, error) {
+	if hash, ok := checksums[artifact]; ok && artifact != "" {
+		return hash, nil
+	}
+
+	var candidates []string
+	for name := range checksums {
+		if hasPlatformComponents(name, runtime.GOOS, runtime.GOARCH) {
+			candidates = append(candidates, name)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", nil
+	case 1:
+		return checksums[candidates[0]], nil
+	default:
+		sort.Strings(candidates)
+		return "", fmt.Errorf("ambiguous checksum entries for %s/%s: %s",
+			runtime.GOOS, runtime.GOARCH, strings.Join(candidates, ", "))
+	}
+}
+
+// hasPlatformComponents reports whether a file name, split on "-", "_" and
+// ".", contains goos and goarch as whole components
+func hasPlatformComponents(name, goos, goarch string) bool {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+
+	hasOS, hasArch := false, false
+	for _, part := range parts {
+		if part == goos {
+			hasOS = true
+		}
+		if part == goarch {
+			hasArch = true
+		}
+	}
+	return hasOS && hasArch
+}
+
 func (u *Updater) extractBinary(archivePath, destDir string) (string, error) {
 	if runtime.GOOS == "windows" {
 		return u.extractZip(archivePath, destDir)
@@ -466,10 +725,69 @@ func (u *Updater) selfRestart(binaryPath string) error {
 	}
 
 	u.log.Info("Restarting with new version...")
+	u.notifyRestart()
 	os.Exit(0)
 	return nil
 }
 
+// currentExecutable returns the resolved path of the running binary
+func currentExecutable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current executable: %w", err)
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve symlinks: %w", err)
+	}
+	return path, nil
+}
+
+// binaryPlatform reads an executable's headers and returns the GOOS and
+// GOARCH it was built for
+func binaryPlatform(path string) (string, string, error) {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		switch f.Machine {
+		case elf.EM_X86_64:
+			return "linux", "amd64", nil
+		case elf.EM_AARCH64:
+			return "linux", "arm64", nil
+		case elf.EM_ARM:
+			return "linux", "arm", nil
+		case elf.EM_386:
+			return "linux", "386", nil
+		}
+		return "linux", f.Machine.String(), nil
+	}
+
+	if f, err := pe.Open(path); err == nil {
+		defer f.Close()
+		switch f.Machine {
+		case pe.IMAGE_FILE_MACHINE_AMD64:
+			return "windows", "amd64", nil
+		case pe.IMAGE_FILE_MACHINE_ARM64:
+			return "windows", "arm64", nil
+		case pe.IMAGE_FILE_MACHINE_I386:
+			return "windows", "386", nil
+		}
+		return "windows", fmt.Sprintf("machine-0x%x", f.Machine), nil
+	}
+
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		switch f.Cpu {
+		case macho.CpuAmd64:
+			return "darwin", "amd64", nil
+		case macho.CpuArm64:
+			return "darwin", "arm64", nil
+		}
+		return "darwin", f.Cpu.String(), nil
+	}
+
+	return "", "", fmt.Errorf("%s is not a recognized executable", path)
+}
+
 func copyFile(src, dst string) error {
 	sourceFile, err := os.Open(src)
 	if err != nil {
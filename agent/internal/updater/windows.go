@@ -0,0 +1,161 @@
+package updater
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// windowsServiceName is the name the installer registers the agent service under
+const windowsServiceName = "ServerKitAgent"
+
+// windowsSwapScript replaces the agent binary once the running agent has
+// exited. It copies Source next to Target and verifies the copy before
+// touching the installed binary, moves the installed binary to Displaced,
+// and restarts the service. If the service does not start, the displaced
+// binary is put back and started instead. All paths arrive as parameters,
+// so spaces and special characters need no escaping.
+const windowsSwapScript = `param(
+    [Parameter(Mandatory=$true)][int]$AgentPid,
+    [Parameter(Mandatory=$true)][string]$Source,
+    [Parameter(Mandatory=$true)][string]$Target,
+    [Parameter(Mandatory=$true)][string]$Displaced,
+    [Parameter(Mandatory=$true)][string]$ServiceName,
+    [Parameter(Mandatory=$true)][string]$LogFile,
+    [switch]$RemoveSource
+)
+
+$ErrorActionPreference = 'Stop'
+Start-Transcript -Path $LogFile -Append | Out-Null
+
+# net.exe output goes to the host so only the result is returned
+function Start-Agent {
+    & net start $ServiceName | Write-Host
+    return ($LASTEXITCODE -eq 0)
+}
+
+$staged = "$Target.new"
+$exitCode = 0
+
+try {
+    Write-Output "Updating $Target from $Source"
+
+    # Stage and verify the new binary before touching the installed one
+    Copy-Item -LiteralPath $Source -Destination $staged -Force
+    $expected = (Get-FileHash -LiteralPath $Source -Algorithm SHA256).Hash
+    $actual = (Get-FileHash -LiteralPath $staged -Algorithm SHA256).Hash
+    if ($expected -ne $actual) {
+        throw "Staged copy does not match source ($actual != $expected)"
+    }
+
+    # Stop the service and wait for the agent process itself to exit
+    if (Get-Service -Name $ServiceName -ErrorAction SilentlyContinue) {
+        & net stop $ServiceName | Out-Null
+    }
+    $proc = Get-Process -Id $AgentPid -ErrorAction SilentlyContinue
+    if ($proc) {
+        Write-Output "Waiting for process $AgentPid to exit"
+        if (-not $proc.WaitForExit(60000)) {
+            Write-Output "Process $AgentPid did not exit, stopping it"
+            Stop-Process -Id $AgentPid -Force
+            $proc.WaitForExit(10000) | Out-Null
+        }
+    }
+
+    # Swap the binaries
+    if (Test-Path -LiteralPath $Displaced) {
+        Remove-Item -LiteralPath $Displaced -Force
+    }
+    Move-Item -LiteralPath $Target -Destination $Displaced -Force
+    try {
+        Move-Item -LiteralPath $staged -Destination $Target -Force
+    } catch {
+        Move-Item -LiteralPath $Displaced -Destination $Target -Force
+        throw
+    }
+
+    if (Start-Agent) {
+        Write-Output "Service started with the new binary"
+        if ($RemoveSource) {
+            Remove-Item -LiteralPath $Source -Force -ErrorAction SilentlyContinue
+        }
+    } else {
+        # Put the previous binary back so the host is not left without an agent
+        Write-Output "net start failed with exit code $LASTEXITCODE, restoring previous binary"
+        Remove-Item -LiteralPath $Target -Force
+        Move-Item -LiteralPath $Displaced -Destination $Target -Force
+        if (-not (Start-Agent)) {
+            Write-Output "Failed to start the restored binary"
+        }
+        $exitCode = 1
+    }
+} catch {
+    Write-Output "Update failed: $_"
+    if (Test-Path -LiteralPath $staged) {
+        Remove-Item -LiteralPath $staged -Force -ErrorAction SilentlyContinue
+    }
+    if (-not (Get-Process -Id $AgentPid -ErrorAction SilentlyContinue)) {
+        Start-Agent | Out-Null
+    }
+    $exitCode = 1
+} finally {
+    Stop-Transcript | Out-Null
+    Remove-Item -LiteralPath $PSCommandPath -Force -ErrorAction SilentlyContinue
+}
+
+exit $exitCode
+`
+
+// scheduleWindowsSwap writes the swap script and starts it in a separate
+// PowerShell process that outlives the agent. The displaced binary keeps the
+// installed version (a backup on install, the failed build on rollback).
+func (u *Updater) scheduleWindowsSwap(source, target, displaced string, removeSource bool) error {
+	scriptFile, err := os.CreateTemp("", "serverkit-update-*.ps1")
+	if err != nil {
+		return fmt.Errorf("failed to create update script: %w", err)
+	}
+	scriptPath := scriptFile.Name()
+
+	_, err = scriptFile.WriteString(windowsSwapScript)
+	scriptFile.Close()
+	if err != nil {
+		os.Remove(scriptPath)
+		return fmt.Errorf("failed to write update script: %w", err)
+	}
+
+	logFile := u.windowsUpdateLog()
+
+	args := []string{
+		"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass",
+		"-File", scriptPath,
+		"-AgentPid", strconv.Itoa(os.Getpid()),
+		"-Source", source,
+		"-Target", target,
+		"-Displaced", displaced,
+		"-ServiceName", windowsServiceName,
+		"-LogFile", logFile,
+	}
+	if removeSource {
+		args = append(args, "-RemoveSource")
+	}
+
+	cmd := exec.Command("powershell.exe", args...)
+	if err := cmd.Start(); err != nil {
+		os.Remove(scriptPath)
+		return fmt.Errorf("failed to start update script: %w", err)
+	}
+	cmd.Process.Release()
+
+	u.log.Info("Update script started", "script", scriptPath, "log", logFile)
+	return nil
+}
+
+// windowsUpdateLog returns where the swap script writes its transcript
+func (u *Updater) windowsUpdateLog() string {
+	if u.logFile != "" {
+		return filepath.Join(filepath.Dir(u.logFile), "update.log")
+	}
+	return filepath.Join(os.TempDir(), "serverkit-update.log")
+}
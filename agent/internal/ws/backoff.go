@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/serverkit/agent/internal/config"
+)
+
+// backoff returns how long to wait before reconnect attempt n (starting at
+// 1). Jitter spreads out agents that lost the server at the same moment so
+// they do not reconnect in lockstep. The result never exceeds
+// MaxReconnectInterval.
+func (c *Client) backoff(attempt int, previous time.Duration) time.Duration {
+	base := c.cfg.ReconnectInterval
+	ceiling := c.cfg.MaxReconnectInterval
+
+	switch c.cfg.ReconnectJitter {
+	case config.JitterNone:
+		return exponential(base, ceiling, attempt)
+	case config.JitterDecorrelated:
+		// Uniform between the base interval and three times the last delay
+		if previous < base {
+			previous = base
+		}
+		return min(ceiling, base+randDuration(3*previous-base))
+	default:
+		// Full jitter: uniform between zero and the exponential backoff
+		return randDuration(exponential(base, ceiling, attempt))
+	}
+}
+
+// exponential returns base * 2^(attempt-1), capped at ceiling
+func exponential(base, ceiling time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < ceiling; i++ {
+		d *= 2
+	}
+	return min(d, ceiling)
+}
+
+// randDuration returns a uniformly random duration in [0, d]
+func randDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
@@ -2,11 +2,15 @@ package ws
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -16,6 +20,25 @@ import (
 	"github.com/serverkit/agent/pkg/protocol"
 )
 
+const (
+	// sessionRenewWindow is how long before expiry the session is renewed
+	sessionRenewWindow = 5 * time.Minute
+	// sessionCheckInterval is how often the session expiry is checked
+	sessionCheckInterval = 30 * time.Second
+	// sessionRenewTimeout is how long a renewal may go unanswered before
+	// it is sent again
+	sessionRenewTimeout = 30 * time.Second
+	// writeTimeout bounds a single write, so a stalled socket releases the
+	// writer instead of blocking it (and shutdown) indefinitely
+	writeTimeout = 10 * time.Second
+	// goodbyeTimeout bounds the disconnect message and close frame writes
+	// on shutdown
+	goodbyeTimeout = 2 * time.Second
+)
+
+// errConnClosed is returned when writing after the connection was closed
+var errConnClosed = errors.New("connection closed")
+
 // MessageHandler is called when a message is received
 type MessageHandler func(msgType protocol.MessageType, data []byte)
 
@@ -26,27 +49,59 @@ type Client struct {
 	log           *logger.Logger
 	conn          *websocket.Conn
 	handler       MessageHandler
+	stateHandler  StateHandler
 	session       *auth.SessionToken
+	renewing      time.Time // When an unanswered session renewal was sent
 
 	mu            sync.RWMutex
+	writeMu       sync.Mutex // Serializes writes; gorilla allows one writer at a time
 	connected     bool
 	reconnecting  bool
+	closed        bool // Set by Shutdown; Run stops reconnecting
 
 	sendCh        chan []byte
 	doneCh        chan struct{}
 
-	reconnectCount int
+	reconnectCount int // Failed attempts since the last connection; drives backoff
+	lastBackoff    time.Duration
+	urlIndex       int // Position in serverURLs of the URL in use
+
+	protocolVersion int // Negotiated at auth; see protocol.ProtocolVersion
+
+	// Connection history for status reporting
+	connects      int
+	lastConnected time.Time
+
+	latency   *latencyTracker
+	outbox    *outbox     // Unconfirmed results; nil when redelivery is disabled
+	ackWarned atomic.Bool // Warned that acks cannot confirm results
+
+	// Server clock minus local clock, from the latest auth response
+	skew      time.Duration
+	skewKnown bool
 }
 
 // NewClient creates a new WebSocket client
 func NewClient(cfg config.ServerConfig, authenticator *auth.Authenticator, log *logger.Logger) *Client {
-	return &Client{
-		cfg:    cfg,
-		auth:   authenticator,
-		log:    log.WithComponent("websocket"),
-		sendCh: make(chan []byte, 100),
-		doneCh: make(chan struct{}),
+	if !config.ValidJitter(cfg.ReconnectJitter) {
+		if cfg.ReconnectJitter != "" {
+			log.Warn("Unknown reconnect jitter strategy, using full jitter", "jitter", cfg.ReconnectJitter)
+		}
+		cfg.ReconnectJitter = config.JitterFull
 	}
+
+	c := &Client{
+		cfg:     cfg,
+		auth:    authenticator,
+		log:     log.WithComponent("websocket"),
+		sendCh:  make(chan []byte, 100),
+		doneCh:  make(chan struct{}),
+		latency: newLatencyTracker(),
+	}
+	if cfg.ResultQueueSize > 0 {
+		c.outbox = newOutbox(cfg.ResultQueueSize)
+	}
+	return c
 }
 
 // SetHandler sets the message handler
@@ -64,22 +119,27 @@ func (c *Client) Connect(ctx context.Context) error {
 	c.mu.Unlock()
 
 	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second,
+		HandshakeTimeout:  10 * time.Second,
+		EnableCompression: c.cfg.Compression,
+		Proxy:             c.cfg.ProxyFunc(),
 	}
 
-	// Allow insecure for development
-	if c.cfg.InsecureSkipVerify {
-		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	// Custom CA, certificate pinning, or insecure mode for development
+	tlsCfg, err := c.cfg.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("invalid TLS configuration: %w", err)
 	}
+	dialer.TLSClientConfig = tlsCfg
 
 	// Add authentication headers
 	headers := http.Header{}
 	headers.Set("X-Agent-ID", c.auth.AgentID())
 	headers.Set("X-API-Key-Prefix", c.auth.GetAPIKeyPrefix())
 
-	c.log.Debug("Connecting to server", "url", c.cfg.URL)
+	serverURL := c.URL()
+	c.log.Debug("Connecting to server", "url", serverURL)
 
-	conn, resp, err := dialer.DialContext(ctx, c.cfg.URL, headers)
+	conn, resp, err := dialer.DialContext(ctx, serverURL, headers)
 	if err != nil {
 		if resp != nil {
 			c.log.Error("Connection failed",
@@ -90,27 +150,90 @@ func (c *Client) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 
+	// The server may decline permessage-deflate; gorilla then falls back to
+	// uncompressed frames on its own, so we only record what was negotiated
+	compressed := c.cfg.Compression && compressionNegotiated(resp)
+	conn.EnableWriteCompression(compressed)
+	c.log.Debug("Compression negotiation",
+		"requested", c.cfg.Compression,
+		"negotiated", compressed,
+	)
+
+	// Bound what a single message can make us buffer. gorilla counts
+	// compressed wire bytes here; readMessage checks the inflated size.
+	conn.SetReadLimit(c.readLimit())
+
 	c.mu.Lock()
+	c.writeMu.Lock()
 	c.conn = conn
+	c.writeMu.Unlock()
 	c.connected = true
-	c.reconnecting = false
-	c.reconnectCount = 0
 	c.mu.Unlock()
 
-	c.log.Info("Connected to server")
+	c.log.Info("Connected to server", "url", serverURL)
+	c.setState(StateConnected)
 
 	// Authenticate
-	if err := c.authenticate(); err != nil {
+	if err := c.authenticate(conn); err != nil {
 		c.Close()
+		c.setState(StateDisconnected)
 		return fmt.Errorf("authentication failed: %w", err)
 	}
 
+	// Only an authenticated connection counts; a rejected one keeps backing off
+	c.mu.Lock()
+	c.reconnecting = false
+	c.reconnectCount = 0
+	c.connects++
+	c.lastConnected = time.Now()
+	c.mu.Unlock()
+
+	c.setState(StateAuthenticated)
+
 	return nil
 }
 
+// compressionNegotiated reports whether the handshake response accepted permessage-deflate
+func compressionNegotiated(resp *http.Response) bool {
+	if resp == nil {
+		return false
+	}
+	for _, ext := range resp.Header.Values("Sec-WebSocket-Extensions") {
+		if strings.Contains(ext, "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
+
 // authenticate sends authentication message and waits for response
-func (c *Client) authenticate() error {
-	timestamp := time.Now().UnixMilli()
+func (c *Client) authenticate(conn *websocket.Conn) error {
+	data, err := c.newAuthMessage()
+	if err != nil {
+		return err
+	}
+
+	c.log.Debug("Sending authentication message")
+
+	sentAt := time.Now()
+	if err := c.write(conn, data); err != nil {
+		return fmt.Errorf("failed to send auth message: %w", err)
+	}
+
+	// Wait for auth response
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	msg, err := c.readMessage(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read auth response: %w", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	return c.handleAuthResponse(msg, sentAt)
+}
+
+// newAuthMessage builds a signed auth message
+func (c *Client) newAuthMessage() ([]byte, error) {
+	timestamp := c.Now().UnixMilli()
 	nonce := auth.GenerateNonce()
 	// Sign with nonce for replay protection
 	signature := c.auth.SignMessageWithNonce(timestamp, nonce)
@@ -120,35 +243,33 @@ func (c *Client) authenticate() error {
 		AgentID:      c.auth.AgentID(),
 		APIKeyPrefix: c.auth.GetAPIKeyPrefix(),
 		Nonce:        nonce,
+		Algorithm:    c.auth.Algorithm(),
+
+		ProtocolVersion: protocol.ProtocolVersion,
 	}
 	authMsg.Timestamp = timestamp
 	authMsg.Signature = signature
 
 	data, err := json.Marshal(authMsg)
 	if err != nil {
-		return fmt.Errorf("failed to marshal auth message: %w", err)
+		return nil, fmt.Errorf("failed to marshal auth message: %w", err)
 	}
+	return data, nil
+}
 
-	c.log.Debug("Sending authentication message")
-
-	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		return fmt.Errorf("failed to send auth message: %w", err)
-	}
-
-	// Wait for auth response
-	c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
-	_, msg, err := c.conn.ReadMessage()
-	if err != nil {
-		return fmt.Errorf("failed to read auth response: %w", err)
-	}
-	c.conn.SetReadDeadline(time.Time{})
-
+// handleAuthResponse stores the session from an auth_ok response. sentAt
+// is when the auth message it answers was sent, for measuring clock skew.
+func (c *Client) handleAuthResponse(msg []byte, sentAt time.Time) error {
 	var response protocol.AuthResponse
 	if err := json.Unmarshal(msg, &response); err != nil {
 		return fmt.Errorf("failed to parse auth response: %w", err)
 	}
+	c.recordSkew(sentAt, time.Now(), response.Timestamp)
 
 	if response.Type == protocol.TypeAuthFail {
+		if isClockError(response.Error) {
+			return c.clockRejected(response.Error, response.Timestamp)
+		}
 		return fmt.Errorf("authentication rejected: %s", response.Error)
 	}
 
@@ -157,18 +278,80 @@ func (c *Client) authenticate() error {
 	}
 
 	// Store session token
-	c.session = &auth.SessionToken{
+	session := &auth.SessionToken{
 		Token:     response.SessionToken,
 		ExpiresAt: time.UnixMilli(response.Expires),
 	}
+	version := response.ProtocolVersion
+	if version <= 0 {
+		version = 1
+	}
+	c.mu.Lock()
+	c.session = session
+	c.renewing = time.Time{}
+	c.protocolVersion = min(version, protocol.ProtocolVersion)
+	c.mu.Unlock()
 
 	c.log.Info("Authentication successful",
-		"expires_in", time.Until(c.session.ExpiresAt).Round(time.Second),
+		"expires_in", time.Until(session.ExpiresAt).Round(time.Second),
 	)
+	c.warnSkew()
 
 	return nil
 }
 
+// sessionLoop renews the session over the open connection shortly before
+// it expires, so commands keep working without a reconnect. The response
+// is handled by readLoop.
+func (c *Client) sessionLoop(ctx context.Context) error {
+	ticker := time.NewTicker(sessionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		c.mu.RLock()
+		session := c.session
+		renewing := c.renewing
+		c.mu.RUnlock()
+
+		// Servers that do not send an expiry never need renewal
+		if session == nil || session.ExpiresAt.UnixMilli() <= 0 {
+			continue
+		}
+		if session.IsExpired() {
+			return fmt.Errorf("session expired before it could be renewed")
+		}
+		if !session.IsExpiringSoon(sessionRenewWindow) {
+			continue
+		}
+		// Give an outstanding renewal time to be answered
+		if !renewing.IsZero() && time.Since(renewing) < sessionRenewTimeout {
+			continue
+		}
+
+		data, err := c.newAuthMessage()
+		if err != nil {
+			return err
+		}
+
+		c.log.Info("Renewing session", "expires_in", time.Until(session.ExpiresAt).Round(time.Second))
+
+		select {
+		case c.sendCh <- data:
+			c.mu.Lock()
+			c.renewing = time.Now()
+			c.mu.Unlock()
+		default:
+			c.log.Warn("Send channel full, session renewal postponed")
+		}
+	}
+}
+
 // Run starts the read/write loops and handles reconnection
 func (c *Client) Run(ctx context.Context) error {
 	for {
@@ -182,8 +365,13 @@ func (c *Client) Run(ctx context.Context) error {
 		// Connect if not connected
 		c.mu.RLock()
 		connected := c.connected
+		closed := c.closed
 		c.mu.RUnlock()
 
+		if closed {
+			return nil
+		}
+
 		if !connected {
 			if err := c.Connect(ctx); err != nil {
 				c.handleReconnect(ctx)
@@ -191,43 +379,123 @@ func (c *Client) Run(ctx context.Context) error {
 			}
 		}
 
-		// Start read/write loops
-		errCh := make(chan error, 2)
+		// Close may drop the connection at any time, so the loops work on
+		// the one that was current when they started
+		conn := c.currentConn()
+		if conn == nil {
+			continue
+		}
+
+		// Start read/write loops, scoped to this connection so neither
+		// outlives it
+		connCtx, cancel := context.WithCancel(ctx)
+		errCh := make(chan error, 3)
 
 		go func() {
-			errCh <- c.readLoop(ctx)
+			errCh <- c.readLoop(connCtx, conn)
 		}()
 
 		go func() {
-			errCh <- c.writeLoop(ctx)
+			errCh <- c.writeLoop(connCtx, conn)
+		}()
+
+		go func() {
+			errCh <- c.sessionLoop(connCtx)
 		}()
 
 		// Wait for error
 		err := <-errCh
+		cancel()
 		c.log.Warn("Connection loop ended", "error", err)
 
 		// Mark as disconnected
 		c.mu.Lock()
 		c.connected = false
 		c.mu.Unlock()
+		c.latency.reset()
+		c.setState(StateDisconnected)
+
+		// Results that were not confirmed go out again once re-authenticated
+		if c.outbox != nil {
+			if dropped := c.outbox.requeue(); dropped > 0 {
+				c.log.Warn("Dropped command results the server never confirmed",
+					"dropped", dropped,
+					"hint", "results are confirmed by heartbeat acks, which stop while paused or when the server does not echo the nonce",
+				)
+			}
+			if pending := c.outbox.len(); pending > 0 {
+				c.log.Info("Command results held for redelivery", "pending", pending)
+			}
+		}
 
 		// Close connection
-		if c.conn != nil {
-			c.conn.Close()
-		}
+		conn.Close()
 
-		// Check if context is cancelled
+		// Check if context is cancelled or the client shut down
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			c.handleReconnect(ctx)
 		}
+		if c.isClosed() {
+			return nil
+		}
+		c.handleReconnect(ctx)
 	}
 }
 
-// readLoop reads messages from the WebSocket
-func (c *Client) readLoop(ctx context.Context) error {
+// readTimeout is how long the connection may stay silent before it is
+// considered dead. Heartbeat acks and pongs arrive at least once per
+// PingInterval on a healthy connection.
+func (c *Client) readTimeout() time.Duration {
+	return 2 * c.cfg.PingInterval
+}
+
+// readLimit is the largest message accepted from the server, in bytes
+func (c *Client) readLimit() int64 {
+	size := c.cfg.MaxMessageSize
+	if size <= 0 {
+		size = config.Default().Server.MaxMessageSize
+	}
+	return int64(size) << 20
+}
+
+// readMessage reads one message, applying readLimit to the payload after
+// decompression. The connection's own read limit only counts wire bytes,
+// which a permessage-deflate frame can inflate far beyond the limit.
+// Overflow is reported as websocket.ErrReadLimit either way.
+func (c *Client) readMessage(conn *websocket.Conn) ([]byte, error) {
+	_, r, err := conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	limit := c.readLimit()
+	msg, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(msg)) > limit {
+		return nil, websocket.ErrReadLimit
+	}
+	return msg, nil
+}
+
+// extendReadDeadline pushes the read deadline of conn out by readTimeout
+func (c *Client) extendReadDeadline(conn *websocket.Conn) {
+	if timeout := c.readTimeout(); timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+}
+
+// readLoop reads messages from the WebSocket. A connection that stays
+// silent past the read deadline (half-open after a NAT timeout or cable
+// pull) fails the read, which triggers a reconnect.
+func (c *Client) readLoop(ctx context.Context, conn *websocket.Conn) error {
+	conn.SetPongHandler(func(string) error {
+		c.extendReadDeadline(conn)
+		return nil
+	})
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -235,8 +503,17 @@ func (c *Client) readLoop(ctx context.Context) error {
 		default:
 		}
 
-		_, msg, err := c.conn.ReadMessage()
+		c.extendReadDeadline(conn)
+		msg, err := c.readMessage(conn)
 		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return fmt.Errorf("no data from server for %s, connection presumed dead", c.readTimeout())
+			}
+			if errors.Is(err, websocket.ErrReadLimit) {
+				c.log.Warn("Rejected a message over the size limit, reconnecting", "limit_bytes", c.readLimit())
+				return fmt.Errorf("message exceeded the %d byte limit: %w", c.readLimit(), err)
+			}
 			return fmt.Errorf("read error: %w", err)
 		}
 
@@ -249,7 +526,19 @@ func (c *Client) readLoop(ctx context.Context) error {
 
 		// Handle heartbeat ack internally
 		if base.Type == protocol.TypeHeartbeatAck {
-			c.log.Debug("Received heartbeat ack")
+			c.handleHeartbeatAck(msg)
+			continue
+		}
+
+		// Session renewals are answered on the open connection; a
+		// rejected renewal forces a clean reconnect
+		if base.Type == protocol.TypeAuthOK || base.Type == protocol.TypeAuthFail {
+			c.mu.RLock()
+			sentAt := c.renewing
+			c.mu.RUnlock()
+			if err := c.handleAuthResponse(msg, sentAt); err != nil {
+				return fmt.Errorf("session renewal failed: %w", err)
+			}
 			continue
 		}
 
@@ -260,38 +549,96 @@ func (c *Client) readLoop(ctx context.Context) error {
 	}
 }
 
-// writeLoop writes messages from the send channel
-func (c *Client) writeLoop(ctx context.Context) error {
+// writeLoop writes messages from the send channel and the result outbox,
+// and pings the server so intermediaries do not idle the connection out
+func (c *Client) writeLoop(ctx context.Context, conn *websocket.Conn) error {
+	var pingCh <-chan time.Time
+	if c.cfg.PingInterval > 0 {
+		ticker := time.NewTicker(c.cfg.PingInterval)
+		defer ticker.Stop()
+		pingCh = ticker.C
+	}
+
+	var resultCh <-chan struct{}
+	if c.outbox != nil {
+		resultCh = c.outbox.ready
+		// Deliver results held over from a previous connection first
+		if err := c.writeResults(conn); err != nil {
+			return err
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-resultCh:
+			if err := c.writeResults(conn); err != nil {
+				return err
+			}
+		case <-pingCh:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return fmt.Errorf("ping error: %w", err)
+			}
 		case msg := <-c.sendCh:
-			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			if err := c.write(conn, msg); err != nil {
 				return fmt.Errorf("write error: %w", err)
 			}
 		}
 	}
 }
 
-// handleReconnect implements exponential backoff reconnection
+// writeResults writes the outbox results not yet sent on conn
+func (c *Client) writeResults(conn *websocket.Conn) error {
+	for _, e := range c.outbox.unwritten() {
+		data, err := json.Marshal(c.encodeMessage(e.msg))
+		if err != nil {
+			// Retrying cannot help
+			c.log.Error("Dropping command result that cannot be marshaled", "id", e.id, "error", err)
+			c.outbox.remove(e)
+			continue
+		}
+		if err := c.write(conn, data); err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+		c.outbox.markWritten(e)
+	}
+	return nil
+}
+
+// write writes a text message on conn, failing once it is no longer the
+// current connection so a loop left over from it cannot write on the next
+func (c *Client) write(conn *websocket.Conn, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.conn != conn {
+		return errConnClosed
+	}
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// handleReconnect waits out the reconnect backoff
 func (c *Client) handleReconnect(ctx context.Context) {
 	c.mu.Lock()
 	c.reconnecting = true
 	c.reconnectCount++
 	count := c.reconnectCount
-	c.mu.Unlock()
-
-	// Calculate backoff duration
-	backoff := c.cfg.ReconnectInterval * time.Duration(1<<uint(count-1))
-	if backoff > c.cfg.MaxReconnectInterval {
-		backoff = c.cfg.MaxReconnectInterval
+	if count == 1 {
+		c.lastBackoff = 0
 	}
+	backoff := c.backoff(count, c.lastBackoff)
+	c.lastBackoff = backoff
+	c.failover(count)
+	c.mu.Unlock()
 
 	c.log.Info("Reconnecting",
 		"attempt", count,
-		"backoff", backoff,
+		"backoff", backoff.Round(time.Millisecond),
+		"jitter", c.cfg.ReconnectJitter,
 	)
+	c.setState(StateReconnecting)
 
 	select {
 	case <-ctx.Done():
@@ -316,13 +663,70 @@ func (c *Client) Send(msg interface{}) error {
 	}
 }
 
+// SendWait queues a message for sending, blocking until there is room in the
+// send channel instead of failing when it is full
+func (c *Client) SendWait(ctx context.Context, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	select {
+	case c.sendCh <- data:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // SendHeartbeat sends a heartbeat message
 func (c *Client) SendHeartbeat(metrics protocol.HeartbeatMetrics) error {
+	nonce := auth.GenerateNonce()
 	msg := protocol.HeartbeatMessage{
 		Message: protocol.NewMessage(protocol.TypeHeartbeat, auth.GenerateNonce()),
+		Nonce:   nonce,
 		Metrics: metrics,
 	}
-	return c.Send(msg)
+	// Recorded first, since the ack can arrive before Send returns
+	c.latency.sent(nonce)
+	if err := c.Send(msg); err != nil {
+		c.latency.forget(nonce)
+		return err
+	}
+	return nil
+}
+
+// handleHeartbeatAck matches an ack to its heartbeat and records the RTT
+func (c *Client) handleHeartbeatAck(data []byte) {
+	var ack protocol.HeartbeatAck
+	if err := json.Unmarshal(data, &ack); err != nil || ack.Nonce == "" {
+		c.log.Debug("Received heartbeat ack")
+		if c.outbox != nil && c.ackWarned.CompareAndSwap(false, true) {
+			c.log.Warn("Server heartbeat acks carry no nonce, so command results cannot be confirmed",
+				"redeliveries", outboxMaxDeliveries,
+				"max_age", outboxMaxAge,
+			)
+		}
+		return
+	}
+
+	rtt, ok := c.latency.acked(ack.Nonce)
+	if !ok {
+		c.log.Debug("Received ack for unknown heartbeat", "nonce", ack.Nonce)
+		return
+	}
+	c.log.Debug("Received heartbeat ack", "rtt", rtt)
+
+	// Everything written before this heartbeat was queued has been read
+	if c.outbox != nil {
+		c.outbox.confirm(time.Now().Add(-rtt))
+	}
+}
+
+// Latency returns the last heartbeat round-trip time and the rolling
+// average. Both are zero until an ack has been matched.
+func (c *Client) Latency() (last, avg time.Duration) {
+	return c.latency.stats()
 }
 
 // SendCommandResult sends a command result
@@ -344,20 +748,67 @@ func (c *Client) SendCommandResult(commandID string, success bool, data interfac
 		Error:     errMsg,
 		Duration:  duration.Milliseconds(),
 	}
-	return c.Send(msg)
+	return c.sendResult(msg.ID, msg)
+}
+
+// SendBatchResult sends the results of a batch command
+func (c *Client) SendBatchResult(batchID string, results []protocol.BatchCommandResult, duration time.Duration) error {
+	msg := protocol.BatchResult{
+		Message:  protocol.NewMessage(protocol.TypeBatchResult, auth.GenerateNonce()),
+		BatchID:  batchID,
+		Results:  results,
+		Duration: duration.Milliseconds(),
+	}
+	return c.sendResult(msg.ID, msg)
+}
+
+// sendResult queues a command or batch result. With the outbox enabled the
+// result is kept until the server has confirmed it, across reconnects.
+func (c *Client) sendResult(id string, msg interface{}) error {
+	if c.outbox == nil {
+		return c.Send(c.encodeMessage(msg))
+	}
+	c.outbox.add(id, msg)
+	return nil
 }
 
 // SendStream sends streaming data
 func (c *Client) SendStream(channel string, data interface{}) error {
+	msg, err := newStreamMessage(channel, data)
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
+
+// SendStreamWait sends streaming data, waiting for room in the send channel
+// so that ordered streams such as terminal output are not silently dropped
+func (c *Client) SendStreamWait(ctx context.Context, channel string, data interface{}) error {
+	msg, err := newStreamMessage(channel, data)
+	if err != nil {
+		return err
+	}
+	return c.SendWait(ctx, msg)
+}
+
+func newStreamMessage(channel string, data interface{}) (*protocol.StreamMessage, error) {
 	dataBytes, err := json.Marshal(data)
 	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
 	}
 
-	msg := protocol.StreamMessage{
+	return &protocol.StreamMessage{
 		Message: protocol.NewMessage(protocol.TypeStream, auth.GenerateNonce()),
 		Channel: channel,
 		Data:    dataBytes,
+	}, nil
+}
+
+// SendSystemInfo sends host facts to the server
+func (c *Client) SendSystemInfo(info protocol.SystemInfo) error {
+	msg := protocol.SystemInfoMessage{
+		Message: protocol.NewMessage(protocol.TypeSystemInfo, auth.GenerateNonce()),
+		Info:    info,
 	}
 	return c.Send(msg)
 }
@@ -379,13 +830,77 @@ func (c *Client) IsConnected() bool {
 	return c.connected
 }
 
+// Shutdown tells the server the agent is going away, then closes the
+// connection for good: Run returns instead of reconnecting. The disconnect
+// message is best-effort and bounded by a short write deadline. Calls after
+// the first do nothing.
+func (c *Client) Shutdown(reason string) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	authenticated := c.connected && c.session != nil
+	c.mu.Unlock()
+
+	if authenticated {
+		if err := c.sendGoodbye(reason); err != nil {
+			c.log.Debug("Failed to send disconnect message", "error", err)
+		} else {
+			c.log.Info("Sent disconnect message", "reason", reason)
+		}
+	}
+
+	return c.Close()
+}
+
+// sendGoodbye writes a disconnect message directly, bypassing the send
+// channel, since the write loop may already have stopped
+func (c *Client) sendGoodbye(reason string) error {
+	msg := protocol.DisconnectMessage{
+		Message: protocol.NewMessage(protocol.TypeDisconnect, auth.GenerateNonce()),
+		Reason:  reason,
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.conn == nil {
+		return errConnClosed
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(goodbyeTimeout))
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// currentConn returns the open connection, or nil once Close dropped it
+func (c *Client) currentConn() *websocket.Conn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn
+}
+
+// isClosed reports whether Shutdown was called
+func (c *Client) isClosed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.closed
+}
+
 // Close closes the WebSocket connection
 func (c *Client) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
 
 	if c.conn != nil {
 		// Send close message
+		c.conn.SetWriteDeadline(time.Now().Add(goodbyeTimeout))
 		c.conn.WriteMessage(
 			websocket.CloseMessage,
 			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
@@ -398,7 +913,28 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// ReconnectCount returns how many times the connection has been
+// re-established since the first successful connection
+func (c *Client) ReconnectCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.connects == 0 {
+		return 0
+	}
+	return c.connects - 1
+}
+
+// LastConnected returns when the last authenticated connection was made,
+// or the zero time if the agent has never connected
+func (c *Client) LastConnected() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastConnected
+}
+
 // Session returns the current session token
 func (c *Client) Session() *auth.SessionToken {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.session
 }
@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/serverkit/agent/internal/auth"
+	"github.com/serverkit/agent/internal/config"
+	"github.com/serverkit/agent/internal/logger"
+	"github.com/serverkit/agent/pkg/protocol"
+)
+
+// newTestServer accepts agents, answers their auth message with auth_ok
+// and hands the connection to serve
+func newTestServer(t *testing.T, accepted *atomic.Int32, serve func(conn *websocket.Conn)) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		resp := protocol.AuthResponse{
+			Message:      protocol.NewMessage(protocol.TypeAuthOK, auth.GenerateNonce()),
+			SessionToken: "session",
+			Expires:      time.Now().Add(time.Hour).UnixMilli(),
+		}
+		data, _ := json.Marshal(resp)
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return
+		}
+		accepted.Add(1)
+		serve(conn)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestClient returns a client of srv that reconnects at once
+func newTestClient(t *testing.T, srv *httptest.Server) *Client {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Server.URL = "ws" + strings.TrimPrefix(srv.URL, "http")
+	cfg.Server.ReconnectInterval = time.Millisecond
+	cfg.Server.MaxReconnectInterval = 5 * time.Millisecond
+	cfg.Server.ReconnectJitter = config.JitterNone
+	cfg.Server.PingInterval = time.Millisecond
+	cfg.Logging.File = filepath.Join(t.TempDir(), "agent.log")
+
+	return NewClient(cfg.Server, auth.New("test-agent", "sk_test_key", "secret"), logger.New(cfg.Logging))
+}
+
+// dropSoon drops a connection after a moment so the client reconnects
+func dropSoon(conn *websocket.Conn) {
+	conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// ackHeartbeats answers every heartbeat as soon as it arrives
+func ackHeartbeats(conn *websocket.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var hb protocol.HeartbeatMessage
+		if json.Unmarshal(data, &hb) != nil || hb.Type != protocol.TypeHeartbeat {
+			continue
+		}
+		ack, _ := json.Marshal(protocol.HeartbeatAck{
+			Message: protocol.NewMessage(protocol.TypeHeartbeatAck, auth.GenerateNonce()),
+			Nonce:   hb.Nonce,
+		})
+		if err := conn.WriteMessage(websocket.TextMessage, ack); err != nil {
+			return
+		}
+	}
+}
+
+// Closing the client while its loops run, and reconnecting underneath
+// them, must not touch a connection that was swapped out (run with -race)
+func TestCloseDuringReconnect(t *testing.T) {
+	var accepted atomic.Int32
+	srv := newTestServer(t, &accepted, dropSoon)
+	c := newTestClient(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+
+	for accepted.Load() < 10 {
+		if ctx.Err() != nil {
+			t.Fatalf("only %d connections accepted", accepted.Load())
+		}
+		c.Send(map[string]string{"type": "noop"})
+		c.Close()
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Shutdown("test")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Shutdown")
+	}
+}
+
+// Heartbeats sent from several goroutines while acks come straight back
+// are all matched to their round trip (run with -race)
+func TestHeartbeatAcks(t *testing.T) {
+	var accepted atomic.Int32
+	srv := newTestServer(t, &accepted, ackHeartbeats)
+	c := newTestClient(t, srv)
+	// A reconnect would forget the heartbeats in flight
+	c.cfg.PingInterval = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go c.Run(ctx)
+	defer c.Shutdown("test")
+
+	for c.Session() == nil {
+		if ctx.Err() != nil {
+			t.Fatal("client never authenticated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := c.SendHeartbeat(protocol.HeartbeatMetrics{}); err != nil {
+					t.Errorf("SendHeartbeat: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for {
+		c.latency.mu.Lock()
+		pending, samples := len(c.latency.pending), len(c.latency.samples)
+		c.latency.mu.Unlock()
+		if pending == 0 && samples > 0 {
+			return
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("acks not matched: %d heartbeats pending, %d round trips recorded", pending, samples)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,59 @@
+package ws
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/serverkit/agent/pkg/protocol"
+)
+
+// ProtocolVersion returns the protocol version negotiated at the latest
+// authentication: 1 for servers that predate negotiation, 0 before the
+// first auth_ok
+func (c *Client) ProtocolVersion() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.protocolVersion
+}
+
+// encodeMessage returns msg with a command result's data encoded for the
+// current connection. The outbox keeps results plain and calls this as it
+// writes them, since a result may be replayed to a server, after a
+// failover, that negotiated an older version than the one it was made for.
+func (c *Client) encodeMessage(msg interface{}) interface{} {
+	result, ok := msg.(protocol.CommandResult)
+	if !ok || result.Encoding != "" {
+		return msg
+	}
+	// result is a copy, so the queued message stays plain
+	result.Data, result.Encoding = c.encodeResult(result.Data)
+	return result
+}
+
+// encodeResult gzips command result data above
+// server.compress_results_over_kb when the server can decode it, returning
+// the data to send and its encoding. Small results, older servers and data
+// that does not shrink are sent as plain JSON.
+func (c *Client) encodeResult(data json.RawMessage) (json.RawMessage, string) {
+	threshold := c.cfg.CompressResultsOver * 1024
+	if threshold <= 0 || len(data) <= threshold || c.ProtocolVersion() < protocol.ResultEncodingVersion {
+		return data, ""
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return data, ""
+	}
+	if err := zw.Close(); err != nil {
+		return data, ""
+	}
+
+	encoded, err := json.Marshal(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if err != nil || len(encoded) >= len(data) {
+		return data, ""
+	}
+	return encoded, protocol.EncodingGzip
+}
@@ -0,0 +1,35 @@
+package ws
+
+// urlFailoverAttempts is how many connection attempts in a row may fail on
+// one server URL before the next configured URL is tried
+const urlFailoverAttempts = 3
+
+// serverURLs lists server.url followed by the fallback URLs
+func (c *Client) serverURLs() []string {
+	return append([]string{c.cfg.URL}, c.cfg.FallbackURLs...)
+}
+
+// URL returns the server URL the client is using or trying
+func (c *Client) URL() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.serverURLs()[c.urlIndex]
+}
+
+// failover moves to the next server URL once the current one has failed
+// urlFailoverAttempts times in a row, wrapping back to server.url after the
+// last fallback. count is the number of consecutive failed attempts. The
+// caller holds c.mu.
+func (c *Client) failover(count int) {
+	urls := c.serverURLs()
+	if len(urls) < 2 || count%urlFailoverAttempts != 0 {
+		return
+	}
+	from := urls[c.urlIndex]
+	c.urlIndex = (c.urlIndex + 1) % len(urls)
+	c.log.Warn("Server unreachable, trying the next server URL",
+		"from", from,
+		"to", urls[c.urlIndex],
+		"attempts", urlFailoverAttempts,
+	)
+}
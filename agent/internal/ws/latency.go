@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// latencySamples is how many round trips the rolling average covers
+	latencySamples = 10
+	// maxPendingHeartbeats bounds the heartbeats awaiting an ack; older
+	// ones are assumed dropped
+	maxPendingHeartbeats = 16
+)
+
+// latencyTracker measures heartbeat round trips by matching each ack to
+// the nonce of the heartbeat it answers, so dropped or reordered acks do
+// not skew the numbers
+type latencyTracker struct {
+	mu      sync.Mutex
+	pending map[string]time.Time
+	samples []time.Duration
+	last    time.Duration
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		pending: make(map[string]time.Time),
+	}
+}
+
+// sent records when a heartbeat was queued. time.Now carries a monotonic
+// reading, so wall clock changes do not affect the result.
+func (t *latencyTracker) sent(nonce string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.pending) >= maxPendingHeartbeats {
+		var oldest string
+		for n, at := range t.pending {
+			if oldest == "" || at.Before(t.pending[oldest]) {
+				oldest = n
+			}
+		}
+		delete(t.pending, oldest)
+	}
+	t.pending[nonce] = time.Now()
+}
+
+// acked records the round trip for a heartbeat ack. It returns false for
+// nonces it is not waiting on.
+func (t *latencyTracker) acked(nonce string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sentAt, ok := t.pending[nonce]
+	if !ok {
+		return 0, false
+	}
+	delete(t.pending, nonce)
+
+	rtt := time.Since(sentAt)
+	t.last = rtt
+	t.samples = append(t.samples, rtt)
+	if len(t.samples) > latencySamples {
+		t.samples = t.samples[1:]
+	}
+	return rtt, true
+}
+
+// forget drops a heartbeat that was never sent
+func (t *latencyTracker) forget(nonce string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, nonce)
+}
+
+// reset forgets heartbeats sent on a connection that has gone away
+func (t *latencyTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = make(map[string]time.Time)
+}
+
+// stats returns the last round trip and the rolling average
+func (t *latencyTracker) stats() (last, avg time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.samples) == 0 {
+		return 0, 0
+	}
+	var sum time.Duration
+	for _, s := range t.samples {
+		sum += s
+	}
+	return t.last, sum / time.Duration(len(t.samples))
+}
@@ -0,0 +1,170 @@
+package ws
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// outboxMaxAge and outboxMaxDeliveries bound how long a result is
+	// redelivered. Confirmation needs heartbeat acks, which do not come
+	// while the agent is paused or from servers that do not echo the
+	// nonce; without a limit those results would be replayed on every
+	// reconnect.
+	outboxMaxAge        = 15 * time.Minute
+	outboxMaxDeliveries = 3
+)
+
+// outboxEntry is a result waiting to be confirmed by the server
+type outboxEntry struct {
+	id         string
+	msg        interface{} // Marshaled at write time; see Client.encodeMessage
+	queued     time.Time
+	written    time.Time // Zero until written on the current connection
+	deliveries int       // Connections the result has been written on
+}
+
+// outbox holds command and batch results until the server has confirmed
+// them, so a result whose connection drops is delivered again after the
+// next authentication. Entries are keyed by message ID and resent with the
+// same ID, which lets the server discard duplicates.
+//
+// The protocol has no per-result ack. A result counts as confirmed once a
+// heartbeat queued after it was written has been acked: the server reads a
+// connection in order, so it has processed the result by then.
+type outbox struct {
+	mu      sync.Mutex
+	entries []*outboxEntry
+	ids     map[string]bool
+	size    int
+	ready   chan struct{}
+}
+
+func newOutbox(size int) *outbox {
+	return &outbox{
+		ids:   make(map[string]bool),
+		size:  size,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// add queues a result. When the outbox is full the oldest result is
+// dropped. It returns false for an ID that is already queued.
+func (o *outbox) add(id string, msg interface{}) bool {
+	o.mu.Lock()
+	if o.ids[id] {
+		o.mu.Unlock()
+		return false
+	}
+	if len(o.entries) >= o.size {
+		delete(o.ids, o.entries[0].id)
+		o.entries = o.entries[1:]
+	}
+	o.entries = append(o.entries, &outboxEntry{id: id, msg: msg, queued: time.Now()})
+	o.ids[id] = true
+	o.mu.Unlock()
+
+	o.signal()
+	return true
+}
+
+// signal wakes the write loop without blocking
+func (o *outbox) signal() {
+	select {
+	case o.ready <- struct{}{}:
+	default:
+	}
+}
+
+// unwritten returns the results not yet written on this connection, oldest
+// first
+func (o *outbox) unwritten() []*outboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var pending []*outboxEntry
+	for _, e := range o.entries {
+		if e.written.IsZero() {
+			pending = append(pending, e)
+		}
+	}
+	return pending
+}
+
+// markWritten records that a result went out on the current connection
+func (o *outbox) markWritten(e *outboxEntry) {
+	o.mu.Lock()
+	e.written = time.Now()
+	e.deliveries++
+	o.mu.Unlock()
+}
+
+// remove drops a result that can never be written
+func (o *outbox) remove(e *outboxEntry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, entry := range o.entries {
+		if entry == e {
+			delete(o.ids, e.id)
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// confirm drops every result written before the given time
+func (o *outbox) confirm(before time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	kept := o.entries[:0]
+	for _, e := range o.entries {
+		if !e.written.IsZero() && e.written.Before(before) {
+			delete(o.ids, e.id)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	// Clear the tail so dropped entries can be collected
+	for i := len(kept); i < len(o.entries); i++ {
+		o.entries[i] = nil
+	}
+	o.entries = kept
+}
+
+// requeue marks every unconfirmed result for sending on the next
+// connection, except those past outboxMaxAge or outboxMaxDeliveries, which
+// are dropped. It returns how many were dropped.
+func (o *outbox) requeue() int {
+	o.mu.Lock()
+	now := time.Now()
+	kept := o.entries[:0]
+	for _, e := range o.entries {
+		if e.deliveries >= outboxMaxDeliveries || now.Sub(e.queued) > outboxMaxAge {
+			delete(o.ids, e.id)
+			continue
+		}
+		e.written = time.Time{}
+		kept = append(kept, e)
+	}
+	dropped := len(o.entries) - len(kept)
+	for i := len(kept); i < len(o.entries); i++ {
+		o.entries[i] = nil
+	}
+	o.entries = kept
+	pending := len(kept)
+	o.mu.Unlock()
+
+	if pending > 0 {
+		o.signal()
+	}
+	return dropped
+}
+
+// len returns the number of unconfirmed results
+func (o *outbox) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
@@ -0,0 +1,102 @@
+package ws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// clockSkewWarn is the clock difference from the server above which the
+// agent warns. Signed timestamps are usually accepted within a few minutes,
+// so this leaves room to fix the clock before authentication fails.
+const clockSkewWarn = 30 * time.Second
+
+// clockErrorHints are substrings of auth-fail reasons that point at the
+// local clock rather than the credentials
+var clockErrorHints = []string{"timestamp", "skew", "clock"}
+
+// recordSkew measures the offset of the server's clock from ours using the
+// timestamp of a response. The server stamped it somewhere between sending
+// and receiving, so the midpoint is compared.
+func (c *Client) recordSkew(sentAt, receivedAt time.Time, serverMillis int64) {
+	if serverMillis <= 0 || sentAt.IsZero() {
+		return
+	}
+	midpoint := sentAt.Add(receivedAt.Sub(sentAt) / 2)
+	skew := time.UnixMilli(serverMillis).Sub(midpoint.Round(0))
+
+	c.mu.Lock()
+	c.skew = skew
+	c.skewKnown = true
+	c.mu.Unlock()
+}
+
+// warnSkew logs when the measured skew is large enough to matter
+func (c *Client) warnSkew() {
+	skew, ok := c.ClockSkew()
+	if ok && (skew > clockSkewWarn || skew < -clockSkewWarn) {
+		c.log.Warn("System clock differs from the server; enable time synchronization",
+			"skew", describeSkew(skew),
+			"correcting", c.cfg.ClockSkewCorrection,
+		)
+	}
+}
+
+// clockRejected logs an auth failure caused by the local clock and returns
+// the error for it. Retrying cannot help until the clock is fixed, so it
+// is reported plainly rather than leaving only the server's reason.
+func (c *Client) clockRejected(reason string, serverMillis int64) error {
+	args := []any{"reason", reason, "local_time", time.Now().UTC().Format(time.RFC3339)}
+	if serverMillis > 0 {
+		args = append(args, "server_time", time.UnixMilli(serverMillis).UTC().Format(time.RFC3339))
+	}
+
+	skew, ok := c.ClockSkew()
+	if !ok {
+		c.log.Error("Authentication rejected because of the system clock; enable time synchronization", args...)
+		return fmt.Errorf("authentication rejected: %s", reason)
+	}
+	args = append(args, "skew", describeSkew(skew))
+	c.log.Error("Authentication rejected because of the system clock; enable time synchronization", args...)
+	return fmt.Errorf("authentication rejected: %s (local clock is %s)", reason, describeSkew(skew))
+}
+
+// ClockSkew returns how far the server's clock is ahead of the local one,
+// negative when it is behind, and whether it has been measured yet
+func (c *Client) ClockSkew() (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.skew, c.skewKnown
+}
+
+// Now returns the time used for signed timestamps: the local clock, moved
+// by the measured skew when server.clock_skew_correction is set
+func (c *Client) Now() time.Time {
+	now := time.Now()
+	if !c.cfg.ClockSkewCorrection {
+		return now
+	}
+	if skew, ok := c.ClockSkew(); ok {
+		return now.Add(skew)
+	}
+	return now
+}
+
+// isClockError reports whether an auth-fail reason blames the timestamp
+func isClockError(reason string) bool {
+	reason = strings.ToLower(reason)
+	for _, hint := range clockErrorHints {
+		if strings.Contains(reason, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeSkew phrases a skew from the local clock's point of view
+func describeSkew(skew time.Duration) string {
+	if skew < 0 {
+		return (-skew).Round(time.Millisecond).String() + " ahead"
+	}
+	return skew.Round(time.Millisecond).String() + " behind"
+}
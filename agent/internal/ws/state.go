@@ -0,0 +1,38 @@
+package ws
+
+// ConnState is a connection lifecycle transition reported to the state
+// handler
+type ConnState string
+
+const (
+	// StateConnected means the socket is open but not yet authenticated
+	StateConnected ConnState = "connected"
+	// StateAuthenticated means the server accepted the agent; messages flow
+	StateAuthenticated ConnState = "authenticated"
+	// StateDisconnected means the connection was lost or closed
+	StateDisconnected ConnState = "disconnected"
+	// StateReconnecting means the client is waiting out a reconnect backoff
+	StateReconnecting ConnState = "reconnecting"
+)
+
+// StateHandler is called on every connection state transition
+type StateHandler func(state ConnState)
+
+// SetStateHandler sets the connection state handler. It is called from the
+// connection goroutine, so it must not block.
+func (c *Client) SetStateHandler(handler StateHandler) {
+	c.mu.Lock()
+	c.stateHandler = handler
+	c.mu.Unlock()
+}
+
+// setState reports a transition to the state handler, if one is set
+func (c *Client) setState(state ConnState) {
+	c.mu.RLock()
+	handler := c.stateHandler
+	c.mu.RUnlock()
+
+	if handler != nil {
+		handler(state)
+	}
+}
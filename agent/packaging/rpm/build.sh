@@ -63,6 +63,7 @@ NoNewPrivileges=false
 ProtectSystem=strict
 ProtectHome=read-only
 ReadWritePaths=/etc/serverkit-agent /var/log/serverkit-agent
+RuntimeDirectory=serverkit-agent
 
 [Install]
 WantedBy=multi-user.target
@@ -21,6 +21,8 @@ const (
 	// Commands
 	TypeCommand       MessageType = "command"
 	TypeCommandResult MessageType = "command_result"
+	TypeBatchCommand  MessageType = "batch_command"
+	TypeBatchResult   MessageType = "batch_result"
 
 	// Streaming
 	TypeSubscribe   MessageType = "subscribe"
@@ -30,12 +32,34 @@ const (
 	// Errors
 	TypeError MessageType = "error"
 
+	// Shutdown
+	TypeDisconnect MessageType = "disconnect"
+
 	// System
 	TypeSystemInfo MessageType = "system_info"
 
 	// Credential Rotation
 	TypeCredentialUpdate    MessageType = "credential_update"
 	TypeCredentialUpdateAck MessageType = "credential_update_ack"
+	TypeRotateRequest       MessageType = "credential_rotate_request"
+)
+
+// ProtocolVersion is the protocol revision this agent speaks. It is sent
+// with auth and the server answers with the revision both sides support;
+// servers that do not answer are treated as version 1.
+//
+// Version 2 adds gzip-encoded command result data (EncodingGzip).
+const ProtocolVersion = 2
+
+// ResultEncodingVersion is the first protocol version whose servers decode
+// CommandResult.Encoding
+const ResultEncodingVersion = 2
+
+// Command result data encodings
+const (
+	// EncodingGzip means Data is a JSON string holding the base64 of the
+	// gzip-compressed JSON result
+	EncodingGzip = "gzip"
 )
 
 // Message is the base message structure
@@ -61,6 +85,12 @@ type AuthMessage struct {
 	AgentID      string `json:"agent_id"`
 	APIKeyPrefix string `json:"api_key_prefix"`
 	Nonce        string `json:"nonce,omitempty"` // Unique nonce for replay protection
+
+	// Algorithm is how Signature was made: hmac-sha256 with the API
+	// secret, or ed25519 with the key registered for this agent
+	Algorithm string `json:"algorithm,omitempty"`
+
+	ProtocolVersion int `json:"protocol_version,omitempty"`
 }
 
 // AuthResponse is sent by server after authentication
@@ -69,11 +99,15 @@ type AuthResponse struct {
 	SessionToken string `json:"session_token,omitempty"`
 	Expires      int64  `json:"expires,omitempty"`
 	Error        string `json:"error,omitempty"`
+
+	// Negotiated protocol version; 0 from servers that predate negotiation
+	ProtocolVersion int `json:"protocol_version,omitempty"`
 }
 
 // HeartbeatMessage is sent periodically by agent
 type HeartbeatMessage struct {
 	Message
+	Nonce   string           `json:"nonce"` // Echoed in the ack to measure round-trip time
 	Metrics HeartbeatMetrics `json:"metrics"`
 }
 
@@ -84,11 +118,17 @@ type HeartbeatMetrics struct {
 	DiskPercent      float64 `json:"disk_percent"`
 	ContainerCount   int     `json:"container_count"`
 	ContainerRunning int     `json:"container_running"`
+
+	// Containers restarting repeatedly, and those OOM-killed since the
+	// previous heartbeat, by name
+	CrashLooping []string `json:"crash_looping,omitempty"`
+	OOMKilled    []string `json:"oom_killed,omitempty"`
 }
 
 // HeartbeatAck is sent by server to acknowledge heartbeat
 type HeartbeatAck struct {
 	Message
+	Nonce string `json:"nonce,omitempty"` // Nonce of the acknowledged heartbeat
 }
 
 // CommandMessage is sent by server to execute a command
@@ -102,6 +142,41 @@ type CommandMessage struct {
 // CommandResult is sent by agent after executing a command
 type CommandResult struct {
 	Message
+	CommandID string          `json:"command_id"`
+	Success   bool            `json:"success"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Encoding  string          `json:"encoding,omitempty"` // How Data is encoded, e.g. EncodingGzip; empty for plain JSON
+	Error     string          `json:"error,omitempty"`
+	Duration  int64           `json:"duration"` // milliseconds
+}
+
+// BatchCommandMessage is sent by server to execute several commands in one
+// round trip
+type BatchCommandMessage struct {
+	Message
+	Commands []BatchCommand `json:"commands"`
+	Parallel bool           `json:"parallel,omitempty"` // Run commands concurrently instead of in order
+}
+
+// BatchCommand is a single command within a batch
+type BatchCommand struct {
+	ID      string          `json:"id"`
+	Action  string          `json:"action"`
+	Params  json.RawMessage `json:"params"`
+	Timeout int             `json:"timeout,omitempty"` // milliseconds
+}
+
+// BatchResult is sent by agent after executing a batch. Results are in the
+// order of the batch's commands.
+type BatchResult struct {
+	Message
+	BatchID  string               `json:"batch_id"`
+	Results  []BatchCommandResult `json:"results"`
+	Duration int64                `json:"duration"` // milliseconds
+}
+
+// BatchCommandResult is the outcome of one command in a batch
+type BatchCommandResult struct {
 	CommandID string          `json:"command_id"`
 	Success   bool            `json:"success"`
 	Data      json.RawMessage `json:"data,omitempty"`
@@ -112,7 +187,8 @@ type CommandResult struct {
 // SubscribeMessage requests subscription to a data stream
 type SubscribeMessage struct {
 	Message
-	Channel string `json:"channel"`
+	Channel string          `json:"channel"`
+	Params  json.RawMessage `json:"params,omitempty"` // Channel-specific options, e.g. event filters
 }
 
 // UnsubscribeMessage cancels a subscription
@@ -135,6 +211,20 @@ type ErrorMessage struct {
 	Details string `json:"details,omitempty"`
 }
 
+// DisconnectMessage is sent by agent just before a graceful shutdown, so the
+// server can mark it offline without waiting for heartbeats to time out
+type DisconnectMessage struct {
+	Message
+	Reason string `json:"reason"`
+}
+
+// Disconnect reasons
+const (
+	DisconnectReasonShutdown = "shutdown" // Stopped by a signal or the service manager
+	DisconnectReasonRestart  = "restart"  // Restart requested via IPC
+	DisconnectReasonUpdate   = "update"   // Replaced by an update or rollback
+)
+
 // SystemInfoMessage contains system information
 type SystemInfoMessage struct {
 	Message
@@ -152,6 +242,11 @@ type SystemInfo struct {
 	TotalDisk    uint64 `json:"total_disk"`
 	DockerVersion string `json:"docker_version,omitempty"`
 	AgentVersion string `json:"agent_version"`
+
+	Platform      string `json:"platform,omitempty"` // Distribution, e.g. "ubuntu"
+	KernelVersion string `json:"kernel_version,omitempty"`
+	CPUModel      string `json:"cpu_model,omitempty"`
+	CPUThreads    int    `json:"cpu_threads,omitempty"`
 }
 
 // Command actions
@@ -163,41 +258,75 @@ const (
 	ActionDockerContainerStart   = "docker:container:start"
 	ActionDockerContainerStop    = "docker:container:stop"
 	ActionDockerContainerRestart = "docker:container:restart"
+	ActionDockerContainerPause   = "docker:container:pause"
+	ActionDockerContainerUnpause = "docker:container:unpause"
+	ActionDockerContainerRename  = "docker:container:rename"
+	ActionDockerContainerUpdate  = "docker:container:update"
+	ActionDockerContainerPrune   = "docker:container:prune"
 	ActionDockerContainerRemove  = "docker:container:remove"
 	ActionDockerContainerLogs    = "docker:container:logs"
 	ActionDockerContainerStats   = "docker:container:stats"
+	ActionDockerContainerHealth  = "docker:container:health"
+	ActionDockerContainerTop     = "docker:container:top"
+	ActionDockerContainerCopy    = "docker:container:copy"
 	ActionDockerContainerExec    = "docker:container:exec"
 
+	// Pull the container's image and recreate it when a newer one is fetched
+	ActionDockerContainerUpdateImage = "docker:container:update-image"
+
 	// Docker image actions
-	ActionDockerImageList   = "docker:image:list"
-	ActionDockerImagePull   = "docker:image:pull"
-	ActionDockerImageRemove = "docker:image:remove"
-	ActionDockerImageBuild  = "docker:image:build"
+	ActionDockerImageList    = "docker:image:list"
+	ActionDockerImagePull    = "docker:image:pull"
+	ActionDockerImageRemove  = "docker:image:remove"
+	ActionDockerImageBuild   = "docker:image:build"
+	ActionDockerImagePrune   = "docker:image:prune"
+	ActionDockerImageTag     = "docker:image:tag"
+	ActionDockerImagePush    = "docker:image:push"
+	ActionDockerImageInspect = "docker:image:inspect"
 
 	// Docker volume actions
-	ActionDockerVolumeList   = "docker:volume:list"
-	ActionDockerVolumeCreate = "docker:volume:create"
-	ActionDockerVolumeRemove = "docker:volume:remove"
+	ActionDockerVolumeList    = "docker:volume:list"
+	ActionDockerVolumeCreate  = "docker:volume:create"
+	ActionDockerVolumeInspect = "docker:volume:inspect"
+	ActionDockerVolumeRemove  = "docker:volume:remove"
+	ActionDockerVolumePrune   = "docker:volume:prune"
 
 	// Docker network actions
-	ActionDockerNetworkList   = "docker:network:list"
-	ActionDockerNetworkCreate = "docker:network:create"
-	ActionDockerNetworkRemove = "docker:network:remove"
+	ActionDockerNetworkList       = "docker:network:list"
+	ActionDockerNetworkCreate     = "docker:network:create"
+	ActionDockerNetworkRemove     = "docker:network:remove"
+	ActionDockerNetworkConnect    = "docker:network:connect"
+	ActionDockerNetworkDisconnect = "docker:network:disconnect"
+	ActionDockerNetworkPrune      = "docker:network:prune"
+
+	// Docker system actions
+	ActionDockerSystemPrune = "docker:system:prune"
+	ActionDockerSystemDf    = "docker:system:df"
 
 	// Docker compose actions
-	ActionDockerComposeList    = "docker:compose:list"
-	ActionDockerComposePs      = "docker:compose:ps"
-	ActionDockerComposeUp      = "docker:compose:up"
-	ActionDockerComposeDown    = "docker:compose:down"
-	ActionDockerComposeLogs    = "docker:compose:logs"
-	ActionDockerComposeRestart = "docker:compose:restart"
-	ActionDockerComposePull    = "docker:compose:pull"
+	ActionDockerComposeList     = "docker:compose:list"
+	ActionDockerComposePs       = "docker:compose:ps"
+	ActionDockerComposeUp       = "docker:compose:up"
+	ActionDockerComposeDown     = "docker:compose:down"
+	ActionDockerComposeLogs     = "docker:compose:logs"
+	ActionDockerComposeRestart  = "docker:compose:restart"
+	ActionDockerComposePull     = "docker:compose:pull"
+	ActionDockerComposeBuild    = "docker:compose:build"
+	ActionDockerComposeConfig   = "docker:compose:config"
+	ActionDockerComposeValidate = "docker:compose:validate"
+	ActionDockerComposeScale    = "docker:compose:scale"
+	ActionDockerComposeStart    = "docker:compose:start"
+	ActionDockerComposeStop     = "docker:compose:stop"
+
+	// Docker event actions
+	ActionDockerEventsHistory = "docker:events:history"
 
 	// System actions
-	ActionSystemMetrics   = "system:metrics"
-	ActionSystemInfo      = "system:info"
-	ActionSystemProcesses = "system:processes"
-	ActionSystemExec      = "system:exec"
+	ActionSystemMetrics      = "system:metrics"
+	ActionSystemInfo         = "system:info"
+	ActionSystemProcesses    = "system:processes"
+	ActionSystemProcessWatch = "system:process:watch"
+	ActionSystemExec         = "system:exec"
 
 	// File actions
 	ActionFileRead  = "file:read"
@@ -214,9 +343,14 @@ const (
 // Stream channels
 const (
 	ChannelMetrics        = "metrics"
+	ChannelBackfill       = "metrics:backfill" // Samples buffered while disconnected, pushed by the agent after a reconnect
+	ChannelDockerEvents   = "docker:events"
 	ChannelContainerLogs  = "container:%s:logs"
 	ChannelContainerStats = "container:%s:stats"
 	ChannelTerminal       = "terminal:%s"
+	ChannelImagePush      = "image:%s:push"
+	ChannelComposeLogs    = "docker:compose:%s:logs"
+	ChannelConnection     = "connection" // Pushed by the agent after each reconnect
 )
 
 // CredentialUpdateMessage is sent by server to rotate credentials
@@ -227,6 +361,14 @@ type CredentialUpdateMessage struct {
 	APISecret  string `json:"api_secret"`
 }
 
+// RotateRequest is sent by the agent to ask for new credentials once its
+// current ones are older than the configured rotation age. The server
+// answers with a CredentialUpdateMessage.
+type RotateRequest struct {
+	Message
+	IssuedAt int64 `json:"issued_at"` // Unix milliseconds, when the current credentials were issued
+}
+
 // CredentialUpdateAck is sent by agent after updating credentials
 type CredentialUpdateAck struct {
 	Message
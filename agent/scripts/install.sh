@@ -243,6 +243,7 @@ NoNewPrivileges=yes
 ProtectSystem=strict
 ProtectHome=yes
 ReadWritePaths=$LOG_DIR $CONFIG_DIR
+RuntimeDirectory=serverkit-agent
 PrivateTmp=yes
 
 [Install]